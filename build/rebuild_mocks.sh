@@ -26,7 +26,7 @@ for i in $items; do
         >internal/pkg/mock_filesystem/${i}.go
 done
 
-items="brick_allocation brick_host session session_actions"
+items="brick_allocation brick_host cluster_config leader session session_actions"
 for i in $items; do
     mockgen -source=internal/pkg/registry/${i}.go \
         >internal/pkg/mock_registry/${i}.go
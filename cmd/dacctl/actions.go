@@ -41,6 +41,12 @@ func createPersistent(c *cli.Context) error {
 	return err
 }
 
+func expandPersistent(c *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return getActions(keystore).ExpandPersistentBuffer(c)
+}
+
 func printOutput(function func() (string, error)) error {
 	sessions, err := function()
 	if err != nil {
@@ -68,6 +74,36 @@ func listPools(_ *cli.Context) error {
 	return printOutput(getActions(keystore).ListPools)
 }
 
+func poolReport(_ *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return printOutput(getActions(keystore).PoolReport)
+}
+
+func getClusterConfig(_ *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return printOutput(getActions(keystore).GetClusterConfig)
+}
+
+func setClusterConfig(c *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return getActions(keystore).SetClusterConfig(c)
+}
+
+func showUnhealthyBricks(_ *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return printOutput(getActions(keystore).ShowUnhealthyBricks)
+}
+
+func clearUnhealthyBrick(c *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return getActions(keystore).ClearUnhealthyBrick(c)
+}
+
 func showConfigurations(_ *cli.Context) error {
 	keystore := getKeystore()
 	defer keystore.Close()
@@ -80,12 +116,26 @@ func teardown(c *cli.Context) error {
 	return getActions(keystore).DeleteBuffer(c)
 }
 
+func cancelActions(c *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return getActions(keystore).CancelActions(c)
+}
+
 func jobProcess(c *cli.Context) error {
 	keystore := getKeystore()
 	defer keystore.Close()
 	return getActions(keystore).ValidateJob(c)
 }
 
+func checkCapacity(c *cli.Context) error {
+	keystore := getKeystore()
+	defer keystore.Close()
+	return printOutput(func() (s string, e error) {
+		return getActions(keystore).CheckCapacity(c)
+	})
+}
+
 func setup(c *cli.Context) error {
 	keystore := getKeystore()
 	defer keystore.Close()
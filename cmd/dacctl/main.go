@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"github.com/RSE-Cambridge/data-acc/pkg/version"
 	"github.com/urfave/cli"
 	"log"
@@ -17,6 +18,25 @@ func stripFunctionArg(systemArgs []string) []string {
 	return systemArgs
 }
 
+// Describes who called dacctl in every log line, and in the actions sent to dacd,
+// e.g. "pre_run:1234" for Slurm's pre_run call for job 1234
+func getCaller(systemArgs []string) string {
+	args := stripFunctionArg(append([]string{}, systemArgs...))
+	if len(args) < 2 {
+		return "dacctl"
+	}
+	caller := args[1]
+	for i, arg := range args {
+		if strings.HasPrefix(arg, "--token=") {
+			return caller + ":" + strings.TrimPrefix(arg, "--token=")
+		}
+		if (arg == "--token" || arg == "-t") && i+1 < len(args) {
+			return caller + ":" + args[i+1]
+		}
+	}
+	return caller
+}
+
 var token = cli.StringFlag{
 	Name:  "token, t",
 	Usage: "Job ID or Persistent Buffer name",
@@ -37,6 +57,10 @@ var groupid = cli.IntFlag{
 	Name:  "groupid, group, g",
 	Usage: "Linux group id that owns the buffer, defaults to match the user.",
 }
+var priority = cli.StringFlag{
+	Name:  "priority, P",
+	Usage: "Priority class of the buffer, one of low, normal or high. High priority can preempt idle buffers.",
+}
 var capacity = cli.StringFlag{
 	Name:  "capacity, C",
 	Usage: "A request of the form <pool>:<int><units> where units could be GiB or TiB.",
@@ -69,21 +93,35 @@ func runCli(args []string) error {
 			Usage: "Destroy the given buffer.",
 			Flags: []cli.Flag{token, job,
 				cli.BoolFlag{
-					Name: "hurry",
+					Name:  "hurry",
+					Usage: "Skip copying data out, except writing back a type=cache buffer.",
 				},
 			},
 			Action: teardown,
 		},
 		{
-			Name:   "job_process",
-			Usage:  "Initial call to validate buffer script",
+			Name:   "cancel",
+			Usage:  "Stop any running or queued actions for the given buffer.",
+			Flags:  []cli.Flag{token},
+			Action: cancelActions,
+		},
+		{
+			Name: "job_process",
+			Usage: "Initial call to validate buffer script. A type=cache buffer copies in the whole pfs " +
+				"directory before the job starts, files are not read in on demand.",
 			Flags:  []cli.Flag{job},
 			Action: jobProcess,
 		},
+		{
+			Name:   "check_capacity",
+			Usage:  "Report if the requested capacity could be allocated, without allocating anything.",
+			Flags:  []cli.Flag{capacity},
+			Action: checkCapacity,
+		},
 		{
 			Name:  "setup",
 			Usage: "Create transient buffer, called after waiting for enough free capacity.",
-			Flags: []cli.Flag{token, job, caller, user, groupid, capacity,
+			Flags: []cli.Flag{token, job, caller, user, groupid, capacity, priority,
 				cli.StringFlag{
 					Name:  "nodehostnamefile",
 					Usage: "Path to file containing list of scheduled compute nodes.",
@@ -145,7 +183,7 @@ func runCli(args []string) error {
 		{
 			Name:  "create_persistent",
 			Usage: "Create a persistent buffer.",
-			Flags: []cli.Flag{token, caller, capacity, user, groupid,
+			Flags: []cli.Flag{token, caller, capacity, user, groupid, priority,
 				cli.StringFlag{
 					Name:  "access, a",
 					Usage: "Access mode, e.g. striped or private.",
@@ -157,11 +195,87 @@ func runCli(args []string) error {
 			},
 			Action: createPersistent,
 		},
+		{
+			Name:  "expand_persistent",
+			Usage: "Add capacity to an existing persistent buffer.",
+			Flags: []cli.Flag{token,
+				cli.StringFlag{
+					Name:  "capacity, C",
+					Usage: "Extra capacity of the form <pool>:+<int><units> where units could be GiB or TiB.",
+				},
+			},
+			Action: expandPersistent,
+		},
 		{
 			Name:   "show_configurations",
 			Usage:  "Returns fake data to keep burst buffer plugin happy.",
 			Action: showConfigurations,
 		},
+		{
+			Name:  "admin",
+			Usage: "Commands to help operate the data accelerator.",
+			Subcommands: []cli.Command{
+				{
+					Name:   "pool_report",
+					Usage:  "Report fragmentation and utilisation of each pool.",
+					Action: poolReport,
+				},
+				{
+					Name:   "unhealthy_bricks",
+					Usage:  "List bricks that are not given to new buffers, such as those that failed to wipe.",
+					Action: showUnhealthyBricks,
+				},
+				{
+					Name:   "clear_unhealthy_brick",
+					Usage:  "Allow an unhealthy brick to be given to new buffers again.",
+					Action: clearUnhealthyBrick,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "host",
+							Usage: "Brick host the brick is on",
+						},
+						cli.StringFlag{
+							Name:  "device",
+							Usage: "Brick device, e.g. nvme0n1",
+						},
+					},
+				},
+				{
+					Name:  "config",
+					Usage: "Settings shared by every brick host, used when creating new buffers.",
+					Subcommands: []cli.Command{
+						{
+							Name:   "get",
+							Usage:  "Show the cluster config.",
+							Action: getClusterConfig,
+						},
+						{
+							Name:   "set",
+							Usage:  "Change a cluster config setting, for all pools or just the given pool.",
+							Action: setClusterConfig,
+							Flags: []cli.Flag{
+								cli.StringFlag{
+									Name:  "key",
+									Usage: "Setting to change, e.g. max_mdt_count",
+								},
+								cli.StringFlag{
+									Name:  "value",
+									Usage: "New value for the setting",
+								},
+								cli.StringFlag{
+									Name:  "pool",
+									Usage: "Only change the setting for this pool",
+								},
+								cli.BoolFlag{
+									Name:  "unset",
+									Usage: "Remove the setting, so the brick host's local config is used",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 		{
 			Name:   "generate_ansible",
 			Usage:  "Creates debug ansible in debug ansible.",
@@ -173,6 +287,10 @@ func runCli(args []string) error {
 }
 
 func main() {
+	if err := config.CheckConfig(); err != nil {
+		log.Fatal(err)
+	}
+
 	logFilename := config.GetDacctlLog()
 	f, err := os.OpenFile(logFilename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 	if err != nil {
@@ -180,6 +298,17 @@ func main() {
 	}
 	defer f.Close()
 
+	logConfig := config.GetLogConfig(config.DefaultEnv)
+	hostname, _ := os.Hostname()
+	err = logging.Setup(f, logConfig.Level, logConfig.Format, logging.Fields{
+		logging.ComponentField: "dacctl",
+		logging.HostField:      hostname,
+		logging.CallerField:    getCaller(os.Args),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// be sure to log any panic
 	defer func() {
 		if r := recover(); r != nil {
@@ -188,7 +317,6 @@ func main() {
 		}
 	}()
 
-	log.SetOutput(f)
 	log.Println("dacctl start, called with:", strings.Join(os.Args, " "))
 
 	if err := runCli(os.Args); err != nil {
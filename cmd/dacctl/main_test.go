@@ -40,6 +40,18 @@ func TestStripFunctionArg(t *testing.T) {
 	}
 }
 
+func TestGetCaller(t *testing.T) {
+	args := []string{"dacctl", "--function", "pre_run", "--token", "1234", "--job", "script"}
+	assert.Equal(t, "pre_run:1234", getCaller(args))
+	assert.Equal(t, "dacctl", args[0])
+	assert.Equal(t, "--function", args[1])
+
+	assert.Equal(t, "setup:42", getCaller([]string{"dacctl", "setup", "-t", "42"}))
+	assert.Equal(t, "teardown:42", getCaller([]string{"dacctl", "teardown", "--token=42"}))
+	assert.Equal(t, "pools", getCaller([]string{"dacctl", "pools"}))
+	assert.Equal(t, "dacctl", getCaller([]string{"dacctl"}))
+}
+
 func TestCreatePersistentBuffer(t *testing.T) {
 	testActions = &stubDacctlActions{}
 	testKeystore = &stubKeystore{}
@@ -60,6 +72,18 @@ func TestCreatePersistentBuffer(t *testing.T) {
 	assert.Equal(t, "CreatePersistentBuffer p1", err.Error())
 }
 
+func TestExpandPersistentBuffer(t *testing.T) {
+	testActions = &stubDacctlActions{}
+	testKeystore = &stubKeystore{}
+	defer func() {
+		testActions = nil
+		testKeystore = nil
+	}()
+
+	err := runCli(strings.Split("--function expand_persistent --token p1 --capacity dw:+1TiB", " "))
+	assert.Equal(t, "ExpandPersistentBuffer p1", err.Error())
+}
+
 func TestDeleteBuffer(t *testing.T) {
 	testActions = &stubDacctlActions{}
 	testKeystore = &stubKeystore{}
@@ -101,6 +125,24 @@ func TestShow(t *testing.T) {
 	err := runCli([]string{"--function", "pools"})
 	assert.Equal(t, "ListPools", err.Error())
 
+	err = runCli([]string{"--function", "admin", "pool_report"})
+	assert.Equal(t, "PoolReport", err.Error())
+
+	err = runCli([]string{"--function", "admin", "config", "get"})
+	assert.Equal(t, "GetClusterConfig", err.Error())
+
+	err = runCli([]string{"--function", "admin", "config", "set", "--key", "max_mdt_count", "--value", "8"})
+	assert.Equal(t, "SetClusterConfig max_mdt_count 8", err.Error())
+
+	err = runCli([]string{"--function", "admin", "unhealthy_bricks"})
+	assert.Equal(t, "ShowUnhealthyBricks", err.Error())
+
+	err = runCli([]string{"--function", "admin", "clear_unhealthy_brick", "--host", "dac1", "--device", "nvme0n1"})
+	assert.Equal(t, "ClearUnhealthyBrick dac1 nvme0n1", err.Error())
+
+	err = runCli([]string{"--function", "cancel", "--token", "foo"})
+	assert.Equal(t, "CancelActions foo", err.Error())
+
 	err = runCli([]string{"--function", "show_instances"})
 	assert.Equal(t, "ShowInstances", err.Error())
 
@@ -125,6 +167,9 @@ func TestFlow(t *testing.T) {
 	err := runCli([]string{"--function", "job_process", "--job", "a"})
 	assert.Equal(t, "ValidateJob", err.Error())
 
+	err = runCli([]string{"--function", "check_capacity", "--capacity", "dw:1GiB"})
+	assert.Equal(t, "CheckCapacity", err.Error())
+
 	err = runCli([]string{"--function", "real_size", "--token", "a"})
 	assert.Equal(t, "RealSize", err.Error())
 
@@ -191,12 +236,20 @@ func (*stubKeystore) NewMutex(lockKey string) (store.Mutex, error) {
 	panic("implement me")
 }
 
+func (*stubKeystore) Campaign(ctxt context.Context, electionKey string, value string) (<-chan struct{}, error) {
+	panic("implement me")
+}
+
 type stubDacctlActions struct{}
 
 func (*stubDacctlActions) CreatePersistentBuffer(c dacctl.CliContext) error {
 	return fmt.Errorf("CreatePersistentBuffer %s", c.String("token"))
 }
 
+func (*stubDacctlActions) ExpandPersistentBuffer(c dacctl.CliContext) error {
+	return fmt.Errorf("ExpandPersistentBuffer %s", c.String("token"))
+}
+
 func (*stubDacctlActions) DeleteBuffer(c dacctl.CliContext) error {
 	return fmt.Errorf("DeleteBuffer %s", c.String("token"))
 }
@@ -216,6 +269,26 @@ func (*stubDacctlActions) ShowSessions() (string, error) {
 func (*stubDacctlActions) ListPools() (string, error) {
 	return "", errors.New("ListPools")
 }
+func (*stubDacctlActions) PoolReport() (string, error) {
+	return "", errors.New("PoolReport")
+}
+
+func (*stubDacctlActions) GetClusterConfig() (string, error) {
+	return "", errors.New("GetClusterConfig")
+}
+func (*stubDacctlActions) SetClusterConfig(c dacctl.CliContext) error {
+	return fmt.Errorf("SetClusterConfig %s %s", c.String("key"), c.String("value"))
+}
+func (*stubDacctlActions) ShowUnhealthyBricks() (string, error) {
+	return "", errors.New("ShowUnhealthyBricks")
+}
+func (*stubDacctlActions) ClearUnhealthyBrick(c dacctl.CliContext) error {
+	return fmt.Errorf("ClearUnhealthyBrick %s %s", c.String("host"), c.String("device"))
+}
+
+func (*stubDacctlActions) CancelActions(c dacctl.CliContext) error {
+	return fmt.Errorf("CancelActions %s", c.String("token"))
+}
 
 func (*stubDacctlActions) ShowConfigurations() (string, error) {
 	return "", errors.New("ShowConfigurations")
@@ -225,6 +298,10 @@ func (*stubDacctlActions) ValidateJob(c dacctl.CliContext) error {
 	return errors.New("ValidateJob")
 }
 
+func (*stubDacctlActions) CheckCapacity(c dacctl.CliContext) (string, error) {
+	return "", errors.New("CheckCapacity")
+}
+
 func (*stubDacctlActions) RealSize(c dacctl.CliContext) (string, error) {
 	return "", errors.New("RealSize")
 }
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"flag"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd/brick_manager_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd/status_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store_impl"
 	"log"
 	"os"
@@ -10,27 +15,61 @@ import (
 	"syscall"
 )
 
-func waitForShutdown(manager dacd.BrickManager) {
-	c := make(chan os.Signal)
-	signal.Notify(c, os.Interrupt, syscall.SIGINT)
-	<-c
-	log.Println("I have been asked to shutdown, doing tidy up...")
-	manager.Shutdown()
-	os.Exit(1)
+func waitForShutdown(manager dacd.BrickManager) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	sig := <-c
+	log.Printf("I have been asked to shutdown by %s, doing tidy up...\n", sig)
+	return manager.Shutdown()
 }
 
 func main() {
+	checkConfig := flag.Bool("check-config", false, "check the configuration is valid, then exit")
+	configReference := flag.Bool("config-reference", false, "print the configuration reference, then exit")
+	flag.Parse()
+
+	if *configReference {
+		fmt.Print(config.GetReference())
+		return
+	}
+	if err := config.CheckConfig(); err != nil {
+		if *checkConfig {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+	if *checkConfig {
+		fmt.Println("configuration OK")
+		return
+	}
+
+	logConfig := config.GetLogConfig(config.DefaultEnv)
+	err := logging.Setup(os.Stderr, logConfig.Level, logConfig.Format, logging.Fields{
+		logging.ComponentField: "dacd",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	log.Println("Starting data-accelerator's brick manager")
 
 	keystore := store_impl.NewKeystore()
-	defer func() {
-		log.Println("keystore closed with error: ", keystore.Close())
-	}()
 
 	manager := brick_manager_impl.NewBrickManager(keystore)
+	logging.AddFields(logging.Fields{logging.HostField: manager.Hostname()})
+	if address := config.GetDacdHttpListen(); address != "" {
+		// start before startup, so readyz can report we are not yet ready
+		status_impl.StartStatusServer(address, manager)
+	}
 	manager.Startup()
 
 	log.Println("Brick manager started for:", manager.Hostname())
 
-	waitForShutdown(manager)
+	err = waitForShutdown(manager)
+	log.Println("keystore closed with error: ", keystore.Close())
+	if err != nil {
+		log.Fatalf("unclean shutdown: %s", err)
+	}
+	log.Println("Brick manager stopped cleanly")
 }
@@ -3,6 +3,7 @@ package config
 import (
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"log"
+	"time"
 )
 
 type BrickManagerConfig struct {
@@ -12,22 +13,119 @@ type BrickManagerConfig struct {
 	DeviceCount          uint
 	DeviceAddressPattern string
 	HostEnabled          bool
+
+	// Maximum number of actions of each type that can run at once,
+	// any type not listed uses DefaultActionWorkers
+	DefaultActionWorkers uint
+	ActionWorkers        map[datamodel.SessionActionType]uint
+
+	// How long shutdown waits for in-flight actions to finish
+	ShutdownTimeout time.Duration
+
+	// How often local sessions are compared with what is mounted, zero disables this.
+	// When ReconcileReportOnly, problems are only logged
+	ReconcileInterval   time.Duration
+	ReconcileReportOnly bool
+
+	// When enabled, bricks are found by looking in sysfs,
+	// rather than using DeviceAddressPattern and DeviceCapacityGiB
+	Discovery BrickDiscoveryConfig
+
+	Housekeeping HousekeepingConfig
+}
+
+// The elected leader runs the cluster wide housekeeping tasks
+type HousekeepingConfig struct {
+	Enabled bool
+
+	StaleActionsInterval     time.Duration
+	DeadHostsInterval        time.Duration
+	ConsistencyCheckInterval time.Duration
+
+	// Idle persistent buffers are deleted after this long, zero disables this
+	IdleBufferExpiry time.Duration
+}
+
+func getHousekeepingConfig(env ReadEnvironemnt) HousekeepingConfig {
+	return HousekeepingConfig{
+		Enabled:                  getBool(env, "DAC_HOUSEKEEPING"),
+		StaleActionsInterval:     time.Second * time.Duration(getUint(env, "DAC_STALE_ACTIONS_INTERVAL_SECONDS")),
+		DeadHostsInterval:        time.Second * time.Duration(getUint(env, "DAC_DEAD_HOSTS_INTERVAL_SECONDS")),
+		ConsistencyCheckInterval: time.Second * time.Duration(getUint(env, "DAC_CONSISTENCY_CHECK_INTERVAL_SECONDS")),
+		IdleBufferExpiry:         time.Second * time.Duration(getUint(env, "DAC_IDLE_BUFFER_EXPIRY_SECONDS")),
+	}
+}
+
+type BrickDiscoveryConfig struct {
+	Enabled bool
+
+	// Tests can point this at a fake sysfs tree
+	SysfsRoot string
+
+	// Regular expressions matched against the block device name,
+	// and the device's model and serial, empty matches everything
+	DevicePattern string
+	ModelPattern  string
+	SerialPattern string
+
+	// Only use devices within this size range, zero means no limit
+	MinSizeGiB uint
+	MaxSizeGiB uint
+}
+
+func getBrickDiscoveryConfig(env ReadEnvironemnt) BrickDiscoveryConfig {
+	return BrickDiscoveryConfig{
+		Enabled:       getBool(env, "DAC_BRICK_DISCOVERY"),
+		SysfsRoot:     getString(env, "DAC_SYSFS_ROOT"),
+		DevicePattern: getString(env, "DAC_BRICK_DEVICE_PATTERN"),
+		ModelPattern:  getString(env, "DAC_BRICK_MODEL_PATTERN"),
+		SerialPattern: getString(env, "DAC_BRICK_SERIAL_PATTERN"),
+		MinSizeGiB:    getUint(env, "DAC_BRICK_MIN_SIZE_GB"),
+		MaxSizeGiB:    getUint(env, "DAC_BRICK_MAX_SIZE_GB"),
+	}
+}
+
+var allSessionActionTypes = []datamodel.SessionActionType{
+	datamodel.SessionCreateFilesystem,
+	datamodel.SessionDelete,
+	datamodel.SessionCopyDataIn,
+	datamodel.SessionMount,
+	datamodel.SessionUnmount,
+	datamodel.SessionCopyDataOut,
+	datamodel.SessionExpand,
+	datamodel.SessionPreempt,
+}
+
+// Get the per action type worker limit, e.g. DAC_ACTION_WORKERS_COPYDATAIN
+func getActionWorkers(env ReadEnvironemnt, defaultWorkers uint) map[datamodel.SessionActionType]uint {
+	workers := make(map[datamodel.SessionActionType]uint)
+	for _, actionType := range allSessionActionTypes {
+		workers[actionType] = defaultWorkers
+		if typeWorkers, ok := lookupUint(env, getActionWorkersKeyName(actionType)); ok {
+			workers[actionType] = typeWorkers
+		}
+	}
+	return workers
 }
 
-// TODO: need additional validation here
+// Expects the config to have already been checked by Validate
 func GetBrickManagerConfig(env ReadEnvironemnt) BrickManagerConfig {
 	config := BrickManagerConfig{
-		datamodel.BrickHostName(getHostname(env)),
-		datamodel.PoolName(getString(env, "DAC_POOL_NAME", "default")),
-		getUint(env, "DAC_BRICK_CAPACITY_GB",
-			getUint(env, "DAC_DEVICE_CAPACITY_GB", 1400)),
-		getUint(env, "DAC_BRICK_COUNT",
-			getUint(env, "DEVICE_COUNT", 12)),
-		getString(env, "DAC_BRICK_ADDRESS_PATTERN",
-			getString(env, "DEVICE_TYPE", "nvme%dn1")),
+		BrickHostName:        datamodel.BrickHostName(getHostname(env)),
+		PoolName:             datamodel.PoolName(getString(env, "DAC_POOL_NAME")),
+		DeviceCapacityGiB:    getUint(env, "DAC_BRICK_CAPACITY_GB"),
+		DeviceCount:          getUint(env, "DAC_BRICK_COUNT"),
+		DeviceAddressPattern: getString(env, "DAC_BRICK_ADDRESS_PATTERN"),
 		// Disabled means don't accept new Sessions, but allow Actions on existing Sessions
-		getBool(env, "DAC_HOST_ENABLED", true),
+		HostEnabled:          getBool(env, "DAC_HOST_ENABLED"),
+		DefaultActionWorkers: getUint(env, "DAC_ACTION_WORKERS"),
+		ShutdownTimeout:      time.Second * time.Duration(getUint(env, "DAC_SHUTDOWN_TIMEOUT_SECONDS")),
+		ReconcileInterval:    time.Second * time.Duration(getUint(env, "DAC_RECONCILE_INTERVAL_SECONDS")),
+		ReconcileReportOnly:  getBool(env, "DAC_RECONCILE_REPORT_ONLY"),
+		Discovery:            getBrickDiscoveryConfig(env),
+		Housekeeping:         getHousekeepingConfig(env),
 	}
+	config.ActionWorkers = getActionWorkers(env, config.DefaultActionWorkers)
 	log.Println("Got brick manager config:", config)
 	return config
 }
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"log"
+	"strings"
+)
+
+// Names of the keys that can be set in the cluster config
+func GetClusterKeys() []string {
+	var keys []string
+	for _, key := range allKeys {
+		if key.Cluster {
+			keys = append(keys, key.Key)
+		}
+	}
+	return keys
+}
+
+func getClusterKey(name string) (configKey, bool) {
+	for _, key := range allKeys {
+		if key.Cluster && key.Key == name {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+// Check a value before it is added to the cluster config
+func CheckClusterSetting(name string, value string) error {
+	key, ok := getClusterKey(name)
+	if !ok {
+		return fmt.Errorf("unknown cluster config key %s, must be one of: %s",
+			name, strings.Join(GetClusterKeys(), ", "))
+	}
+	return checkValue(key, value)
+}
+
+// Values from the cluster config take precedence over the local environment
+type clusterEnv struct {
+	env    ReadEnvironemnt
+	values map[string]string
+}
+
+func (c clusterEnv) LookupEnv(name string) (string, bool) {
+	if value, ok := c.values[name]; ok {
+		return value, ok
+	}
+	return c.env.LookupEnv(name)
+}
+
+func (c clusterEnv) Hostname() (string, error) {
+	return c.env.Hostname()
+}
+
+// Returns an environment that includes the cluster config settings for the given pool
+func NewClusterEnv(env ReadEnvironemnt, clusterConfig datamodel.ClusterConfig,
+	poolName datamodel.PoolName) ReadEnvironemnt {
+	values := make(map[string]string)
+	addValues := func(settings map[string]string) {
+		for name, value := range settings {
+			if err := CheckClusterSetting(name, value); err != nil {
+				log.Printf("ignoring cluster config setting due to: %s\n", err)
+				continue
+			}
+			key, _ := getClusterKey(name)
+			values[key.Name] = value
+		}
+	}
+	addValues(clusterConfig.Settings)
+	addValues(clusterConfig.PoolSettings[poolName])
+
+	// A local mdt_size_gb would otherwise override the cluster's mdt_size_mb
+	_, hasSizeGB := values["DAC_MDT_SIZE_GB"]
+	if _, hasSizeMB := values["DAC_MDT_SIZE_MB"]; hasSizeMB && !hasSizeGB {
+		values["DAC_MDT_SIZE_GB"] = "0"
+	}
+	return clusterEnv{env, values}
+}
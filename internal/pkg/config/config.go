@@ -19,36 +19,49 @@ func getHostname(env ReadEnvironemnt) string {
 	return hostname
 }
 
-func getUint(env ReadEnvironemnt, key string, defaultVal uint) uint {
-	val, ok := env.LookupEnv(key)
+// Values are checked by Validate on startup, so parse errors here are a bug
+func lookupUint(env ReadEnvironemnt, name string) (uint, bool) {
+	key := getKey(name)
+	val, ok := lookupValue(env, key)
 	if !ok {
-		return defaultVal
+		return 0, false
 	}
 	intVal, err := strconv.ParseUint(val, 10, 32)
 	if err != nil {
-		log.Printf("error parsing %s", key)
-		return defaultVal
+		log.Panicf("error parsing %s, config should have been validated: %s", name, err)
+	}
+	return uint(intVal), true
+}
+
+func getUint(env ReadEnvironemnt, name string) uint {
+	if val, ok := lookupUint(env, name); ok {
+		return val
+	}
+	intVal, err := strconv.ParseUint(getKey(name).Default, 10, 32)
+	if err != nil {
+		log.Panicf("invalid default for %s: %s", name, err)
 	}
 	return uint(intVal)
 }
 
-func getString(env ReadEnvironemnt, key string, defaultVal string) string {
-	val, ok := env.LookupEnv(key)
+func getString(env ReadEnvironemnt, name string) string {
+	key := getKey(name)
+	val, ok := lookupValue(env, key)
 	if !ok {
-		return defaultVal
+		return key.Default
 	}
 	return val
 }
 
-func getBool(env ReadEnvironemnt, key string, defaultVal bool) bool {
-	val, ok := env.LookupEnv(key)
+func getBool(env ReadEnvironemnt, name string) bool {
+	key := getKey(name)
+	val, ok := lookupValue(env, key)
 	if !ok {
-		return defaultVal
+		val = key.Default
 	}
 	boolVal, err := strconv.ParseBool(val)
 	if err != nil {
-		log.Printf("error parsing %s", key)
-		return defaultVal
+		log.Panicf("error parsing %s, config should have been validated: %s", name, err)
 	}
 	return boolVal
 }
@@ -65,4 +78,5 @@ func (systemEnv) Hostname() (string, error) {
 	//return "hostname", nil
 }
 
-var DefaultEnv ReadEnvironemnt = systemEnv{}
+// Environment variables override anything in the config file
+var DefaultEnv ReadEnvironemnt = newFileEnv(systemEnv{}, getConfigFilename(systemEnv{}))
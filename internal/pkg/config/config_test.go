@@ -3,8 +3,10 @@ package config
 import (
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestGetBrickManagerConfig(t *testing.T) {
@@ -17,4 +19,274 @@ func TestGetBrickManagerConfig(t *testing.T) {
 	assert.Equal(t, true, config.HostEnabled)
 	assert.Equal(t, "nvme%dn1", config.DeviceAddressPattern)
 	assert.Equal(t, uint(1400), config.DeviceCapacityGiB)
+	assert.Equal(t, uint(4), config.DefaultActionWorkers)
+	assert.Equal(t, uint(4), config.ActionWorkers[datamodel.SessionMount])
+	assert.Equal(t, time.Minute*5, config.ShutdownTimeout)
+	assert.Equal(t, time.Minute*5, config.ReconcileInterval)
+	assert.False(t, config.ReconcileReportOnly)
+	assert.False(t, config.Discovery.Enabled)
+	assert.Equal(t, "/sys", config.Discovery.SysfsRoot)
+	assert.True(t, config.Housekeeping.Enabled)
+	assert.Equal(t, time.Minute*10, config.Housekeeping.StaleActionsInterval)
+	assert.Equal(t, time.Minute, config.Housekeeping.DeadHostsInterval)
+	assert.Equal(t, time.Hour, config.Housekeeping.ConsistencyCheckInterval)
+	assert.Equal(t, time.Duration(0), config.Housekeeping.IdleBufferExpiry)
+}
+
+type fakeEnv map[string]string
+
+func (env fakeEnv) LookupEnv(key string) (string, bool) {
+	val, ok := env[key]
+	return val, ok
+}
+
+func (env fakeEnv) Hostname() (string, error) {
+	return "hostname", nil
+}
+
+func TestGetBrickManagerConfig_ActionWorkers(t *testing.T) {
+	config := GetBrickManagerConfig(fakeEnv{
+		"DAC_ACTION_WORKERS":            "2",
+		"DAC_ACTION_WORKERS_COPYDATAIN": "1",
+		"DAC_ACTION_WORKERS_MOUNT":      "8",
+	})
+
+	assert.Equal(t, uint(2), config.DefaultActionWorkers)
+	assert.Equal(t, uint(1), config.ActionWorkers[datamodel.SessionCopyDataIn])
+	assert.Equal(t, uint(8), config.ActionWorkers[datamodel.SessionMount])
+	assert.Equal(t, uint(2), config.ActionWorkers[datamodel.SessionCopyDataOut])
+	assert.Equal(t, uint(2), config.ActionWorkers[datamodel.SessionDelete])
+}
+
+func TestGetActionTimeout(t *testing.T) {
+	env := fakeEnv{"DAC_ACTION_TIMEOUT_SECONDS_COPYDATAIN": "3500"}
+	assert.Equal(t, time.Second*3500, GetActionTimeout(env, datamodel.SessionCopyDataIn))
+	assert.Equal(t, time.Minute*30, GetActionTimeout(env, datamodel.SessionMount))
+
+	env["DAC_ACTION_TIMEOUT_SECONDS"] = "60"
+	assert.Equal(t, time.Minute, GetActionTimeout(env, datamodel.SessionMount))
+}
+
+func TestGetBrickManagerConfig_Aliases(t *testing.T) {
+	config := GetBrickManagerConfig(fakeEnv{
+		"DAC_DEVICE_CAPACITY_GB": "100",
+		"DEVICE_COUNT":           "2",
+		"DAC_BRICK_COUNT":        "3",
+	})
+
+	assert.Equal(t, uint(100), config.DeviceCapacityGiB)
+	assert.Equal(t, uint(3), config.DeviceCount)
+}
+
+func TestGetLogConfig(t *testing.T) {
+	assert.Equal(t, LogConfig{Level: "info", Format: "json"}, GetLogConfig(fakeEnv{}))
+	assert.Equal(t, LogConfig{Level: "debug", Format: "text"},
+		GetLogConfig(fakeEnv{"DAC_LOG_LEVEL": "debug", "DAC_LOG_FORMAT": "text"}))
+}
+
+func TestValidate(t *testing.T) {
+	errs := Validate(fakeEnv{"ETCD_ENDPOINTS": "127.0.0.1:2379"})
+	assert.Nil(t, errs)
+
+	errs = Validate(fakeEnv{
+		"DAC_ACTION_WORKERS_COPYDATAOUT": "asdf",
+		"DAC_ACTION_WORKERS_MOUNT":       "0",
+		"DAC_HOST_ENABLED":               "yes",
+		"DAC_POOL_NAME":                  "bad pool",
+		"DAC_BRICK_DEVICE_PATTERN":       "(",
+		"DAC_BRICK_ADDRESS_PATTERN":      "nvme",
+		"DAC_HTTP_LISTEN":                "8090",
+		"DAC_LOG_FORMAT":                 "xml",
+	})
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		"invalid brick_manager.pool_name (DAC_POOL_NAME) value 'bad pool': must only contain the characters A-Za-z0-9.-",
+		"invalid brick_manager.brick_address_pattern (DAC_BRICK_ADDRESS_PATTERN) value 'nvme': must contain %d exactly once",
+		"invalid brick_manager.host_enabled (DAC_HOST_ENABLED) value 'yes': must be true or false",
+		"invalid brick_manager.http_listen (DAC_HTTP_LISTEN) value '8090': address 8090: missing port in address",
+		"invalid brick_discovery.device_pattern (DAC_BRICK_DEVICE_PATTERN) value '(': error parsing regexp: missing closing ): `(`",
+		"missing required keystore.endpoints (ETCDCTL_ENDPOINTS)",
+		"invalid logging.format (DAC_LOG_FORMAT) value 'xml': must be json or text",
+		"invalid action_workers.mount (DAC_ACTION_WORKERS_MOUNT) value '0': must be at least one",
+		"invalid action_workers.copydataout (DAC_ACTION_WORKERS_COPYDATAOUT) value 'asdf': must be a whole number",
+	}, messages)
+}
+
+func TestValidate_DiscoverySizes(t *testing.T) {
+	errs := Validate(fakeEnv{
+		"ETCDCTL_ENDPOINTS":     "127.0.0.1:2379",
+		"DAC_BRICK_MIN_SIZE_GB": "100",
+		"DAC_BRICK_MAX_SIZE_GB": "10",
+	})
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "brick_discovery.max_size_gb must not be less than min_size_gb", errs[0].Error())
+}
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "data-acc-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestNewFileEnv(t *testing.T) {
+	filename := writeConfigFile(t, `
+brick_manager:
+  pool_name: fast
+  brick_count: 4
+  host_enabled: false
+action_workers:
+  copydatain: 1
+keystore:
+  endpoints: 127.0.0.1:2379
+`)
+	defer os.Remove(filename)
+
+	env := newFileEnv(fakeEnv{"DAC_BRICK_COUNT": "6"}, filename)
+	assert.Nil(t, Validate(env))
+
+	config := GetBrickManagerConfig(env)
+	assert.Equal(t, datamodel.PoolName("fast"), config.PoolName)
+	assert.Equal(t, uint(6), config.DeviceCount)
+	assert.False(t, config.HostEnabled)
+	assert.Equal(t, uint(1), config.ActionWorkers[datamodel.SessionCopyDataIn])
+	assert.Equal(t, uint(4), config.ActionWorkers[datamodel.SessionMount])
+	assert.Equal(t, []string{"127.0.0.1:2379"}, GetKeystoreConfig(env).Endpoints)
+}
+
+func TestNewFileEnv_Errors(t *testing.T) {
+	filename := writeConfigFile(t, `
+brick_manager:
+  pool_nam: fast
+  brick_count: -1
+keystore:
+  endpoints:
+`)
+	defer os.Remove(filename)
+
+	errs := Validate(newFileEnv(fakeEnv{}, filename))
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	assert.Equal(t, []string{
+		"missing value for config file key: keystore.endpoints",
+		"unknown config file key: brick_manager.pool_nam",
+		"invalid brick_manager.brick_count (DAC_BRICK_COUNT) value '-1': must be a whole number",
+		"missing required keystore.endpoints (ETCDCTL_ENDPOINTS)",
+	}, messages)
+}
+
+func TestNewFileEnv_MissingFile(t *testing.T) {
+	env := newFileEnv(fakeEnv{"ETCDCTL_ENDPOINTS": "127.0.0.1:2379"}, "/does/not/exist.yaml")
+	assert.Nil(t, Validate(env))
+
+	env = newFileEnv(fakeEnv{
+		"ETCDCTL_ENDPOINTS": "127.0.0.1:2379",
+		"DAC_CONFIG_FILE":   "/does/not/exist.yaml",
+	}, "/does/not/exist.yaml")
+	errs := Validate(env)
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t,
+		"unable to read config file /does/not/exist.yaml: open /does/not/exist.yaml: no such file or directory",
+		errs[0].Error())
+}
+
+func TestGetReference(t *testing.T) {
+	generated, err := ioutil.ReadFile("../../../docs/configuration.md")
+	assert.Nil(t, err)
+	assert.Equal(t, string(generated), GetReference(),
+		"please regenerate docs/configuration.md using: dacd --config-reference")
+}
+
+func TestNewClusterEnv(t *testing.T) {
+	env := fakeEnv{"DAC_MDT_SIZE_GB": "10", "DAC_MGS_DEV": "sdc", "DAC_HOST_GROUP": "local"}
+	clusterConfig := datamodel.ClusterConfig{
+		Settings: map[string]string{"mdt_size_mb": "512", "max_mdt_count": "asdf"},
+		PoolSettings: map[datamodel.PoolName]map[string]string{
+			"fast": {"mgs_device": "sdd", "host_group": "ignored"},
+		},
+	}
+
+	settings := GetFilesystemSettings(NewClusterEnv(env, clusterConfig, "default"))
+	assert.Equal(t, datamodel.FilesystemSettings{MGSDevice: "sdc", MaxMDTs: 24, MDTSizeMB: 512,
+		Type: datamodel.LustreFilesystem, Provider: "ansible"}, settings)
+
+	clusterEnv := NewClusterEnv(env, clusterConfig, "fast")
+	settings = GetFilesystemSettings(clusterEnv)
+	assert.Equal(t, datamodel.FilesystemSettings{MGSDevice: "sdd", MaxMDTs: 24, MDTSizeMB: 512,
+		Type: datamodel.LustreFilesystem, Provider: "ansible"}, settings)
+	assert.Equal(t, "local", getString(clusterEnv, "DAC_HOST_GROUP"))
+
+	settings = GetFilesystemSettings(NewClusterEnv(env, datamodel.ClusterConfig{}, "default"))
+	assert.Equal(t, datamodel.FilesystemSettings{MGSDevice: "sdc", MaxMDTs: 24, MDTSizeMB: 10240,
+		Type: datamodel.LustreFilesystem, Provider: "ansible"}, settings)
+}
+
+func TestGetFilesystemSettings_Type(t *testing.T) {
+	clusterConfig := datamodel.ClusterConfig{
+		PoolSettings: map[datamodel.PoolName]map[string]string{"beegfs": {"type": "beegfs"}},
+	}
+	assert.Equal(t, datamodel.BeeGFSFilesystem,
+		GetFilesystemSettings(NewClusterEnv(fakeEnv{}, clusterConfig, "beegfs")).Type)
+	assert.Equal(t, datamodel.LustreFilesystem,
+		GetFilesystemSettings(NewClusterEnv(fakeEnv{}, clusterConfig, "default")).Type)
+
+	assert.Equal(t, "invalid filesystem.type (DAC_FILESYSTEM_TYPE) value 'gpfs': must be one of lustre or beegfs",
+		CheckClusterSetting("type", "gpfs").Error())
+}
+
+func TestRegisterProviderKeys(t *testing.T) {
+	defer func(keys []configKey) { allKeys = keys }(allKeys)
+	RegisterProviderKeys("mysite", []ProviderKey{
+		{Key: "root_dir", Default: "/var/lib/data-acc/mysite", Description: "Directory for buffers."},
+		{Key: "mode", Validate: validName},
+	})
+
+	assert.Equal(t, map[string]string{"root_dir": "/data", "mode": ""},
+		GetProviderSettings(fakeEnv{"DAC_MYSITE_PROVIDER_ROOT_DIR": "/data"}, "mysite"))
+	assert.Equal(t, map[string]string{}, GetProviderSettings(fakeEnv{}, "unknown"))
+	assert.Equal(t, "/var/lib/data-acc/fs-ansible/", GetProviderSettings(fakeEnv{}, "ansible")["ansible_dir"])
+
+	errs := Validate(fakeEnv{"ETCDCTL_ENDPOINTS": "127.0.0.1:2379", "DAC_MYSITE_PROVIDER_MODE": "a/b"})
+	assert.Equal(t, 1, len(errs))
+	assert.Equal(t, "invalid mysite_provider.mode (DAC_MYSITE_PROVIDER_MODE) value 'a/b': "+
+		"must only contain the characters A-Za-z0-9.-", errs[0].Error())
+
+	assert.Panics(t, func() { RegisterProviderKeys("mysite", []ProviderKey{{Key: "mode"}}) })
+	assert.Panics(t, func() { RegisterProviderKeys("a/b", nil) })
+}
+
+func TestParseConfigFile_OldPaths(t *testing.T) {
+	values, errs := parseConfigFile([]byte(`
+filesystem:
+  ansible_dir: /opt/fs-ansible
+ansible_provider:
+  host_group: dac-test
+`))
+	assert.Nil(t, errs)
+	assert.Equal(t, map[string]string{"DAC_ANSIBLE_DIR": "/opt/fs-ansible", "DAC_HOST_GROUP": "dac-test"}, values)
+}
+
+func TestGetWipePolicy(t *testing.T) {
+	assert.Equal(t, datamodel.WipeNone, GetWipePolicy(DefaultEnv))
+
+	clusterConfig := datamodel.ClusterConfig{
+		PoolSettings: map[datamodel.PoolName]map[string]string{"secure": {"wipe_policy": "overwrite"}},
+	}
+	assert.Equal(t, datamodel.WipeOverwrite, GetWipePolicy(NewClusterEnv(fakeEnv{}, clusterConfig, "secure")))
+	assert.Equal(t, datamodel.WipeDiscard,
+		GetWipePolicy(NewClusterEnv(fakeEnv{"DAC_WIPE_POLICY": "discard"}, clusterConfig, "default")))
+
+	assert.Equal(t, "invalid filesystem.wipe_policy (DAC_WIPE_POLICY) value 'shred': "+
+		"must be one of none, discard or overwrite",
+		CheckClusterSetting("wipe_policy", "shred").Error())
 }
@@ -0,0 +1,16 @@
+package config
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"time"
+)
+
+// How long dacctl waits for the given type of action, e.g. DAC_ACTION_TIMEOUT_SECONDS_COPYDATAIN.
+// dacd stops the action if it is still running after this time.
+func GetActionTimeout(env ReadEnvironemnt, actionType datamodel.SessionActionType) time.Duration {
+	timeout, ok := lookupUint(env, getActionTimeoutKeyName(actionType))
+	if !ok {
+		timeout = getUint(env, "DAC_ACTION_TIMEOUT_SECONDS")
+	}
+	return time.Second * time.Duration(timeout)
+}
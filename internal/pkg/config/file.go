@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+const defaultConfigFilename = "/etc/data-acc/data-acc.yaml"
+
+// DAC_CONFIG_FILE can only be set using an environment variable
+func getConfigFilename(env ReadEnvironemnt) string {
+	if filename, ok := env.LookupEnv("DAC_CONFIG_FILE"); ok {
+		return filename
+	}
+	return defaultConfigFilename
+}
+
+// Reads values from the environment first, then falls back to the config file
+type fileEnv struct {
+	env      ReadEnvironemnt
+	filename string
+	values   map[string]string
+
+	// Reported by Validate, so the error can be shown along with any other problems
+	loadErrors []error
+}
+
+func newFileEnv(env ReadEnvironemnt, filename string) *fileEnv {
+	fileEnv := &fileEnv{env: env, filename: filename, values: make(map[string]string)}
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		_, explicitFile := env.LookupEnv("DAC_CONFIG_FILE")
+		if explicitFile || !os.IsNotExist(err) {
+			fileEnv.loadErrors = append(fileEnv.loadErrors,
+				fmt.Errorf("unable to read config file %s: %s", filename, err))
+		}
+		return fileEnv
+	}
+	fileEnv.values, fileEnv.loadErrors = parseConfigFile(raw)
+	return fileEnv
+}
+
+func (f *fileEnv) LookupEnv(name string) (string, bool) {
+	if value, ok := f.env.LookupEnv(name); ok {
+		return value, ok
+	}
+	value, ok := f.values[name]
+	return value, ok
+}
+
+func (f *fileEnv) Hostname() (string, error) {
+	return f.env.Hostname()
+}
+
+// Parse the YAML, returning values keyed by environment variable name
+func parseConfigFile(raw []byte) (map[string]string, []error) {
+	sections := make(map[string]map[string]interface{})
+	if err := yaml.UnmarshalStrict(raw, &sections); err != nil {
+		return nil, []error{fmt.Errorf("unable to parse config file: %s", err)}
+	}
+
+	keysByPath := make(map[string]configKey)
+	for _, key := range allKeys {
+		keysByPath[key.Section+"."+key.Key] = key
+		for _, oldPath := range key.OldPaths {
+			keysByPath[oldPath] = key
+		}
+	}
+
+	values := make(map[string]string)
+	var errs []error
+	for section, sectionValues := range sections {
+		for name, value := range sectionValues {
+			path := section + "." + name
+			key, ok := keysByPath[path]
+			if !ok {
+				errs = append(errs, fmt.Errorf("unknown config file key: %s", path))
+				continue
+			}
+			if value == nil {
+				errs = append(errs, fmt.Errorf("missing value for config file key: %s", path))
+				continue
+			}
+			values[key.Name] = fmt.Sprint(value)
+		}
+	}
+	sortErrors(errs)
+	return values, errs
+}
+
+func sortErrors(errs []error) {
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+}
+
+// Check every config value, returning all the problems found
+func Validate(env ReadEnvironemnt) []error {
+	var errs []error
+	if fileEnv, ok := env.(*fileEnv); ok {
+		errs = append(errs, fileEnv.loadErrors...)
+	}
+	for _, key := range allKeys {
+		value, ok := lookupValue(env, key)
+		if !ok {
+			if key.Required {
+				errs = append(errs, fmt.Errorf("missing required %s.%s (%s)", key.Section, key.Key, key.Name))
+			}
+			continue
+		}
+		if err := checkValue(key, value); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	minSize, hasMin := lookupUint(env, "DAC_BRICK_MIN_SIZE_GB")
+	maxSize, hasMax := lookupUint(env, "DAC_BRICK_MAX_SIZE_GB")
+	if len(errs) == 0 && hasMin && hasMax && maxSize > 0 && maxSize < minSize {
+		errs = append(errs, fmt.Errorf("brick_discovery.max_size_gb must not be less than min_size_gb"))
+	}
+	return errs
+}
+
+// Validate the default environment, returning a single error listing all the problems
+func CheckConfig() error {
+	errs := Validate(DefaultEnv)
+	if len(errs) == 0 {
+		return nil
+	}
+	var messages []string
+	for _, err := range errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Errorf("invalid configuration:\n  %s", strings.Join(messages, "\n  "))
+}
@@ -1,5 +1,7 @@
 package config
 
+import "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+
 type FilesystemConfig struct {
 	MGSDevice   string
 	MaxMDTs     uint
@@ -8,22 +10,60 @@ type FilesystemConfig struct {
 	SkipAnsible bool
 	LnetSuffix  string
 	MDTSizeMB   uint
+
+	SSHExecutor       string
+	SSHUser           string
+	SSHKeyFile        string
+	SSHKnownHostsFile string
+	SSHParallelHosts  uint
 }
 
 func GetFilesystemConfig() FilesystemConfig {
 	env := DefaultEnv
-	conf := FilesystemConfig{
-		MGSDevice:   getString(env, "DAC_MGS_DEV", "sdb"),
-		MaxMDTs:     getUint(env, "DAC_MAX_MDT_COUNT", 24),
-		HostGroup:   getString(env, "DAC_HOST_GROUP", "dac-prod"),
-		AnsibleDir:  getString(env, "DAC_ANSIBLE_DIR", "/var/lib/data-acc/fs-ansible/"),
-		SkipAnsible: getBool(env, "DAC_SKIP_ANSIBLE", false),
-		LnetSuffix:  getString(env, "DAC_LNET_SUFFIX", ""),
+	settings := GetFilesystemSettings(env)
+	return FilesystemConfig{
+		MGSDevice:   settings.MGSDevice,
+		MaxMDTs:     settings.MaxMDTs,
+		HostGroup:   getString(env, "DAC_HOST_GROUP"),
+		AnsibleDir:  getString(env, "DAC_ANSIBLE_DIR"),
+		SkipAnsible: getBool(env, "DAC_SKIP_ANSIBLE"),
+		LnetSuffix:  settings.LnetSuffix,
+		MDTSizeMB:   settings.MDTSizeMB,
+
+		SSHExecutor:       getString(env, "DAC_SSH_EXECUTOR"),
+		SSHUser:           getString(env, "DAC_SSH_USER"),
+		SSHKeyFile:        getString(env, "DAC_SSH_KEY_FILE"),
+		SSHKnownHostsFile: getString(env, "DAC_SSH_KNOWN_HOSTS_FILE"),
+		SSHParallelHosts:  getUint(env, "DAC_SSH_PARALLEL_HOSTS"),
+	}
+}
+
+func (conf FilesystemConfig) GetFilesystemSettings() datamodel.FilesystemSettings {
+	return datamodel.FilesystemSettings{
+		MGSDevice:  conf.MGSDevice,
+		MaxMDTs:    conf.MaxMDTs,
+		MDTSizeMB:  conf.MDTSizeMB,
+		LnetSuffix: conf.LnetSuffix,
 	}
-	mdtSizeMB := getUint(env, "DAC_MDT_SIZE_GB", 0) * 1024
+}
+
+// Use NewClusterEnv to include any values from the cluster config
+func GetFilesystemSettings(env ReadEnvironemnt) datamodel.FilesystemSettings {
+	mdtSizeMB := getUint(env, "DAC_MDT_SIZE_GB") * 1024
 	if mdtSizeMB == 0 {
-		mdtSizeMB = getUint(env, "DAC_MDT_SIZE_MB", uint(20*1024))
+		mdtSizeMB = getUint(env, "DAC_MDT_SIZE_MB")
+	}
+	return datamodel.FilesystemSettings{
+		MGSDevice:  getString(env, "DAC_MGS_DEV"),
+		MaxMDTs:    getUint(env, "DAC_MAX_MDT_COUNT"),
+		MDTSizeMB:  mdtSizeMB,
+		LnetSuffix: getString(env, "DAC_LNET_SUFFIX"),
+		Type:       datamodel.FilesystemType(getString(env, "DAC_FILESYSTEM_TYPE")),
+		Provider:   getString(env, "DAC_FILESYSTEM_PROVIDER"),
 	}
-	conf.MDTSizeMB = mdtSizeMB
-	return conf
+}
+
+// Use NewClusterEnv to include any values from the cluster config
+func GetWipePolicy(env ReadEnvironemnt) datamodel.WipePolicy {
+	return datamodel.WipePolicy(getString(env, "DAC_WIPE_POLICY"))
 }
@@ -0,0 +1,7 @@
+package config
+
+// Address for dacd's health and status HTTP listener, e.g. ":8090"
+// Empty means the listener is disabled
+func GetDacdHttpListen() string {
+	return getString(DefaultEnv, "DAC_HTTP_LISTEN")
+}
@@ -0,0 +1,355 @@
+package config
+
+import (
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type valueKind int
+
+const (
+	stringValue valueKind = iota
+	uintValue
+	boolValue
+)
+
+func (kind valueKind) String() string {
+	switch kind {
+	case uintValue:
+		return "uint"
+	case boolValue:
+		return "bool"
+	default:
+		return "string"
+	}
+}
+
+// Every config value can be set in the config file as <Section>.<Key>,
+// or using the environment variable Name, which takes precedence
+type configKey struct {
+	Name        string
+	Section     string
+	Key         string
+	Kind        valueKind
+	Default     string
+	Description string
+
+	// Older environment variable names that are still accepted
+	Aliases []string
+
+	// Older config file paths, <section>.<key>, that are still accepted
+	OldPaths []string
+
+	// True if there is no sensible default
+	Required bool
+
+	// True if the value can be set for the whole cluster in the keystore,
+	// which then takes precedence over the local value
+	Cluster bool
+
+	// Extra checks, on top of the value parsing as the correct kind
+	Validate func(value string) error
+}
+
+func positive(value string) error {
+	if value == "0" {
+		return fmt.Errorf("must be at least one")
+	}
+	return nil
+}
+
+func validName(value string) error {
+	if !parsers.IsValidName(value) {
+		return fmt.Errorf("must only contain the characters A-Za-z0-9.-")
+	}
+	return nil
+}
+
+func validRegexp(value string) error {
+	_, err := regexp.Compile(value)
+	return err
+}
+
+func validListenAddress(value string) error {
+	if value == "" {
+		return nil
+	}
+	_, _, err := net.SplitHostPort(value)
+	return err
+}
+
+func validAddressPattern(value string) error {
+	if strings.Count(value, "%d") != 1 {
+		return fmt.Errorf("must contain %%d exactly once")
+	}
+	return nil
+}
+
+func validWipePolicy(value string) error {
+	switch datamodel.WipePolicy(value) {
+	case datamodel.WipeNone, datamodel.WipeDiscard, datamodel.WipeOverwrite:
+		return nil
+	}
+	return fmt.Errorf("must be one of none, discard or overwrite")
+}
+
+func validFilesystemType(value string) error {
+	switch datamodel.FilesystemType(value) {
+	case datamodel.LustreFilesystem, datamodel.BeeGFSFilesystem:
+		return nil
+	}
+	return fmt.Errorf("must be one of lustre or beegfs")
+}
+
+func validAbsolutePath(value string) error {
+	if !path.IsAbs(value) {
+		return fmt.Errorf("must be an absolute path")
+	}
+	return nil
+}
+
+func validMountMethod(value string) error {
+	if value != "symlink" && value != "bind" {
+		return fmt.Errorf("must be symlink or bind")
+	}
+	return nil
+}
+
+// Empty means use the default location
+func validOptionalAbsolutePath(value string) error {
+	if value == "" {
+		return nil
+	}
+	return validAbsolutePath(value)
+}
+
+func validSSHExecutor(value string) error {
+	if value != "exec" && value != "native" {
+		return fmt.Errorf("must be exec or native")
+	}
+	return nil
+}
+
+func validLogLevel(value string) error {
+	switch value {
+	case "debug", "info", "warning", "error":
+		return nil
+	}
+	return fmt.Errorf("must be one of debug, info, warning or error")
+}
+
+func validLogFormat(value string) error {
+	if value != "json" && value != "text" {
+		return fmt.Errorf("must be json or text")
+	}
+	return nil
+}
+
+var allKeys = []configKey{
+	{Name: "DAC_POOL_NAME", Section: "brick_manager", Key: "pool_name", Default: "default",
+		Description: "Pool that this host's bricks are added to.", Validate: validName},
+	{Name: "DAC_BRICK_CAPACITY_GB", Section: "brick_manager", Key: "brick_capacity_gb", Kind: uintValue,
+		Default: "1400", Aliases: []string{"DAC_DEVICE_CAPACITY_GB"}, Validate: positive,
+		Description: "Size of each brick in GiB, ignored when using brick discovery."},
+	{Name: "DAC_BRICK_COUNT", Section: "brick_manager", Key: "brick_count", Kind: uintValue,
+		Default: "12", Aliases: []string{"DEVICE_COUNT"}, Validate: positive,
+		Description: "Number of bricks on this host, or the number expected when using brick discovery."},
+	{Name: "DAC_BRICK_ADDRESS_PATTERN", Section: "brick_manager", Key: "brick_address_pattern",
+		Default: "nvme%dn1", Aliases: []string{"DEVICE_TYPE"}, Validate: validAddressPattern,
+		Description: "Pattern used to name each brick device, where %d is replaced by the brick index."},
+	{Name: "DAC_HOST_ENABLED", Section: "brick_manager", Key: "host_enabled", Kind: boolValue, Default: "true",
+		Description: "When false, no new sessions use this host, but actions on existing sessions still run."},
+	{Name: "DAC_ACTION_WORKERS", Section: "brick_manager", Key: "action_workers", Kind: uintValue,
+		Default: "4", Validate: positive,
+		Description: "Maximum number of actions of each type that can run at once."},
+	{Name: "DAC_SHUTDOWN_TIMEOUT_SECONDS", Section: "brick_manager", Key: "shutdown_timeout_seconds",
+		Kind: uintValue, Default: "300",
+		Description: "How long shutdown waits for in-flight actions to finish."},
+	{Name: "DAC_RECONCILE_INTERVAL_SECONDS", Section: "brick_manager", Key: "reconcile_interval_seconds",
+		Kind: uintValue, Default: "300",
+		Description: "How often sessions are checked against the mounted filesystems, zero disables the check."},
+	{Name: "DAC_RECONCILE_REPORT_ONLY", Section: "brick_manager", Key: "reconcile_report_only", Kind: boolValue,
+		Default: "false", Description: "Only log problems found by the reconcile check, rather than fixing them."},
+	{Name: "DAC_HTTP_LISTEN", Section: "brick_manager", Key: "http_listen", Validate: validListenAddress,
+		Description: "Address for the health, status and metrics HTTP listener, e.g. :8090. Disabled when empty."},
+
+	{Name: "DAC_HOUSEKEEPING", Section: "housekeeping", Key: "enabled", Kind: boolValue, Default: "true",
+		Description: "Take part in the election for the dacd that runs the cluster wide housekeeping tasks."},
+	{Name: "DAC_STALE_ACTIONS_INTERVAL_SECONDS", Section: "housekeeping", Key: "stale_actions_interval_seconds",
+		Kind: uintValue, Default: "600", Validate: positive,
+		Description: "How often action responses and cancel requests no one is waiting for are removed."},
+	{Name: "DAC_DEAD_HOSTS_INTERVAL_SECONDS", Section: "housekeeping", Key: "dead_hosts_interval_seconds",
+		Kind: uintValue, Default: "60", Validate: positive,
+		Description: "How often brick hosts are checked to see if their dacd is still alive."},
+	{Name: "DAC_CONSISTENCY_CHECK_INTERVAL_SECONDS", Section: "housekeeping",
+		Key: "consistency_check_interval_seconds", Kind: uintValue, Default: "3600", Validate: positive,
+		Description: "How often the sessions are checked against the registered bricks."},
+	{Name: "DAC_IDLE_BUFFER_EXPIRY_SECONDS", Section: "housekeeping", Key: "idle_buffer_expiry_seconds",
+		Kind: uintValue, Default: "0",
+		Description: "Delete persistent buffers no job has used for this long, zero disables this."},
+
+	{Name: "DAC_BRICK_DISCOVERY", Section: "brick_discovery", Key: "enabled", Kind: boolValue, Default: "false",
+		Description: "Find bricks by looking for block devices in sysfs."},
+	{Name: "DAC_SYSFS_ROOT", Section: "brick_discovery", Key: "sysfs_root", Default: "/sys",
+		Description: "Where sysfs is mounted."},
+	{Name: "DAC_BRICK_DEVICE_PATTERN", Section: "brick_discovery", Key: "device_pattern",
+		Default: "^nvme[0-9]+n[0-9]+$", Validate: validRegexp,
+		Description: "Regular expression the block device name must match."},
+	{Name: "DAC_BRICK_MODEL_PATTERN", Section: "brick_discovery", Key: "model_pattern", Validate: validRegexp,
+		Description: "Regular expression the device model must match."},
+	{Name: "DAC_BRICK_SERIAL_PATTERN", Section: "brick_discovery", Key: "serial_pattern", Validate: validRegexp,
+		Description: "Regular expression the device serial must match."},
+	{Name: "DAC_BRICK_MIN_SIZE_GB", Section: "brick_discovery", Key: "min_size_gb", Kind: uintValue, Default: "0",
+		Description: "Ignore devices smaller than this many GiB."},
+	{Name: "DAC_BRICK_MAX_SIZE_GB", Section: "brick_discovery", Key: "max_size_gb", Kind: uintValue, Default: "0",
+		Description: "Ignore devices larger than this many GiB, zero means no limit."},
+
+	{Name: "DAC_FILESYSTEM_PROVIDER", Section: "filesystem", Key: "provider", Default: "ansible", Cluster: true,
+		Validate:    validName,
+		Description: "Provider that creates and mounts the filesystems, configured in its <provider>_provider section."},
+	{Name: "DAC_FILESYSTEM_TYPE", Section: "filesystem", Key: "type", Default: "lustre", Cluster: true,
+		Validate:    validFilesystemType,
+		Description: "Filesystem created for each buffer, one of lustre or beegfs, unless the job asks for another."},
+	{Name: "DAC_MGS_DEV", Section: "filesystem", Key: "mgs_device", Default: "sdb", Cluster: true,
+		Description: "Device used for the Lustre MGS."},
+	{Name: "DAC_MAX_MDT_COUNT", Section: "filesystem", Key: "max_mdt_count", Kind: uintValue, Default: "24",
+		Cluster: true, Validate: positive, Description: "Maximum number of MDTs in each filesystem."},
+	{Name: "DAC_LNET_SUFFIX", Section: "filesystem", Key: "lnet_suffix", Cluster: true,
+		Description: "Suffix added to hostnames to get the Lustre network identifier."},
+	{Name: "DAC_MDT_SIZE_GB", Section: "filesystem", Key: "mdt_size_gb", Kind: uintValue, Default: "0",
+		Cluster: true, Description: "Size of each MDT in GiB, when set this is used instead of mdt_size_mb."},
+	{Name: "DAC_MDT_SIZE_MB", Section: "filesystem", Key: "mdt_size_mb", Kind: uintValue, Default: "20480",
+		Cluster: true, Validate: positive, Description: "Size of each MDT in MiB."},
+	{Name: "DAC_WIPE_POLICY", Section: "filesystem", Key: "wipe_policy", Default: "none", Cluster: true,
+		Validate:    validWipePolicy,
+		Description: "How bricks are wiped when a buffer is deleted, one of none, discard or overwrite."},
+
+	{Name: "DAC_HOST_GROUP", Section: "ansible_provider", Key: "host_group", Default: "dac-prod",
+		OldPaths: []string{"filesystem.host_group"}, Description: "Ansible host group used in the generated inventory."},
+	{Name: "DAC_ANSIBLE_DIR", Section: "ansible_provider", Key: "ansible_dir", Default: "/var/lib/data-acc/fs-ansible/",
+		OldPaths:    []string{"filesystem.ansible_dir"},
+		Description: "Directory containing fs-ansible and its virtual environment."},
+	{Name: "DAC_SKIP_ANSIBLE", Section: "ansible_provider", Key: "skip_ansible", Kind: boolValue, Default: "false",
+		OldPaths:    []string{"filesystem.skip_ansible"},
+		Description: "Skip running ansible and ssh commands, only useful for testing."},
+	{Name: "DAC_SSH_EXECUTOR", Section: "ansible_provider", Key: "ssh_executor", Default: "exec",
+		Validate: validSSHExecutor,
+		Description: "How commands are run on other hosts, exec runs the ssh command for each one, " +
+			"native uses a built-in client that reuses connections and checks host keys."},
+	{Name: "DAC_SSH_USER", Section: "ansible_provider", Key: "ssh_user",
+		Description: "User the native ssh client logs in as, empty means the user running dacd."},
+	{Name: "DAC_SSH_KEY_FILE", Section: "ansible_provider", Key: "ssh_key_file", Validate: validOptionalAbsolutePath,
+		Description: "Private key used by the native ssh client, empty means the id_rsa, id_ecdsa or id_ed25519 in ~/.ssh."},
+	{Name: "DAC_SSH_KNOWN_HOSTS_FILE", Section: "ansible_provider", Key: "ssh_known_hosts_file",
+		Validate:    validOptionalAbsolutePath,
+		Description: "Host keys checked by the native ssh client, empty means ~/.ssh/known_hosts."},
+	{Name: "DAC_SSH_PARALLEL_HOSTS", Section: "ansible_provider", Key: "ssh_parallel_hosts", Kind: uintValue,
+		Default: "32", Validate: positive,
+		Description: "Most hosts a command is run on at once, such as when mounting or checking a job's buffers."},
+
+	{Name: "DAC_LOCAL_PROVIDER_ROOT_DIR", Section: "local_provider", Key: "root_dir",
+		Default: "/var/lib/data-acc/local", Validate: validAbsolutePath,
+		Description: "Directory on the brick host holding a directory for each buffer."},
+	{Name: "DAC_LOCAL_PROVIDER_MOUNT_METHOD", Section: "local_provider", Key: "mount_method", Default: "symlink",
+		Validate:    validMountMethod,
+		Description: "How buffers appear under /mnt/dac, one of symlink or bind, where bind needs root."},
+
+	{Name: "ETCDCTL_ENDPOINTS", Section: "keystore", Key: "endpoints", Required: true,
+		Aliases: []string{"ETCD_ENDPOINTS"}, Description: "Comma separated list of etcd endpoints."},
+	{Name: "ETCDCTL_CERT_FILE", Section: "keystore", Key: "cert_file",
+		Description: "Client certificate used to connect to etcd."},
+	{Name: "ETCDCTL_KEY_FILE", Section: "keystore", Key: "key_file",
+		Description: "Client key used to connect to etcd."},
+	{Name: "ETCDCTL_CA_FILE", Section: "keystore", Key: "ca_file",
+		Description: "CA certificate used to check the etcd server certificate."},
+
+	{Name: "DAC_LOG_LEVEL", Section: "logging", Key: "level", Default: "info", Validate: validLogLevel,
+		Description: "Only log messages at this level or above, one of debug, info, warning or error."},
+	{Name: "DAC_LOG_FORMAT", Section: "logging", Key: "format", Default: "json", Validate: validLogFormat,
+		Description: "Write each log line as json, or as text."},
+
+	{Name: "DACCTL_LOG", Section: "dacctl", Key: "log_file", Default: "/var/log/dacctl.log",
+		Description: "File dacctl writes its log to."},
+	{Name: "DAC_ACTION_TIMEOUT_SECONDS", Section: "dacctl", Key: "action_timeout_seconds", Kind: uintValue,
+		Default: "1800", Validate: positive,
+		Description: "How long dacctl waits for an action, after which dacd stops the action."},
+}
+
+func getActionWorkersKeyName(actionType datamodel.SessionActionType) string {
+	return fmt.Sprintf("DAC_ACTION_WORKERS_%s", strings.ToUpper(string(actionType)))
+}
+
+func getActionTimeoutKeyName(actionType datamodel.SessionActionType) string {
+	return fmt.Sprintf("DAC_ACTION_TIMEOUT_SECONDS_%s", strings.ToUpper(string(actionType)))
+}
+
+func init() {
+	for _, actionType := range allSessionActionTypes {
+		allKeys = append(allKeys, configKey{
+			Name:     getActionWorkersKeyName(actionType),
+			Section:  "action_workers",
+			Key:      strings.ToLower(string(actionType)),
+			Kind:     uintValue,
+			Validate: positive,
+			Description: fmt.Sprintf("Maximum number of %s actions that can run at once, "+
+				"defaults to brick_manager.action_workers.", actionType),
+		})
+	}
+	for _, actionType := range allSessionActionTypes {
+		allKeys = append(allKeys, configKey{
+			Name:     getActionTimeoutKeyName(actionType),
+			Section:  "action_timeouts",
+			Key:      strings.ToLower(string(actionType)),
+			Kind:     uintValue,
+			Validate: positive,
+			Description: fmt.Sprintf("Seconds dacctl waits for %s actions, "+
+				"defaults to dacctl.action_timeout_seconds.", actionType),
+		})
+	}
+}
+
+func getKey(name string) configKey {
+	for _, key := range allKeys {
+		if key.Name == name {
+			return key
+		}
+	}
+	panic(fmt.Sprintf("unknown config key %s", name))
+}
+
+// Look for the value using the key name, then any aliases
+func lookupValue(env ReadEnvironemnt, key configKey) (string, bool) {
+	for _, name := range append([]string{key.Name}, key.Aliases...) {
+		if value, ok := env.LookupEnv(name); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+func checkValue(key configKey, value string) error {
+	var err error
+	switch key.Kind {
+	case uintValue:
+		if _, parseErr := strconv.ParseUint(value, 10, 32); parseErr != nil {
+			err = fmt.Errorf("must be a whole number")
+		}
+	case boolValue:
+		if _, parseErr := strconv.ParseBool(value); parseErr != nil {
+			err = fmt.Errorf("must be true or false")
+		}
+	}
+	if err == nil && key.Validate != nil {
+		err = key.Validate(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s.%s (%s) value '%s': %s", key.Section, key.Key, key.Name, value, err)
+	}
+	return nil
+}
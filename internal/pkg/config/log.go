@@ -1,5 +1,17 @@
 package config
 
 func GetDacctlLog() string {
-	return getString(DefaultEnv, "DACCTL_LOG", "/var/log/dacctl.log")
+	return getString(DefaultEnv, "DACCTL_LOG")
+}
+
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+func GetLogConfig(env ReadEnvironemnt) LogConfig {
+	return LogConfig{
+		Level:  getString(env, "DAC_LOG_LEVEL"),
+		Format: getString(env, "DAC_LOG_FORMAT"),
+	}
 }
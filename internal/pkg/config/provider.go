@@ -0,0 +1,64 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A setting in a filesystem provider's config section, <provider>_provider
+type ProviderKey struct {
+	Key         string
+	Default     string
+	Description string
+	Required    bool
+
+	// Extra checks on the value, can be nil
+	Validate func(value string) error
+}
+
+func getProviderSection(provider string) string {
+	return provider + "_provider"
+}
+
+// Environment variable used for a provider's setting, e.g. DAC_LOCAL_PROVIDER_ROOT_DIR
+func getProviderKeyName(provider string, key string) string {
+	return strings.ToUpper(fmt.Sprintf("DAC_%s_PROVIDER_%s", provider, key))
+}
+
+// Adds the settings for a provider, so they are validated and documented with every other key.
+// Must be called from an init function, before the config is checked
+func RegisterProviderKeys(provider string, keys []ProviderKey) {
+	if err := validName(provider); err != nil {
+		panic(fmt.Sprintf("invalid provider name %s: %s", provider, err))
+	}
+	section := getProviderSection(provider)
+	for _, key := range keys {
+		name := getProviderKeyName(provider, key.Key)
+		for _, existing := range allKeys {
+			if existing.Name == name || (existing.Section == section && existing.Key == key.Key) {
+				panic(fmt.Sprintf("duplicate config key %s.%s", section, key.Key))
+			}
+		}
+		allKeys = append(allKeys, configKey{
+			Name:        name,
+			Section:     section,
+			Key:         key.Key,
+			Default:     key.Default,
+			Description: key.Description,
+			Required:    key.Required,
+			Validate:    key.Validate,
+		})
+	}
+}
+
+// Values for every key in the provider's section, keyed by the config file key name
+func GetProviderSettings(env ReadEnvironemnt, provider string) map[string]string {
+	settings := make(map[string]string)
+	section := getProviderSection(provider)
+	for _, key := range allKeys {
+		if key.Section == section {
+			settings[key.Key] = getString(env, key.Name)
+		}
+	}
+	return settings
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+func getSections() []string {
+	var sections []string
+	seen := make(map[string]bool)
+	for _, key := range allKeys {
+		if !seen[key.Section] {
+			seen[key.Section] = true
+			sections = append(sections, key.Section)
+		}
+	}
+	return sections
+}
+
+func formatDefault(key configKey) string {
+	if key.Required {
+		return "required"
+	}
+	if key.Default == "" {
+		return ""
+	}
+	return fmt.Sprintf("`%s`", key.Default)
+}
+
+func formatKeys(key configKey) string {
+	keys := []string{fmt.Sprintf("`%s`", key.Key)}
+	for _, oldPath := range key.OldPaths {
+		keys = append(keys, fmt.Sprintf("`%s` (deprecated)", oldPath))
+	}
+	return strings.Join(keys, "<br>")
+}
+
+func formatEnvNames(key configKey) string {
+	names := []string{fmt.Sprintf("`%s`", key.Name)}
+	for _, alias := range key.Aliases {
+		names = append(names, fmt.Sprintf("`%s` (deprecated)", alias))
+	}
+	return strings.Join(names, "<br>")
+}
+
+// Markdown describing every config key, used to generate docs/configuration.md
+func GetReference() string {
+	var builder strings.Builder
+	builder.WriteString(`# Configuration reference
+
+This file is generated by running ` + "`dacd --config-reference`" + `, please do not edit it by hand.
+
+dacd and dacctl read their configuration from the YAML file ` + "`" + defaultConfigFilename + "`" + `,
+or the file named by the ` + "`DAC_CONFIG_FILE`" + ` environment variable.
+The file is optional, unless ` + "`DAC_CONFIG_FILE`" + ` is set.
+Every key can also be set using its environment variable,
+which takes precedence over the value in the file.
+
+The configuration is checked on startup, and any unknown key or invalid value stops the process.
+Run ` + "`dacd --check-config`" + ` to check the configuration without starting dacd.
+
+For example:
+
+` + "```yaml" + `
+brick_manager:
+  pool_name: default
+  brick_count: 12
+action_workers:
+  copydatain: 2
+keystore:
+  endpoints: 127.0.0.1:2379
+` + "```\n")
+
+	for _, section := range getSections() {
+		builder.WriteString(fmt.Sprintf("\n## %s\n\n", section))
+		builder.WriteString("| Key | Environment variable | Type | Default | Description |\n")
+		builder.WriteString("|-----|----------------------|------|---------|-------------|\n")
+		for _, key := range allKeys {
+			if key.Section != section {
+				continue
+			}
+			description := key.Description
+			if key.Cluster {
+				description += " Can be set for the whole cluster, see below."
+			}
+			builder.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s |\n",
+				formatKeys(key), formatEnvNames(key), key.Kind, formatDefault(key), description))
+		}
+	}
+
+	builder.WriteString(`
+## Cluster config
+
+Filesystem settings must be the same on every brick host, otherwise the shape of a filesystem
+depends on which host is primary. These can be stored in etcd, where they take precedence
+over each brick host's local config, e.g.:
+
+` + "```" + `
+dacctl admin config get
+dacctl admin config set --key max_mdt_count --value 8
+dacctl admin config set --key mdt_size_mb --value 40960 --pool fast
+dacctl admin config set --key mdt_size_mb --pool fast --unset
+` + "```" + `
+
+dacd watches for changes, and uses the latest settings when creating new buffers.
+Existing buffers keep the settings they were created with.
+
+## Filesystem providers
+
+Each pool uses the provider named by ` + "`filesystem.provider`" + `, which can differ between pools
+using the cluster config. Each provider reads its own settings from its ` + "`<provider>_provider`" + ` section.
+Buffers keep using the provider that created them.
+Providers built into dacd are listed above, sites can add their own as described in docs/install.md.
+`)
+	return builder.String()
+}
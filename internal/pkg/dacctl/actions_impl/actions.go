@@ -64,6 +64,14 @@ func (d *dacctlActions) DeleteBuffer(c dacctl.CliContext) error {
 	return d.session.DeleteSession(sessionName, hurry)
 }
 
+func (d *dacctlActions) CancelActions(c dacctl.CliContext) error {
+	sessionName, err := d.getSessionName(c)
+	if err != nil {
+		return err
+	}
+	return d.session.CancelSession(sessionName)
+}
+
 func (d *dacctlActions) DataIn(c dacctl.CliContext) error {
 	sessionName, err := d.getSessionName(c)
 	if err != nil {
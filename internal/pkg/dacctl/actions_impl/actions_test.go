@@ -68,6 +68,25 @@ func TestDacctlActions_DeleteBuffer(t *testing.T) {
 	assert.Equal(t, "Please provide these required parameters: token", err.Error())
 }
 
+func TestDacctlActions_CancelActions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+
+	fakeError := errors.New("fake")
+	session.EXPECT().CancelSession(datamodel.SessionName("bar")).Return(fakeError)
+
+	actions := dacctlActions{session: session}
+	err := actions.CancelActions(&mockCliContext{
+		strings: map[string]string{"token": "bar"},
+	})
+
+	assert.Equal(t, fakeError, err)
+
+	err = actions.CancelActions(&mockCliContext{})
+	assert.Equal(t, "Please provide these required parameters: token", err.Error())
+}
+
 func TestDacctlActions_DataIn(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -0,0 +1,23 @@
+package actions_impl
+
+import (
+	"encoding/json"
+	"log"
+)
+
+type capacityCheck struct {
+	Pool           string `json:"pool"`
+	RequestedBytes uint   `json:"requested_bytes"`
+	ActualBytes    uint   `json:"actual_bytes"`
+	Bricks         uint   `json:"bricks"`
+	FitsNow        bool   `json:"fits_now"`
+	FitsEmptyPool  bool   `json:"fits_empty_pool"`
+}
+
+func capacityCheckToString(check capacityCheck) string {
+	output, err := json.Marshal(check)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return string(output)
+}
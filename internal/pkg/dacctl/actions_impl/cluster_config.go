@@ -0,0 +1,95 @@
+package actions_impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"log"
+)
+
+type clusterConfig struct {
+	Revision     int64                        `json:"revision"`
+	Settings     map[string]string            `json:"settings"`
+	PoolSettings map[string]map[string]string `json:"pool_settings"`
+}
+
+func getClusterConfigAsString(source datamodel.ClusterConfig) string {
+	output := clusterConfig{
+		Revision:     source.Revision,
+		Settings:     source.Settings,
+		PoolSettings: make(map[string]map[string]string),
+	}
+	if output.Settings == nil {
+		output.Settings = make(map[string]string)
+	}
+	for poolName, settings := range source.PoolSettings {
+		output.PoolSettings[string(poolName)] = settings
+	}
+	raw, err := json.Marshal(output)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return string(raw)
+}
+
+func (d *dacctlActions) GetClusterConfig() (string, error) {
+	clusterConfig, err := d.session.GetClusterConfig()
+	if err != nil {
+		return "", err
+	}
+	return getClusterConfigAsString(clusterConfig), nil
+}
+
+func (d *dacctlActions) SetClusterConfig(c dacctl.CliContext) error {
+	if err := checkRequiredStrings(c, "key"); err != nil {
+		return err
+	}
+	key := c.String("key")
+	value := c.String("value")
+	poolName := datamodel.PoolName(c.String("pool"))
+	unset := c.Bool("unset")
+
+	// allow any key to be removed, in case it is no longer supported
+	if !unset {
+		if err := config.CheckClusterSetting(key, value); err != nil {
+			return err
+		}
+	}
+	if poolName != "" && !parsers.IsValidName(string(poolName)) {
+		return fmt.Errorf("badly formatted pool name: %s", poolName)
+	}
+
+	clusterConfig, err := d.session.GetClusterConfig()
+	if err != nil {
+		return err
+	}
+	if clusterConfig.Settings == nil {
+		clusterConfig.Settings = make(map[string]string)
+	}
+	if clusterConfig.PoolSettings == nil {
+		clusterConfig.PoolSettings = make(map[datamodel.PoolName]map[string]string)
+	}
+	settings := clusterConfig.Settings
+	if poolName != "" {
+		settings = clusterConfig.PoolSettings[poolName]
+		if settings == nil {
+			settings = make(map[string]string)
+			clusterConfig.PoolSettings[poolName] = settings
+		}
+	}
+
+	if unset {
+		delete(settings, key)
+	} else {
+		settings[key] = value
+	}
+	if poolName != "" && len(settings) == 0 {
+		delete(clusterConfig.PoolSettings, poolName)
+	}
+
+	_, err = d.session.UpdateClusterConfig(clusterConfig)
+	return err
+}
@@ -0,0 +1,70 @@
+package actions_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_facade"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDacctlActions_GetClusterConfig(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	session.EXPECT().GetClusterConfig().Return(datamodel.ClusterConfig{}, nil)
+
+	actions := dacctlActions{session: session}
+	output, err := actions.GetClusterConfig()
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"revision":0,"settings":{},"pool_settings":{}}`, output)
+}
+
+func TestDacctlActions_SetClusterConfig(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	actions := dacctlActions{session: session}
+
+	session.EXPECT().GetClusterConfig().Return(datamodel.ClusterConfig{
+		Revision: 3,
+		Settings: map[string]string{"lnet_suffix": "-opa"},
+	}, nil)
+	session.EXPECT().UpdateClusterConfig(datamodel.ClusterConfig{
+		Revision:     3,
+		Settings:     map[string]string{"lnet_suffix": "-opa"},
+		PoolSettings: map[datamodel.PoolName]map[string]string{"fast": {"max_mdt_count": "8"}},
+	})
+	err := actions.SetClusterConfig(&mockCliContext{
+		strings: map[string]string{"key": "max_mdt_count", "value": "8", "pool": "fast"},
+	})
+	assert.Nil(t, err)
+
+	session.EXPECT().GetClusterConfig().Return(datamodel.ClusterConfig{
+		Revision:     4,
+		Settings:     map[string]string{"lnet_suffix": "-opa"},
+		PoolSettings: map[datamodel.PoolName]map[string]string{"fast": {"max_mdt_count": "8"}},
+	}, nil)
+	session.EXPECT().UpdateClusterConfig(datamodel.ClusterConfig{
+		Revision:     4,
+		Settings:     map[string]string{},
+		PoolSettings: map[datamodel.PoolName]map[string]string{"fast": {"max_mdt_count": "8"}},
+	})
+	err = actions.SetClusterConfig(&mockCliContext{
+		strings:  map[string]string{"key": "lnet_suffix"},
+		booleans: map[string]bool{"unset": true},
+	})
+	assert.Nil(t, err)
+
+	err = actions.SetClusterConfig(&mockCliContext{strings: map[string]string{"key": "max_mdt_count", "value": "0"}})
+	assert.Equal(t, "invalid filesystem.max_mdt_count (DAC_MAX_MDT_COUNT) value '0': must be at least one",
+		err.Error())
+
+	err = actions.SetClusterConfig(&mockCliContext{strings: map[string]string{"key": "skip_ansible", "value": "true"}})
+	assert.Equal(t, "unknown cluster config key skip_ansible, must be one of: "+
+		"provider, type, mgs_device, max_mdt_count, lnet_suffix, mdt_size_gb, mdt_size_mb, wipe_policy", err.Error())
+
+	err = actions.SetClusterConfig(&mockCliContext{})
+	assert.Equal(t, "Please provide these required parameters: key", err.Error())
+}
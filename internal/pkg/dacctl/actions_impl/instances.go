@@ -2,7 +2,7 @@ package actions_impl
 
 import (
 	"encoding/json"
-	"github.com/prometheus/common/log"
+	"log"
 )
 
 type instanceCapacity struct {
@@ -14,10 +14,19 @@ type instanceLinks struct {
 	Session string `json:"session"`
 }
 
+type instancePreemption struct {
+	PreemptedBy string `json:"preempted_by"`
+	PreemptedAt uint   `json:"preempted_at"`
+	Reason      string `json:"reason"`
+}
+
 type instance struct {
-	Id       string           `json:"id"`
-	Capacity instanceCapacity `json:"capacity"`
-	Links    instanceLinks    `json:"links"`
+	Id         string              `json:"id"`
+	Capacity   instanceCapacity    `json:"capacity"`
+	Links      instanceLinks       `json:"links"`
+	Preemption *instancePreemption `json:"preemption,omitempty"`
+	// Failed attempts the most recent action retried, if any
+	FailedAttempts []string `json:"failed_attempts,omitempty"`
 }
 
 type instances []instance
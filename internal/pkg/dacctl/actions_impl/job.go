@@ -23,9 +23,71 @@ func (d *dacctlActions) ValidateJob(c dacctl.CliContext) error {
 		// TODO check valid pools, etc, etc.
 		log.Println("Summary of job file:", summary)
 	}
+
+	if summary.PerJobBuffer != nil && summary.PerJobBuffer.CapacityBytes > 0 {
+		return d.checkCapacityCanEverFit(summary.PerJobBuffer.PoolName, summary.PerJobBuffer.CapacityBytes)
+	}
 	return nil
 }
 
+// Reject requests now, rather than leaving Slurm waiting forever for capacity
+func (d *dacctlActions) checkCapacityCanEverFit(poolName string, capacityBytes int) error {
+	var poolNames []datamodel.PoolName
+	if poolName != "" {
+		if !parsers2.IsValidName(poolName) {
+			return fmt.Errorf("badly formatted pool name: %s", poolName)
+		}
+		poolNames = append(poolNames, datamodel.PoolName(poolName))
+	} else {
+		// Pool is picked later by Slurm, so check it fits at least one pool
+		pools, err := d.session.GetPools()
+		if err != nil {
+			return err
+		}
+		for _, pool := range pools {
+			poolNames = append(poolNames, pool.Pool.Name)
+		}
+	}
+
+	for _, name := range poolNames {
+		check, err := d.session.CheckCapacity(name, capacityBytes)
+		if err != nil {
+			return err
+		}
+		if check.FitsEmptyPool {
+			return nil
+		}
+	}
+	return fmt.Errorf("requested capacity of %d bytes can never fit in pools: %s", capacityBytes, poolNames)
+}
+
+func (d *dacctlActions) CheckCapacity(c dacctl.CliContext) (string, error) {
+	err := checkRequiredStrings(c, "capacity")
+	if err != nil {
+		return "", err
+	}
+	pool, capacityBytes, err := parsers2.ParseCapacityBytes(c.String("capacity"))
+	if err != nil {
+		return "", err
+	}
+	if !parsers2.IsValidName(pool) {
+		return "", fmt.Errorf("badly formatted pool name: %s", pool)
+	}
+
+	check, err := d.session.CheckCapacity(datamodel.PoolName(pool), capacityBytes)
+	if err != nil {
+		return "", err
+	}
+	return capacityCheckToString(capacityCheck{
+		Pool:           string(check.PoolName),
+		RequestedBytes: uint(check.RequestedBytes),
+		ActualBytes:    uint(check.ActualSizeBytes),
+		Bricks:         uint(check.BricksRequired),
+		FitsNow:        check.FitsNow,
+		FitsEmptyPool:  check.FitsEmptyPool,
+	}), nil
+}
+
 func (d *dacctlActions) CreatePerJobBuffer(c dacctl.CliContext) error {
 	checkRequiredStrings(c, "token", "job", "caller", "capacity")
 	// TODO: need to specify user and group too
@@ -46,6 +108,10 @@ func (d *dacctlActions) CreatePerJobBuffer(c dacctl.CliContext) error {
 	if err != nil {
 		return err
 	}
+	priority, err := priorityFromString(c.String("priority"))
+	if err != nil {
+		return err
+	}
 
 	// extract info from job file
 	swapBytes := 0
@@ -54,15 +120,26 @@ func (d *dacctlActions) CreatePerJobBuffer(c dacctl.CliContext) error {
 	}
 	access := datamodel.NoAccess
 	bufferType := datamodel.Scratch
+	var fsType datamodel.FilesystemType
 	if summary.PerJobBuffer != nil {
 		access = summary.PerJobBuffer.AccessMode
-		if summary.PerJobBuffer.BufferType != datamodel.Scratch {
-			return fmt.Errorf("cache is not supported")
-		}
+		fsType = summary.PerJobBuffer.FilesystemType
+		// The parser adds the copies that fill and write back the cache
+		bufferType = summary.PerJobBuffer.BufferType
 	}
 	var multiJobVolumes []datamodel.SessionName
+	multiJobAccess := make(map[datamodel.SessionName]datamodel.AccessMode)
 	for _, attachment := range summary.Attachments {
+		if !parsers2.IsValidName(string(attachment)) {
+			return fmt.Errorf("invalid persistent buffer name: %s", attachment)
+		}
+		// The paths depend on how the persistent buffer was created
+		multiJobSession, err := d.session.GetSession(attachment)
+		if err != nil {
+			return fmt.Errorf("unable to find persistent buffer %s due to: %s", attachment, err)
+		}
 		multiJobVolumes = append(multiJobVolumes, attachment)
+		multiJobAccess[attachment] = multiJobSession.VolumeRequest.Access
 	}
 
 	request := datamodel.VolumeRequest{
@@ -73,6 +150,8 @@ func (d *dacctlActions) CreatePerJobBuffer(c dacctl.CliContext) error {
 		Access:             access,
 		Type:               bufferType,
 		SwapBytes:          swapBytes,
+		Priority:           priority,
+		FilesystemType:     fsType,
 	}
 	// TODO: must be a better way!
 	// ensure multi job volumes are sorted, to avoid deadlocks (*cough*)
@@ -89,11 +168,11 @@ func (d *dacctlActions) CreatePerJobBuffer(c dacctl.CliContext) error {
 		StageInRequests:     summary.DataIn,
 		StageOutRequests:    summary.DataOut,
 	}
-	session.Paths = getPaths(session)
+	session.Paths = getPaths(session, multiJobAccess)
 	return d.session.CreateSession(session)
 }
 
-func getPaths(session datamodel.Session) map[string]string {
+func getPaths(session datamodel.Session, multiJobAccess map[datamodel.SessionName]datamodel.AccessMode) map[string]string {
 	paths := make(map[string]string)
 	if session.VolumeRequest.MultiJob == false {
 		if session.VolumeRequest.Access == datamodel.Private || session.VolumeRequest.Access == datamodel.PrivateAndStriped {
@@ -105,9 +184,16 @@ func getPaths(session datamodel.Session) map[string]string {
 		}
 	}
 	for _, multiJobVolume := range session.MultiJobAttachments {
-		multiJobBase := fmt.Sprintf(datamodel.MountMultiJobBasePattern, session.Name, multiJobVolume)
-		paths[fmt.Sprintf("DW_PERSISTENT_STRIPED_%s", multiJobVolume)] = fmt.Sprintf(
-			"%s/%s", multiJobBase, datamodel.MountGlobalDir)
+		access := multiJobAccess[multiJobVolume]
+		if access != datamodel.Private {
+			multiJobBase := fmt.Sprintf(datamodel.MountMultiJobBasePattern, session.Name, multiJobVolume)
+			paths[fmt.Sprintf("DW_PERSISTENT_STRIPED_%s", multiJobVolume)] = fmt.Sprintf(
+				"%s/%s", multiJobBase, datamodel.MountGlobalDir)
+		}
+		if access == datamodel.Private || access == datamodel.PrivateAndStriped {
+			paths[fmt.Sprintf("DW_PERSISTENT_PRIVATE_%s", multiJobVolume)] = fmt.Sprintf(
+				datamodel.MountMultiJobPrivatePattern, session.Name, multiJobVolume)
+		}
 	}
 	return paths
 }
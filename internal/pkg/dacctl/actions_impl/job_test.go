@@ -1,6 +1,7 @@
 package actions_impl
 
 import (
+	"errors"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_facade"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_fileio"
@@ -37,7 +38,7 @@ func TestDacctlActions_CreatePerJobBuffer(t *testing.T) {
 	disk := mock_fileio.NewMockDisk(mockCtrl)
 
 	lines := []string{
-		`#DW jobdw capacity=4MiB access_mode=striped,private type=scratch`,
+		`#DW jobdw capacity=4MiB access_mode=striped,private type=scratch fs=beegfs`,
 		`#DW persistentdw name=myBBname2`,
 		`#DW persistentdw name=myBBname1`,
 		`#DW swap 4MiB`,
@@ -46,6 +47,10 @@ func TestDacctlActions_CreatePerJobBuffer(t *testing.T) {
 		`#DW stage_out source=$DW_JOB_STRIPED/outdir destination=/global/scratch1/outdir type=directory`,
 	}
 	disk.EXPECT().Lines("jobfile").Return(lines, nil)
+	session.EXPECT().GetSession(datamodel.SessionName("myBBname2")).Return(datamodel.Session{
+		VolumeRequest: datamodel.VolumeRequest{MultiJob: true, Access: datamodel.Striped}}, nil)
+	session.EXPECT().GetSession(datamodel.SessionName("myBBname1")).Return(datamodel.Session{
+		VolumeRequest: datamodel.VolumeRequest{MultiJob: true, Access: datamodel.PrivateAndStriped}}, nil)
 	session.EXPECT().CreateSession(datamodel.Session{
 		Name:                "token",
 		Owner:               1001,
@@ -77,10 +82,12 @@ func TestDacctlActions_CreatePerJobBuffer(t *testing.T) {
 			Access:             datamodel.PrivateAndStriped,
 			Type:               datamodel.Scratch,
 			SwapBytes:          4194304,
+			FilesystemType:     datamodel.BeeGFSFilesystem,
 		},
 		Paths: map[string]string{
 			"DW_JOB_PRIVATE":                  "/mnt/dac/token_job_private",
 			"DW_JOB_STRIPED":                  "/mnt/dac/token_job/global",
+			"DW_PERSISTENT_PRIVATE_myBBname1": "/mnt/dac/token_persistent_myBBname1_private",
 			"DW_PERSISTENT_STRIPED_myBBname1": "/mnt/dac/token_persistent_myBBname1/global",
 			"DW_PERSISTENT_STRIPED_myBBname2": "/mnt/dac/token_persistent_myBBname2/global",
 		},
@@ -92,3 +99,129 @@ func TestDacctlActions_CreatePerJobBuffer(t *testing.T) {
 
 	assert.Nil(t, err)
 }
+
+func TestDacctlActions_CreatePerJobBuffer_PersistentPrivate(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	disk := mock_fileio.NewMockDisk(mockCtrl)
+
+	lines := []string{`#DW persistentdw name=myBBname1`}
+	disk.EXPECT().Lines("jobfile").Return(lines, nil)
+	session.EXPECT().GetSession(datamodel.SessionName("myBBname1")).Return(datamodel.Session{
+		VolumeRequest: datamodel.VolumeRequest{MultiJob: true, Access: datamodel.Private}}, nil)
+	session.EXPECT().CreateSession(gomock.Any()).DoAndReturn(func(s datamodel.Session) error {
+		assert.Equal(t, map[string]string{
+			"DW_PERSISTENT_PRIVATE_myBBname1": "/mnt/dac/token_persistent_myBBname1_private",
+		}, s.Paths)
+		return nil
+	})
+
+	actions := dacctlActions{session: session, disk: disk}
+	err := actions.CreatePerJobBuffer(getMockCliContext(2))
+	assert.Nil(t, err)
+
+	disk.EXPECT().Lines("jobfile").Return(lines, nil)
+	session.EXPECT().GetSession(datamodel.SessionName("myBBname1")).Return(
+		datamodel.Session{}, errors.New("not found"))
+	err = actions.CreatePerJobBuffer(getMockCliContext(2))
+	assert.Equal(t, "unable to find persistent buffer myBBname1 due to: not found", err.Error())
+}
+
+func TestDacctlActions_CreatePerJobBuffer_Cache(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	disk := mock_fileio.NewMockDisk(mockCtrl)
+
+	lines := []string{`#DW jobdw capacity=4MiB type=cache pfs=/global/scratch1/john`}
+	disk.EXPECT().Lines("jobfile").Return(lines, nil)
+	session.EXPECT().CreateSession(datamodel.Session{
+		Name:      "token",
+		Owner:     1001,
+		Group:     1002,
+		CreatedAt: 123,
+		StageInRequests: []datamodel.DataCopyRequest{
+			{
+				SourceType:  datamodel.Directory,
+				Source:      "/global/scratch1/john/",
+				Destination: "$DW_JOB_STRIPED/",
+			},
+		},
+		StageOutRequests: []datamodel.DataCopyRequest{
+			{
+				SourceType:  datamodel.Directory,
+				Source:      "$DW_JOB_STRIPED/",
+				Destination: "/global/scratch1/john/",
+			},
+		},
+		VolumeRequest: datamodel.VolumeRequest{
+			Caller:             "caller",
+			PoolName:           "pool1",
+			TotalCapacityBytes: 2147483648,
+			Access:             datamodel.Striped,
+			Type:               datamodel.Cache,
+		},
+		Paths: map[string]string{
+			"DW_JOB_STRIPED": "/mnt/dac/token_job/global",
+		},
+	}).Return(nil)
+
+	fakeTime = 123
+	actions := dacctlActions{session: session, disk: disk}
+	err := actions.CreatePerJobBuffer(getMockCliContext(2))
+
+	assert.Nil(t, err)
+}
+
+func TestDacctlActions_ValidateJob_CapacityNeverFits(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	disk := mock_fileio.NewMockDisk(mockCtrl)
+
+	disk.EXPECT().Lines("jobfile").Return([]string{`#DW jobdw capacity=4TiB`}, nil)
+	session.EXPECT().GetPools().Return([]datamodel.PoolInfo{
+		{Pool: datamodel.Pool{Name: "pool1"}},
+		{Pool: datamodel.Pool{Name: "pool2"}},
+	}, nil)
+	session.EXPECT().CheckCapacity(datamodel.PoolName("pool1"), 4398046511104).Return(
+		datamodel.PoolCapacityCheck{FitsEmptyPool: false}, nil)
+	session.EXPECT().CheckCapacity(datamodel.PoolName("pool2"), 4398046511104).Return(
+		datamodel.PoolCapacityCheck{FitsEmptyPool: false}, nil)
+
+	actions := dacctlActions{session: session, disk: disk}
+	err := actions.ValidateJob(&mockCliContext{strings: map[string]string{"job": "jobfile"}})
+	assert.Equal(t, "requested capacity of 4398046511104 bytes can never fit in pools: [pool1 pool2]", err.Error())
+
+	disk.EXPECT().Lines("jobfile").Return([]string{`#DW jobdw capacity=4TiB pool=pool2`}, nil)
+	session.EXPECT().CheckCapacity(datamodel.PoolName("pool2"), 4398046511104).Return(
+		datamodel.PoolCapacityCheck{FitsEmptyPool: true}, nil)
+
+	err = actions.ValidateJob(&mockCliContext{strings: map[string]string{"job": "jobfile"}})
+	assert.Nil(t, err)
+}
+
+func TestDacctlActions_CheckCapacity(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+
+	session.EXPECT().CheckCapacity(datamodel.PoolName("pool1"), 1073741824).Return(datamodel.PoolCapacityCheck{
+		PoolName:        "pool1",
+		RequestedBytes:  1073741824,
+		ActualSizeBytes: 2147483648,
+		BricksRequired:  1,
+		FitsNow:         false,
+		FitsEmptyPool:   true,
+	}, nil)
+
+	actions := dacctlActions{session: session}
+	output, err := actions.CheckCapacity(&mockCliContext{strings: map[string]string{"capacity": "pool1:1GiB"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"pool":"pool1","requested_bytes":1073741824,"actual_bytes":2147483648,"bricks":1,"fits_now":false,"fits_empty_pool":true}`, output)
+
+	_, err = actions.CheckCapacity(&mockCliContext{})
+	assert.Equal(t, "Please provide these required parameters: capacity", err.Error())
+}
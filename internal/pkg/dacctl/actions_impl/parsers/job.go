@@ -5,6 +5,7 @@ import (
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/fileio"
 	"log"
+	"path"
 	"strings"
 )
 
@@ -63,9 +64,48 @@ func getJobSummary(lines []string) (jobSummary, error) {
 			})
 		}
 	}
+	if summary.PerJobBuffer != nil {
+		if err := addCacheCopyRequests(&summary); err != nil {
+			return jobSummary{}, err
+		}
+	}
 	return summary, nil
 }
 
+// A cache buffer is filled from its pfs path before the job starts,
+// then rsync only writes back files that are newer in the buffer
+func addCacheCopyRequests(summary *jobSummary) error {
+	buffer := summary.PerJobBuffer
+	if buffer.BufferType != datamodel.Cache {
+		if buffer.CachePath != "" {
+			return fmt.Errorf("pfs is only supported for type=cache")
+		}
+		return nil
+	}
+	if !path.IsAbs(buffer.CachePath) || !IsValidPath(buffer.CachePath) {
+		return fmt.Errorf("type=cache needs an absolute pfs path, not: %s", buffer.CachePath)
+	}
+	if buffer.AccessMode != datamodel.Striped && buffer.AccessMode != datamodel.PrivateAndStriped {
+		return fmt.Errorf("type=cache needs striped access")
+	}
+
+	pfsDir := strings.TrimSuffix(buffer.CachePath, "/") + "/"
+	stripedDir := "$DW_JOB_STRIPED/"
+	prefetch := datamodel.DataCopyRequest{
+		SourceType:  datamodel.Directory,
+		Source:      pfsDir,
+		Destination: stripedDir,
+	}
+	writeBack := datamodel.DataCopyRequest{
+		SourceType:  datamodel.Directory,
+		Source:      stripedDir,
+		Destination: pfsDir,
+	}
+	summary.DataIn = append([]datamodel.DataCopyRequest{prefetch}, summary.DataIn...)
+	summary.DataOut = append(summary.DataOut, writeBack)
+	return nil
+}
+
 type jobCommand interface{}
 
 var stringToAccessMode = map[string]datamodel.AccessMode{
@@ -86,6 +126,21 @@ var stringToBufferType = map[string]datamodel.BufferType{
 	"cache":   datamodel.Cache,
 }
 
+// Empty means use the pool's filesystem type
+var stringToFilesystemType = map[string]datamodel.FilesystemType{
+	"":       "",
+	"lustre": datamodel.LustreFilesystem,
+	"beegfs": datamodel.BeeGFSFilesystem,
+}
+
+func filesystemTypeFromString(raw string) (datamodel.FilesystemType, error) {
+	fsType, ok := stringToFilesystemType[strings.ToLower(raw)]
+	if !ok {
+		return "", fmt.Errorf("unsupported filesystem type: %s", raw)
+	}
+	return fsType, nil
+}
+
 type cmdCreatePersistent struct {
 	Name          string
 	CapacityBytes int
@@ -104,9 +159,15 @@ type cmdAttachPersistent string
 
 type cmdPerJobBuffer struct {
 	CapacityBytes int
-	AccessMode    datamodel.AccessMode
-	BufferType    datamodel.BufferType
-	GenericCmd    bool
+	// Optional, when empty the pool comes from Slurm's capacity request
+	PoolName   string
+	AccessMode datamodel.AccessMode
+	BufferType datamodel.BufferType
+	// Optional, when empty the pool's filesystem type is used
+	FilesystemType datamodel.FilesystemType
+	GenericCmd     bool
+	// Parallel filesystem path that backs a cache buffer
+	CachePath string
 }
 
 type cmdAttachPerJobSwap struct {
@@ -200,11 +261,18 @@ func parseJobRequest(lines []string) ([]jobCommand, error) {
 				log.Println(err)
 				return nil, err
 			}
+			fsType, err := filesystemTypeFromString(argKeyPair["fs"])
+			if err != nil {
+				return nil, err
+			}
 			command = cmdPerJobBuffer{
-				CapacityBytes: size,
-				GenericCmd:    isGeneric,
-				AccessMode:    accessModeFromString(argKeyPair["access_mode"]),
-				BufferType:    bufferTypeFromString(argKeyPair["type"]),
+				CapacityBytes:  size,
+				PoolName:       argKeyPair["pool"],
+				GenericCmd:     isGeneric,
+				AccessMode:     accessModeFromString(argKeyPair["access_mode"]),
+				BufferType:     bufferTypeFromString(argKeyPair["type"]),
+				FilesystemType: fsType,
+				CachePath:      argKeyPair["pfs"],
 			}
 		case "swap":
 			if len(args) != 1 {
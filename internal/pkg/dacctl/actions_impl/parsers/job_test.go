@@ -82,9 +82,48 @@ func TestGetJobSummary(t *testing.T) {
 	assert.Equal(t, datamodel.SessionName("myBBname2"), result.Attachments[1])
 
 	assert.Equal(t, 4194304, result.PerJobBuffer.CapacityBytes)
+	assert.Equal(t, "", result.PerJobBuffer.PoolName)
 	assert.Equal(t, 4000000, result.Swap.SizeBytes)
 }
 
+func TestGetJobSummary_Cache(t *testing.T) {
+	lines := []string{
+		`#BB jobdw capacity=4MiB type=cache pfs=/global/scratch1/john/`,
+		`#DW stage_in source=/global/cscratch1/filename1 destination=$DW_JOB_STRIPED/filename1 type=file`,
+		`#DW stage_out source=$DW_JOB_STRIPED/outdir destination=/global/scratch1/outdir type=directory`,
+	}
+	result, err := getJobSummary(lines)
+
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.Cache, result.PerJobBuffer.BufferType)
+	assert.Equal(t, "/global/scratch1/john/", result.PerJobBuffer.CachePath)
+	assert.Equal(t, []datamodel.DataCopyRequest{
+		{SourceType: datamodel.Directory, Source: "/global/scratch1/john/", Destination: "$DW_JOB_STRIPED/"},
+		{SourceType: datamodel.File, Source: "/global/cscratch1/filename1", Destination: "$DW_JOB_STRIPED/filename1"},
+	}, result.DataIn)
+	assert.Equal(t, []datamodel.DataCopyRequest{
+		{SourceType: datamodel.Directory, Source: "$DW_JOB_STRIPED/outdir", Destination: "/global/scratch1/outdir"},
+		{SourceType: datamodel.Directory, Source: "$DW_JOB_STRIPED/", Destination: "/global/scratch1/john/"},
+	}, result.DataOut)
+
+	lines = []string{`#DW jobdw capacity=4MiB type=cache`}
+	result, err = getJobSummary(lines)
+	assert.Equal(t, "type=cache needs an absolute pfs path, not: ", err.Error())
+	assert.Nil(t, result.PerJobBuffer)
+
+	lines = []string{`#DW jobdw capacity=4MiB type=cache pfs=global/john`}
+	result, err = getJobSummary(lines)
+	assert.Equal(t, "type=cache needs an absolute pfs path, not: global/john", err.Error())
+
+	lines = []string{`#DW jobdw capacity=4MiB type=cache access_mode=private pfs=/global/john`}
+	result, err = getJobSummary(lines)
+	assert.Equal(t, "type=cache needs striped access", err.Error())
+
+	lines = []string{`#DW jobdw capacity=4MiB type=scratch pfs=/global/john`}
+	result, err = getJobSummary(lines)
+	assert.Equal(t, "pfs is only supported for type=cache", err.Error())
+}
+
 func TestGetJobSummary_Errors(t *testing.T) {
 	lines := []string{`#DW bad_command asdf=asdf`}
 	result, err := getJobSummary(lines)
@@ -96,6 +135,22 @@ func TestGetJobSummary_Errors(t *testing.T) {
 	assert.Equal(t, "unable to parse swap command: #DW swap 1B asdf", err.Error())
 	assert.Nil(t, result.PerJobBuffer)
 
+	lines = []string{`#DW jobdw capacity=4MiB pool=fast`}
+	result, err = getJobSummary(lines)
+	assert.Nil(t, err)
+	assert.Equal(t, "fast", result.PerJobBuffer.PoolName)
+	assert.Equal(t, datamodel.FilesystemType(""), result.PerJobBuffer.FilesystemType)
+
+	lines = []string{`#DW jobdw capacity=4MiB fs=BeeGFS`}
+	result, err = getJobSummary(lines)
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.BeeGFSFilesystem, result.PerJobBuffer.FilesystemType)
+
+	lines = []string{`#DW jobdw capacity=4MiB fs=gpfs`}
+	result, err = getJobSummary(lines)
+	assert.Equal(t, "unsupported filesystem type: gpfs", err.Error())
+	assert.Nil(t, result.PerJobBuffer)
+
 	lines = []string{`#DW swap 1B`}
 	result, err = getJobSummary(lines)
 	assert.Equal(t, "unable to parse size: 1B", err.Error())
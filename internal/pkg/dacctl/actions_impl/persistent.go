@@ -1,6 +1,8 @@
 package actions_impl
 
 import (
+	"errors"
+	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl"
 	parsers2 "github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
@@ -30,6 +32,21 @@ func bufferTypeFromString(raw string) datamodel.BufferType {
 	return stringToBufferType[strings.ToLower(raw)]
 }
 
+var stringToPriority = map[string]datamodel.PriorityClass{
+	"":       datamodel.NormalPriority,
+	"low":    datamodel.LowPriority,
+	"normal": datamodel.NormalPriority,
+	"high":   datamodel.HighPriority,
+}
+
+func priorityFromString(raw string) (datamodel.PriorityClass, error) {
+	priority, ok := stringToPriority[strings.ToLower(raw)]
+	if !ok {
+		return datamodel.NormalPriority, fmt.Errorf("unrecognised priority: %s", raw)
+	}
+	return priority, nil
+}
+
 var fakeTime uint = 0
 
 func getNow() uint {
@@ -48,6 +65,10 @@ func (d *dacctlActions) CreatePersistentBuffer(c dacctl.CliContext) error {
 	if err != nil {
 		return err
 	}
+	priority, err := priorityFromString(c.String("priority"))
+	if err != nil {
+		return err
+	}
 	request := datamodel.VolumeRequest{
 		MultiJob:           true,
 		Caller:             c.String("caller"),
@@ -55,6 +76,7 @@ func (d *dacctlActions) CreatePersistentBuffer(c dacctl.CliContext) error {
 		PoolName:           datamodel.PoolName(pool),
 		Access:             accessModeFromString(c.String("access")),
 		Type:               bufferTypeFromString(c.String("type")),
+		Priority:           priority,
 	}
 	session := datamodel.Session{
 		Name:          datamodel.SessionName(c.String("token")),
@@ -65,3 +87,22 @@ func (d *dacctlActions) CreatePersistentBuffer(c dacctl.CliContext) error {
 	}
 	return d.session.CreateSession(session)
 }
+
+func (d *dacctlActions) ExpandPersistentBuffer(c dacctl.CliContext) error {
+	err := checkRequiredStrings(c, "token", "capacity")
+	if err != nil {
+		return err
+	}
+	sessionName, err := d.getSessionName(c)
+	if err != nil {
+		return err
+	}
+	pool, extraCapacityBytes, err := parsers2.ParseCapacityBytes(c.String("capacity"))
+	if err != nil {
+		return err
+	}
+	if extraCapacityBytes <= 0 {
+		return errors.New("capacity increase must be greater than zero")
+	}
+	return d.session.ExpandSession(sessionName, datamodel.PoolName(pool), extraCapacityBytes)
+}
@@ -1,6 +1,7 @@
 package actions_impl
 
 import (
+	"errors"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_facade"
 	"github.com/golang/mock/gomock"
@@ -32,3 +33,57 @@ func TestDacctlActions_CreatePersistentBuffer(t *testing.T) {
 
 	assert.Nil(t, err)
 }
+
+func TestDacctlActions_CreatePersistentBuffer_Priority(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+
+	session.EXPECT().CreateSession(datamodel.Session{
+		Name:      "token",
+		Owner:     1001,
+		Group:     1002,
+		CreatedAt: 123,
+		VolumeRequest: datamodel.VolumeRequest{
+			MultiJob:           true,
+			Caller:             "caller",
+			PoolName:           "pool1",
+			TotalCapacityBytes: 2147483648,
+			Priority:           datamodel.HighPriority,
+		},
+	}).Return(nil)
+	fakeTime = 123
+
+	actions := dacctlActions{session: session}
+	context := getMockCliContext(2)
+	context.strings["priority"] = "High"
+	err := actions.CreatePersistentBuffer(context)
+	assert.Nil(t, err)
+
+	context.strings["priority"] = "urgent"
+	err = actions.CreatePersistentBuffer(context)
+	assert.Equal(t, "unrecognised priority: urgent", err.Error())
+}
+
+func TestDacctlActions_ExpandPersistentBuffer(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+
+	fakeErr := errors.New("fake")
+	session.EXPECT().ExpandSession(datamodel.SessionName("bar"), datamodel.PoolName("pool1"), 2147483648).Return(fakeErr)
+
+	actions := dacctlActions{session: session}
+	err := actions.ExpandPersistentBuffer(&mockCliContext{
+		strings: map[string]string{"token": "bar", "capacity": "pool1:+2GiB"},
+	})
+	assert.Equal(t, fakeErr, err)
+
+	err = actions.ExpandPersistentBuffer(&mockCliContext{})
+	assert.Equal(t, "Please provide these required parameters: token, capacity", err.Error())
+
+	err = actions.ExpandPersistentBuffer(&mockCliContext{
+		strings: map[string]string{"token": "bar", "capacity": "pool1:0"},
+	})
+	assert.Equal(t, "capacity increase must be greater than zero", err.Error())
+}
@@ -2,6 +2,7 @@ package actions_impl
 
 import (
 	"encoding/json"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"log"
 )
 
@@ -23,3 +24,48 @@ func getPoolsAsString(list pools) string {
 	}
 	return string(output)
 }
+
+type poolReport struct {
+	Id                  string         `json:"id"`
+	Granularity         uint           `json:"granularity"`
+	FreeBricksByHost    map[string]int `json:"free_bricks_by_host"`
+	LargestBufferBytes  map[string]int `json:"largest_buffer_bytes"`
+	RequestedBytes      int            `json:"requested_bytes"`
+	AllocatedBytes      int            `json:"allocated_bytes"`
+	RoundingWasteBytes  int            `json:"rounding_waste_bytes"`
+	DeadHostBricks      int            `json:"dead_host_bricks"`
+	DisabledHostBricks  int            `json:"disabled_host_bricks"`
+	AllocationAgeCounts map[string]int `json:"allocation_age_counts"`
+}
+
+func getPoolReportsAsString(reports []datamodel.PoolReport) string {
+	list := []poolReport{}
+	for _, report := range reports {
+		freeBricks := make(map[string]int)
+		for host, free := range report.FreeBricksByHost {
+			freeBricks[string(host)] = free
+		}
+		largestBuffer := make(map[string]int)
+		for policy, bytes := range report.LargestBufferBytes {
+			largestBuffer[string(policy)] = bytes
+		}
+		list = append(list, poolReport{
+			Id:                  string(report.Pool.Name),
+			Granularity:         report.Pool.GranularityBytes,
+			FreeBricksByHost:    freeBricks,
+			LargestBufferBytes:  largestBuffer,
+			RequestedBytes:      report.RequestedBytes,
+			AllocatedBytes:      report.AllocatedBytes,
+			RoundingWasteBytes:  report.AllocatedBytes - report.RequestedBytes,
+			DeadHostBricks:      report.DeadHostBricks,
+			DisabledHostBricks:  report.DisabledHostBricks,
+			AllocationAgeCounts: report.AllocationAges,
+		})
+	}
+	message := map[string][]poolReport{"pools": list}
+	output, err := json.Marshal(message)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return string(output)
+}
@@ -50,11 +50,22 @@ func (d *dacctlActions) ShowInstances() (string, error) {
 
 	instances := instances{}
 	for _, session := range allSessions {
-		instances = append(instances, instance{
+		instance := instance{
 			Id:       string(session.Name),
 			Capacity: instanceCapacity{Bytes: uint(session.ActualSizeBytes)},
 			Links:    instanceLinks{string(session.Name)},
-		})
+		}
+		if session.Preemption != nil {
+			instance.Preemption = &instancePreemption{
+				PreemptedBy: string(session.Preemption.PreemptedBy),
+				PreemptedAt: session.Preemption.PreemptedAt,
+				Reason:      session.Preemption.Reason,
+			}
+		}
+		for _, attempt := range session.Status.FailedAttempts {
+			instance.FailedAttempts = append(instance.FailedAttempts, attempt.String())
+		}
+		instances = append(instances, instance)
 	}
 	return instancesToString(instances), nil
 }
@@ -98,6 +109,14 @@ func (d *dacctlActions) ListPools() (string, error) {
 	return getPoolsAsString(pools), nil
 }
 
+func (d *dacctlActions) PoolReport() (string, error) {
+	reports, err := d.session.GetPoolReports()
+	if err != nil {
+		return "", err
+	}
+	return getPoolReportsAsString(reports), nil
+}
+
 func (d *dacctlActions) ShowConfigurations() (string, error) {
 	// NOTE: Slurm doesn't read any of the output, so we don't send anything
 	return configurationToString(configurations{}), nil
@@ -104,6 +104,86 @@ func TestDacctlActions_ShowInstances(t *testing.T) {
 	assert.Equal(t, expected, output)
 }
 
+func TestDacctlActions_ShowInstances_Preempted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	session.EXPECT().GetAllSessions().Return([]datamodel.Session{
+		{
+			Name: datamodel.SessionName("foo"),
+			Preemption: &datamodel.PreemptionRecord{
+				PreemptedBy: "bar",
+				PreemptedAt: 1234,
+				Reason:      "reason",
+			},
+		},
+	}, nil)
+	actions := dacctlActions{session: session}
+
+	output, err := actions.ShowInstances()
+
+	assert.Nil(t, err)
+	expected := `{"instances":[{"id":"foo","capacity":{"bytes":0,"nodes":0},"links":{"session":"foo"},` +
+		`"preemption":{"preempted_by":"bar","preempted_at":1234,"reason":"reason"}}]}`
+	assert.Equal(t, expected, output)
+}
+
+func TestDacctlActions_ShowInstances_FailedAttempts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	session.EXPECT().GetAllSessions().Return([]datamodel.Session{
+		{
+			Name: datamodel.SessionName("foo"),
+			Status: datamodel.SessionStatus{FailedAttempts: []datamodel.ActionAttempt{
+				{Operation: "mount", Hostname: "node123", Attempt: 2, MaxAttempts: 5, Error: "exit status 255"},
+			}},
+		},
+	}, nil)
+	actions := dacctlActions{session: session}
+
+	output, err := actions.ShowInstances()
+
+	assert.Nil(t, err)
+	expected := `{"instances":[{"id":"foo","capacity":{"bytes":0,"nodes":0},"links":{"session":"foo"},` +
+		`"failed_attempts":["mount attempt 2/5 on node123 failed: exit status 255"]}]}`
+	assert.Equal(t, expected, output)
+}
+
+func TestDacctlActions_PoolReport(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	session.EXPECT().GetPoolReports().Return([]datamodel.PoolReport{
+		{
+			Pool:               datamodel.Pool{Name: "pool1", GranularityBytes: 1024},
+			FreeBricksByHost:   map[datamodel.BrickHostName]int{"host1": 2},
+			LargestBufferBytes: map[datamodel.AllocationPolicy]int{datamodel.AllocationPolicyAny: 2048},
+			RequestedBytes:     1000,
+			AllocatedBytes:     1024,
+			DeadHostBricks:     1,
+			DisabledHostBricks: 2,
+			AllocationAges:     map[string]int{"under_1h": 1},
+		},
+	}, nil)
+	actions := dacctlActions{session: session}
+
+	output, err := actions.PoolReport()
+
+	assert.Nil(t, err)
+	expected := `{"pools":[{"id":"pool1","granularity":1024,"free_bricks_by_host":{"host1":2},` +
+		`"largest_buffer_bytes":{"any":2048},"requested_bytes":1000,"allocated_bytes":1024,` +
+		`"rounding_waste_bytes":24,"dead_host_bricks":1,"disabled_host_bricks":2,` +
+		`"allocation_age_counts":{"under_1h":1}}]}`
+	assert.Equal(t, expected, output)
+
+	fakeErr := errors.New("fake")
+	session.EXPECT().GetPoolReports().Return(nil, fakeErr)
+	output, err = actions.PoolReport()
+	assert.Equal(t, "", output)
+	assert.Equal(t, fakeErr, err)
+}
+
 func TestDacctlActions_ShowSessions(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	defer mockCtrl.Finish()
@@ -0,0 +1,56 @@
+package actions_impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"log"
+)
+
+type unhealthyBrick struct {
+	Hostname string `json:"hostname"`
+	Device   string `json:"device"`
+	Reason   string `json:"reason"`
+	MarkedAt uint   `json:"marked_at"`
+}
+
+func getUnhealthyBricksAsString(source []datamodel.UnhealthyBrick) string {
+	list := []unhealthyBrick{}
+	for _, brick := range source {
+		list = append(list, unhealthyBrick{
+			Hostname: string(brick.Brick.BrickHostName),
+			Device:   brick.Brick.Device,
+			Reason:   brick.Reason,
+			MarkedAt: brick.MarkedAt,
+		})
+	}
+	output, err := json.Marshal(map[string][]unhealthyBrick{"unhealthy_bricks": list})
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	return string(output)
+}
+
+func (d *dacctlActions) ShowUnhealthyBricks() (string, error) {
+	unhealthyBricks, err := d.session.GetUnhealthyBricks()
+	if err != nil {
+		return "", err
+	}
+	return getUnhealthyBricksAsString(unhealthyBricks), nil
+}
+
+func (d *dacctlActions) ClearUnhealthyBrick(c dacctl.CliContext) error {
+	if err := checkRequiredStrings(c, "host", "device"); err != nil {
+		return err
+	}
+	brick := datamodel.Brick{
+		BrickHostName: datamodel.BrickHostName(c.String("host")),
+		Device:        c.String("device"),
+	}
+	if !parsers.IsValidName(string(brick.BrickHostName)) || !parsers.IsValidName(brick.Device) {
+		return fmt.Errorf("badly formatted host or device: %s %s", brick.BrickHostName, brick.Device)
+	}
+	return d.session.ClearUnhealthyBrick(brick)
+}
@@ -0,0 +1,50 @@
+package actions_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_facade"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDacctlActions_ShowUnhealthyBricks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	actions := dacctlActions{session: session}
+
+	session.EXPECT().GetUnhealthyBricks().Return(nil, nil)
+	output, err := actions.ShowUnhealthyBricks()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"unhealthy_bricks":[]}`, output)
+
+	session.EXPECT().GetUnhealthyBricks().Return([]datamodel.UnhealthyBrick{{
+		Brick:    datamodel.Brick{BrickHostName: "dac1", Device: "nvme0n1"},
+		Reason:   "wipe failed",
+		MarkedAt: 42,
+	}}, nil)
+	output, err = actions.ShowUnhealthyBricks()
+	assert.Nil(t, err)
+	assert.Equal(t, `{"unhealthy_bricks":[{"hostname":"dac1","device":"nvme0n1","reason":"wipe failed",`+
+		`"marked_at":42}]}`, output)
+}
+
+func TestDacctlActions_ClearUnhealthyBrick(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := mock_facade.NewMockSession(mockCtrl)
+	actions := dacctlActions{session: session}
+
+	session.EXPECT().ClearUnhealthyBrick(datamodel.Brick{BrickHostName: "dac1", Device: "nvme0n1"})
+	err := actions.ClearUnhealthyBrick(&mockCliContext{
+		strings: map[string]string{"host": "dac1", "device": "nvme0n1"}})
+	assert.Nil(t, err)
+
+	err = actions.ClearUnhealthyBrick(&mockCliContext{strings: map[string]string{"host": "dac1"}})
+	assert.Equal(t, "Please provide these required parameters: device", err.Error())
+
+	err = actions.ClearUnhealthyBrick(&mockCliContext{
+		strings: map[string]string{"host": "dac1", "device": "/dev/nvme0n1"}})
+	assert.Equal(t, "badly formatted host or device: dac1 /dev/nvme0n1", err.Error())
+}
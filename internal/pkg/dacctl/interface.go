@@ -8,13 +8,21 @@ type CliContext interface {
 
 type DacctlActions interface {
 	CreatePersistentBuffer(c CliContext) error
+	ExpandPersistentBuffer(c CliContext) error
 	DeleteBuffer(c CliContext) error
+	CancelActions(c CliContext) error
 	CreatePerJobBuffer(c CliContext) error
 	ShowInstances() (string, error)
 	ShowSessions() (string, error)
 	ListPools() (string, error)
+	PoolReport() (string, error)
+	GetClusterConfig() (string, error)
+	SetClusterConfig(c CliContext) error
+	ShowUnhealthyBricks() (string, error)
+	ClearUnhealthyBrick(c CliContext) error
 	ShowConfigurations() (string, error)
 	ValidateJob(c CliContext) error
+	CheckCapacity(c CliContext) (string, error)
 	RealSize(c CliContext) (string, error)
 	DataIn(c CliContext) error
 	Paths(c CliContext) error
@@ -0,0 +1,11 @@
+package workflow_impl
+
+import "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+
+func (s sessionFacade) GetClusterConfig() (datamodel.ClusterConfig, error) {
+	return s.clusterConfig.GetClusterConfig()
+}
+
+func (s sessionFacade) UpdateClusterConfig(clusterConfig datamodel.ClusterConfig) (datamodel.ClusterConfig, error) {
+	return s.clusterConfig.UpdateClusterConfig(clusterConfig)
+}
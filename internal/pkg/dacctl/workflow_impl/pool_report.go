@@ -0,0 +1,107 @@
+package workflow_impl
+
+import (
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"time"
+)
+
+type allocationAgeBucket struct {
+	Name   string
+	MaxAge time.Duration
+}
+
+// Buckets are checked in order, the last one catches everything else
+var allocationAgeBuckets = []allocationAgeBucket{
+	{"under_1h", time.Hour},
+	{"under_1d", time.Hour * 24},
+	{"under_1w", time.Hour * 24 * 7},
+	{"over_1w", 0},
+}
+
+func (s sessionFacade) GetPoolReports() ([]datamodel.PoolReport, error) {
+	poolInfos, err := s.allocations.GetAllPoolInfos()
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := s.session.GetAllSessions()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get all sessions due to: %s", err)
+	}
+	brickHosts, err := s.brickHosts.GetAllBrickHosts()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get all brick hosts due to: %s", err)
+	}
+	aliveHosts := make(map[datamodel.BrickHostName]bool)
+	for _, brickHost := range brickHosts {
+		alive, err := s.brickHosts.IsBrickHostAlive(brickHost.Name)
+		if err != nil {
+			return nil, err
+		}
+		aliveHosts[brickHost.Name] = alive
+	}
+
+	now := time.Now()
+	var reports []datamodel.PoolReport
+	for _, poolInfo := range poolInfos {
+		reports = append(reports, getPoolReport(poolInfo, sessions, brickHosts, aliveHosts, now))
+	}
+	return reports, nil
+}
+
+func getAllocationAgeBucket(createdAt uint, now time.Time) string {
+	age := now.Sub(time.Unix(int64(createdAt), 0))
+	for _, bucket := range allocationAgeBuckets {
+		if bucket.MaxAge == 0 || age < bucket.MaxAge {
+			return bucket.Name
+		}
+	}
+	return allocationAgeBuckets[len(allocationAgeBuckets)-1].Name
+}
+
+func getPoolReport(poolInfo datamodel.PoolInfo, sessions []datamodel.Session, brickHosts []datamodel.BrickHost,
+	aliveHosts map[datamodel.BrickHostName]bool, now time.Time) datamodel.PoolReport {
+	report := datamodel.PoolReport{
+		Pool:               poolInfo.Pool,
+		FreeBricksByHost:   make(map[datamodel.BrickHostName]int),
+		LargestBufferBytes: make(map[datamodel.AllocationPolicy]int),
+		AllocationAges:     make(map[string]int),
+	}
+	granularity := int(poolInfo.Pool.GranularityBytes)
+
+	for _, brick := range poolInfo.AvailableBricks {
+		report.FreeBricksByHost[brick.BrickHostName] += 1
+	}
+	mostFreeOnOneHost := 0
+	for _, free := range report.FreeBricksByHost {
+		if free > mostFreeOnOneHost {
+			mostFreeOnOneHost = free
+		}
+	}
+	report.LargestBufferBytes[datamodel.AllocationPolicyAny] = len(poolInfo.AvailableBricks) * granularity
+	report.LargestBufferBytes[datamodel.AllocationPolicySpread] = len(report.FreeBricksByHost) * granularity
+	report.LargestBufferBytes[datamodel.AllocationPolicySingleHost] = mostFreeOnOneHost * granularity
+
+	for _, brickHost := range brickHosts {
+		for _, brick := range brickHost.Bricks {
+			if brick.PoolName != poolInfo.Pool.Name {
+				continue
+			}
+			if !brickHost.Enabled {
+				report.DisabledHostBricks += 1
+			} else if !aliveHosts[brickHost.Name] {
+				report.DeadHostBricks += 1
+			}
+		}
+	}
+
+	for _, session := range sessions {
+		if session.VolumeRequest.PoolName != poolInfo.Pool.Name || len(session.AllocatedBricks) == 0 {
+			continue
+		}
+		report.RequestedBytes += session.VolumeRequest.TotalCapacityBytes
+		report.AllocatedBytes += session.ActualSizeBytes
+		report.AllocationAges[getAllocationAgeBucket(session.CreatedAt, now)] += 1
+	}
+	return report
+}
@@ -0,0 +1,107 @@
+package workflow_impl
+
+import (
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetPoolReport(t *testing.T) {
+	poolInfo := datamodel.PoolInfo{
+		Pool: datamodel.Pool{Name: "pool1", GranularityBytes: 1024},
+		AvailableBricks: []datamodel.Brick{
+			{Device: "sda", BrickHostName: "host1", PoolName: "pool1"},
+			{Device: "sdb", BrickHostName: "host1", PoolName: "pool1"},
+			{Device: "sdc", BrickHostName: "host1", PoolName: "pool1"},
+			{Device: "sda", BrickHostName: "host2", PoolName: "pool1"},
+		},
+	}
+	brickHosts := []datamodel.BrickHost{
+		{Name: "host1", Enabled: true},
+		{Name: "host2", Enabled: true},
+		{Name: "host3", Enabled: true, Bricks: []datamodel.Brick{
+			{Device: "sda", BrickHostName: "host3", PoolName: "pool1"},
+			{Device: "sdb", BrickHostName: "host3", PoolName: "pool2"},
+		}},
+		{Name: "host4", Enabled: false, Bricks: []datamodel.Brick{
+			{Device: "sda", BrickHostName: "host4", PoolName: "pool1"},
+			{Device: "sdb", BrickHostName: "host4", PoolName: "pool1"},
+		}},
+	}
+	aliveHosts := map[datamodel.BrickHostName]bool{"host1": true, "host2": true, "host4": true}
+	now := time.Unix(1000000, 0)
+	sessions := []datamodel.Session{
+		{
+			Name:            "new",
+			CreatedAt:       uint(now.Unix()) - 60,
+			VolumeRequest:   datamodel.VolumeRequest{PoolName: "pool1", TotalCapacityBytes: 1000},
+			ActualSizeBytes: 1024,
+			AllocatedBricks: []datamodel.Brick{{Device: "sdd", BrickHostName: "host1"}},
+		},
+		{
+			Name:            "old",
+			CreatedAt:       uint(now.Unix()) - 60*60*24*8,
+			VolumeRequest:   datamodel.VolumeRequest{PoolName: "pool1", TotalCapacityBytes: 2000},
+			ActualSizeBytes: 2048,
+			AllocatedBricks: []datamodel.Brick{{Device: "sdb", BrickHostName: "host2"}, {Device: "sde", BrickHostName: "host1"}},
+		},
+		{
+			Name:          "nobricks",
+			VolumeRequest: datamodel.VolumeRequest{PoolName: "pool1", TotalCapacityBytes: 2000},
+		},
+		{
+			Name:            "otherpool",
+			VolumeRequest:   datamodel.VolumeRequest{PoolName: "pool2", TotalCapacityBytes: 2000},
+			ActualSizeBytes: 2048,
+			AllocatedBricks: []datamodel.Brick{{Device: "sdb", BrickHostName: "host3"}},
+		},
+	}
+
+	report := getPoolReport(poolInfo, sessions, brickHosts, aliveHosts, now)
+
+	assert.Equal(t, poolInfo.Pool, report.Pool)
+	assert.Equal(t, map[datamodel.BrickHostName]int{"host1": 3, "host2": 1}, report.FreeBricksByHost)
+	assert.Equal(t, map[datamodel.AllocationPolicy]int{
+		datamodel.AllocationPolicyAny:        4096,
+		datamodel.AllocationPolicySpread:     2048,
+		datamodel.AllocationPolicySingleHost: 3072,
+	}, report.LargestBufferBytes)
+	assert.Equal(t, 3000, report.RequestedBytes)
+	assert.Equal(t, 3072, report.AllocatedBytes)
+	assert.Equal(t, 1, report.DeadHostBricks)
+	assert.Equal(t, 2, report.DisabledHostBricks)
+	assert.Equal(t, map[string]int{"under_1h": 1, "over_1w": 1}, report.AllocationAges)
+}
+
+func TestSessionFacade_GetPoolReports(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	allocations := mock_registry.NewMockAllocationRegistry(mockCtrl)
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	brickHosts := mock_registry.NewMockBrickHostRegistry(mockCtrl)
+	facade := sessionFacade{allocations: allocations, session: sessionRegistry, brickHosts: brickHosts}
+
+	allocations.EXPECT().GetAllPoolInfos().Return([]datamodel.PoolInfo{
+		{Pool: datamodel.Pool{Name: "pool1", GranularityBytes: 1024}},
+	}, nil)
+	sessionRegistry.EXPECT().GetAllSessions().Return(nil, nil)
+	brickHosts.EXPECT().GetAllBrickHosts().Return([]datamodel.BrickHost{{Name: "host1"}}, nil)
+	brickHosts.EXPECT().IsBrickHostAlive(datamodel.BrickHostName("host1")).Return(true, nil)
+
+	reports, err := facade.GetPoolReports()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(reports))
+	assert.Equal(t, datamodel.PoolName("pool1"), reports[0].Pool.Name)
+	assert.Equal(t, 0, reports[0].LargestBufferBytes[datamodel.AllocationPolicyAny])
+
+	allocations.EXPECT().GetAllPoolInfos().Return(nil, nil)
+	sessionRegistry.EXPECT().GetAllSessions().Return(nil, errors.New("fake"))
+	reports, err = facade.GetPoolReports()
+	assert.Nil(t, reports)
+	assert.Equal(t, "unable to get all sessions due to: fake", err.Error())
+}
@@ -0,0 +1,179 @@
+package workflow_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"log"
+	"sort"
+	"time"
+)
+
+// Only high priority sessions can reclaim capacity from other sessions,
+// everyone else has to wait for bricks to be free.
+// Victims are chosen, and their bricks reserved for this session, under the allocation mutex,
+// so no other session can take the bricks once they are released
+func (s sessionFacade) reserveCapacityIfPoolFull(session datamodel.Session) ([]datamodel.SessionName, error) {
+	request := session.VolumeRequest
+	if request.Priority < datamodel.HighPriority || request.TotalCapacityBytes == 0 {
+		return nil, nil
+	}
+
+	allocationMutex, err := s.allocations.GetAllocationMutex()
+	if err != nil {
+		return nil, err
+	}
+	if err := allocationMutex.Lock(context.TODO()); err != nil {
+		return nil, err
+	}
+	defer allocationMutex.Unlock(context.TODO())
+
+	pool, err := s.allocations.GetPoolInfo(request.PoolName)
+	if err != nil {
+		return nil, err
+	}
+	bricksRequired, _ := getBricksRequired(pool.Pool, request.TotalCapacityBytes)
+	bricksFree := len(pool.AvailableBricks) + len(pool.ReservedBricks[session.Name])
+	if bricksRequired <= bricksFree {
+		return nil, nil
+	}
+	allSessions, err := s.session.GetAllSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	victims, err := choosePreemptionVictims(allSessions, request.PoolName, request.Priority,
+		bricksRequired-bricksFree)
+	if err != nil {
+		return nil, fmt.Errorf("refusing session %s: %s", session.Name, err)
+	}
+
+	// Long enough to preempt every victim, then create this session
+	reservedUntil := time.Now().Add(time.Duration(len(victims))*
+		config.GetActionTimeout(config.DefaultEnv, datamodel.SessionPreempt) +
+		config.GetActionTimeout(config.DefaultEnv, datamodel.SessionCreateFilesystem))
+
+	var reserved []datamodel.SessionName
+	for _, victim := range victims {
+		// Record the decision before asking for the bricks to be released,
+		// the update fails if the victim changed since it was chosen
+		victim.Preemption = &datamodel.PreemptionRecord{
+			PreemptedBy:         session.Name,
+			PreemptedByPriority: request.Priority,
+			PreemptedAt:         uint(time.Now().Unix()),
+			Reason: fmt.Sprintf("idle buffer with priority %d in full pool %s",
+				victim.VolumeRequest.Priority, victim.VolumeRequest.PoolName),
+			ReservedBricks: victim.AllocatedBricks,
+			ReservedUntil:  uint(reservedUntil.Unix()),
+		}
+		if _, err := s.session.UpdateSession(victim); err != nil {
+			return reserved, fmt.Errorf("unable to reserve bricks of session %s due to: %s", victim.Name, err)
+		}
+		reserved = append(reserved, victim.Name)
+	}
+	return reserved, nil
+}
+
+// Preempts each victim in turn, any victims not yet preempted when one fails are left alone
+func (s sessionFacade) preemptVictims(session datamodel.Session, victims []datamodel.SessionName) error {
+	for i, victimName := range victims {
+		log.Printf("Preempting session %s to make room for session %s\n", victimName, session.Name)
+		if err := s.preemptSession(victimName, session); err != nil {
+			s.releaseReservations(session.Name, victims[i+1:], true)
+			return fmt.Errorf("unable to preempt session %s due to: %s", victimName, err)
+		}
+	}
+	return nil
+}
+
+// Returns any unused reserved bricks to the pool, and when cancel is set
+// also withdraws the decision to preempt buffers that still have their filesystem
+func (s sessionFacade) releaseReservations(preemptedBy datamodel.SessionName, victims []datamodel.SessionName,
+	cancel bool) {
+	for _, victimName := range victims {
+		victim, err := s.session.GetSession(victimName)
+		if err != nil {
+			log.Printf("unable to release bricks reserved from session %s due to: %s", victimName, err)
+			continue
+		}
+		if victim.Preemption == nil || victim.Preemption.PreemptedBy != preemptedBy {
+			continue
+		}
+		if cancel && victim.Status.FileSystemCreated {
+			victim.Preemption = nil
+		} else {
+			victim.Preemption.ReservedBricks = nil
+			victim.Preemption.ReservedUntil = 0
+		}
+		if _, err := s.session.UpdateSession(victim); err != nil {
+			log.Printf("unable to release bricks reserved from session %s due to: %s", victimName, err)
+		}
+	}
+}
+
+// Pick idle persistent buffers with a lower priority, lowest priority and oldest first,
+// until enough bricks would be freed up
+func choosePreemptionVictims(allSessions []datamodel.Session, poolName datamodel.PoolName,
+	priority datamodel.PriorityClass, bricksNeeded int) ([]datamodel.Session, error) {
+	var candidates []datamodel.Session
+	referenced := datamodel.GetReferencedBuffers(allSessions)
+	for _, session := range allSessions {
+		if !session.VolumeRequest.MultiJob || session.VolumeRequest.PoolName != poolName {
+			continue
+		}
+		if session.VolumeRequest.Priority >= priority || session.Preemption != nil {
+			continue
+		}
+		if !session.Status.FileSystemCreated || session.Status.DeleteRequested || session.ActualSizeBytes == 0 {
+			continue
+		}
+		if !session.IsIdle() || referenced[session.Name] {
+			continue
+		}
+		candidates = append(candidates, session)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].VolumeRequest.Priority != candidates[j].VolumeRequest.Priority {
+			return candidates[i].VolumeRequest.Priority < candidates[j].VolumeRequest.Priority
+		}
+		return candidates[i].CreatedAt < candidates[j].CreatedAt
+	})
+
+	var victims []datamodel.Session
+	bricksFreed := 0
+	for _, candidate := range candidates {
+		if bricksFreed >= bricksNeeded {
+			break
+		}
+		victims = append(victims, candidate)
+		bricksFreed += len(candidate.AllocatedBricks)
+	}
+	if bricksFreed < bricksNeeded {
+		return nil, fmt.Errorf(
+			"pool %s is full, need %d more bricks but only %d can be freed from idle lower priority buffers",
+			poolName, bricksNeeded, bricksFreed)
+	}
+	return victims, nil
+}
+
+func (s sessionFacade) preemptSession(victimName datamodel.SessionName, preemptedBy datamodel.Session) error {
+	return s.submitJob(victimName, datamodel.SessionPreempt,
+		func() (datamodel.Session, error) {
+			victim, err := s.session.GetSession(victimName)
+			if err != nil {
+				return victim, err
+			}
+			allSessions, err := s.session.GetAllSessions()
+			if err != nil {
+				return victim, err
+			}
+			// Double check nothing changed since the bricks were reserved
+			if victim.Status.DeleteRequested || victim.Preemption == nil ||
+				victim.Preemption.PreemptedBy != preemptedBy.Name || !victim.IsIdle() ||
+				datamodel.GetReferencedBuffers(allSessions)[victimName] {
+				return victim, fmt.Errorf("session %s is no longer able to be preempted", victimName)
+			}
+			return victim, nil
+		})
+}
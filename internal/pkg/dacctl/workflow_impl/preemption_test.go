@@ -0,0 +1,174 @@
+package workflow_impl
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func getPreemptionCandidate(name string, priority datamodel.PriorityClass, createdAt uint, bricks int) datamodel.Session {
+	return datamodel.Session{
+		Name:            datamodel.SessionName(name),
+		CreatedAt:       createdAt,
+		ActualSizeBytes: bricks * 1024,
+		AllocatedBricks: make([]datamodel.Brick, bricks),
+		Status:          datamodel.SessionStatus{FileSystemCreated: true},
+		VolumeRequest: datamodel.VolumeRequest{
+			MultiJob: true,
+			PoolName: "pool1",
+			Priority: priority,
+		},
+		CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{
+			datamodel.SessionName(name): {SessionName: datamodel.SessionName(name)},
+		},
+	}
+}
+
+func TestChoosePreemptionVictims(t *testing.T) {
+	inUse := getPreemptionCandidate("inuse", datamodel.LowPriority, 1, 4)
+	inUse.CurrentAttachments["job1"] = datamodel.AttachmentSession{SessionName: "job1"}
+	otherPool := getPreemptionCandidate("otherpool", datamodel.LowPriority, 1, 4)
+	otherPool.VolumeRequest.PoolName = "pool2"
+	alreadyPreempted := getPreemptionCandidate("preempted", datamodel.LowPriority, 1, 4)
+	alreadyPreempted.Preemption = &datamodel.PreemptionRecord{PreemptedBy: "other"}
+	// a job that is set up, but has not mounted the buffer yet
+	referenced := getPreemptionCandidate("referenced", datamodel.LowPriority, 0, 4)
+	job := datamodel.Session{Name: "job2", MultiJobAttachments: []datamodel.SessionName{"referenced"}}
+
+	allSessions := []datamodel.Session{
+		inUse,
+		referenced,
+		job,
+		otherPool,
+		alreadyPreempted,
+		getPreemptionCandidate("high", datamodel.HighPriority, 1, 4),
+		getPreemptionCandidate("normal", datamodel.NormalPriority, 2, 2),
+		getPreemptionCandidate("lownew", datamodel.LowPriority, 5, 2),
+		getPreemptionCandidate("lowold", datamodel.LowPriority, 3, 1),
+	}
+
+	victims, err := choosePreemptionVictims(allSessions, "pool1", datamodel.HighPriority, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(victims))
+	assert.Equal(t, datamodel.SessionName("lowold"), victims[0].Name)
+	assert.Equal(t, datamodel.SessionName("lownew"), victims[1].Name)
+
+	victims, err = choosePreemptionVictims(allSessions, "pool1", datamodel.HighPriority, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(victims))
+	assert.Equal(t, datamodel.SessionName("normal"), victims[2].Name)
+
+	victims, err = choosePreemptionVictims(allSessions, "pool1", datamodel.NormalPriority, 4)
+	assert.Nil(t, victims)
+	assert.Equal(t,
+		"pool pool1 is full, need 4 more bricks but only 3 can be freed from idle lower priority buffers",
+		err.Error())
+}
+
+func TestSessionFacade_GetBricks_Reserved(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	allocations := mock_registry.NewMockAllocationRegistry(mockCtrl)
+	facade := sessionFacade{allocations: allocations}
+	freeBrick := datamodel.Brick{Device: "sda", BrickHostName: "host1"}
+	reservedBrick := datamodel.Brick{Device: "sdb", BrickHostName: "host2"}
+	allocations.EXPECT().GetPoolInfo(datamodel.PoolName("pool1")).Return(datamodel.PoolInfo{
+		Pool:            datamodel.Pool{Name: "pool1", GranularityBytes: 1024},
+		AvailableBricks: []datamodel.Brick{freeBrick},
+		ReservedBricks:  map[datamodel.SessionName][]datamodel.Brick{"urgent": {reservedBrick}},
+	}, nil).Times(3)
+
+	_, bricks, err := facade.getBricks("other", "pool1", 2048)
+	assert.Equal(t, "unable to get number of requested bricks (2) for given pool (pool1)", err.Error())
+
+	_, bricks, err = facade.getBricks("urgent", "pool1", 1024)
+	assert.Nil(t, err)
+	assert.Equal(t, []datamodel.Brick{reservedBrick}, bricks)
+
+	_, bricks, err = facade.getBricks("urgent", "pool1", 2048)
+	assert.Nil(t, err)
+	assert.Equal(t, []datamodel.Brick{reservedBrick, freeBrick}, bricks)
+}
+
+func TestSessionFacade_CreateSession_Preempt(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	allocations := mock_registry.NewMockAllocationRegistry(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry, actions: actions, allocations: allocations}
+
+	pool := datamodel.Pool{Name: "pool1", GranularityBytes: 1024}
+	victimBricks := []datamodel.Brick{{Device: "sda", BrickHostName: "host1"}}
+	victim := getPreemptionCandidate("old", datamodel.LowPriority, 1, 1)
+	victim.AllocatedBricks = victimBricks
+	urgent := datamodel.Session{Name: "urgent", VolumeRequest: datamodel.VolumeRequest{
+		PoolName: "pool1", TotalCapacityBytes: 1024, Priority: datamodel.HighPriority}}
+
+	allocations.EXPECT().GetPool(datamodel.PoolName("pool1")).Return(pool, nil)
+	allocationMutex := mock_store.NewMockMutex(mockCtrl)
+	allocations.EXPECT().GetAllocationMutex().Return(allocationMutex, nil).Times(2)
+	allocationMutex.EXPECT().Lock(context.TODO()).Times(2)
+	allocationMutex.EXPECT().Unlock(context.TODO()).Times(2)
+
+	// the victim's bricks are reserved under the allocation mutex
+	allocations.EXPECT().GetPoolInfo(datamodel.PoolName("pool1")).Return(datamodel.PoolInfo{Pool: pool}, nil)
+	sessionRegistry.EXPECT().GetAllSessions().Return([]datamodel.Session{victim}, nil).Times(2)
+	var reservedVictim datamodel.Session
+	sessionRegistry.EXPECT().UpdateSession(gomock.Any()).DoAndReturn(
+		func(session datamodel.Session) (datamodel.Session, error) {
+			assert.Equal(t, datamodel.SessionName("urgent"), session.Preemption.PreemptedBy)
+			assert.Equal(t, victimBricks, session.Preemption.ReservedBricks)
+			assert.NotZero(t, session.Preemption.ReservedUntil)
+			reservedVictim = session
+			return session, nil
+		})
+
+	victimMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(victim.Name).Return(victimMutex, nil)
+	victimMutex.EXPECT().Lock(gomock.Any())
+	sessionRegistry.EXPECT().GetSession(victim.Name).DoAndReturn(
+		func(datamodel.SessionName) (datamodel.Session, error) { return reservedVictim, nil })
+	victimActions := make(chan datamodel.SessionAction, 1)
+	victimActions <- datamodel.SessionAction{}
+	close(victimActions)
+	actions.EXPECT().SendSessionAction(gomock.Any(), datamodel.SessionPreempt, gomock.Any()).Return(victimActions, nil)
+	victimMutex.EXPECT().Unlock(context.TODO())
+
+	// a concurrent create has taken every free brick, but the released bricks are still reserved
+	urgentMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(urgent.Name).Return(urgentMutex, nil)
+	urgentMutex.EXPECT().Lock(gomock.Any())
+	allocations.EXPECT().GetPoolInfo(datamodel.PoolName("pool1")).Return(datamodel.PoolInfo{
+		Pool:           pool,
+		ReservedBricks: map[datamodel.SessionName][]datamodel.Brick{"urgent": victimBricks},
+	}, nil)
+	created := urgent
+	created.ActualSizeBytes = 1024
+	created.AllocatedBricks = victimBricks
+	created.PrimaryBrickHost = "host1"
+	sessionRegistry.EXPECT().CreateSession(created).Return(created, nil)
+	urgentActions := make(chan datamodel.SessionAction, 1)
+	urgentActions <- datamodel.SessionAction{}
+	close(urgentActions)
+	actions.EXPECT().SendSessionAction(gomock.Any(), datamodel.SessionCreateFilesystem, created).Return(urgentActions, nil)
+	urgentMutex.EXPECT().Unlock(context.TODO())
+
+	// then the reservation is dropped, keeping the record of the preemption
+	sessionRegistry.EXPECT().GetSession(victim.Name).DoAndReturn(
+		func(datamodel.SessionName) (datamodel.Session, error) { return reservedVictim, nil })
+	sessionRegistry.EXPECT().UpdateSession(gomock.Any()).DoAndReturn(
+		func(session datamodel.Session) (datamodel.Session, error) {
+			assert.Equal(t, datamodel.SessionName("urgent"), session.Preemption.PreemptedBy)
+			assert.Nil(t, session.Preemption.ReservedBricks)
+			return session, nil
+		})
+
+	err := facade.CreateSession(urgent)
+
+	assert.Nil(t, err)
+}
@@ -4,10 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/facade"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry_impl"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
@@ -19,27 +21,33 @@ import (
 
 func NewSessionFacade(keystore store.Keystore) facade.Session {
 	return sessionFacade{
-		session:     registry_impl.NewSessionRegistry(keystore),
-		actions:     registry_impl.NewSessionActionsRegistry(keystore),
-		allocations: registry_impl.NewAllocationRegistry(keystore),
-		ansible:     filesystem_impl.NewAnsible(),
+		session:       registry_impl.NewSessionRegistry(keystore),
+		actions:       registry_impl.NewSessionActionsRegistry(keystore),
+		allocations:   registry_impl.NewAllocationRegistry(keystore),
+		brickHosts:    registry_impl.NewBrickHostRegistry(keystore),
+		clusterConfig: registry_impl.NewClusterConfigRegistry(keystore),
+		ansible:       filesystem_impl.NewAnsible(),
 	}
 }
 
 type sessionFacade struct {
-	session     registry.SessionRegistry
-	actions     registry.SessionActions
-	allocations registry.AllocationRegistry
-	ansible     filesystem.Ansible
+	session       registry.SessionRegistry
+	actions       registry.SessionActions
+	allocations   registry.AllocationRegistry
+	brickHosts    registry.BrickHostRegistry
+	clusterConfig registry.ClusterConfigRegistry
+	ansible       filesystem.Ansible
 }
 
 func (s sessionFacade) submitJob(sessionName datamodel.SessionName, actionType datamodel.SessionActionType,
 	getSession func() (datamodel.Session, error)) error {
-	// 30 min timeout to aquire lock and send action
-	ctxt, cancelFunc := context.WithTimeout(context.Background(), time.Minute*30)
+	// Timeout to aquire lock and wait for the action, dacd stops the action when it is reached
+	ctxt, cancelFunc := context.WithTimeout(context.Background(),
+		config.GetActionTimeout(config.DefaultEnv, actionType))
 	defer func() {
 		cancelFunc()
 	}()
+	ctxt = logging.WithFields(ctxt, logging.Fields{logging.SessionField: sessionName, logging.ActionField: actionType})
 
 	sessionMutex, err := s.session.GetSessionMutex(sessionName)
 	if err != nil {
@@ -104,6 +112,20 @@ func (s sessionFacade) CreateSession(session datamodel.Session) error {
 		return err
 	}
 
+	// Bricks freed by preemption are reserved for this session,
+	// once it has allocated, or failed to, any left over are returned to the pool
+	victims, err := s.reserveCapacityIfPoolFull(session)
+	if len(victims) > 0 {
+		defer s.releaseReservations(session.Name, victims, false)
+	}
+	if err != nil {
+		s.releaseReservations(session.Name, victims, true)
+		return err
+	}
+	if err := s.preemptVictims(session, victims); err != nil {
+		return err
+	}
+
 	return s.submitJob(session.Name, datamodel.SessionCreateFilesystem,
 		func() (datamodel.Session, error) {
 			// Allocate bricks, and choose brick host server
@@ -143,7 +165,8 @@ func (s sessionFacade) doAllocationAndWriteSession(session datamodel.Session) (d
 		defer allocationMutex.Unlock(context.TODO())
 
 		// Write allocations before creating the session
-		actualSizeBytes, chosenBricks, err := s.getBricks(session.VolumeRequest.PoolName, session.VolumeRequest.TotalCapacityBytes)
+		actualSizeBytes, chosenBricks, err := s.getBricks(session.Name, session.VolumeRequest.PoolName,
+			session.VolumeRequest.TotalCapacityBytes)
 		if err != nil {
 			return session, fmt.Errorf("can't allocate for session: %s due to %s", session.Name, err)
 		}
@@ -171,16 +194,97 @@ func (s sessionFacade) doAllocationAndWriteSession(session datamodel.Session) (d
 	return s.session.CreateSession(session)
 }
 
-func (s sessionFacade) getBricks(poolName datamodel.PoolName, bytes int) (int, []datamodel.Brick, error) {
+func (s sessionFacade) ExpandSession(sessionName datamodel.SessionName, poolName datamodel.PoolName,
+	extraCapacityBytes int) error {
+	if extraCapacityBytes <= 0 {
+		return fmt.Errorf("must request more than zero bytes to expand session: %s", sessionName)
+	}
+	return s.submitJob(sessionName, datamodel.SessionExpand,
+		func() (datamodel.Session, error) {
+			session, err := s.session.GetSession(sessionName)
+			if err != nil {
+				log.Println("Unable to find session we want to expand:", sessionName)
+				return session, err
+			}
+			if !session.VolumeRequest.MultiJob {
+				return session, fmt.Errorf("only persistent buffers can be expanded: %s", sessionName)
+			}
+			if session.VolumeRequest.PoolName != poolName {
+				return session, fmt.Errorf("can't expand session %s in pool %s with bricks from pool %s",
+					sessionName, session.VolumeRequest.PoolName, poolName)
+			}
+			if session.Status.DeleteRequested {
+				return session, fmt.Errorf("can't expand session once delete has been requested: %s", sessionName)
+			}
+			if !session.Status.FileSystemCreated || session.ActualSizeBytes == 0 {
+				return session, fmt.Errorf("can't expand session without a filesystem: %s", sessionName)
+			}
+			// Checked before allocating, as dacd can't add bricks to these filesystems
+			if getFilesystemType(session) == datamodel.BeeGFSFilesystem {
+				return session, fmt.Errorf("can't expand session with a %s filesystem: %s",
+					datamodel.BeeGFSFilesystem, sessionName)
+			}
+			if len(session.PendingExpandBricks) > 0 {
+				// The capacity was added by the failed request, so just retry adding its bricks
+				log.Printf("retrying previous expand of session %s, with bricks: %+v",
+					sessionName, session.PendingExpandBricks)
+				return session, nil
+			}
+			return s.doExpandAllocationAndUpdateSession(session, extraCapacityBytes)
+		})
+}
+
+// The type recorded when the filesystem was created, otherwise the one requested
+func getFilesystemType(session datamodel.Session) datamodel.FilesystemType {
+	if session.FilesystemSettings != nil {
+		return session.FilesystemSettings.Type
+	}
+	return session.VolumeRequest.FilesystemType
+}
+
+func (s sessionFacade) doExpandAllocationAndUpdateSession(session datamodel.Session,
+	extraCapacityBytes int) (datamodel.Session, error) {
+	allocationMutex, err := s.allocations.GetAllocationMutex()
+	if err != nil {
+		return session, err
+	}
+
+	err = allocationMutex.Lock(context.TODO())
+	if err != nil {
+		return session, err
+	}
+	defer allocationMutex.Unlock(context.TODO())
+
+	// Update allocations in the session before asking for the filesystem to grow
+	actualSizeBytes, chosenBricks, err := s.getBricks(session.Name, session.VolumeRequest.PoolName, extraCapacityBytes)
+	if err != nil {
+		return session, fmt.Errorf("can't allocate for session: %s due to %s", session.Name, err)
+	}
+
+	session.VolumeRequest.TotalCapacityBytes += extraCapacityBytes
+	session.ActualSizeBytes += actualSizeBytes
+	session.AllocatedBricks = append(session.AllocatedBricks, chosenBricks...)
+	session.PendingExpandBricks = chosenBricks
+	return s.session.UpdateSession(session)
+}
+
+func (s sessionFacade) getBricks(sessionName datamodel.SessionName, poolName datamodel.PoolName,
+	bytes int) (int, []datamodel.Brick, error) {
 	pool, err := s.allocations.GetPoolInfo(poolName)
 	if err != nil {
 		return 0, nil, err
 	}
 
-	bricksRequired := int(math.Ceil(float64(bytes) / float64(pool.Pool.GranularityBytes)))
-	actualSize := bricksRequired * int(pool.Pool.GranularityBytes)
+	bricksRequired, actualSize := getBricksRequired(pool.Pool, bytes)
 
-	bricks := pickBricks(bricksRequired, pool)
+	// Use any bricks reserved for the session first
+	var bricks []datamodel.Brick
+	if reserved := pool.ReservedBricks[sessionName]; len(reserved) > 0 {
+		bricks = pickBricks(bricksRequired, datamodel.PoolInfo{AvailableBricks: reserved})
+	}
+	if len(bricks) < bricksRequired {
+		bricks = append(bricks, pickBricks(bricksRequired-len(bricks), pool)...)
+	}
 	if len(bricks) != bricksRequired {
 		return 0, nil, fmt.Errorf(
 			"unable to get number of requested bricks (%d) for given pool (%s)",
@@ -191,6 +295,13 @@ func (s sessionFacade) getBricks(poolName datamodel.PoolName, bytes int) (int, [
 	return actualSize, bricks, nil
 }
 
+// Round up to the nearest brick, as a brick is the pool's unit of allocation
+func getBricksRequired(pool datamodel.Pool, bytes int) (int, int) {
+	bricksRequired := int(math.Ceil(float64(bytes) / float64(pool.GranularityBytes)))
+	actualSize := bricksRequired * int(pool.GranularityBytes)
+	return bricksRequired, actualSize
+}
+
 func pickBricks(bricksRequired int, poolInfo datamodel.PoolInfo) []datamodel.Brick {
 	// pick some of the available bricks
 	s := rand.NewSource(time.Now().Unix())
@@ -237,10 +348,21 @@ func (s sessionFacade) DeleteSession(sessionName datamodel.SessionName, hurry bo
 			// can be deleted when it is next stated
 			session.Status.DeleteRequested = true
 			session.Status.DeleteSkipCopyDataOut = hurry
+			if hurry && isCacheWriteBackPending(session) {
+				// skipping the write back would lose the job's changes
+				log.Println("Warning, writing back cache buffer before delete, despite hurry:", sessionName)
+				session.Status.DeleteSkipCopyDataOut = false
+			}
 			return s.session.UpdateSession(session)
 		})
 }
 
+// Cache buffers write back to their pfs path when the data is copied out
+func isCacheWriteBackPending(session datamodel.Session) bool {
+	return session.VolumeRequest.Type == datamodel.Cache && len(session.StageOutRequests) > 0 &&
+		!session.Status.CopyDataOutComplete
+}
+
 func (s sessionFacade) CopyDataIn(sessionName datamodel.SessionName) error {
 	return s.submitJob(sessionName, datamodel.SessionCopyDataIn,
 		func() (datamodel.Session, error) {
@@ -281,6 +403,24 @@ func (s sessionFacade) GetPools() ([]datamodel.PoolInfo, error) {
 	return s.allocations.GetAllPoolInfos()
 }
 
+func (s sessionFacade) CheckCapacity(poolName datamodel.PoolName, capacityBytes int) (datamodel.PoolCapacityCheck, error) {
+	check := datamodel.PoolCapacityCheck{PoolName: poolName, RequestedBytes: capacityBytes}
+	pool, err := s.allocations.GetPoolInfo(poolName)
+	if err != nil {
+		return check, err
+	}
+
+	check.BricksRequired, check.ActualSizeBytes = getBricksRequired(pool.Pool, capacityBytes)
+	check.FitsNow = check.BricksRequired <= len(pool.AvailableBricks)
+	// Reserved and unhealthy bricks are only out of use for a while
+	totalBricks := len(pool.AvailableBricks) + len(pool.AllocatedBricks) + len(pool.UnhealthyBricks)
+	for _, reserved := range pool.ReservedBricks {
+		totalBricks += len(reserved)
+	}
+	check.FitsEmptyPool = check.BricksRequired <= totalBricks
+	return check, nil
+}
+
 func (s sessionFacade) GetSession(sessionName datamodel.SessionName) (datamodel.Session, error) {
 	return s.session.GetSession(sessionName)
 }
@@ -295,5 +435,23 @@ func (s sessionFacade) GenerateAnsible(sessionName datamodel.SessionName) (strin
 		log.Println("Unable to find session we want to mount:", sessionName)
 		return "", err
 	}
-	return s.ansible.CreateEnvironment(session)
+	return s.ansible.CreateEnvironment(context.Background(), session)
+}
+
+func (s sessionFacade) CancelSession(sessionName datamodel.SessionName) error {
+	session, err := s.session.GetSession(sessionName)
+	if err != nil {
+		return err
+	}
+	actions, err := s.actions.CancelSessionActions(session)
+	if err != nil {
+		return err
+	}
+	if len(actions) == 0 {
+		return fmt.Errorf("no actions to cancel for session: %s", sessionName)
+	}
+	for _, action := range actions {
+		log.Printf("Requested cancel of %s action for session %s\n", action.ActionType, sessionName)
+	}
+	return nil
 }
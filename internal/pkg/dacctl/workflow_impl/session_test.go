@@ -174,3 +174,232 @@ func TestSessionFacade_DeleteSession(t *testing.T) {
 
 	assert.Equal(t, fakeErr, err)
 }
+
+func TestSessionFacade_DeleteSession_CacheWriteBack(t *testing.T) {
+	sessionName := datamodel.SessionName("foo")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry, actions: actions}
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(sessionName).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	initialSession := datamodel.Session{Name: "foo",
+		VolumeRequest:    datamodel.VolumeRequest{Type: datamodel.Cache},
+		StageOutRequests: []datamodel.DataCopyRequest{{Source: "$DW_JOB_STRIPED/", Destination: "/pfs/"}}}
+	sessionRegistry.EXPECT().GetSession(sessionName).Return(initialSession, nil)
+	// the changes in the cache are still written back
+	updatedSession := initialSession
+	updatedSession.Status = datamodel.SessionStatus{DeleteRequested: true}
+	sessionRegistry.EXPECT().UpdateSession(updatedSession).Return(updatedSession, nil)
+	actionChan := make(chan datamodel.SessionAction, 1)
+	actionChan <- datamodel.SessionAction{}
+	close(actionChan)
+	actions.EXPECT().SendSessionAction(gomock.Any(), datamodel.SessionDelete, updatedSession).Return(actionChan, nil)
+	sessionMutex.EXPECT().Unlock(context.TODO())
+
+	err := facade.DeleteSession(sessionName, true)
+
+	assert.Nil(t, err)
+	initialSession.Status.CopyDataOutComplete = true
+	assert.False(t, isCacheWriteBackPending(initialSession))
+}
+
+func TestSessionFacade_ExpandSession(t *testing.T) {
+	sessionName := datamodel.SessionName("foo")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	allocations := mock_registry.NewMockAllocationRegistry(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry, actions: actions, allocations: allocations}
+
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(sessionName).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	existingBricks := []datamodel.Brick{{Device: "sda", BrickHostName: "host1"}}
+	initialSession := datamodel.Session{
+		Name: sessionName,
+		VolumeRequest: datamodel.VolumeRequest{
+			MultiJob: true, PoolName: "pool1", TotalCapacityBytes: 1000,
+		},
+		Status:          datamodel.SessionStatus{FileSystemCreated: true},
+		ActualSizeBytes: 1024,
+		AllocatedBricks: existingBricks,
+	}
+	sessionRegistry.EXPECT().GetSession(sessionName).Return(initialSession, nil)
+	allocationMutex := mock_store.NewMockMutex(mockCtrl)
+	allocations.EXPECT().GetAllocationMutex().Return(allocationMutex, nil)
+	allocationMutex.EXPECT().Lock(context.TODO())
+	newBricks := []datamodel.Brick{{Device: "sdb", BrickHostName: "host2"}}
+	allocations.EXPECT().GetPoolInfo(datamodel.PoolName("pool1")).Return(datamodel.PoolInfo{
+		Pool:            datamodel.Pool{Name: "pool1", GranularityBytes: 1024},
+		AvailableBricks: newBricks,
+	}, nil)
+	updatedSession := initialSession
+	updatedSession.VolumeRequest.TotalCapacityBytes = 1500
+	updatedSession.ActualSizeBytes = 2048
+	updatedSession.AllocatedBricks = append(existingBricks, newBricks...)
+	updatedSession.PendingExpandBricks = newBricks
+	sessionRegistry.EXPECT().UpdateSession(updatedSession).Return(updatedSession, nil)
+	allocationMutex.EXPECT().Unlock(context.TODO())
+	actionChan := make(chan datamodel.SessionAction)
+	actions.EXPECT().SendSessionAction(gomock.Any(), datamodel.SessionExpand, updatedSession).Return(actionChan, nil)
+	sessionMutex.EXPECT().Unlock(context.TODO())
+	go func() {
+		actionChan <- datamodel.SessionAction{}
+		close(actionChan)
+	}()
+
+	err := facade.ExpandSession(sessionName, "pool1", 500)
+
+	assert.Nil(t, err)
+}
+
+func TestSessionFacade_ExpandSession_RetryPending(t *testing.T) {
+	sessionName := datamodel.SessionName("foo")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry, actions: actions}
+
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(sessionName).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	pendingBricks := []datamodel.Brick{{Device: "sdb", BrickHostName: "host2"}}
+	session := datamodel.Session{
+		Name:                sessionName,
+		VolumeRequest:       datamodel.VolumeRequest{MultiJob: true, PoolName: "pool1", TotalCapacityBytes: 1500},
+		Status:              datamodel.SessionStatus{FileSystemCreated: true, Error: "unable to expand: fake"},
+		ActualSizeBytes:     2048,
+		AllocatedBricks:     append([]datamodel.Brick{{Device: "sda", BrickHostName: "host1"}}, pendingBricks...),
+		PendingExpandBricks: pendingBricks,
+	}
+	sessionRegistry.EXPECT().GetSession(sessionName).Return(session, nil)
+	// no new bricks are allocated, the pending ones are sent again
+	actionChan := make(chan datamodel.SessionAction)
+	actions.EXPECT().SendSessionAction(gomock.Any(), datamodel.SessionExpand, session).Return(actionChan, nil)
+	sessionMutex.EXPECT().Unlock(context.TODO())
+	go func() {
+		actionChan <- datamodel.SessionAction{}
+		close(actionChan)
+	}()
+
+	err := facade.ExpandSession(sessionName, "pool1", 500)
+
+	assert.Nil(t, err)
+}
+
+func TestSessionFacade_ExpandSession_BeeGFS(t *testing.T) {
+	sessionName := datamodel.SessionName("foo")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry}
+
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(sessionName).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	// rejected before any bricks are allocated
+	sessionRegistry.EXPECT().GetSession(sessionName).Return(datamodel.Session{
+		Name:               sessionName,
+		VolumeRequest:      datamodel.VolumeRequest{MultiJob: true, PoolName: "pool1"},
+		Status:             datamodel.SessionStatus{FileSystemCreated: true},
+		ActualSizeBytes:    1024,
+		FilesystemSettings: &datamodel.FilesystemSettings{Type: datamodel.BeeGFSFilesystem},
+	}, nil)
+	sessionMutex.EXPECT().Unlock(context.TODO())
+
+	err := facade.ExpandSession(sessionName, "pool1", 500)
+
+	assert.Equal(t, "can't expand session with a beegfs filesystem: foo", err.Error())
+}
+
+func TestSessionFacade_ExpandSession_NotPersistent(t *testing.T) {
+	sessionName := datamodel.SessionName("foo")
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry}
+
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(sessionName).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	sessionRegistry.EXPECT().GetSession(sessionName).Return(datamodel.Session{Name: sessionName}, nil)
+	sessionMutex.EXPECT().Unlock(context.TODO())
+
+	err := facade.ExpandSession(sessionName, "pool1", 500)
+
+	assert.Equal(t, "only persistent buffers can be expanded: foo", err.Error())
+
+	err = facade.ExpandSession(sessionName, "pool1", 0)
+	assert.Equal(t, "must request more than zero bytes to expand session: foo", err.Error())
+}
+
+func TestSessionFacade_CheckCapacity(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	allocations := mock_registry.NewMockAllocationRegistry(mockCtrl)
+	facade := sessionFacade{allocations: allocations}
+
+	allocations.EXPECT().GetPoolInfo(datamodel.PoolName("pool1")).Return(datamodel.PoolInfo{
+		Pool:            datamodel.Pool{Name: "pool1", GranularityBytes: 1024},
+		AvailableBricks: []datamodel.Brick{{Device: "sda"}},
+		AllocatedBricks: []datamodel.BrickAllocation{{Brick: datamodel.Brick{Device: "sdb"}}},
+	}, nil).Times(2)
+
+	check, err := facade.CheckCapacity("pool1", 1500)
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.PoolCapacityCheck{
+		PoolName:        "pool1",
+		RequestedBytes:  1500,
+		ActualSizeBytes: 2048,
+		BricksRequired:  2,
+		FitsNow:         false,
+		FitsEmptyPool:   true,
+	}, check)
+
+	check, err = facade.CheckCapacity("pool1", 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, 1024, check.ActualSizeBytes)
+	assert.True(t, check.FitsNow)
+
+	// bricks that are reserved or unhealthy still count towards an empty pool
+	allocations.EXPECT().GetPoolInfo(datamodel.PoolName("pool1")).Return(datamodel.PoolInfo{
+		Pool:            datamodel.Pool{Name: "pool1", GranularityBytes: 1024},
+		AvailableBricks: []datamodel.Brick{{Device: "sda"}},
+		ReservedBricks:  map[datamodel.SessionName][]datamodel.Brick{"urgent": {{Device: "sdb"}}},
+		UnhealthyBricks: []datamodel.Brick{{Device: "sdc"}},
+	}, nil).Times(2)
+
+	check, err = facade.CheckCapacity("pool1", 3072)
+	assert.Nil(t, err)
+	assert.False(t, check.FitsNow)
+	assert.True(t, check.FitsEmptyPool)
+
+	check, err = facade.CheckCapacity("pool1", 4096)
+	assert.Nil(t, err)
+	assert.False(t, check.FitsEmptyPool)
+}
+
+func TestSessionFacade_CancelSession(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	facade := sessionFacade{session: sessionRegistry, actions: actions}
+	session := datamodel.Session{Name: "foo", PrimaryBrickHost: "host1"}
+	sessionRegistry.EXPECT().GetSession(session.Name).Return(session, nil).Times(2)
+	actions.EXPECT().CancelSessionActions(session).Return([]datamodel.SessionAction{
+		{Uuid: "uuid1", ActionType: datamodel.SessionCopyDataIn, Session: session},
+	}, nil)
+
+	err := facade.CancelSession(session.Name)
+	assert.Nil(t, err)
+
+	actions.EXPECT().CancelSessionActions(session).Return(nil, nil)
+	err = facade.CancelSession(session.Name)
+	assert.Equal(t, "no actions to cancel for session: foo", err.Error())
+}
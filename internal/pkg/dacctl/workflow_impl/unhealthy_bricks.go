@@ -0,0 +1,11 @@
+package workflow_impl
+
+import "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+
+func (s sessionFacade) GetUnhealthyBricks() ([]datamodel.UnhealthyBrick, error) {
+	return s.brickHosts.GetUnhealthyBricks()
+}
+
+func (s sessionFacade) ClearUnhealthyBrick(brick datamodel.Brick) error {
+	return s.brickHosts.ClearUnhealthyBrick(brick)
+}
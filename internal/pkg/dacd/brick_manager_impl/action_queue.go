@@ -0,0 +1,197 @@
+package brick_manager_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+type queuedAction struct {
+	action   datamodel.SessionAction
+	queuedAt time.Time
+	started  bool
+}
+
+// Limits how many actions of each type run at once,
+// while making sure actions for one session run in the order they arrived
+type sessionActionQueue struct {
+	process        func(action datamodel.SessionAction)
+	defaultWorkers uint
+	workers        map[datamodel.SessionActionType]chan struct{}
+
+	// protects everything below
+	lock     sync.Mutex
+	sessions map[datamodel.SessionName][]queuedAction
+	depth    map[datamodel.SessionActionType]int
+	inFlight map[string]datamodel.SessionAction
+	running  sync.WaitGroup
+	stopped  bool
+}
+
+func newSessionActionQueue(process func(action datamodel.SessionAction), defaultWorkers uint,
+	workers map[datamodel.SessionActionType]uint) *sessionActionQueue {
+	queue := &sessionActionQueue{
+		process:        process,
+		defaultWorkers: defaultWorkers,
+		workers:        make(map[datamodel.SessionActionType]chan struct{}),
+		sessions:       make(map[datamodel.SessionName][]queuedAction),
+		depth:          make(map[datamodel.SessionActionType]int),
+		inFlight:       make(map[string]datamodel.SessionAction),
+	}
+	for actionType, limit := range workers {
+		queue.workers[actionType] = make(chan struct{}, limit)
+	}
+	return queue
+}
+
+// Queue the action, it runs after all earlier actions for the same session
+func (q *sessionActionQueue) Add(action datamodel.SessionAction) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	sessionName := action.Session.Name
+	if q.stopped {
+		log.Printf("Not queuing action %s for session %s as shutting down\n", action.ActionType, sessionName)
+		return
+	}
+	pending, sessionActive := q.sessions[sessionName]
+	q.sessions[sessionName] = append(pending, queuedAction{action: action, queuedAt: time.Now()})
+	q.depth[action.ActionType] += 1
+	actionQueueDepth.WithLabelValues(string(action.ActionType)).Inc()
+	log.Printf("Queued action %s for session %s, queue depth %d\n",
+		action.ActionType, sessionName, q.depth[action.ActionType])
+
+	if !sessionActive {
+		q.running.Add(1)
+		go q.processSession(sessionName)
+	}
+}
+
+// Number of queued actions that have not yet started, by action type
+func (q *sessionActionQueue) Depth() map[datamodel.SessionActionType]int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	depth := make(map[datamodel.SessionActionType]int)
+	for actionType, count := range q.depth {
+		if count > 0 {
+			depth[actionType] = count
+		}
+	}
+	return depth
+}
+
+// Actions that are currently being processed, ordered by session name
+func (q *sessionActionQueue) InFlight() []datamodel.SessionAction {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	var actions []datamodel.SessionAction
+	for _, action := range q.inFlight {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Session.Name < actions[j].Session.Name
+	})
+	return actions
+}
+
+// Wait for all started actions to complete
+func (q *sessionActionQueue) Wait() {
+	q.running.Wait()
+}
+
+// Stop starting new actions, any actions that have not been started
+// stay in the keystore, so they are retried on the next start
+func (q *sessionActionQueue) Stop() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.stopped = true
+	for sessionName := range q.sessions {
+		q.dropQueued(sessionName)
+	}
+}
+
+func (q *sessionActionQueue) getWorkers(actionType datamodel.SessionActionType) chan struct{} {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	workers, ok := q.workers[actionType]
+	if !ok {
+		workers = make(chan struct{}, q.defaultWorkers)
+		q.workers[actionType] = workers
+	}
+	return workers
+}
+
+// Takes the next action for the session, or removes the session if none are left
+func (q *sessionActionQueue) nextAction(sessionName datamodel.SessionName) (queuedAction, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	pending := q.sessions[sessionName]
+	if len(pending) == 0 || q.stopped {
+		q.dropQueued(sessionName)
+		return queuedAction{}, false
+	}
+	// leave the action in the queue until it completes,
+	// so new actions for this session don't start a second goroutine
+	return pending[0], true
+}
+
+// Forgets the actions not yet started for the session, caller must hold the lock
+func (q *sessionActionQueue) dropQueued(sessionName datamodel.SessionName) {
+	pending := q.sessions[sessionName]
+	var running []queuedAction
+	for _, queued := range pending {
+		if queued.started {
+			running = append(running, queued)
+			continue
+		}
+		q.depth[queued.action.ActionType] -= 1
+		actionQueueDepth.WithLabelValues(string(queued.action.ActionType)).Dec()
+	}
+	if len(running) == 0 {
+		delete(q.sessions, sessionName)
+	} else {
+		q.sessions[sessionName] = running
+	}
+}
+
+func (q *sessionActionQueue) actionDone(sessionName datamodel.SessionName) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	delete(q.inFlight, q.sessions[sessionName][0].action.Uuid)
+	q.sessions[sessionName] = q.sessions[sessionName][1:]
+}
+
+func (q *sessionActionQueue) processSession(sessionName datamodel.SessionName) {
+	defer q.running.Done()
+	for {
+		next, ok := q.nextAction(sessionName)
+		if !ok {
+			return
+		}
+		action := next.action
+
+		workers := q.getWorkers(action.ActionType)
+		workers <- struct{}{}
+		q.lock.Lock()
+		if q.stopped {
+			// stopped while waiting for a worker, so the action was dropped
+			q.dropQueued(sessionName)
+			q.lock.Unlock()
+			<-workers
+			return
+		}
+		q.depth[action.ActionType] -= 1
+		q.sessions[sessionName][0].started = true
+		q.inFlight[action.Uuid] = action
+		q.lock.Unlock()
+		actionQueueDepth.WithLabelValues(string(action.ActionType)).Dec()
+		actionQueueWait.WithLabelValues(string(action.ActionType)).Observe(time.Since(next.queuedAt).Seconds())
+
+		q.process(action)
+
+		<-workers
+		q.actionDone(sessionName)
+	}
+}
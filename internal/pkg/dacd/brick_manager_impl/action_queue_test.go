@@ -0,0 +1,102 @@
+package brick_manager_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestSessionActionQueue_SessionOrder(t *testing.T) {
+	var lock sync.Mutex
+	processed := make(map[datamodel.SessionName][]string)
+	process := func(action datamodel.SessionAction) {
+		lock.Lock()
+		defer lock.Unlock()
+		processed[action.Session.Name] = append(processed[action.Session.Name], action.Uuid)
+	}
+	queue := newSessionActionQueue(process, 2, nil)
+
+	for _, uuid := range []string{"1", "2", "3", "4"} {
+		queue.Add(datamodel.SessionAction{Uuid: uuid, ActionType: datamodel.SessionMount,
+			Session: datamodel.Session{Name: "foo"}})
+		queue.Add(datamodel.SessionAction{Uuid: uuid, ActionType: datamodel.SessionCopyDataIn,
+			Session: datamodel.Session{Name: "bar"}})
+	}
+	queue.Wait()
+
+	assert.Equal(t, []string{"1", "2", "3", "4"}, processed["foo"])
+	assert.Equal(t, []string{"1", "2", "3", "4"}, processed["bar"])
+	assert.Equal(t, map[datamodel.SessionActionType]int{}, queue.Depth())
+}
+
+func TestSessionActionQueue_WorkerLimits(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan datamodel.SessionActionType, 10)
+	process := func(action datamodel.SessionAction) {
+		started <- action.ActionType
+		<-block
+	}
+	queue := newSessionActionQueue(process, 2,
+		map[datamodel.SessionActionType]uint{datamodel.SessionCopyDataIn: 1})
+
+	for _, name := range []datamodel.SessionName{"a", "b", "c"} {
+		queue.Add(datamodel.SessionAction{Uuid: string(name), ActionType: datamodel.SessionCopyDataIn,
+			Session: datamodel.Session{Name: name}})
+	}
+	for _, name := range []datamodel.SessionName{"d", "e", "f"} {
+		queue.Add(datamodel.SessionAction{Uuid: string(name), ActionType: datamodel.SessionMount,
+			Session: datamodel.Session{Name: name}})
+	}
+
+	// one copy in and two mounts can start, given the limits
+	startedCount := make(map[datamodel.SessionActionType]int)
+	for i := 0; i < 3; i++ {
+		startedCount[<-started] += 1
+	}
+	assert.Equal(t, map[datamodel.SessionActionType]int{
+		datamodel.SessionCopyDataIn: 1, datamodel.SessionMount: 2}, startedCount)
+	assert.Equal(t, map[datamodel.SessionActionType]int{
+		datamodel.SessionCopyDataIn: 2, datamodel.SessionMount: 1}, queue.Depth())
+	inFlight := queue.InFlight()
+	assert.Equal(t, 3, len(inFlight))
+	assert.Equal(t, datamodel.SessionCopyDataIn, inFlight[0].ActionType)
+
+	close(block)
+	queue.Wait()
+	assert.Equal(t, 3, len(started))
+	assert.Equal(t, map[datamodel.SessionActionType]int{}, queue.Depth())
+	assert.Nil(t, queue.InFlight())
+}
+
+func TestSessionActionQueue_Stop(t *testing.T) {
+	gauge := actionQueueDepth.WithLabelValues(string(datamodel.SessionUnmount))
+	initialDepth := testutil.ToFloat64(gauge)
+	block := make(chan struct{})
+	started := make(chan string, 10)
+	process := func(action datamodel.SessionAction) {
+		started <- action.Uuid
+		<-block
+	}
+	queue := newSessionActionQueue(process, 1, nil)
+
+	queue.Add(datamodel.SessionAction{Uuid: "a1", ActionType: datamodel.SessionUnmount,
+		Session: datamodel.Session{Name: "a"}})
+	assert.Equal(t, "a1", <-started)
+	queue.Add(datamodel.SessionAction{Uuid: "a2", ActionType: datamodel.SessionUnmount,
+		Session: datamodel.Session{Name: "a"}})
+	queue.Add(datamodel.SessionAction{Uuid: "b1", ActionType: datamodel.SessionUnmount,
+		Session: datamodel.Session{Name: "b"}})
+	assert.Equal(t, initialDepth+2, testutil.ToFloat64(gauge))
+
+	// actions that have not started are dropped, and no longer counted as queued
+	queue.Stop()
+	assert.Equal(t, map[datamodel.SessionActionType]int{}, queue.Depth())
+	assert.Equal(t, initialDepth, testutil.ToFloat64(gauge))
+	close(block)
+	queue.Wait()
+	assert.Equal(t, 0, len(started))
+	assert.Equal(t, map[datamodel.SessionActionType]int{}, queue.Depth())
+	assert.Equal(t, initialDepth, testutil.ToFloat64(gauge))
+}
@@ -2,23 +2,50 @@ package brick_manager_impl
 
 import (
 	"context"
+	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/workflow_impl"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/facade"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry_impl"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
 	"log"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+var keepAlivePollInterval = time.Millisecond * 100
+
 func NewBrickManager(keystore store.Keystore) dacd.BrickManager {
-	return &brickManager{
-		config:               config.GetBrickManagerConfig(config.DefaultEnv),
+	brickManagerConfig := config.GetBrickManagerConfig(config.DefaultEnv)
+	clusterConfig := newClusterConfigWatcher(registry_impl.NewClusterConfigRegistry(keystore), config.DefaultEnv)
+	sessionActionHandler := newSessionActionHandler(keystore, clusterConfig)
+	manager := &brickManager{
+		config:               brickManagerConfig,
+		clusterConfig:        clusterConfig,
 		brickRegistry:        registry_impl.NewBrickHostRegistry(keystore),
 		sessionRegistry:      registry_impl.NewSessionRegistry(keystore),
 		sessionActions:       registry_impl.NewSessionActionsRegistry(keystore),
-		sessionActionHandler: NewSessionActionHandler(keystore),
+		leaderRegistry:       registry_impl.NewLeaderRegistry(keystore),
+		sessionActionHandler: sessionActionHandler,
+		actionQueue: newSessionActionQueue(sessionActionHandler.ProcessSessionAction,
+			brickManagerConfig.DefaultActionWorkers, brickManagerConfig.ActionWorkers),
+	}
+	if brickManagerConfig.Housekeeping.Enabled {
+		tasks := getHousekeepingTasks(brickManagerConfig.Housekeeping, housekeepingDeps{
+			sessionRegistry: manager.sessionRegistry,
+			sessionActions:  manager.sessionActions,
+			brickRegistry:   manager.brickRegistry,
+			sessionFacade:   workflow_impl.NewSessionFacade(keystore),
+		})
+		manager.housekeeper = newHousekeeper(brickManagerConfig.BrickHostName, manager.leaderRegistry, tasks)
 	}
+	registerLocalSessionsGauge(manager)
+	return manager
 }
 
 type brickManager struct {
@@ -26,7 +53,24 @@ type brickManager struct {
 	brickRegistry        registry.BrickHostRegistry
 	sessionRegistry      registry.SessionRegistry
 	sessionActions       registry.SessionActions
+	leaderRegistry       registry.LeaderRegistry
 	sessionActionHandler facade.SessionActionHandler
+	actionQueue          *sessionActionQueue
+	clusterConfig        *clusterConfigWatcher
+
+	// The datamodel.BrickHost registered at startup, devices are only discovered once
+	brickHost atomic.Value
+
+	// Cancelled on shutdown, to stop the keepalive and watching for new actions
+	stopListening context.CancelFunc
+	restoring     sync.WaitGroup
+	ready         int32
+
+	// Held while reconciling, so shutdown can wait for it to finish
+	reconciling sync.Mutex
+
+	// Nil when this host doesn't take part in the leader election
+	housekeeper *housekeeper
 }
 
 func (bm *brickManager) Hostname() string {
@@ -37,13 +81,22 @@ func (bm *brickManager) Startup() {
 	// TODO: should we get the allocation mutex until we are started the keep alive?
 	// TODO: add a drain configuration?
 
-	err := bm.brickRegistry.UpdateBrickHost(getBrickHost(bm.config))
+	brickHost := getBrickHost(bm.config)
+	err := bm.brickRegistry.UpdateBrickHost(brickHost)
 	if err != nil {
 		log.Panicf("failed to update brick host: %s", err)
 	}
+	bm.brickHost.Store(brickHost)
+
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	bm.stopListening = cancelFunc
+
+	if err := bm.clusterConfig.Start(ctxt); err != nil {
+		log.Panicf("failed to watch cluster config: %s", err)
+	}
 
 	// If we are are enabled, this includes new create session requests
-	events, err := bm.sessionActions.GetSessionActionRequests(context.TODO(), bm.config.BrickHostName)
+	events, err := bm.sessionActions.GetSessionActionRequests(ctxt, bm.config.BrickHostName)
 
 	// Assume we got restarted, first try to finish all pending actions
 	bm.completePendingActions()
@@ -53,21 +106,84 @@ func (bm *brickManager) Startup() {
 	bm.restoreSessions()
 
 	// Tell everyone we are listening
-	err = bm.brickRegistry.KeepAliveHost(context.TODO(), bm.config.BrickHostName)
+	err = bm.brickRegistry.KeepAliveHost(ctxt, bm.config.BrickHostName)
 	if err != nil {
 		log.Panicf("failed to start keep alive host: %s", err)
 	}
 
+	// Only ready once all sessions have been restored
+	go func() {
+		bm.restoring.Wait()
+		if ctxt.Err() == nil {
+			atomic.StoreInt32(&bm.ready, 1)
+			log.Println("Brick manager is ready")
+			if bm.housekeeper != nil {
+				bm.housekeeper.Start(ctxt)
+			}
+			bm.reconcileLoop(ctxt)
+		}
+	}()
+
 	// Process any events, given others know we are alive
 	go func() {
 		for event := range events {
-			// TODO: we could limit the number of workers
-			go bm.sessionActionHandler.ProcessSessionAction(event)
+			bm.actionQueue.Add(event)
+		}
+		if ctxt.Err() == nil {
+			log.Println("ERROR: stopped waiting for new Session Actions")
+		} else {
+			log.Println("Stopped waiting for new Session Actions")
 		}
-		log.Println("ERROR: stopped waiting for new Session Actions")
 	}()
 }
 
+func (bm *brickManager) ActionQueueDepth() map[datamodel.SessionActionType]int {
+	return bm.actionQueue.Depth()
+}
+
+func (bm *brickManager) IsReady() bool {
+	return atomic.LoadInt32(&bm.ready) == 1
+}
+
+func (bm *brickManager) GetStatus() (dacd.BrickManagerStatus, error) {
+	status := dacd.BrickManagerStatus{
+		Hostname:        bm.Hostname(),
+		Ready:           bm.IsReady(),
+		InFlightActions: bm.actionQueue.InFlight(),
+		QueueDepth:      bm.actionQueue.Depth(),
+	}
+	// No bricks until startup has registered them
+	if brickHost, ok := bm.brickHost.Load().(datamodel.BrickHost); ok {
+		status.Bricks = brickHost.Bricks
+	}
+	sessions, err := bm.sessionRegistry.GetAllSessions()
+	if err != nil {
+		return status, fmt.Errorf("unable to fetch all sessions due to: %s", err)
+	}
+	status.LocalSessions = bm.getLocalSessions(sessions)
+	if bm.housekeeper != nil {
+		status.IsLeader = bm.housekeeper.IsLeader()
+	}
+	status.Leader, err = bm.leaderRegistry.GetLeader()
+	if err != nil {
+		return status, fmt.Errorf("unable to get leader due to: %s", err)
+	}
+	return status, nil
+}
+
+func (bm *brickManager) getLocalSessions(sessions []datamodel.Session) []datamodel.Session {
+	var localSessions []datamodel.Session
+	for _, session := range sessions {
+		for _, brick := range session.AllocatedBricks {
+			if brick.BrickHostName == bm.config.BrickHostName {
+				localSessions = append(localSessions, session)
+				break
+			}
+		}
+	}
+	return localSessions
+}
+
 func (bm *brickManager) completePendingActions() {
 	// Assume the service has been restarted, lets
 	// retry any actions that haven't been completed
@@ -91,32 +207,125 @@ func (bm *brickManager) restoreSessions() {
 	if err != nil {
 		log.Panicf("unable to fetch all sessions due to: %s", err)
 	}
-	for _, session := range sessions {
-		hasLocalBrick := false
-		for _, brick := range session.AllocatedBricks {
-			if brick.BrickHostName == bm.config.BrickHostName {
-				hasLocalBrick = true
-			}
-		}
-		if !hasLocalBrick {
-			continue
-		}
-
+	for _, session := range bm.getLocalSessions(sessions) {
 		if session.Status.FileSystemCreated && !session.Status.DeleteRequested {
 			// If we have previously finished creating the session,
 			// and we don't have a pending delete, try to restore the session
 			log.Println("Restoring session with local brick", session.Name)
-			go bm.sessionActionHandler.RestoreSession(session)
+			bm.restoring.Add(1)
+			go func(session datamodel.Session) {
+				defer bm.restoring.Done()
+				// Like in-flight actions, shutdown waits for the restore to finish
+				bm.sessionActionHandler.RestoreSession(context.Background(), session)
+			}(session)
 		} else {
-			// TODO: should we just do the delete here?
+			// Interrupted deletes are completed by the reconcile loop
 			log.Printf("WARNING session in strange state: %+v\n", session)
 		}
 	}
 }
 
-func (bm *brickManager) Shutdown() {
-	// Delete the keepalive key, to stop new actions being sent
-	// Wait for existing actions by trying to get a lock on all
-	// sessions we for which we are the primary brick
-	// TODO...
+// Periodically check sessions still match what is really on the hosts
+func (bm *brickManager) reconcileLoop(ctxt context.Context) {
+	if bm.config.ReconcileInterval == 0 {
+		log.Println("Session reconcile is disabled")
+		return
+	}
+	ticker := time.NewTicker(bm.config.ReconcileInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctxt.Done():
+			return
+		case <-ticker.C:
+			bm.reconcileSessions(ctxt)
+		}
+	}
+}
+
+func (bm *brickManager) reconcileSessions(ctxt context.Context) {
+	bm.reconciling.Lock()
+	defer bm.reconciling.Unlock()
+
+	sessions, err := bm.sessionRegistry.GetAllSessions()
+	if err != nil {
+		log.Printf("unable to reconcile sessions due to: %s\n", err)
+		return
+	}
+	// Skip sessions with queued or running actions, they may be mid change
+	actions, err := bm.sessionActions.GetOutstandingSessionActionRequests(bm.config.BrickHostName)
+	if err != nil {
+		log.Printf("unable to reconcile sessions due to: %s\n", err)
+		return
+	}
+	busy := make(map[datamodel.SessionName]bool)
+	for _, action := range actions {
+		busy[action.Session.Name] = true
+	}
+
+	for _, session := range bm.getLocalSessions(sessions) {
+		if ctxt.Err() != nil {
+			return
+		}
+		// Only the primary brick host looks after each session
+		if session.PrimaryBrickHost != bm.config.BrickHostName || busy[session.Name] {
+			continue
+		}
+		// Let the current session finish, shutdown waits for it
+		bm.sessionActionHandler.ReconcileSession(context.Background(), session.Name, bm.config.ReconcileReportOnly)
+	}
+}
+
+func (bm *brickManager) Shutdown() error {
+	atomic.StoreInt32(&bm.ready, 0)
+
+	// Delete the keepalive key, to stop new actions being sent,
+	// and stop watching for any new actions
+	if bm.stopListening != nil {
+		bm.stopListening()
+	}
+	bm.waitForKeepAliveRemoved()
+
+	// Queued actions stay in the keystore, to be picked up on next start
+	bm.actionQueue.Stop()
+	log.Printf("Waiting up to %s for in-flight actions to finish\n", bm.config.ShutdownTimeout)
+
+	finished := make(chan struct{})
+	go func() {
+		// actions always drop their session mutex when they complete
+		bm.actionQueue.Wait()
+		bm.restoring.Wait()
+		bm.reconciling.Lock()
+		bm.reconciling.Unlock()
+		if bm.housekeeper != nil {
+			bm.housekeeper.Wait()
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		log.Println("All in-flight actions have finished")
+		filesystem_impl.CloseConnections()
+		return nil
+	case <-time.After(bm.config.ShutdownTimeout):
+		return fmt.Errorf("timed out after %s waiting for %d in-flight actions to finish",
+			bm.config.ShutdownTimeout, len(bm.actionQueue.InFlight()))
+	}
+}
+
+func (bm *brickManager) waitForKeepAliveRemoved() {
+	// the keepalive lease is only a few seconds, so don't wait forever
+	for i := 0; i < 100; i++ {
+		alive, err := bm.brickRegistry.IsBrickHostAlive(bm.config.BrickHostName)
+		if err != nil {
+			log.Printf("unable to check keepalive for %s due to: %s\n", bm.config.BrickHostName, err)
+			return
+		}
+		if !alive {
+			log.Println("Removed keepalive for", bm.config.BrickHostName)
+			return
+		}
+		time.Sleep(keepAlivePollInterval)
+	}
+	log.Println("WARNING: keepalive still present for", bm.config.BrickHostName)
 }
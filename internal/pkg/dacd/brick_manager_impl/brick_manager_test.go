@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestBrickManager_Hostname(t *testing.T) {
@@ -24,21 +25,138 @@ func TestBrickManager_Startup(t *testing.T) {
 	sessionActions := mock_registry.NewMockSessionActions(mockCtrl)
 	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
 	handler := mock_facade.NewMockSessionActionHandler(mockCtrl)
+	clusterConfigRegistry := mock_registry.NewMockClusterConfigRegistry(mockCtrl)
 	brickManager := brickManager{
 		config:               config.GetBrickManagerConfig(config.DefaultEnv),
 		brickRegistry:        brickRegistry,
 		sessionActions:       sessionActions,
 		sessionActionHandler: handler,
 		sessionRegistry:      sessionRegistry,
+		clusterConfig:        newClusterConfigWatcher(clusterConfigRegistry, config.DefaultEnv),
 	}
 
 	// TODO...
+	clusterConfigRegistry.EXPECT().WatchClusterConfig(gomock.Any())
+	clusterConfigRegistry.EXPECT().GetClusterConfig()
 	brickRegistry.EXPECT().UpdateBrickHost(gomock.Any())
-	sessionActions.EXPECT().GetSessionActionRequests(context.TODO(), gomock.Any())
+	sessionActions.EXPECT().GetSessionActionRequests(gomock.Any(), gomock.Any())
 	sessionActions.EXPECT().GetOutstandingSessionActionRequests(brickManager.config.BrickHostName)
 	sessionRegistry.EXPECT().GetAllSessions()
 	hostname, _ := os.Hostname()
-	brickRegistry.EXPECT().KeepAliveHost(context.TODO(), datamodel.BrickHostName(hostname))
+	brickRegistry.EXPECT().KeepAliveHost(gomock.Any(), datamodel.BrickHostName(hostname))
 
 	brickManager.Startup()
 }
+
+func TestBrickManager_Shutdown(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	brickRegistry := mock_registry.NewMockBrickHostRegistry(mockCtrl)
+	block := make(chan struct{})
+	started := make(chan struct{})
+	brickManager := brickManager{
+		config:        config.BrickManagerConfig{BrickHostName: "host", ShutdownTimeout: time.Millisecond * 10},
+		brickRegistry: brickRegistry,
+		actionQueue: newSessionActionQueue(func(action datamodel.SessionAction) {
+			close(started)
+			<-block
+		}, 1, nil),
+	}
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	brickManager.stopListening = cancelFunc
+	brickManager.actionQueue.Add(datamodel.SessionAction{ActionType: datamodel.SessionMount,
+		Session: datamodel.Session{Name: "inflight"}})
+	<-started
+	brickManager.actionQueue.Add(datamodel.SessionAction{ActionType: datamodel.SessionMount,
+		Session: datamodel.Session{Name: "queued"}})
+
+	gomock.InOrder(
+		brickRegistry.EXPECT().IsBrickHostAlive(datamodel.BrickHostName("host")).Return(true, nil),
+		brickRegistry.EXPECT().IsBrickHostAlive(datamodel.BrickHostName("host")).Return(false, nil),
+	)
+	keepAlivePollInterval = 0
+
+	err := brickManager.Shutdown()
+
+	assert.NotNil(t, ctxt.Err())
+	assert.Equal(t, "timed out after 10ms waiting for 1 in-flight actions to finish", err.Error())
+	assert.Equal(t, map[datamodel.SessionActionType]int{}, brickManager.ActionQueueDepth())
+
+	brickRegistry.EXPECT().IsBrickHostAlive(datamodel.BrickHostName("host")).Return(false, nil)
+	brickManager.config.ShutdownTimeout = time.Minute
+	close(block)
+	err = brickManager.Shutdown()
+	assert.Nil(t, err)
+}
+
+func TestBrickManager_GetStatus(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	leaderRegistry := mock_registry.NewMockLeaderRegistry(mockCtrl)
+	brickManager := brickManager{
+		config: config.BrickManagerConfig{
+			BrickHostName: "host1", PoolName: "pool1",
+			DeviceCount: 1, DeviceAddressPattern: "nvme%dn1", DeviceCapacityGiB: 10,
+		},
+		sessionRegistry: sessionRegistry,
+		leaderRegistry:  leaderRegistry,
+		actionQueue:     newSessionActionQueue(nil, 1, nil),
+		housekeeper:     newHousekeeper("host1", leaderRegistry, nil),
+	}
+	localSession := datamodel.Session{Name: "local", AllocatedBricks: []datamodel.Brick{
+		{BrickHostName: "host2"}, {BrickHostName: "host1"},
+	}}
+	sessionRegistry.EXPECT().GetAllSessions().Return([]datamodel.Session{
+		localSession,
+		{Name: "remote", AllocatedBricks: []datamodel.Brick{{BrickHostName: "host2"}}},
+	}, nil)
+	leaderRegistry.EXPECT().GetLeader().Return(datamodel.BrickHostName("host2"), nil)
+
+	brickManager.brickHost.Store(getBrickHost(brickManager.config))
+	// the devices are not looked up again
+	brickManager.config.DeviceCount = 2
+
+	status, err := brickManager.GetStatus()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "host1", status.Hostname)
+	assert.False(t, status.Ready)
+	assert.Equal(t, []datamodel.Brick{
+		{Device: "nvme0n1", BrickHostName: "host1", PoolName: "pool1", CapacityGiB: 10},
+	}, status.Bricks)
+	assert.Nil(t, status.InFlightActions)
+	assert.Equal(t, map[datamodel.SessionActionType]int{}, status.QueueDepth)
+	assert.Equal(t, []datamodel.Session{localSession}, status.LocalSessions)
+	assert.False(t, status.IsLeader)
+	assert.Equal(t, datamodel.BrickHostName("host2"), status.Leader)
+
+	brickManager.ready = 1
+	assert.True(t, brickManager.IsReady())
+}
+
+func TestBrickManager_ReconcileSessions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	sessionActions := mock_registry.NewMockSessionActions(mockCtrl)
+	handler := mock_facade.NewMockSessionActionHandler(mockCtrl)
+	brickManager := brickManager{
+		config:               config.BrickManagerConfig{BrickHostName: "host1", ReconcileReportOnly: true},
+		sessionRegistry:      sessionRegistry,
+		sessionActions:       sessionActions,
+		sessionActionHandler: handler,
+	}
+	bricks := []datamodel.Brick{{BrickHostName: "host2"}, {BrickHostName: "host1"}}
+	sessionRegistry.EXPECT().GetAllSessions().Return([]datamodel.Session{
+		{Name: "primary", PrimaryBrickHost: "host1", AllocatedBricks: bricks},
+		{Name: "secondary", PrimaryBrickHost: "host2", AllocatedBricks: bricks},
+		{Name: "busy", PrimaryBrickHost: "host1", AllocatedBricks: bricks},
+		{Name: "remote", PrimaryBrickHost: "host2", AllocatedBricks: []datamodel.Brick{{BrickHostName: "host2"}}},
+	}, nil)
+	sessionActions.EXPECT().GetOutstandingSessionActionRequests(datamodel.BrickHostName("host1")).Return(
+		[]datamodel.SessionAction{{Session: datamodel.Session{Name: "busy"}}}, nil)
+	handler.EXPECT().ReconcileSession(context.Background(), datamodel.SessionName("primary"), true)
+
+	brickManager.reconcileSessions(context.Background())
+}
@@ -0,0 +1,124 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (s *sessionActionHandler) getWipePolicy(poolName datamodel.PoolName) datamodel.WipePolicy {
+	if s.clusterConfig == nil {
+		return datamodel.WipeNone
+	}
+	return s.clusterConfig.GetWipePolicy(poolName)
+}
+
+// Wipe the bricks on every host at once, recording each host as it finishes,
+// so a retried delete only wipes the remaining hosts.
+// Caller must hold the session mutex
+func (s *sessionActionHandler) wipeBricks(ctxt context.Context, session datamodel.Session) (datamodel.Session, error) {
+	policy := s.getWipePolicy(session.VolumeRequest.PoolName)
+	if policy == datamodel.WipeNone {
+		return session, nil
+	}
+
+	wiped := make(map[datamodel.BrickHostName]bool)
+	for _, hostName := range session.Status.WipedBrickHosts {
+		wiped[hostName] = true
+	}
+	bricksByHost := make(map[datamodel.BrickHostName][]datamodel.Brick)
+	for _, brick := range session.AllocatedBricks {
+		if !wiped[brick.BrickHostName] {
+			bricksByHost[brick.BrickHostName] = append(bricksByHost[brick.BrickHostName], brick)
+		}
+	}
+
+	type hostResult struct {
+		hostName datamodel.BrickHostName
+		err      error
+	}
+	results := make(chan hostResult, len(bricksByHost))
+	for hostName, bricks := range bricksByHost {
+		go func(hostName datamodel.BrickHostName, bricks []datamodel.Brick) {
+			results <- hostResult{hostName, s.wipeHostBricks(ctxt, bricks, policy)}
+		}(hostName, bricks)
+	}
+
+	var errs []string
+	for range bricksByHost {
+		result := <-results
+		if result.err != nil {
+			errs = append(errs, result.err.Error())
+			continue
+		}
+		session.Status.WipedBrickHosts = append(session.Status.WipedBrickHosts, result.hostName)
+		updatedSession, err := s.sessionRegistry.UpdateSession(session)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("unable to record wipe on %s due to: %s", result.hostName, err))
+			continue
+		}
+		session = updatedSession
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return session, fmt.Errorf("unable to wipe bricks: %s", strings.Join(errs, "; "))
+	}
+	logging.FromContext(ctxt).Printf("wiped bricks using wipe policy: %s", policy)
+	return session, nil
+}
+
+// Wipe all the given bricks at once
+func (s *sessionActionHandler) wipeHostBricks(ctxt context.Context, bricks []datamodel.Brick,
+	policy datamodel.WipePolicy) error {
+	results := make(chan error, len(bricks))
+	for _, brick := range bricks {
+		go func(brick datamodel.Brick) {
+			results <- s.wipeBrick(ctxt, brick, policy)
+		}(brick)
+	}
+	var errs []string
+	for range bricks {
+		if err := <-results; err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// A brick that fails to wipe is marked unhealthy, so it is never given to another session,
+// and the delete can carry on
+func (s *sessionActionHandler) wipeBrick(ctxt context.Context, brick datamodel.Brick,
+	policy datamodel.WipePolicy) error {
+	err := s.fsProvider.Wipe(ctxt, brick, policy)
+	if err == nil {
+		brickWipes.WithLabelValues(string(policy), "success").Inc()
+		return nil
+	}
+	brickWipes.WithLabelValues(string(policy), "failure").Inc()
+
+	// Don't blame the brick when the action was stopped, the next delete tries again
+	if ctxt.Err() != nil {
+		return fmt.Errorf("wipe of %s on %s stopped: %s", brick.Device, brick.BrickHostName, err)
+	}
+	logging.FromContext(ctxt).Errorf("marking brick %s on %s unhealthy, as wipe failed due to: %s",
+		brick.Device, brick.BrickHostName, err)
+	unhealthyBrick := datamodel.UnhealthyBrick{
+		Brick:    brick,
+		Reason:   fmt.Sprintf("%s wipe failed: %s", policy, err),
+		MarkedAt: uint(time.Now().Unix()),
+	}
+	if markErr := s.brickHosts.MarkBrickUnhealthy(unhealthyBrick); markErr != nil {
+		return fmt.Errorf("wipe of %s on %s failed: %s, and unable to mark it unhealthy due to: %s",
+			brick.Device, brick.BrickHostName, err, markErr)
+	}
+	return nil
+}
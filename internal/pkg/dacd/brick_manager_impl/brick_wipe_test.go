@@ -0,0 +1,111 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func setupWipeTest(mockCtrl *gomock.Controller, policy string) (*sessionActionHandler,
+	*mock_registry.MockSessionRegistry, *mock_filesystem.MockProvider, *mock_registry.MockBrickHostRegistry) {
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	fsProvider := mock_filesystem.NewMockProvider(mockCtrl)
+	brickHosts := mock_registry.NewMockBrickHostRegistry(mockCtrl)
+	clusterConfig := newClusterConfigWatcher(nil, fakeEnv{})
+	clusterConfig.current = datamodel.ClusterConfig{
+		PoolSettings: map[datamodel.PoolName]map[string]string{"secure": {"wipe_policy": policy}},
+	}
+	handler := &sessionActionHandler{sessionRegistry: sessionRegistry, fsProvider: fsProvider,
+		clusterConfig: clusterConfig, brickHosts: brickHosts}
+	return handler, sessionRegistry, fsProvider, brickHosts
+}
+
+var wipeBricks = []datamodel.Brick{
+	{BrickHostName: "dac1", Device: "nvme0n1"},
+	{BrickHostName: "dac1", Device: "nvme1n1"},
+	{BrickHostName: "dac2", Device: "nvme0n1"},
+}
+
+func TestSessionActionHandler_WipeBricks_None(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	handler, _, _, _ := setupWipeTest(mockCtrl, "overwrite")
+	session := datamodel.Session{Name: "job1", AllocatedBricks: wipeBricks,
+		VolumeRequest: datamodel.VolumeRequest{PoolName: "default"}}
+
+	updated, err := handler.wipeBricks(context.TODO(), session)
+
+	assert.Nil(t, err)
+	assert.Equal(t, session, updated)
+}
+
+func TestSessionActionHandler_WipeBricks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	handler, sessionRegistry, fsProvider, brickHosts := setupWipeTest(mockCtrl, "discard")
+	session := datamodel.Session{Name: "job1", Revision: 1, AllocatedBricks: wipeBricks,
+		VolumeRequest: datamodel.VolumeRequest{PoolName: "secure"},
+		Status:        datamodel.SessionStatus{WipedBrickHosts: []datamodel.BrickHostName{"dac2"}}}
+
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[0], datamodel.WipeDiscard)
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[1], datamodel.WipeDiscard).Return(errors.New("fake"))
+	brickHosts.EXPECT().MarkBrickUnhealthy(gomock.Any()).DoAndReturn(func(brick datamodel.UnhealthyBrick) error {
+		assert.Equal(t, wipeBricks[1], brick.Brick)
+		assert.Equal(t, "discard wipe failed: fake", brick.Reason)
+		return nil
+	})
+	expected := session
+	expected.Status.WipedBrickHosts = []datamodel.BrickHostName{"dac2", "dac1"}
+	updated := expected
+	updated.Revision = 2
+	sessionRegistry.EXPECT().UpdateSession(expected).Return(updated, nil)
+
+	result, err := handler.wipeBricks(context.TODO(), session)
+
+	assert.Nil(t, err)
+	assert.Equal(t, updated, result)
+}
+
+func TestSessionActionHandler_WipeBricks_Errors(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	handler, sessionRegistry, fsProvider, brickHosts := setupWipeTest(mockCtrl, "overwrite")
+	session := datamodel.Session{Name: "job1", AllocatedBricks: wipeBricks,
+		VolumeRequest: datamodel.VolumeRequest{PoolName: "secure"}}
+
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[0], datamodel.WipeOverwrite)
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[1], datamodel.WipeOverwrite)
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[2], datamodel.WipeOverwrite).Return(errors.New("fake"))
+	brickHosts.EXPECT().MarkBrickUnhealthy(gomock.Any()).Return(errors.New("etcd down"))
+	sessionRegistry.EXPECT().UpdateSession(gomock.Any()).DoAndReturn(
+		func(session datamodel.Session) (datamodel.Session, error) {
+			assert.Equal(t, []datamodel.BrickHostName{"dac1"}, session.Status.WipedBrickHosts)
+			return session, nil
+		})
+
+	_, err := handler.wipeBricks(context.TODO(), session)
+
+	assert.Equal(t, "unable to wipe bricks: wipe of nvme0n1 on dac2 failed: fake, "+
+		"and unable to mark it unhealthy due to: etcd down", err.Error())
+}
+
+func TestSessionActionHandler_WipeBricks_Cancelled(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	handler, _, fsProvider, _ := setupWipeTest(mockCtrl, "overwrite")
+	session := datamodel.Session{Name: "job1", AllocatedBricks: wipeBricks[2:],
+		VolumeRequest: datamodel.VolumeRequest{PoolName: "secure"}}
+	ctxt, cancelFunc := context.WithCancel(context.TODO())
+	cancelFunc()
+
+	fsProvider.EXPECT().Wipe(ctxt, wipeBricks[2], datamodel.WipeOverwrite).Return(errors.New("killed"))
+
+	_, err := handler.wipeBricks(ctxt, session)
+
+	assert.Equal(t, "unable to wipe bricks: wipe of nvme0n1 on dac2 stopped: killed", err.Error())
+}
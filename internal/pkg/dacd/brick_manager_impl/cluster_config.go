@@ -0,0 +1,66 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
+	"log"
+	"sync"
+)
+
+// Keeps the latest cluster config, so changes apply to new sessions without a restart
+type clusterConfigWatcher struct {
+	registry registry.ClusterConfigRegistry
+	env      config.ReadEnvironemnt
+
+	mutex   sync.RWMutex
+	current datamodel.ClusterConfig
+}
+
+func newClusterConfigWatcher(registry registry.ClusterConfigRegistry, env config.ReadEnvironemnt) *clusterConfigWatcher {
+	return &clusterConfigWatcher{registry: registry, env: env}
+}
+
+// Watch until the context is cancelled
+func (w *clusterConfigWatcher) Start(ctxt context.Context) error {
+	// Start watching before the first get, so no change is missed
+	updates := w.registry.WatchClusterConfig(ctxt)
+	clusterConfig, err := w.registry.GetClusterConfig()
+	if err != nil {
+		return fmt.Errorf("unable to get cluster config due to: %s", err)
+	}
+	w.update(clusterConfig)
+
+	go func() {
+		for clusterConfig := range updates {
+			w.update(clusterConfig)
+		}
+	}()
+	return nil
+}
+
+func (w *clusterConfigWatcher) update(clusterConfig datamodel.ClusterConfig) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	// The watch can report a change the first get already returned,
+	// but zero means the config was deleted
+	if clusterConfig.Revision != 0 && clusterConfig.Revision <= w.current.Revision {
+		return
+	}
+	w.current = clusterConfig
+	log.Printf("Using cluster config: %+v\n", clusterConfig)
+}
+
+func (w *clusterConfigWatcher) GetFilesystemSettings(poolName datamodel.PoolName) datamodel.FilesystemSettings {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return config.GetFilesystemSettings(config.NewClusterEnv(w.env, w.current, poolName))
+}
+
+func (w *clusterConfigWatcher) GetWipePolicy(poolName datamodel.PoolName) datamodel.WipePolicy {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return config.GetWipePolicy(config.NewClusterEnv(w.env, w.current, poolName))
+}
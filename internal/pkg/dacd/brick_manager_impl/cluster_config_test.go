@@ -0,0 +1,70 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type fakeEnv map[string]string
+
+func (env fakeEnv) LookupEnv(key string) (string, bool) {
+	val, ok := env[key]
+	return val, ok
+}
+
+func (env fakeEnv) Hostname() (string, error) {
+	return "hostname", nil
+}
+
+func TestClusterConfigWatcher(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	clusterConfigRegistry := mock_registry.NewMockClusterConfigRegistry(mockCtrl)
+	watcher := newClusterConfigWatcher(clusterConfigRegistry, fakeEnv{
+		"DAC_LNET_SUFFIX": "-local",
+		"DAC_MDT_SIZE_GB": "10",
+	})
+
+	updates := make(chan datamodel.ClusterConfig)
+	ctxt := context.Background()
+	clusterConfigRegistry.EXPECT().WatchClusterConfig(ctxt).Return(updates)
+	clusterConfigRegistry.EXPECT().GetClusterConfig().Return(datamodel.ClusterConfig{
+		Revision: 2,
+		Settings: map[string]string{"max_mdt_count": "4", "mdt_size_mb": "512"},
+		PoolSettings: map[datamodel.PoolName]map[string]string{
+			"fast": {"max_mdt_count": "8", "mgs_device": "sdc"},
+		},
+	}, nil)
+
+	err := watcher.Start(ctxt)
+
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.FilesystemSettings{
+		MGSDevice: "sdb", MaxMDTs: 4, MDTSizeMB: 512, LnetSuffix: "-local",
+		Type: datamodel.LustreFilesystem, Provider: "ansible",
+	}, watcher.GetFilesystemSettings("default"))
+	assert.Equal(t, datamodel.FilesystemSettings{
+		MGSDevice: "sdc", MaxMDTs: 8, MDTSizeMB: 512, LnetSuffix: "-local",
+		Type: datamodel.LustreFilesystem, Provider: "ansible",
+	}, watcher.GetFilesystemSettings("fast"))
+
+	// stale update is ignored
+	updates <- datamodel.ClusterConfig{Revision: 1}
+	assert.Equal(t, uint(4), watcher.GetFilesystemSettings("default").MaxMDTs)
+
+	updates <- datamodel.ClusterConfig{Revision: 3, Settings: map[string]string{"lnet_suffix": "-opa"}}
+	assert.Eventually(t, func() bool {
+		return watcher.GetFilesystemSettings("default").LnetSuffix == "-opa"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, datamodel.FilesystemSettings{
+		MGSDevice: "sdb", MaxMDTs: 24, MDTSizeMB: 10240, LnetSuffix: "-opa",
+		Type: datamodel.LustreFilesystem, Provider: "ansible",
+	}, watcher.GetFilesystemSettings("default"))
+
+	close(updates)
+}
@@ -0,0 +1,123 @@
+package brick_manager_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"io/ioutil"
+	"log"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const bytesInGiB = 1024 * 1024 * 1024
+
+// sysfs always reports size in 512 byte sectors, regardless of the device block size
+const sysfsSectorBytes = 512
+
+type blockDevice struct {
+	Name      string
+	Model     string
+	Serial    string
+	SizeBytes uint64
+}
+
+func readSysfsValue(filename string) string {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// Lists all block devices that report a size, i.e. devices that exist
+func readBlockDevices(sysfsRoot string) ([]blockDevice, error) {
+	blockDir := path.Join(sysfsRoot, "block")
+	entries, err := ioutil.ReadDir(blockDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []blockDevice
+	for _, entry := range entries {
+		deviceDir := path.Join(blockDir, entry.Name())
+		sectors, err := strconv.ParseUint(readSysfsValue(path.Join(deviceDir, "size")), 10, 64)
+		if err != nil || sectors == 0 {
+			log.Printf("Skipping block device %s as unable to read its size\n", entry.Name())
+			continue
+		}
+		devices = append(devices, blockDevice{
+			Name:      entry.Name(),
+			Model:     readSysfsValue(path.Join(deviceDir, "device", "model")),
+			Serial:    readSysfsValue(path.Join(deviceDir, "device", "serial")),
+			SizeBytes: sectors * sysfsSectorBytes,
+		})
+	}
+	return devices, nil
+}
+
+func mustCompile(name string, pattern string) *regexp.Regexp {
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		log.Panicf("invalid %s %s due to: %s", name, pattern, err)
+	}
+	return compiled
+}
+
+func filterBlockDevices(devices []blockDevice, discovery config.BrickDiscoveryConfig) []blockDevice {
+	devicePattern := mustCompile("device pattern", discovery.DevicePattern)
+	modelPattern := mustCompile("model pattern", discovery.ModelPattern)
+	serialPattern := mustCompile("serial pattern", discovery.SerialPattern)
+
+	var matched []blockDevice
+	for _, device := range devices {
+		sizeGiB := device.SizeBytes / bytesInGiB
+		if !devicePattern.MatchString(device.Name) {
+			continue
+		}
+		if !modelPattern.MatchString(device.Model) || !serialPattern.MatchString(device.Serial) {
+			log.Printf("Skipping device %s with model '%s' and serial '%s'\n",
+				device.Name, device.Model, device.Serial)
+			continue
+		}
+		if sizeGiB < uint64(discovery.MinSizeGiB) ||
+			(discovery.MaxSizeGiB > 0 && sizeGiB > uint64(discovery.MaxSizeGiB)) {
+			log.Printf("Skipping device %s with size %dGiB\n", device.Name, sizeGiB)
+			continue
+		}
+		matched = append(matched, device)
+	}
+	return matched
+}
+
+// Find the devices to use as bricks, and the capacity to use for every brick.
+// As all bricks in a pool must be the same size, the smallest device is used
+func discoverDevices(brickManagerConfig config.BrickManagerConfig) ([]string, uint) {
+	allDevices, err := readBlockDevices(brickManagerConfig.Discovery.SysfsRoot)
+	if err != nil {
+		log.Panicf("unable to discover block devices due to: %s", err)
+	}
+	devices := filterBlockDevices(allDevices, brickManagerConfig.Discovery)
+
+	var names []string
+	var capacityGiB uint
+	for _, device := range devices {
+		names = append(names, device.Name)
+		sizeGiB := uint(device.SizeBytes / bytesInGiB)
+		if capacityGiB == 0 || sizeGiB < capacityGiB {
+			capacityGiB = sizeGiB
+		}
+	}
+	for _, device := range devices {
+		if uint(device.SizeBytes/bytesInGiB) != capacityGiB {
+			log.Printf("WARNING: device %s is larger than the smallest device, only using %dGiB\n",
+				device.Name, capacityGiB)
+		}
+	}
+
+	if len(names) != int(brickManagerConfig.DeviceCount) {
+		log.Printf("WARNING: expected %d devices but discovered %d: %s\n",
+			brickManagerConfig.DeviceCount, len(names), strings.Join(names, ","))
+	}
+	return names, capacityGiB
+}
@@ -0,0 +1,119 @@
+package brick_manager_impl
+
+import (
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func writeFakeBlockDevice(t *testing.T, sysfsRoot string, name string, sizeGiB uint64, model string, serial string) {
+	deviceDir := path.Join(sysfsRoot, "block", name)
+	if err := os.MkdirAll(path.Join(deviceDir, "device"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sectors := sizeGiB * bytesInGiB / sysfsSectorBytes
+	files := map[string]string{
+		"size":          fmt.Sprintf("%d\n", sectors),
+		"device/model":  model + "    \n",
+		"device/serial": serial + "\n",
+	}
+	for filename, content := range files {
+		if err := ioutil.WriteFile(path.Join(deviceDir, filename), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getFakeSysfs(t *testing.T) string {
+	sysfsRoot, err := ioutil.TempDir("", "sysfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFakeBlockDevice(t, sysfsRoot, "nvme0n1", 1490, "INTEL SSDPE2KX020T8", "PHLJ0001")
+	writeFakeBlockDevice(t, sysfsRoot, "nvme1n1", 1500, "INTEL SSDPE2KX020T8", "PHLJ0002")
+	writeFakeBlockDevice(t, sysfsRoot, "nvme2n1", 400, "Dell Boot", "BOOT0001")
+	writeFakeBlockDevice(t, sysfsRoot, "sda", 1500, "INTEL SSDPE2KX020T8", "PHLJ0003")
+	// a device that has gone away still has a directory, but no size
+	if err := os.MkdirAll(path.Join(sysfsRoot, "block", "nvme3n1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return sysfsRoot
+}
+
+func TestReadBlockDevices(t *testing.T) {
+	sysfsRoot := getFakeSysfs(t)
+	defer os.RemoveAll(sysfsRoot)
+
+	devices, err := readBlockDevices(sysfsRoot)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 4, len(devices))
+	assert.Equal(t, blockDevice{
+		Name: "nvme0n1", Model: "INTEL SSDPE2KX020T8", Serial: "PHLJ0001", SizeBytes: 1490 * bytesInGiB,
+	}, devices[0])
+	assert.Equal(t, "sda", devices[3].Name)
+
+	_, err = readBlockDevices(path.Join(sysfsRoot, "missing"))
+	assert.NotNil(t, err)
+}
+
+func TestFilterBlockDevices(t *testing.T) {
+	devices := []blockDevice{
+		{Name: "nvme0n1", Model: "INTEL P4600", Serial: "PHLJ1", SizeBytes: 1490 * bytesInGiB},
+		{Name: "nvme1n1", Model: "INTEL P4600", Serial: "BTLJ2", SizeBytes: 1490 * bytesInGiB},
+		{Name: "nvme2n1", Model: "Dell Boot", Serial: "PHLJ3", SizeBytes: 400 * bytesInGiB},
+		{Name: "sda", Model: "INTEL P4600", Serial: "PHLJ4", SizeBytes: 1490 * bytesInGiB},
+	}
+	discovery := config.BrickDiscoveryConfig{DevicePattern: "^nvme"}
+	assert.Equal(t, devices[:3], filterBlockDevices(devices, discovery))
+
+	discovery.ModelPattern = "P4600"
+	assert.Equal(t, devices[:2], filterBlockDevices(devices, discovery))
+
+	discovery.SerialPattern = "^PHLJ"
+	assert.Equal(t, devices[:1], filterBlockDevices(devices, discovery))
+
+	discovery = config.BrickDiscoveryConfig{DevicePattern: "^nvme", MinSizeGiB: 1000}
+	assert.Equal(t, devices[:2], filterBlockDevices(devices, discovery))
+
+	discovery = config.BrickDiscoveryConfig{MaxSizeGiB: 1000}
+	assert.Equal(t, devices[2:3], filterBlockDevices(devices, discovery))
+
+	assert.Panics(t, func() {
+		filterBlockDevices(devices, config.BrickDiscoveryConfig{ModelPattern: "("})
+	})
+}
+
+func TestGetBrickHost_Discovery(t *testing.T) {
+	sysfsRoot := getFakeSysfs(t)
+	defer os.RemoveAll(sysfsRoot)
+	brickManagerConfig := config.BrickManagerConfig{
+		BrickHostName:     "host1",
+		PoolName:          "pool1",
+		DeviceCount:       12,
+		DeviceCapacityGiB: 1400,
+		HostEnabled:       true,
+		Discovery: config.BrickDiscoveryConfig{
+			Enabled:       true,
+			SysfsRoot:     sysfsRoot,
+			DevicePattern: "^nvme[0-9]+n[0-9]+$",
+			ModelPattern:  "^INTEL",
+		},
+	}
+
+	brickHost := getBrickHost(brickManagerConfig)
+
+	assert.Equal(t, datamodel.BrickHost{
+		Name:    "host1",
+		Enabled: true,
+		Bricks: []datamodel.Brick{
+			{Device: "nvme0n1", BrickHostName: "host1", PoolName: "pool1", CapacityGiB: 1490},
+			{Device: "nvme1n1", BrickHostName: "host1", PoolName: "pool1", CapacityGiB: 1490},
+		},
+	}, brickHost)
+}
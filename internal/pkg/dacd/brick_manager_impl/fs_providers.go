@@ -0,0 +1,147 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
+	// Registers the built-in local provider
+	_ "github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_local"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"strings"
+)
+
+// Sends each call to the provider that created the session's filesystem,
+// so each pool can use a different provider
+type providerRouter struct {
+	providers     map[string]filesystem.Provider
+	errors        map[string]error
+	clusterConfig *clusterConfigWatcher
+}
+
+// Creates every registered provider, a provider that fails to start
+// only fails the actions for sessions that use it
+func newProviderRouter(env config.ReadEnvironemnt, clusterConfig *clusterConfigWatcher) filesystem.Provider {
+	router := &providerRouter{
+		providers:     make(map[string]filesystem.Provider),
+		errors:        make(map[string]error),
+		clusterConfig: clusterConfig,
+	}
+	for _, name := range filesystem.GetProviderNames() {
+		factory, _ := filesystem.GetProviderFactory(name)
+		provider, err := factory(config.GetProviderSettings(env, name))
+		if err != nil {
+			logging.FromContext(context.Background()).Errorf("unable to start filesystem provider %s due to: %s",
+				name, err)
+			router.errors[name] = err
+			continue
+		}
+		router.providers[name] = provider
+	}
+	return router
+}
+
+func (r *providerRouter) getProvider(name string) (filesystem.Provider, error) {
+	if provider, ok := r.providers[name]; ok {
+		return provider, nil
+	}
+	if err, ok := r.errors[name]; ok {
+		return nil, fmt.Errorf("filesystem provider %s failed to start: %s", name, err)
+	}
+	return nil, fmt.Errorf("unknown filesystem provider %s, must be one of: %s",
+		name, strings.Join(filesystem.GetProviderNames(), ", "))
+}
+
+// Sessions created before the provider was recorded all used ansible
+func (r *providerRouter) getSessionProvider(session datamodel.Session) (filesystem.Provider, error) {
+	name := filesystem_impl.ProviderName
+	if session.FilesystemSettings != nil && session.FilesystemSettings.Provider != "" {
+		name = session.FilesystemSettings.Provider
+	}
+	return r.getProvider(name)
+}
+
+func (r *providerRouter) Create(ctxt context.Context, session datamodel.Session) (datamodel.FilesystemStatus, error) {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return session.FilesystemStatus, err
+	}
+	return provider.Create(ctxt, session)
+}
+
+func (r *providerRouter) Restore(ctxt context.Context, session datamodel.Session) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.Restore(ctxt, session)
+}
+
+func (r *providerRouter) Delete(ctxt context.Context, session datamodel.Session) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.Delete(ctxt, session)
+}
+
+func (r *providerRouter) Expand(ctxt context.Context, session datamodel.Session, newBricks []datamodel.Brick) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.Expand(ctxt, session, newBricks)
+}
+
+func (r *providerRouter) DataCopyIn(ctxt context.Context, session datamodel.Session) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.DataCopyIn(ctxt, session)
+}
+
+func (r *providerRouter) DataCopyOut(ctxt context.Context, session datamodel.Session) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.DataCopyOut(ctxt, session)
+}
+
+func (r *providerRouter) Mount(ctxt context.Context, session datamodel.Session,
+	attachments datamodel.AttachmentSession, setInitialPermissions bool) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.Mount(ctxt, session, attachments, setInitialPermissions)
+}
+
+func (r *providerRouter) Unmount(ctxt context.Context, session datamodel.Session,
+	attachments datamodel.AttachmentSession) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.Unmount(ctxt, session, attachments)
+}
+
+func (r *providerRouter) CheckMounts(ctxt context.Context, session datamodel.Session) error {
+	provider, err := r.getSessionProvider(session)
+	if err != nil {
+		return err
+	}
+	return provider.CheckMounts(ctxt, session)
+}
+
+// Bricks are wiped by the provider their pool currently uses
+func (r *providerRouter) Wipe(ctxt context.Context, brick datamodel.Brick, policy datamodel.WipePolicy) error {
+	provider, err := r.getProvider(r.clusterConfig.GetFilesystemSettings(brick.PoolName).Provider)
+	if err != nil {
+		return err
+	}
+	return provider.Wipe(ctxt, brick, policy)
+}
@@ -0,0 +1,63 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_filesystem"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewProviderRouter(t *testing.T) {
+	router := newProviderRouter(fakeEnv{}, nil).(*providerRouter)
+	assert.Contains(t, router.providers, "ansible")
+	assert.Contains(t, router.providers, "local")
+	assert.Empty(t, router.errors)
+}
+
+func TestProviderRouter(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	ansible := mock_filesystem.NewMockProvider(mockCtrl)
+	local := mock_filesystem.NewMockProvider(mockCtrl)
+	clusterConfig := newClusterConfigWatcher(nil, fakeEnv{})
+	clusterConfig.current = datamodel.ClusterConfig{
+		PoolSettings: map[datamodel.PoolName]map[string]string{"local": {"provider": "local"}},
+	}
+	router := &providerRouter{
+		providers:     map[string]filesystem.Provider{"ansible": ansible, "local": local},
+		errors:        map[string]error{"broken": errors.New("bad config")},
+		clusterConfig: clusterConfig,
+	}
+	ctxt := context.TODO()
+
+	oldSession := datamodel.Session{Name: "old"}
+	ansible.EXPECT().Delete(ctxt, oldSession)
+	assert.Nil(t, router.Delete(ctxt, oldSession))
+
+	localSession := datamodel.Session{Name: "local",
+		FilesystemSettings: &datamodel.FilesystemSettings{Provider: "local"}}
+	local.EXPECT().Mount(ctxt, localSession, datamodel.AttachmentSession{}, true)
+	assert.Nil(t, router.Mount(ctxt, localSession, datamodel.AttachmentSession{}, true))
+
+	unknownSession := datamodel.Session{Name: "unknown",
+		FilesystemSettings: &datamodel.FilesystemSettings{Provider: "gpfs"}}
+	_, err := router.Create(ctxt, unknownSession)
+	assert.Equal(t, "unknown filesystem provider gpfs, must be one of: ansible, local", err.Error())
+
+	brokenSession := datamodel.Session{Name: "broken",
+		FilesystemSettings: &datamodel.FilesystemSettings{Provider: "broken"}}
+	err = router.Restore(ctxt, brokenSession)
+	assert.Equal(t, "filesystem provider broken failed to start: bad config", err.Error())
+
+	localBrick := datamodel.Brick{BrickHostName: "dac1", Device: "nvme0n1", PoolName: "local"}
+	local.EXPECT().Wipe(ctxt, localBrick, datamodel.WipeDiscard)
+	assert.Nil(t, router.Wipe(ctxt, localBrick, datamodel.WipeDiscard))
+
+	defaultBrick := datamodel.Brick{BrickHostName: "dac1", Device: "nvme0n1", PoolName: "default"}
+	ansible.EXPECT().Wipe(ctxt, defaultBrick, datamodel.WipeDiscard)
+	assert.Nil(t, router.Wipe(ctxt, defaultBrick, datamodel.WipeDiscard))
+}
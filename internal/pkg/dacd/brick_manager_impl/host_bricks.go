@@ -7,7 +7,7 @@ import (
 )
 
 func getDevices(brickManagerConfig config.BrickManagerConfig) []string {
-	// TODO: should check these devices exist
+	// TODO: should check these devices exist, unless using discovery
 	var bricks []string
 	for i := 0; i < int(brickManagerConfig.DeviceCount); i++ {
 		device := fmt.Sprintf(brickManagerConfig.DeviceAddressPattern, i)
@@ -17,13 +17,19 @@ func getDevices(brickManagerConfig config.BrickManagerConfig) []string {
 }
 
 func getBrickHost(brickManagerConfig config.BrickManagerConfig) datamodel.BrickHost {
+	devices := getDevices(brickManagerConfig)
+	capacityGiB := brickManagerConfig.DeviceCapacityGiB
+	if brickManagerConfig.Discovery.Enabled {
+		devices, capacityGiB = discoverDevices(brickManagerConfig)
+	}
+
 	var bricks []datamodel.Brick
-	for _, device := range getDevices(brickManagerConfig) {
+	for _, device := range devices {
 		bricks = append(bricks, datamodel.Brick{
 			Device:        device,
 			BrickHostName: brickManagerConfig.BrickHostName,
 			PoolName:      brickManagerConfig.PoolName,
-			CapacityGiB:   brickManagerConfig.DeviceCapacityGiB,
+			CapacityGiB:   capacityGiB,
 		})
 	}
 
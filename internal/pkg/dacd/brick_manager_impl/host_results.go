@@ -0,0 +1,68 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+)
+
+// Unless the provider says which hosts failed, any of the hosts may still be mounted
+func recordHostResults(attachment *datamodel.AttachmentSession, hosts []string, actionErr error, mounting bool) {
+	hostErrors, knownHosts := actionErr.(filesystem.HostErrors)
+	results := make(map[string]datamodel.AttachmentHostResult)
+	for host, result := range attachment.HostResults {
+		results[host] = result
+	}
+	for _, host := range hosts {
+		result := datamodel.AttachmentHostResult{Mounted: mounting}
+		hostErr, failed := hostErrors[host]
+		if actionErr != nil && !knownHosts {
+			hostErr, failed = actionErr, true
+		}
+		if failed {
+			result.Error = hostErr.Error()
+			// Providers undo the mount on the hosts they say failed, unless that also failed
+			_, stillMounted := hostErr.(filesystem.StillMountedError)
+			result.Mounted = !mounting || !knownHosts || stillMounted
+		}
+		results[host] = result
+	}
+	attachment.HostResults = results
+}
+
+// Hosts that may have the attachment mounted, all of them if nothing was recorded
+func getMountedHosts(attachment datamodel.AttachmentSession) []string {
+	if attachment.HostResults == nil {
+		return attachment.Hosts
+	}
+	var hosts []string
+	for _, host := range attachment.Hosts {
+		if result, ok := attachment.HostResults[host]; !ok || result.Mounted {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// Saves which hosts are mounted, so a rollback or unmount only touches those hosts.
+// Returns the error from the action, if there was one
+func (s *sessionActionHandler) updateHostResults(ctxt context.Context, session datamodel.Session,
+	attachmentKey datamodel.SessionName, hosts []string, actionErr error, mounting bool) (datamodel.Session, error) {
+	attachment, ok := session.CurrentAttachments[attachmentKey]
+	if !ok {
+		return session, actionErr
+	}
+	recordHostResults(&attachment, hosts, actionErr, mounting)
+	session.CurrentAttachments[attachmentKey] = attachment
+
+	updated, err := s.sessionRegistry.UpdateSession(session)
+	if err != nil {
+		if actionErr != nil {
+			logging.FromContext(ctxt).Errorf("unable to record host results for %s due to: %s", session.Name, err)
+			return session, actionErr
+		}
+		return session, err
+	}
+	return updated, actionErr
+}
@@ -0,0 +1,81 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRecordHostResults(t *testing.T) {
+	attachment := datamodel.AttachmentSession{Hosts: []string{"host1", "host2", "host3"}}
+
+	recordHostResults(&attachment, attachment.Hosts,
+		filesystem.HostErrors{"host2": errors.New("fake")}, true)
+	assert.Equal(t, map[string]datamodel.AttachmentHostResult{
+		"host1": {Mounted: true},
+		"host2": {Mounted: false, Error: "fake"},
+		"host3": {Mounted: true},
+	}, attachment.HostResults)
+	assert.Equal(t, []string{"host1", "host3"}, getMountedHosts(attachment))
+
+	recordHostResults(&attachment, []string{"host1", "host3"},
+		filesystem.HostErrors{"host3": errors.New("busy")}, false)
+	assert.Equal(t, map[string]datamodel.AttachmentHostResult{
+		"host1": {Mounted: false},
+		"host2": {Mounted: false, Error: "fake"},
+		"host3": {Mounted: true, Error: "busy"},
+	}, attachment.HostResults)
+	assert.Equal(t, []string{"host3"}, getMountedHosts(attachment))
+
+	// a host that failed to mount, and then failed to unmount, is still mounted
+	recordHostResults(&attachment, []string{"host2"}, filesystem.HostErrors{
+		"host2": filesystem.StillMountedError{MountErr: errors.New("fake"), UnmountErr: errors.New("busy")}}, true)
+	assert.Equal(t, datamodel.AttachmentHostResult{Mounted: true, Error: "fake, then unable to unmount due to: busy"},
+		attachment.HostResults["host2"])
+	assert.Equal(t, []string{"host2", "host3"}, getMountedHosts(attachment))
+
+	// without host errors, any host may be mounted
+	recordHostResults(&attachment, attachment.Hosts, errors.New("fake"), true)
+	assert.Equal(t, attachment.Hosts, getMountedHosts(attachment))
+	assert.Equal(t, "fake", attachment.HostResults["host1"].Error)
+}
+
+func TestGetMountedHosts(t *testing.T) {
+	attachment := datamodel.AttachmentSession{Hosts: []string{"host1", "host2"}}
+	assert.Equal(t, attachment.Hosts, getMountedHosts(attachment))
+
+	attachment.HostResults = map[string]datamodel.AttachmentHostResult{"host1": {}}
+	assert.Equal(t, []string{"host2"}, getMountedHosts(attachment))
+}
+
+func TestSessionActionHandler_UpdateHostResults(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	handler := &sessionActionHandler{sessionRegistry: sessionRegistry}
+	session := datamodel.Session{Name: "job1", CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{
+		"job1": {SessionName: "job1", Hosts: []string{"host1", "host2"}},
+	}}
+
+	sessionRegistry.EXPECT().UpdateSession(gomock.Any()).DoAndReturn(func(session datamodel.Session) (datamodel.Session, error) {
+		assert.Equal(t, map[string]datamodel.AttachmentHostResult{
+			"host1": {Mounted: true},
+			"host2": {Mounted: false, Error: "fake"},
+		}, session.CurrentAttachments["job1"].HostResults)
+		session.Revision = 2
+		return session, errors.New("update failed")
+	})
+	updated, err := handler.updateHostResults(context.TODO(), session, "job1", []string{"host1", "host2"},
+		filesystem.HostErrors{"host2": errors.New("fake")}, true)
+	assert.Equal(t, "failed on 1 hosts: host2: fake", err.Error())
+	assert.Equal(t, int64(0), updated.Revision)
+
+	updated, err = handler.updateHostResults(context.TODO(), session, "job2", nil, nil, true)
+	assert.Nil(t, err)
+	assert.Equal(t, session, updated)
+}
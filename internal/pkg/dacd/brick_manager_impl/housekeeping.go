@@ -0,0 +1,130 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var campaignRetryInterval = time.Second * 10
+
+// A cluster wide task, only run by the elected leader
+type housekeepingTask struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctxt context.Context) error
+}
+
+// Campaigns to be the leader, and while elected runs the housekeeping tasks
+type housekeeper struct {
+	hostname       datamodel.BrickHostName
+	leaderRegistry registry.LeaderRegistry
+	tasks          []housekeepingTask
+
+	leader  int32
+	running sync.WaitGroup
+}
+
+func newHousekeeper(hostname datamodel.BrickHostName, leaderRegistry registry.LeaderRegistry,
+	tasks []housekeepingTask) *housekeeper {
+	return &housekeeper{hostname: hostname, leaderRegistry: leaderRegistry, tasks: tasks}
+}
+
+// Keeps campaigning until the context is done,
+// at which point leadership is given up
+func (h *housekeeper) Start(ctxt context.Context) {
+	h.running.Add(1)
+	go func() {
+		defer h.running.Done()
+		h.campaignLoop(ctxt)
+	}()
+}
+
+func (h *housekeeper) IsLeader() bool {
+	return atomic.LoadInt32(&h.leader) == 1
+}
+
+// Wait for any running tasks to finish, once the context given to Start is done
+func (h *housekeeper) Wait() {
+	h.running.Wait()
+}
+
+func (h *housekeeper) campaignLoop(ctxt context.Context) {
+	ctxt = logging.WithFields(ctxt, logging.Fields{logging.TaskField: "leader_election"})
+	for ctxt.Err() == nil {
+		lost, err := h.leaderRegistry.Campaign(ctxt, h.hostname)
+		if err != nil {
+			if ctxt.Err() != nil {
+				break
+			}
+			logging.FromContext(ctxt).Errorf("unable to campaign for leader, retrying in %s, due to: %s",
+				campaignRetryInterval, err)
+			select {
+			case <-ctxt.Done():
+			case <-time.After(campaignRetryInterval):
+			}
+			continue
+		}
+		h.lead(ctxt, lost)
+	}
+	logging.FromContext(ctxt).Info("stopped campaigning for leader")
+}
+
+func (h *housekeeper) lead(ctxt context.Context, lost <-chan struct{}) {
+	logging.FromContext(ctxt).Infof("elected leader, starting %d housekeeping tasks", len(h.tasks))
+	h.setLeader(true)
+	defer h.setLeader(false)
+
+	leaderCtxt, cancelFunc := context.WithCancel(ctxt)
+	defer cancelFunc()
+	var tasks sync.WaitGroup
+	for _, task := range h.tasks {
+		tasks.Add(1)
+		go func(task housekeepingTask) {
+			defer tasks.Done()
+			runHousekeepingTask(leaderCtxt, task)
+		}(task)
+	}
+
+	select {
+	case <-lost:
+		logging.FromContext(ctxt).Warn("lost leadership, stopping housekeeping tasks")
+	case <-ctxt.Done():
+		logging.FromContext(ctxt).Info("giving up leadership, stopping housekeeping tasks")
+	}
+	cancelFunc()
+	tasks.Wait()
+}
+
+func (h *housekeeper) setLeader(isLeader bool) {
+	if isLeader {
+		atomic.StoreInt32(&h.leader, 1)
+		housekeepingLeader.Set(1)
+	} else {
+		atomic.StoreInt32(&h.leader, 0)
+		housekeepingLeader.Set(0)
+	}
+}
+
+func runHousekeepingTask(ctxt context.Context, task housekeepingTask) {
+	ctxt = logging.WithFields(ctxt, logging.Fields{logging.TaskField: task.Name})
+	ticker := time.NewTicker(task.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctxt.Done():
+			return
+		case <-ticker.C:
+			if err := task.Run(ctxt); err != nil {
+				logging.FromContext(ctxt).Errorf("housekeeping task %s failed due to: %s", task.Name, err)
+				observeHousekeepingRun(task.Name, "failure")
+			} else {
+				observeHousekeepingRun(task.Name, "success")
+			}
+		}
+	}
+}
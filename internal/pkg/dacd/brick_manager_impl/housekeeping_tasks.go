@@ -0,0 +1,194 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/facade"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
+	"sort"
+	"time"
+)
+
+// Idle buffers are not urgent, so no need to check very often
+var idleBufferCheckInterval = time.Minute * 10
+
+// Used to run the housekeeping tasks
+type housekeepingDeps struct {
+	sessionRegistry registry.SessionRegistry
+	sessionActions  registry.SessionActions
+	brickRegistry   registry.BrickHostRegistry
+	sessionFacade   facade.Session
+}
+
+func getHousekeepingTasks(housekeepingConfig config.HousekeepingConfig, deps housekeepingDeps) []housekeepingTask {
+	tasks := []housekeepingTask{
+		{Name: "stale_actions", Interval: housekeepingConfig.StaleActionsInterval, Run: deps.deleteStaleActions},
+		{Name: "dead_hosts", Interval: housekeepingConfig.DeadHostsInterval, Run: deps.checkDeadHosts},
+		{Name: "consistency", Interval: housekeepingConfig.ConsistencyCheckInterval, Run: deps.checkConsistency},
+	}
+	if housekeepingConfig.IdleBufferExpiry > 0 {
+		expiry := housekeepingConfig.IdleBufferExpiry
+		tasks = append(tasks, housekeepingTask{
+			Name:     "idle_buffers",
+			Interval: idleBufferCheckInterval,
+			Run: func(ctxt context.Context) error {
+				return deps.expireIdleBuffers(ctxt, expiry)
+			},
+		})
+	}
+	return tasks
+}
+
+func (d housekeepingDeps) deleteStaleActions(ctxt context.Context) error {
+	deleted, err := d.sessionActions.DeleteStaleSessionActions(time.Now())
+	if deleted > 0 {
+		logging.FromContext(ctxt).Infof("deleted %d stale session action keys", deleted)
+	}
+	return err
+}
+
+func (d housekeepingDeps) checkDeadHosts(ctxt context.Context) error {
+	hosts, err := d.brickRegistry.GetAllBrickHosts()
+	if err != nil {
+		return fmt.Errorf("unable to get brick hosts due to: %s", err)
+	}
+	dead := make(map[datamodel.BrickHostName]bool)
+	for _, host := range hosts {
+		alive, err := d.brickRegistry.IsBrickHostAlive(host.Name)
+		if err != nil {
+			return fmt.Errorf("unable to check brick host %s due to: %s", host.Name, err)
+		}
+		if !alive {
+			dead[host.Name] = true
+		}
+	}
+	deadBrickHosts.Set(float64(len(dead)))
+	if len(dead) == 0 {
+		return nil
+	}
+
+	sessions, err := d.sessionRegistry.GetAllSessions()
+	if err != nil {
+		return fmt.Errorf("unable to get sessions due to: %s", err)
+	}
+	for _, session := range sessions {
+		if dead[session.PrimaryBrickHost] {
+			logging.FromContext(ctxt).Warnf("session %s can't be updated while its primary brick host %s is down",
+				session.Name, session.PrimaryBrickHost)
+		}
+	}
+	for name := range dead {
+		logging.FromContext(ctxt).Warnf("brick host %s is not alive", name)
+	}
+	return nil
+}
+
+func (d housekeepingDeps) checkConsistency(ctxt context.Context) error {
+	sessions, err := d.sessionRegistry.GetAllSessions()
+	if err != nil {
+		return fmt.Errorf("unable to get sessions due to: %s", err)
+	}
+	hosts, err := d.brickRegistry.GetAllBrickHosts()
+	if err != nil {
+		return fmt.Errorf("unable to get brick hosts due to: %s", err)
+	}
+	problems := findInconsistencies(sessions, hosts)
+	for _, problem := range problems {
+		logging.FromContext(ctxt).Warnf("inconsistency found: %s", problem)
+	}
+	inconsistencies.Set(float64(len(problems)))
+	return nil
+}
+
+// Look for sessions that don't match the registered bricks
+func findInconsistencies(sessions []datamodel.Session, hosts []datamodel.BrickHost) []string {
+	registeredHosts := make(map[datamodel.BrickHostName]bool)
+	registeredBricks := make(map[datamodel.Brick]bool)
+	for _, host := range hosts {
+		registeredHosts[host.Name] = true
+		for _, brick := range host.Bricks {
+			registeredBricks[datamodel.Brick{BrickHostName: brick.BrickHostName, Device: brick.Device}] = true
+		}
+	}
+
+	var problems []string
+	allocatedTo := make(map[datamodel.Brick]datamodel.SessionName)
+	for _, session := range sessions {
+		if len(session.AllocatedBricks) == 0 {
+			continue
+		}
+		if !registeredHosts[session.PrimaryBrickHost] {
+			problems = append(problems, fmt.Sprintf("session %s has unregistered primary brick host %s",
+				session.Name, session.PrimaryBrickHost))
+		}
+		hasPrimaryBrick := false
+		for _, brick := range session.AllocatedBricks {
+			key := datamodel.Brick{BrickHostName: brick.BrickHostName, Device: brick.Device}
+			if owner, ok := allocatedTo[key]; ok {
+				problems = append(problems, fmt.Sprintf("brick %s on %s allocated to both %s and %s",
+					brick.Device, brick.BrickHostName, owner, session.Name))
+			}
+			allocatedTo[key] = session.Name
+			if !registeredBricks[key] {
+				problems = append(problems, fmt.Sprintf("session %s has unregistered brick %s on %s",
+					session.Name, brick.Device, brick.BrickHostName))
+			}
+			if brick.BrickHostName == session.PrimaryBrickHost {
+				hasPrimaryBrick = true
+			}
+		}
+		if !hasPrimaryBrick {
+			problems = append(problems, fmt.Sprintf("session %s has no bricks on its primary brick host %s",
+				session.Name, session.PrimaryBrickHost))
+		}
+	}
+	return problems
+}
+
+// Find persistent buffers no job has used since the given time
+func findIdleBuffers(sessions []datamodel.Session, usedBefore time.Time) []datamodel.SessionName {
+	var idle []datamodel.SessionName
+	referenced := datamodel.GetReferencedBuffers(sessions)
+	for _, session := range sessions {
+		if !session.VolumeRequest.MultiJob || session.Status.DeleteRequested || session.Preemption != nil {
+			continue
+		}
+		if !session.IsIdle() || referenced[session.Name] {
+			continue
+		}
+		lastUsed := session.CreatedAt
+		if session.LastUsedAt > lastUsed {
+			lastUsed = session.LastUsedAt
+		}
+		if int64(lastUsed) < usedBefore.Unix() {
+			idle = append(idle, session.Name)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool { return idle[i] < idle[j] })
+	return idle
+}
+
+func (d housekeepingDeps) expireIdleBuffers(ctxt context.Context, expiry time.Duration) error {
+	sessions, err := d.sessionRegistry.GetAllSessions()
+	if err != nil {
+		return fmt.Errorf("unable to get sessions due to: %s", err)
+	}
+	var failed []string
+	for _, name := range findIdleBuffers(sessions, time.Now().Add(-expiry)) {
+		if ctxt.Err() != nil {
+			break
+		}
+		logging.FromContext(ctxt).Infof("deleting persistent buffer %s, unused for more than %s", name, expiry)
+		if err := d.sessionFacade.DeleteSession(name, false); err != nil {
+			logging.FromContext(ctxt).Errorf("unable to delete idle buffer %s due to: %s", name, err)
+			failed = append(failed, string(name))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to delete idle buffers: %v", failed)
+	}
+	return nil
+}
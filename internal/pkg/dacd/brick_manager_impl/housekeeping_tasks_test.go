@@ -0,0 +1,144 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_facade"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetHousekeepingTasks(t *testing.T) {
+	housekeepingConfig := config.HousekeepingConfig{
+		StaleActionsInterval: time.Minute, DeadHostsInterval: time.Second, ConsistencyCheckInterval: time.Hour,
+	}
+	tasks := getHousekeepingTasks(housekeepingConfig, housekeepingDeps{})
+	assert.Equal(t, 3, len(tasks))
+	assert.Equal(t, "stale_actions", tasks[0].Name)
+	assert.Equal(t, time.Minute, tasks[0].Interval)
+	assert.Equal(t, "dead_hosts", tasks[1].Name)
+	assert.Equal(t, "consistency", tasks[2].Name)
+
+	housekeepingConfig.IdleBufferExpiry = time.Hour * 24
+	tasks = getHousekeepingTasks(housekeepingConfig, housekeepingDeps{})
+	assert.Equal(t, 4, len(tasks))
+	assert.Equal(t, "idle_buffers", tasks[3].Name)
+	assert.Equal(t, idleBufferCheckInterval, tasks[3].Interval)
+}
+
+func TestHousekeeping_CheckDeadHosts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	brickRegistry := mock_registry.NewMockBrickHostRegistry(mockCtrl)
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	deps := housekeepingDeps{brickRegistry: brickRegistry, sessionRegistry: sessionRegistry}
+
+	brickRegistry.EXPECT().GetAllBrickHosts().Return([]datamodel.BrickHost{{Name: "host1"}, {Name: "host2"}}, nil)
+	brickRegistry.EXPECT().IsBrickHostAlive(datamodel.BrickHostName("host1")).Return(true, nil)
+	brickRegistry.EXPECT().IsBrickHostAlive(datamodel.BrickHostName("host2")).Return(false, nil)
+	sessionRegistry.EXPECT().GetAllSessions().Return([]datamodel.Session{{Name: "foo", PrimaryBrickHost: "host2"}}, nil)
+
+	err := deps.checkDeadHosts(context.Background())
+	assert.Nil(t, err)
+
+	brickRegistry.EXPECT().GetAllBrickHosts().Return(nil, errors.New("fake"))
+	err = deps.checkDeadHosts(context.Background())
+	assert.Equal(t, "unable to get brick hosts due to: fake", err.Error())
+}
+
+func TestFindInconsistencies(t *testing.T) {
+	hosts := []datamodel.BrickHost{
+		{Name: "host1", Bricks: []datamodel.Brick{
+			{Device: "nvme0n1", BrickHostName: "host1"}, {Device: "nvme1n1", BrickHostName: "host1"},
+		}},
+		{Name: "host2", Bricks: []datamodel.Brick{{Device: "nvme0n1", BrickHostName: "host2"}}},
+	}
+	sessions := []datamodel.Session{
+		{Name: "good", PrimaryBrickHost: "host1", AllocatedBricks: []datamodel.Brick{
+			{Device: "nvme0n1", BrickHostName: "host1"},
+		}},
+		{Name: "empty"},
+		{Name: "double", PrimaryBrickHost: "host1", AllocatedBricks: []datamodel.Brick{
+			{Device: "nvme0n1", BrickHostName: "host1"},
+		}},
+		{Name: "unregistered", PrimaryBrickHost: "host3", AllocatedBricks: []datamodel.Brick{
+			{Device: "nvme0n1", BrickHostName: "host3"},
+		}},
+		{Name: "noprimary", PrimaryBrickHost: "host1", AllocatedBricks: []datamodel.Brick{
+			{Device: "nvme0n1", BrickHostName: "host2"},
+		}},
+	}
+
+	problems := findInconsistencies(sessions, hosts)
+
+	assert.Equal(t, []string{
+		"brick nvme0n1 on host1 allocated to both good and double",
+		"session unregistered has unregistered primary brick host host3",
+		"session unregistered has unregistered brick nvme0n1 on host3",
+		"session noprimary has no bricks on its primary brick host host1",
+	}, problems)
+	assert.Nil(t, findInconsistencies(sessions[:2], hosts))
+}
+
+func TestFindIdleBuffers(t *testing.T) {
+	now := time.Now()
+	old := uint(now.Add(-time.Hour * 48).Unix())
+	recent := uint(now.Add(-time.Hour).Unix())
+	sessions := []datamodel.Session{
+		{Name: "job", CreatedAt: old},
+		{Name: "unused", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+		{Name: "new", CreatedAt: recent, VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+		{Name: "recentlyused", CreatedAt: old, LastUsedAt: recent,
+			VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+		{Name: "attached", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true},
+			CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{"job": {}}},
+		{Name: "deleting", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true},
+			Status: datamodel.SessionStatus{DeleteRequested: true}},
+		{Name: "preempted", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true},
+			Preemption: &datamodel.PreemptionRecord{}},
+		{Name: "alsounused", CreatedAt: old, LastUsedAt: old,
+			VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+		{Name: "referenced", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+		{Name: "job2", CreatedAt: old, MultiJobAttachments: []datamodel.SessionName{"referenced"}},
+	}
+
+	idle := findIdleBuffers(sessions, now.Add(-time.Hour*24))
+
+	assert.Equal(t, []datamodel.SessionName{"alsounused", "unused"}, idle)
+}
+
+func TestHousekeeping_ExpireIdleBuffers(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	sessionFacade := mock_facade.NewMockSession(mockCtrl)
+	deps := housekeepingDeps{sessionRegistry: sessionRegistry, sessionFacade: sessionFacade}
+
+	old := uint(time.Now().Add(-time.Hour * 2).Unix())
+	sessionRegistry.EXPECT().GetAllSessions().Return([]datamodel.Session{
+		{Name: "a", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+		{Name: "b", CreatedAt: old, VolumeRequest: datamodel.VolumeRequest{MultiJob: true}},
+	}, nil)
+	sessionFacade.EXPECT().DeleteSession(datamodel.SessionName("a"), false).Return(errors.New("fake"))
+	sessionFacade.EXPECT().DeleteSession(datamodel.SessionName("b"), false)
+
+	err := deps.expireIdleBuffers(context.Background(), time.Hour)
+
+	assert.Equal(t, "unable to delete idle buffers: [a]", err.Error())
+}
+
+func TestHousekeeping_DeleteStaleActions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionActions := mock_registry.NewMockSessionActions(mockCtrl)
+	deps := housekeepingDeps{sessionActions: sessionActions}
+
+	sessionActions.EXPECT().DeleteStaleSessionActions(gomock.Any()).Return(2, nil)
+
+	assert.Nil(t, deps.deleteStaleActions(context.Background()))
+}
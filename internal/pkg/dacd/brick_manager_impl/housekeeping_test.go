@@ -0,0 +1,75 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestHousekeeper_RunsTasksWhileLeader(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	leaderRegistry := mock_registry.NewMockLeaderRegistry(mockCtrl)
+	campaignRetryInterval = time.Millisecond
+
+	ran := make(chan string, 10)
+	runTask := func(ctxt context.Context) error {
+		ran <- "task"
+		return nil
+	}
+	housekeeper := newHousekeeper("host1", leaderRegistry, []housekeepingTask{
+		{Name: "task", Interval: time.Millisecond, Run: runTask},
+	})
+
+	lost := make(chan struct{})
+	secondTerm := make(chan struct{})
+	gomock.InOrder(
+		leaderRegistry.EXPECT().Campaign(gomock.Any(), datamodel.BrickHostName("host1")).
+			Return(nil, errors.New("fake")),
+		leaderRegistry.EXPECT().Campaign(gomock.Any(), datamodel.BrickHostName("host1")).
+			Return(lost, nil),
+		leaderRegistry.EXPECT().Campaign(gomock.Any(), datamodel.BrickHostName("host1")).
+			DoAndReturn(func(ctxt context.Context, hostname datamodel.BrickHostName) (<-chan struct{}, error) {
+				close(secondTerm)
+				<-ctxt.Done()
+				return nil, ctxt.Err()
+			}),
+	)
+
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	housekeeper.Start(ctxt)
+
+	assert.Equal(t, "task", <-ran)
+	assert.True(t, housekeeper.IsLeader())
+
+	close(lost)
+	<-secondTerm
+	assert.False(t, housekeeper.IsLeader())
+
+	cancelFunc()
+	housekeeper.Wait()
+	assert.False(t, housekeeper.IsLeader())
+}
+
+func TestRunHousekeepingTask_StopsWhenDone(t *testing.T) {
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	runs := 0
+	task := housekeepingTask{Name: "fail", Interval: time.Millisecond, Run: func(ctxt context.Context) error {
+		runs += 1
+		assert.Equal(t, "fail", logging.FromContext(ctxt).Data[logging.TaskField])
+		if runs == 2 {
+			cancelFunc()
+		}
+		return errors.New("fake")
+	}}
+
+	runHousekeepingTask(ctxt, task)
+
+	assert.Equal(t, 2, runs)
+}
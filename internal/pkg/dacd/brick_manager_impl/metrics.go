@@ -0,0 +1,108 @@
+package brick_manager_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"log"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// 0.5 seconds up to around an hour, as creates can take tens of minutes
+	actionBuckets = prometheus.ExponentialBuckets(0.5, 2, 14)
+
+	actionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dac_session_actions_total",
+		Help: "Number of session actions processed, by action type and result.",
+	}, []string{"action_type", "result"})
+
+	actionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dac_session_action_duration_seconds",
+		Help:    "Time taken to process session actions, including waiting for the session mutex.",
+		Buckets: actionBuckets,
+	}, []string{"action_type", "result"})
+
+	actionQueueWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dac_session_action_queue_wait_seconds",
+		Help:    "Time session actions spent queued waiting for a free worker.",
+		Buckets: actionBuckets,
+	}, []string{"action_type"})
+
+	actionQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "dac_session_action_queue_depth",
+		Help: "Number of session actions waiting for a free worker.",
+	}, []string{"action_type"})
+
+	reconcileProblems = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dac_reconcile_problems_total",
+		Help: "Number of problems found while reconciling sessions, by problem.",
+	}, []string{"problem"})
+
+	brickWipes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dac_brick_wipes_total",
+		Help: "Number of bricks wiped when deleting a buffer, by wipe policy and result.",
+	}, []string{"policy", "result"})
+
+	housekeepingLeader = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dac_housekeeping_leader",
+		Help: "One when this host is the leader running the cluster wide housekeeping tasks.",
+	})
+
+	housekeepingRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dac_housekeeping_runs_total",
+		Help: "Number of housekeeping task runs, by task and result.",
+	}, []string{"task", "result"})
+
+	deadBrickHosts = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dac_dead_brick_hosts",
+		Help: "Number of registered brick hosts that are not alive, set by the leader.",
+	})
+
+	inconsistencies = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "dac_inconsistencies",
+		Help: "Number of sessions and bricks found not to match by the consistency check, set by the leader.",
+	})
+)
+
+func getResultLabel(action datamodel.SessionAction) string {
+	if action.Error != "" {
+		return "failure"
+	}
+	return "success"
+}
+
+func observeSessionAction(action datamodel.SessionAction, duration time.Duration) {
+	result := getResultLabel(action)
+	actionsTotal.WithLabelValues(string(action.ActionType), result).Inc()
+	actionDuration.WithLabelValues(string(action.ActionType), result).Observe(duration.Seconds())
+}
+
+var registerLocalSessionsOnce sync.Once
+
+// Only one brick manager per process, but the gauge must only be registered once
+func registerLocalSessionsGauge(bm *brickManager) {
+	registerLocalSessionsOnce.Do(func() {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "dac_local_sessions",
+			Help: "Number of sessions with at least one brick on this host.",
+		}, func() float64 {
+			sessions, err := bm.sessionRegistry.GetAllSessions()
+			if err != nil {
+				log.Println("unable to count local sessions due to:", err)
+				return math.NaN()
+			}
+			return float64(len(bm.getLocalSessions(sessions)))
+		})
+	})
+}
+
+func observeReconcileProblem(problem string) {
+	reconcileProblems.WithLabelValues(problem).Inc()
+}
+
+func observeHousekeepingRun(task string, result string) {
+	housekeepingRuns.WithLabelValues(task, result).Inc()
+}
@@ -0,0 +1,23 @@
+package brick_manager_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestObserveSessionAction(t *testing.T) {
+	success := actionsTotal.WithLabelValues(string(datamodel.SessionExpand), "success")
+	failure := actionsTotal.WithLabelValues(string(datamodel.SessionExpand), "failure")
+	initialSuccess := testutil.ToFloat64(success)
+	initialFailure := testutil.ToFloat64(failure)
+
+	observeSessionAction(datamodel.SessionAction{ActionType: datamodel.SessionExpand}, time.Second)
+	observeSessionAction(datamodel.SessionAction{ActionType: datamodel.SessionExpand, Error: "fake"}, time.Second)
+	observeSessionAction(datamodel.SessionAction{ActionType: datamodel.SessionExpand, Error: "fake"}, time.Second)
+
+	assert.Equal(t, initialSuccess+1, testutil.ToFloat64(success))
+	assert.Equal(t, initialFailure+2, testutil.ToFloat64(failure))
+}
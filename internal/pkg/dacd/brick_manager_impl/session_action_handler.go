@@ -4,23 +4,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/facade"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
-	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry_impl"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
 	"log"
+	"strings"
+	"sync"
+	"time"
 )
 
-func NewSessionActionHandler(keystore store.Keystore) facade.SessionActionHandler {
+func newSessionActionHandler(keystore store.Keystore, clusterConfig *clusterConfigWatcher) facade.SessionActionHandler {
 	return &sessionActionHandler{
 		registry_impl.NewSessionRegistry(keystore),
 		registry_impl.NewSessionActionsRegistry(keystore),
-		// TODO: fix up fsprovider!!
-		filesystem_impl.NewFileSystemProvider(nil),
+		newProviderRouter(config.DefaultEnv, clusterConfig),
 		false,
+		clusterConfig,
+		registry_impl.NewBrickHostRegistry(keystore),
 	}
 }
 
@@ -29,6 +34,8 @@ type sessionActionHandler struct {
 	actions         registry.SessionActions
 	fsProvider      filesystem.Provider
 	skipActions     bool
+	clusterConfig   *clusterConfigWatcher
+	brickHosts      registry.BrickHostRegistry
 }
 
 func (s *sessionActionHandler) ProcessSessionAction(action datamodel.SessionAction) {
@@ -45,51 +52,181 @@ func (s *sessionActionHandler) ProcessSessionAction(action datamodel.SessionActi
 		s.handleUnmount(action)
 	case datamodel.SessionCopyDataOut:
 		s.handleCopyOut(action)
+	case datamodel.SessionExpand:
+		s.handleExpand(action)
+	case datamodel.SessionPreempt:
+		s.handlePreempt(action)
 	default:
 		log.Panicf("not yet implemented action for %+v", action)
 	}
 }
 
-func (s *sessionActionHandler) processWithMutex(action datamodel.SessionAction, process func() (datamodel.Session, error)) {
+// Stop the action at its deadline, or when someone asks for it to be cancelled
+func (s *sessionActionHandler) getActionContext(action datamodel.SessionAction) (context.Context, context.CancelFunc) {
+	ctxt := logging.WithFields(context.Background(), logging.Fields{
+		logging.SessionField:    action.Session.Name,
+		logging.ActionField:     action.ActionType,
+		logging.ActionUuidField: action.Uuid,
+		logging.CallerField:     action.Caller,
+	})
+	cancelDeadline := func() {}
+	if !action.Deadline.IsZero() {
+		ctxt, cancelDeadline = context.WithDeadline(ctxt, action.Deadline)
+	}
+	ctxt, cancelFunc := context.WithCancel(ctxt)
+
+	cancelled, err := s.actions.WatchForCancel(ctxt, action)
+	if err != nil {
+		logging.FromContext(ctxt).Errorf("unable to watch for cancel of action due to: %s", err)
+	} else {
+		go func() {
+			select {
+			case <-cancelled:
+				logging.FromContext(ctxt).Warn("cancelling action")
+				cancelFunc()
+			case <-ctxt.Done():
+			}
+		}()
+	}
+	return ctxt, func() {
+		cancelFunc()
+		cancelDeadline()
+	}
+}
 
+// Make it clear when an error is due to the action being stopped
+func getActionError(ctxt context.Context, err error) string {
+	switch ctxt.Err() {
+	case context.Canceled:
+		return fmt.Sprintf("action cancelled: %s", err)
+	case context.DeadlineExceeded:
+		return fmt.Sprintf("action deadline exceeded: %s", err)
+	default:
+		return err.Error()
+	}
+}
+
+func (s *sessionActionHandler) processWithMutex(action datamodel.SessionAction,
+	process func(ctxt context.Context) (datamodel.Session, error)) {
+	startTime := time.Now()
+	ctxt, cancelFunc := s.getActionContext(action)
 	sessionName := action.Session.Name
+	attempts := &failedAttempts{save: func(attempts []datamodel.ActionAttempt) {
+		if err := s.sessionRegistry.UpdateFailedAttempts(sessionName, attempts); err != nil {
+			logging.FromContext(ctxt).Errorf("unable to record failed attempts due to: %s", err)
+		}
+	}}
+	ctxt = filesystem.WithActionInfo(ctxt, filesystem.ActionInfo{
+		ActionType: action.ActionType, RecordAttempt: attempts.Add})
+	logger := logging.FromContext(ctxt)
+
+	// Always complete the action, so the caller sees any error
+	defer func() {
+		cancelFunc()
+		observeSessionAction(action, time.Since(startTime))
+		if err := s.actions.CompleteSessionAction(action); err != nil {
+			logger.Errorf("failed to complete action due to: %s", err)
+		}
+	}()
+
 	sessionMutex, err := s.sessionRegistry.GetSessionMutex(sessionName)
 	if err != nil {
-		log.Printf("unable to get session mutex: %s due to: %s\n", sessionName, err)
+		logger.Errorf("unable to get session mutex due to: %s", err)
 		action.Error = err.Error()
 		return
 	}
-	err = sessionMutex.Lock(context.TODO())
+	err = sessionMutex.Lock(ctxt)
 	if err != nil {
-		log.Printf("unable to lock session mutex: %s due to: %s\n", sessionName, err)
-		action.Error = err.Error()
+		logger.Errorf("unable to lock session mutex due to: %s", err)
+		action.Error = getActionError(ctxt, err)
 		return
 	}
 
-	// Always complete action and drop mutex on function exit
+	// Always drop mutex on function exit, even if the action was cancelled
 	defer func() {
-		if err := s.actions.CompleteSessionAction(action); err != nil {
-			log.Printf("failed to complete action %+v due to: %s\n", action, err.Error())
-		}
-		if err := sessionMutex.Unlock(context.TODO()); err != nil {
-			log.Printf("failed to drop mutex for: %s due to: %s\n", sessionName, err.Error())
+		if err := sessionMutex.Unlock(context.Background()); err != nil {
+			logger.Errorf("failed to drop session mutex due to: %s", err)
 		}
 	}()
 
-	log.Printf("starting action %+v\n", action)
+	logger.Info("starting action")
 
-	session, err := process()
+	session, err := process(ctxt)
 	if err != nil {
-		action.Error = err.Error()
-		log.Printf("error during action %+v\n", action)
+		action.Error = getActionError(ctxt, err)
+		logger.WithField("error", action.Error).Error("action failed")
 	} else {
 		action.Session = session
-		log.Printf("finished action %+v\n", action)
+		logger.Info("finished action")
+	}
+
+	// Once deleted, the session has nowhere to keep its attempts
+	if action.ActionType != datamodel.SessionDelete || err != nil {
+		attempts.Flush()
+	}
+	if recorded := attempts.Get(); len(recorded) > 0 && err == nil {
+		action.Session.Status.FailedAttempts = recorded
+	}
+}
+
+// Keep a limited number of failed attempts in the session status
+const maxFailedAttempts = 20
+
+// Saving every attempt could flood the store when many hosts fail at once
+var failedAttemptsSaveInterval = time.Second * 5
+
+// Users see what had to be retried while the action is still running,
+// attempts that arrive too soon after the last save wait for the next one, or Flush
+type failedAttempts struct {
+	mutex     sync.Mutex
+	attempts  []datamodel.ActionAttempt
+	save      func(attempts []datamodel.ActionAttempt)
+	lastSaved time.Time
+	unsaved   bool
+}
+
+func (f *failedAttempts) Add(attempt datamodel.ActionAttempt) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.attempts = append(f.attempts, attempt)
+	f.unsaved = true
+	if time.Since(f.lastSaved) >= failedAttemptsSaveInterval {
+		f.saveLocked()
 	}
 }
 
+// Save any attempts not yet saved
+func (f *failedAttempts) Flush() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.unsaved {
+		f.saveLocked()
+	}
+}
+
+func (f *failedAttempts) saveLocked() {
+	if f.save != nil {
+		f.save(f.getLocked())
+	}
+	f.lastSaved = time.Now()
+	f.unsaved = false
+}
+
+func (f *failedAttempts) Get() []datamodel.ActionAttempt {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.getLocked()
+}
+
+func (f *failedAttempts) getLocked() []datamodel.ActionAttempt {
+	if len(f.attempts) > maxFailedAttempts {
+		return f.attempts[len(f.attempts)-maxFailedAttempts:]
+	}
+	return f.attempts
+}
+
 func (s *sessionActionHandler) handleCreate(action datamodel.SessionAction) {
-	s.processWithMutex(action, func() (datamodel.Session, error) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
 		session := action.Session
 		// Nothing to create, just complete the action
 		// TODO: why do we send the action?
@@ -109,7 +246,15 @@ func (s *sessionActionHandler) handleCreate(action datamodel.SessionAction) {
 		// Only call create if we have a per job buffer to create
 		// Note: we always need to do the mount to enable copy-in/out
 		if session.ActualSizeBytes != 0 {
-			fsStatus, err := s.fsProvider.Create(session)
+			// Record the settings, so every later action uses the same ones
+			if session.FilesystemSettings == nil && s.clusterConfig != nil {
+				settings := s.clusterConfig.GetFilesystemSettings(session.VolumeRequest.PoolName)
+				if session.VolumeRequest.FilesystemType != "" {
+					settings.Type = session.VolumeRequest.FilesystemType
+				}
+				session.FilesystemSettings = &settings
+			}
+			fsStatus, err := s.fsProvider.Create(ctxt, session)
 			session.FilesystemStatus = fsStatus
 			if err != nil {
 				session.Status.Error = err.Error()
@@ -118,7 +263,7 @@ func (s *sessionActionHandler) handleCreate(action datamodel.SessionAction) {
 			var updateErr error
 			session, updateErr = s.sessionRegistry.UpdateSession(session)
 			if updateErr != nil {
-				log.Println("Failed to update session:", updateErr)
+				logging.FromContext(ctxt).Errorln("Failed to update session:", updateErr)
 				if err == nil {
 					err = updateErr
 				}
@@ -126,17 +271,17 @@ func (s *sessionActionHandler) handleCreate(action datamodel.SessionAction) {
 			if err != nil {
 				return session, err
 			}
-			log.Println("Filesystem created, now mount on primary brick host")
+			logging.FromContext(ctxt).Println("Filesystem created, now mount on primary brick host")
 		}
 
-		session, err = s.doAllMounts(session, true)
+		session, err = s.doAllMounts(ctxt, session, true)
 		session.Status.FileSystemCreated = err == nil
 		if err != nil {
 			session.Status.Error = err.Error()
 		}
 		session, updateErr := s.sessionRegistry.UpdateSession(session)
 		if updateErr != nil {
-			log.Println("Failed to update session:", updateErr)
+			logging.FromContext(ctxt).Errorln("Failed to update session:", updateErr)
 			if err == nil {
 				err = updateErr
 			}
@@ -146,44 +291,55 @@ func (s *sessionActionHandler) handleCreate(action datamodel.SessionAction) {
 }
 
 func (s *sessionActionHandler) handleDelete(action datamodel.SessionAction) {
-	s.processWithMutex(action, func() (datamodel.Session, error) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
 		session, err := s.sessionRegistry.GetSession(action.Session.Name)
 		if err != nil {
 			// TODO: deal with already being deleted? add check if exists call?
 			return action.Session, fmt.Errorf("error getting session: %s", err)
 		}
+		return session, s.deleteSession(ctxt, session)
+	})
+}
 
-		if err := s.doAllUnmounts(session, getAttachmentKey(session.Name, true)); err != nil {
-			return session, fmt.Errorf("failed primary brick host unmount, due to: %s", err.Error())
-		}
-		log.Println("did umount primary brick host during delete")
+// Caller must hold the session mutex
+func (s *sessionActionHandler) deleteSession(ctxt context.Context, session datamodel.Session) error {
+	session, err := s.doAllUnmounts(ctxt, session, getAttachmentKey(session.Name, true))
+	if err != nil {
+		return fmt.Errorf("failed primary brick host unmount, due to: %s", err.Error())
+	}
+	logging.FromContext(ctxt).Println("did umount primary brick host during delete")
 
-		if !session.Status.UnmountComplete {
-			if err := s.doAllUnmounts(session, getAttachmentKey(session.Name, false)); err != nil {
-				return session, fmt.Errorf("failed retry unmount during delete, due to: %s", err.Error())
-			}
-			log.Println("did unmount during delete")
+	if !session.Status.UnmountComplete {
+		session, err = s.doAllUnmounts(ctxt, session, getAttachmentKey(session.Name, false))
+		if err != nil {
+			return fmt.Errorf("failed retry unmount during delete, due to: %s", err.Error())
 		}
-		if !session.Status.CopyDataOutComplete && !session.Status.DeleteSkipCopyDataOut {
-			if err := s.fsProvider.DataCopyOut(action.Session); err != nil {
-				return session, fmt.Errorf("failed DataCopyOut during delete, due to: %s", err.Error())
-			}
-			log.Println("did data copy out during delete")
+		logging.FromContext(ctxt).Println("did unmount during delete")
+	}
+	if !session.Status.CopyDataOutComplete && !session.Status.DeleteSkipCopyDataOut {
+		if err := s.fsProvider.DataCopyOut(ctxt, session); err != nil {
+			return fmt.Errorf("failed DataCopyOut during delete, due to: %s", err.Error())
 		}
+		logging.FromContext(ctxt).Println("did data copy out during delete")
+	}
 
-		// Only try delete if we have bricks to delete
-		if session.ActualSizeBytes > 0 {
-			if err := s.fsProvider.Delete(session); err != nil {
-				return session, err
-			}
+	// Only try delete if we have bricks to delete
+	if session.ActualSizeBytes > 0 {
+		if err := s.fsProvider.Delete(ctxt, session); err != nil {
+			return err
+		}
+		// The session keeps the bricks, so they are not reused until the wipe is done
+		session, err = s.wipeBricks(ctxt, session)
+		if err != nil {
+			return err
 		}
+	}
 
-		return session, s.sessionRegistry.DeleteSession(session)
-	})
+	return s.sessionRegistry.DeleteSession(session)
 }
 
 func (s *sessionActionHandler) handleCopyIn(action datamodel.SessionAction) {
-	s.processWithMutex(action, func() (datamodel.Session, error) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
 		// Get latest session now we have the mutex
 		session, err := s.sessionRegistry.GetSession(action.Session.Name)
 		if err != nil {
@@ -193,7 +349,7 @@ func (s *sessionActionHandler) handleCopyIn(action datamodel.SessionAction) {
 			return session, fmt.Errorf("can't do action once delete has been requested for")
 		}
 
-		if err := s.fsProvider.DataCopyIn(session); err != nil {
+		if err := s.fsProvider.DataCopyIn(ctxt, session); err != nil {
 			return session, err
 		}
 
@@ -203,7 +359,7 @@ func (s *sessionActionHandler) handleCopyIn(action datamodel.SessionAction) {
 }
 
 func (s *sessionActionHandler) handleCopyOut(action datamodel.SessionAction) {
-	s.processWithMutex(action, func() (datamodel.Session, error) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
 		// Get latest session now we have the mutex
 		session, err := s.sessionRegistry.GetSession(action.Session.Name)
 		if err != nil {
@@ -213,7 +369,7 @@ func (s *sessionActionHandler) handleCopyOut(action datamodel.SessionAction) {
 			return session, fmt.Errorf("can't do action once delete has been requested for")
 		}
 
-		if err := s.fsProvider.DataCopyOut(session); err != nil {
+		if err := s.fsProvider.DataCopyOut(ctxt, session); err != nil {
 			return session, err
 		}
 
@@ -222,6 +378,99 @@ func (s *sessionActionHandler) handleCopyOut(action datamodel.SessionAction) {
 	})
 }
 
+func (s *sessionActionHandler) handleExpand(action datamodel.SessionAction) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
+		// Get latest session now we have the mutex
+		session, err := s.sessionRegistry.GetSession(action.Session.Name)
+		if err != nil {
+			return action.Session, fmt.Errorf("error getting session: %s", err)
+		}
+		if session.Status.DeleteRequested {
+			return session, fmt.Errorf("can't do action once delete has been requested for")
+		}
+		if len(session.PendingExpandBricks) == 0 {
+			logging.FromContext(ctxt).Println("Skip expand, no new bricks for session:", session.Name)
+			return session, nil
+		}
+
+		err = s.fsProvider.Expand(ctxt, session, session.PendingExpandBricks)
+		if err != nil {
+			// bricks stay allocated, as they may be partly added to the filesystem,
+			// and expand_persistent retries adding them
+			session.Status.Error = expandErrorPrefix + err.Error()
+		} else {
+			session.PendingExpandBricks = nil
+			if strings.HasPrefix(session.Status.Error, expandErrorPrefix) {
+				session.Status.Error = ""
+			}
+		}
+
+		session, updateErr := s.sessionRegistry.UpdateSession(session)
+		if updateErr != nil {
+			logging.FromContext(ctxt).Errorln("Failed to update session:", updateErr)
+			if err == nil {
+				err = updateErr
+			}
+		}
+		return session, err
+	})
+}
+
+func (s *sessionActionHandler) handlePreempt(action datamodel.SessionAction) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
+		// Get latest session now we have the mutex
+		session, err := s.sessionRegistry.GetSession(action.Session.Name)
+		if err != nil {
+			return action.Session, fmt.Errorf("error getting session: %s", err)
+		}
+		if session.Status.DeleteRequested {
+			return session, fmt.Errorf("can't do action once delete has been requested for")
+		}
+		if session.Preemption == nil {
+			return session, fmt.Errorf("preemption decision not recorded for session: %s", session.Name)
+		}
+
+		// Stage out while still mounted on the primary brick host
+		if !session.Status.CopyDataOutComplete {
+			if err := s.fsProvider.DataCopyOut(ctxt, session); err != nil {
+				return session, fmt.Errorf("failed DataCopyOut during preempt, due to: %s", err.Error())
+			}
+			session.Status.CopyDataOutComplete = true
+		}
+		session, err = s.doAllUnmounts(ctxt, session, getAttachmentKey(session.Name, true))
+		if err != nil {
+			return session, fmt.Errorf("failed primary brick host unmount, due to: %s", err.Error())
+		}
+		delete(session.CurrentAttachments, getAttachmentKey(session.Name, true))
+		if err := s.fsProvider.Delete(ctxt, session); err != nil {
+			session.Status.Error = err.Error()
+			if _, updateErr := s.sessionRegistry.UpdateSession(session); updateErr != nil {
+				logging.FromContext(ctxt).Errorln("Failed to update session:", updateErr)
+			}
+			return session, err
+		}
+		// The preempting session gets the bricks next, so wipe them first,
+		// the bricks stay allocated to this session until that is done
+		session, err = s.wipeBricks(ctxt, session)
+		if err != nil {
+			session.Status.Error = err.Error()
+			if _, updateErr := s.sessionRegistry.UpdateSession(session); updateErr != nil {
+				logging.FromContext(ctxt).Errorln("Failed to update session:", updateErr)
+			}
+			return session, err
+		}
+
+		// Release the bricks, but keep the session so users can see it was preempted
+		session.AllocatedBricks = nil
+		session.Status.WipedBrickHosts = nil
+		session.PendingExpandBricks = nil
+		session.ActualSizeBytes = 0
+		session.FilesystemStatus = datamodel.FilesystemStatus{}
+		session.Status.FileSystemCreated = false
+		return s.sessionRegistry.UpdateSession(session)
+	})
+}
+
 func addHostsFromSession(attachment *datamodel.AttachmentSession, actionSession datamodel.Session, forPrimaryBrickHost bool) {
 	if forPrimaryBrickHost {
 		attachment.Hosts = []string{string(actionSession.PrimaryBrickHost)}
@@ -230,7 +479,7 @@ func addHostsFromSession(attachment *datamodel.AttachmentSession, actionSession
 	}
 }
 
-func (s *sessionActionHandler) doAllMounts(actionSession datamodel.Session, forPrimaryBrickHost bool) (datamodel.Session, error) {
+func (s *sessionActionHandler) doAllMounts(ctxt context.Context, actionSession datamodel.Session, forPrimaryBrickHost bool) (datamodel.Session, error) {
 	if actionSession.ActualSizeBytes > 0 {
 		jobAttachment := datamodel.AttachmentSession{
 			SessionName:  actionSession.Name,
@@ -241,6 +490,8 @@ func (s *sessionActionHandler) doAllMounts(actionSession datamodel.Session, forP
 		if forPrimaryBrickHost {
 			// Never deal with private mount, as make no sense for copy in to private dir
 			jobAttachment.PrivateMount = false
+			// Swap is only for the compute nodes
+			jobAttachment.SwapBytes = 0
 		}
 		addHostsFromSession(&jobAttachment, actionSession, forPrimaryBrickHost)
 		if err := updateAttachments(&actionSession, jobAttachment, forPrimaryBrickHost); err != nil {
@@ -252,14 +503,17 @@ func (s *sessionActionHandler) doAllMounts(actionSession datamodel.Session, forP
 		}
 		actionSession = session
 
-		if err := s.fsProvider.Mount(actionSession, jobAttachment, forPrimaryBrickHost); err != nil {
+		mountErr := s.fsProvider.Mount(ctxt, actionSession, jobAttachment, forPrimaryBrickHost)
+		actionSession, err = s.updateHostResults(ctxt, actionSession,
+			getAttachmentKey(actionSession.Name, forPrimaryBrickHost), jobAttachment.Hosts, mountErr, true)
+		if err != nil {
 			return actionSession, err
 		}
 		// TODO: should we track success of each attachment session?
 	}
 	for _, sessionName := range actionSession.MultiJobAttachments {
-		if err := s.doMultiJobMount(actionSession, sessionName, forPrimaryBrickHost); err != nil {
-			return actionSession, nil
+		if err := s.doMultiJobMount(ctxt, actionSession, sessionName, forPrimaryBrickHost); err != nil {
+			return actionSession, err
 		}
 	}
 	return actionSession, nil
@@ -289,19 +543,19 @@ func updateAttachments(session *datamodel.Session, attachment datamodel.Attachme
 	return nil
 }
 
-func (s *sessionActionHandler) doMultiJobMount(actionSession datamodel.Session, sessionName datamodel.SessionName, forPrimaryBrickHost bool) error {
+func (s *sessionActionHandler) doMultiJobMount(ctxt context.Context, actionSession datamodel.Session, sessionName datamodel.SessionName, forPrimaryBrickHost bool) error {
 	sessionMutex, err := s.sessionRegistry.GetSessionMutex(sessionName)
 	if err != nil {
-		log.Printf("unable to get session mutex: %s due to: %s\n", sessionName, err)
+		logging.FromContext(ctxt).Errorf("unable to get session mutex: %s due to: %s", sessionName, err)
 		return err
 	}
-	if err = sessionMutex.Lock(context.TODO()); err != nil {
-		log.Printf("unable to lock session mutex: %s due to: %s\n", sessionName, err)
+	if err = sessionMutex.Lock(ctxt); err != nil {
+		logging.FromContext(ctxt).Errorf("unable to lock session mutex: %s due to: %s", sessionName, err)
 		return err
 	}
 	defer func() {
-		if err := sessionMutex.Unlock(context.TODO()); err != nil {
-			log.Println("failed to drop mutex for:", sessionName)
+		if err := sessionMutex.Unlock(context.Background()); err != nil {
+			logging.FromContext(ctxt).Errorln("failed to drop mutex for:", sessionName)
 		}
 	}()
 
@@ -312,36 +566,49 @@ func (s *sessionActionHandler) doMultiJobMount(actionSession datamodel.Session,
 	if !multiJobSession.VolumeRequest.MultiJob {
 		log.Panicf("trying multi-job attach to non-multi job session %s", multiJobSession.Name)
 	}
+	if multiJobSession.Preemption != nil {
+		return fmt.Errorf("can't attach persistent buffer %s as it was preempted by %s",
+			multiJobSession.Name, multiJobSession.Preemption.PreemptedBy)
+	}
 
+	access := multiJobSession.VolumeRequest.Access
 	multiJobAttachment := datamodel.AttachmentSession{
 		SessionName: actionSession.Name,
-		GlobalMount: true,
+		// Anything but private alone is striped, as it was before private was supported
+		GlobalMount: access != datamodel.Private,
+		// Each host sees the same private directory in every job that attaches the buffer
+		PrivateMount: !forPrimaryBrickHost && (access == datamodel.Private || access == datamodel.PrivateAndStriped),
 	}
 	addHostsFromSession(&multiJobAttachment, actionSession, forPrimaryBrickHost)
 	if err := updateAttachments(&multiJobSession, multiJobAttachment, forPrimaryBrickHost); err != nil {
 		return err
 	}
+	// Job setup mounts on the primary brick host, so this also records when a job was set up
+	multiJobSession.LastUsedAt = uint(time.Now().Unix())
 
 	multiJobSession, err = s.sessionRegistry.UpdateSession(multiJobSession)
 	if err != nil {
 		return err
 	}
-	return s.fsProvider.Mount(multiJobSession, multiJobAttachment, false)
+	mountErr := s.fsProvider.Mount(ctxt, multiJobSession, multiJobAttachment, false)
+	_, err = s.updateHostResults(ctxt, multiJobSession, getAttachmentKey(actionSession.Name, forPrimaryBrickHost),
+		multiJobAttachment.Hosts, mountErr, true)
+	return err
 }
 
-func (s *sessionActionHandler) doMultiJobUnmount(actionSession datamodel.Session, sessionName datamodel.SessionName, attachmentKey datamodel.SessionName) error {
+func (s *sessionActionHandler) doMultiJobUnmount(ctxt context.Context, actionSession datamodel.Session, sessionName datamodel.SessionName, attachmentKey datamodel.SessionName) error {
 	sessionMutex, err := s.sessionRegistry.GetSessionMutex(sessionName)
 	if err != nil {
-		log.Printf("unable to get session mutex: %s due to: %s\n", sessionName, err)
+		logging.FromContext(ctxt).Errorf("unable to get session mutex: %s due to: %s", sessionName, err)
 		return err
 	}
-	if err = sessionMutex.Lock(context.TODO()); err != nil {
-		log.Printf("unable to lock session mutex: %s due to: %s\n", sessionName, err)
+	if err = sessionMutex.Lock(ctxt); err != nil {
+		logging.FromContext(ctxt).Errorf("unable to lock session mutex: %s due to: %s", sessionName, err)
 		return err
 	}
 	defer func() {
-		if err := sessionMutex.Unlock(context.TODO()); err != nil {
-			log.Println("failed to drop mutex for:", sessionName)
+		if err := sessionMutex.Unlock(context.Background()); err != nil {
+			logging.FromContext(ctxt).Errorln("failed to drop mutex for:", sessionName)
 		}
 	}()
 
@@ -355,35 +622,45 @@ func (s *sessionActionHandler) doMultiJobUnmount(actionSession datamodel.Session
 
 	attachments, ok := multiJobSession.CurrentAttachments[attachmentKey]
 	if !ok {
-		log.Println("skip multi-job detach, already seems to be detached")
+		logging.FromContext(ctxt).Println("skip multi-job detach, already seems to be detached")
 		return nil
 	}
-	if err := s.fsProvider.Unmount(multiJobSession, attachments); err != nil {
+	mounted := attachments
+	mounted.Hosts = getMountedHosts(attachments)
+	if err := s.fsProvider.Unmount(ctxt, multiJobSession, mounted); err != nil {
+		_, err = s.updateHostResults(ctxt, multiJobSession, attachmentKey, mounted.Hosts, err, false)
 		return err
 	}
 
 	// update multi job session to note our attachments have now gone
 	delete(multiJobSession.CurrentAttachments, attachmentKey)
+	multiJobSession.LastUsedAt = uint(time.Now().Unix())
 	_, err = s.sessionRegistry.UpdateSession(multiJobSession)
 	return err
 }
 
-func (s *sessionActionHandler) doAllUnmounts(actionSession datamodel.Session, attachmentKey datamodel.SessionName) error {
+// Only unmounts the hosts that may still be mounted
+func (s *sessionActionHandler) doAllUnmounts(ctxt context.Context, actionSession datamodel.Session, attachmentKey datamodel.SessionName) (datamodel.Session, error) {
 	if actionSession.ActualSizeBytes > 0 {
-		if err := s.fsProvider.Unmount(actionSession, actionSession.CurrentAttachments[attachmentKey]); err != nil {
-			return err
+		mounted := actionSession.CurrentAttachments[attachmentKey]
+		mounted.Hosts = getMountedHosts(mounted)
+		unmountErr := s.fsProvider.Unmount(ctxt, actionSession, mounted)
+		session, err := s.updateHostResults(ctxt, actionSession, attachmentKey, mounted.Hosts, unmountErr, false)
+		if err != nil {
+			return session, err
 		}
+		actionSession = session
 	}
 	for _, sessionName := range actionSession.MultiJobAttachments {
-		if err := s.doMultiJobUnmount(actionSession, sessionName, attachmentKey); err != nil {
-			return err
+		if err := s.doMultiJobUnmount(ctxt, actionSession, sessionName, attachmentKey); err != nil {
+			return actionSession, err
 		}
 	}
-	return nil
+	return actionSession, nil
 }
 
 func (s *sessionActionHandler) handleMount(action datamodel.SessionAction) {
-	s.processWithMutex(action, func() (datamodel.Session, error) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
 		session, err := s.sessionRegistry.GetSession(action.Session.Name)
 		if err != nil {
 			return action.Session, fmt.Errorf("error getting session: %s", err)
@@ -395,10 +672,10 @@ func (s *sessionActionHandler) handleMount(action datamodel.SessionAction) {
 			return session, errors.New("already mounted, can't mount again")
 		}
 
-		session, err = s.doAllMounts(session, false)
+		session, err = s.doAllMounts(ctxt, session, false)
 		if err != nil {
-			if err := s.doAllUnmounts(session, getAttachmentKey(session.Name, false)); err != nil {
-				log.Println("error while rolling back possible partial mount", action.Session.Name, err)
+			if _, err := s.doAllUnmounts(ctxt, session, getAttachmentKey(session.Name, false)); err != nil {
+				logging.FromContext(ctxt).Errorln("error while rolling back possible partial mount", action.Session.Name, err)
 			}
 			return action.Session, err
 		}
@@ -409,7 +686,7 @@ func (s *sessionActionHandler) handleMount(action datamodel.SessionAction) {
 }
 
 func (s *sessionActionHandler) handleUnmount(action datamodel.SessionAction) {
-	s.processWithMutex(action, func() (datamodel.Session, error) {
+	s.processWithMutex(action, func(ctxt context.Context) (datamodel.Session, error) {
 		session, err := s.sessionRegistry.GetSession(action.Session.Name)
 		if err != nil {
 			return action.Session, fmt.Errorf("error getting session: %s", err)
@@ -421,7 +698,8 @@ func (s *sessionActionHandler) handleUnmount(action datamodel.SessionAction) {
 			return session, errors.New("already unmounted, can't umount again")
 		}
 
-		if err := s.doAllUnmounts(session, getAttachmentKey(session.Name, false)); err != nil {
+		session, err = s.doAllUnmounts(ctxt, session, getAttachmentKey(session.Name, false))
+		if err != nil {
 			return action.Session, err
 		}
 
@@ -430,40 +708,41 @@ func (s *sessionActionHandler) handleUnmount(action datamodel.SessionAction) {
 	})
 }
 
-func (s *sessionActionHandler) RestoreSession(session datamodel.Session) {
+func (s *sessionActionHandler) RestoreSession(ctxt context.Context, session datamodel.Session) {
 	if session.ActualSizeBytes == 0 {
 		// Nothing to do
 		return
 	}
+	ctxt = logging.WithFields(ctxt, logging.Fields{logging.SessionField: session.Name, logging.ActionField: "Restore"})
 
 	// Get session lock before attempting the restore
 	sessionMutex, err := s.sessionRegistry.GetSessionMutex(session.Name)
 	if err != nil {
-		log.Printf("unable to get session mutex: %s due to: %s\n", session.Name, err)
+		logging.FromContext(ctxt).Errorf("unable to get session mutex: %s due to: %s", session.Name, err)
 		return
 	}
-	err = sessionMutex.Lock(context.TODO())
+	err = sessionMutex.Lock(ctxt)
 	if err != nil {
-		log.Printf("unable to lock session mutex: %s due to: %s\n", session.Name, err)
+		logging.FromContext(ctxt).Errorf("unable to lock session mutex: %s due to: %s", session.Name, err)
 		return
 	}
 	// Always drop mutex on function exit
 	defer func() {
-		if err := sessionMutex.Unlock(context.TODO()); err != nil {
-			log.Printf("failed to drop mutex for: %s due to: %s\n", session.Name, err.Error())
+		if err := sessionMutex.Unlock(context.Background()); err != nil {
+			logging.FromContext(ctxt).Errorf("failed to drop mutex for: %s due to: %s", session.Name, err.Error())
 		}
 	}()
 
-	err = s.fsProvider.Restore(session)
+	// The provider leaves out bricks from an incomplete expand
+	err = s.fsProvider.Restore(ctxt, session)
 
 	if err != nil {
-		log.Printf("unable to restore session: %+v\n", session)
-		session.Status.Error = err.Error()
+		logging.FromContext(ctxt).Errorf("unable to restore session due to: %s", err)
+		session.Status.Error = restoreErrorPrefix + err.Error()
 		if _, err := s.sessionRegistry.UpdateSession(session); err != nil {
 			log.Panicf("unable to report that session restore failed for session: %s", session.Name)
 		}
 	}
 
-	// TODO: do we just assume any pending mounts will resume in their own time? or should we retry mounts too?
-	// TODO: we need to redo at least the primary brick host mount, to allow a later data copy out?
+	// Any missing mounts are found and retried by the reconcile loop
 }
@@ -1,17 +1,324 @@
 package brick_manager_impl
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestSessionActionHandler_ProcessSessionAction_Unknown(t *testing.T) {
 	action := datamodel.SessionAction{}
-	handler := NewSessionActionHandler(nil)
+	handler := newSessionActionHandler(nil, nil)
 
 	assert.PanicsWithValue(t,
 		fmt.Sprintf("not yet implemented action for %+v", action),
 		func() { handler.ProcessSessionAction(action) })
 }
+
+func TestSessionActionHandler_ProcessSessionAction_Cancel(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	fsProvider := mock_filesystem.NewMockProvider(mockCtrl)
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	handler := sessionActionHandler{sessionRegistry: sessionRegistry, actions: actions, fsProvider: fsProvider}
+	session := datamodel.Session{Name: "job1"}
+	action := datamodel.SessionAction{Uuid: "uuid1", ActionType: datamodel.SessionCopyDataIn, Session: session}
+
+	cancelled := make(chan struct{})
+	close(cancelled)
+	actions.EXPECT().WatchForCancel(gomock.Any(), action).Return(cancelled, nil)
+	sessionRegistry.EXPECT().GetSessionMutex(session.Name).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	sessionRegistry.EXPECT().GetSession(session.Name).Return(session, nil)
+	fsProvider.EXPECT().DataCopyIn(gomock.Any(), session).DoAndReturn(
+		func(ctxt context.Context, session datamodel.Session) error {
+			<-ctxt.Done()
+			return errors.New("killed")
+		})
+	sessionMutex.EXPECT().Unlock(context.Background())
+	action.Error = "action cancelled: killed"
+	actions.EXPECT().CompleteSessionAction(action)
+
+	handler.ProcessSessionAction(datamodel.SessionAction{
+		Uuid: "uuid1", ActionType: datamodel.SessionCopyDataIn, Session: session})
+}
+
+func TestSessionActionHandler_ProcessSessionAction_RecordsFailedAttempts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	fsProvider := mock_filesystem.NewMockProvider(mockCtrl)
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	handler := sessionActionHandler{sessionRegistry: sessionRegistry, actions: actions, fsProvider: fsProvider}
+	session := datamodel.Session{Name: "job1"}
+	action := datamodel.SessionAction{Uuid: "uuid1", ActionType: datamodel.SessionCopyDataIn, Session: session}
+	attempt := datamodel.ActionAttempt{ActionType: datamodel.SessionCopyDataIn, Operation: "copy",
+		Hostname: "localhost", Attempt: 1, MaxAttempts: 3, Error: "fake"}
+
+	actions.EXPECT().WatchForCancel(gomock.Any(), action).Return(make(chan struct{}), nil)
+	sessionRegistry.EXPECT().GetSessionMutex(session.Name).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	sessionRegistry.EXPECT().GetSession(session.Name).Return(session, nil)
+	fsProvider.EXPECT().DataCopyIn(gomock.Any(), session).DoAndReturn(
+		func(ctxt context.Context, session datamodel.Session) error {
+			info := filesystem.GetActionInfo(ctxt)
+			assert.Equal(t, datamodel.SessionCopyDataIn, info.ActionType)
+			// saved straight away, without changing the session's revision
+			sessionRegistry.EXPECT().UpdateFailedAttempts(session.Name, []datamodel.ActionAttempt{attempt})
+			info.RecordAttempt(attempt)
+			return nil
+		})
+	copied := session
+	copied.Status.CopyDataInComplete = true
+	sessionRegistry.EXPECT().UpdateSession(copied).Return(copied, nil)
+	updated := copied
+	updated.Status.FailedAttempts = []datamodel.ActionAttempt{attempt}
+	sessionMutex.EXPECT().Unlock(context.Background())
+	action.Session = updated
+	actions.EXPECT().CompleteSessionAction(action)
+
+	handler.ProcessSessionAction(datamodel.SessionAction{
+		Uuid: "uuid1", ActionType: datamodel.SessionCopyDataIn, Session: session})
+}
+
+func TestFailedAttempts_Get(t *testing.T) {
+	attempts := &failedAttempts{}
+	assert.Nil(t, attempts.Get())
+	for i := uint(1); i <= maxFailedAttempts+2; i++ {
+		attempts.Add(datamodel.ActionAttempt{Attempt: i})
+	}
+	result := attempts.Get()
+	assert.Equal(t, maxFailedAttempts, len(result))
+	assert.Equal(t, uint(3), result[0].Attempt)
+}
+
+func TestFailedAttempts_Save(t *testing.T) {
+	var saved [][]datamodel.ActionAttempt
+	attempts := &failedAttempts{save: func(attempts []datamodel.ActionAttempt) {
+		saved = append(saved, attempts)
+	}}
+	attempts.Flush()
+	assert.Nil(t, saved)
+
+	attempts.Add(datamodel.ActionAttempt{Attempt: 1})
+	assert.Equal(t, 1, len(saved))
+
+	// too soon to save again, until the end of the action
+	attempts.Add(datamodel.ActionAttempt{Attempt: 2})
+	assert.Equal(t, 1, len(saved))
+	attempts.Flush()
+	assert.Equal(t, [][]datamodel.ActionAttempt{
+		{{Attempt: 1}},
+		{{Attempt: 1}, {Attempt: 2}},
+	}, saved)
+	attempts.Flush()
+	assert.Equal(t, 2, len(saved))
+}
+
+func TestGetActionError(t *testing.T) {
+	err := errors.New("asdf")
+	assert.Equal(t, "asdf", getActionError(context.Background(), err))
+
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+	assert.Equal(t, "action cancelled: asdf", getActionError(ctxt, err))
+
+	ctxt, cancelFunc = context.WithDeadline(context.Background(), time.Now())
+	defer cancelFunc()
+	assert.Equal(t, "action deadline exceeded: asdf", getActionError(ctxt, err))
+}
+
+func TestSessionActionHandler_ProcessSessionAction_ExpandRetry(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	fsProvider := mock_filesystem.NewMockProvider(mockCtrl)
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	handler := sessionActionHandler{sessionRegistry: sessionRegistry, actions: actions, fsProvider: fsProvider}
+	newBricks := []datamodel.Brick{{BrickHostName: "dac2", Device: "nvme1n1"}}
+	session := datamodel.Session{Name: "buffer1",
+		AllocatedBricks:     append([]datamodel.Brick{{BrickHostName: "dac1", Device: "nvme1n1"}}, newBricks...),
+		PendingExpandBricks: newBricks}
+	action := datamodel.SessionAction{Uuid: "uuid1", ActionType: datamodel.SessionExpand, Session: session}
+
+	actions.EXPECT().WatchForCancel(gomock.Any(), gomock.Any()).Return(make(chan struct{}), nil).Times(2)
+	sessionRegistry.EXPECT().GetSessionMutex(session.Name).Return(sessionMutex, nil).Times(2)
+	sessionMutex.EXPECT().Lock(gomock.Any()).Times(2)
+	sessionMutex.EXPECT().Unlock(context.Background()).Times(2)
+
+	// bricks stay pending after a failure
+	sessionRegistry.EXPECT().GetSession(session.Name).Return(session, nil)
+	fsProvider.EXPECT().Expand(gomock.Any(), session, newBricks).Return(errors.New("fake"))
+	failed := session
+	failed.Status.Error = "unable to expand: fake"
+	sessionRegistry.EXPECT().UpdateSession(failed).Return(failed, nil)
+	failedAction := action
+	failedAction.Error = "fake"
+	actions.EXPECT().CompleteSessionAction(failedAction)
+
+	handler.ProcessSessionAction(action)
+
+	// the retry adds the same bricks, and clears the error
+	sessionRegistry.EXPECT().GetSession(session.Name).Return(failed, nil)
+	fsProvider.EXPECT().Expand(gomock.Any(), failed, newBricks)
+	expanded := session
+	expanded.PendingExpandBricks = nil
+	sessionRegistry.EXPECT().UpdateSession(expanded).Return(expanded, nil)
+	expandedAction := action
+	expandedAction.Session = expanded
+	actions.EXPECT().CompleteSessionAction(expandedAction)
+
+	handler.ProcessSessionAction(action)
+}
+
+// Sessions are kept in memory, so tests can check the state after an action
+func setupMountTest(mockCtrl *gomock.Controller, sessions ...datamodel.Session) (*sessionActionHandler,
+	*mock_registry.MockSessionActions, *mock_filesystem.MockProvider, map[datamodel.SessionName]datamodel.Session) {
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	actions := mock_registry.NewMockSessionActions(mockCtrl)
+	fsProvider := mock_filesystem.NewMockProvider(mockCtrl)
+	handler := &sessionActionHandler{sessionRegistry: sessionRegistry, actions: actions, fsProvider: fsProvider}
+
+	stored := make(map[datamodel.SessionName]datamodel.Session)
+	for _, session := range sessions {
+		stored[session.Name] = session
+	}
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(gomock.Any()).Return(sessionMutex, nil).AnyTimes()
+	sessionMutex.EXPECT().Lock(gomock.Any()).AnyTimes()
+	sessionMutex.EXPECT().Unlock(gomock.Any()).AnyTimes()
+	sessionRegistry.EXPECT().GetSession(gomock.Any()).DoAndReturn(
+		func(name datamodel.SessionName) (datamodel.Session, error) {
+			return stored[name], nil
+		}).AnyTimes()
+	sessionRegistry.EXPECT().UpdateSession(gomock.Any()).DoAndReturn(
+		func(session datamodel.Session) (datamodel.Session, error) {
+			stored[session.Name] = session
+			return session, nil
+		}).AnyTimes()
+	actions.EXPECT().WatchForCancel(gomock.Any(), gomock.Any()).Return(make(chan struct{}), nil).AnyTimes()
+	return handler, actions, fsProvider, stored
+}
+
+func TestSessionActionHandler_ProcessSessionAction_MountPreempted(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	job := datamodel.Session{Name: "job1", RequestedAttachHosts: []string{"client1"},
+		MultiJobAttachments: []datamodel.SessionName{"buffer1"}}
+	buffer := datamodel.Session{Name: "buffer1", VolumeRequest: datamodel.VolumeRequest{MultiJob: true},
+		Preemption: &datamodel.PreemptionRecord{PreemptedBy: "urgent"}}
+	handler, actions, _, stored := setupMountTest(mockCtrl, job, buffer)
+
+	action := datamodel.SessionAction{Uuid: "uuid1", ActionType: datamodel.SessionMount, Session: job}
+	failedAction := action
+	failedAction.Error = "can't attach persistent buffer buffer1 as it was preempted by urgent"
+	actions.EXPECT().CompleteSessionAction(failedAction)
+
+	handler.ProcessSessionAction(action)
+
+	assert.False(t, stored["job1"].Status.MountComplete)
+	assert.Nil(t, stored["buffer1"].CurrentAttachments)
+}
+
+func TestSessionActionHandler_ProcessSessionAction_MountPartialFailure(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	job := datamodel.Session{Name: "job1", RequestedAttachHosts: []string{"client1", "client2"},
+		MultiJobAttachments: []datamodel.SessionName{"buffer1"}}
+	buffer := datamodel.Session{Name: "buffer1", VolumeRequest: datamodel.VolumeRequest{MultiJob: true}}
+	handler, actions, fsProvider, stored := setupMountTest(mockCtrl, job, buffer)
+
+	hostErrors := filesystem.HostErrors{"client2": errors.New("fake")}
+	fsProvider.EXPECT().Mount(gomock.Any(), gomock.Any(), gomock.Any(), false).Return(hostErrors)
+	// only the host that mounted is rolled back
+	fsProvider.EXPECT().Unmount(gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(
+		func(ctxt context.Context, session datamodel.Session, attachment datamodel.AttachmentSession) error {
+			assert.Equal(t, datamodel.SessionName("buffer1"), session.Name)
+			assert.Equal(t, []string{"client1"}, attachment.Hosts)
+			return nil
+		})
+	action := datamodel.SessionAction{Uuid: "uuid1", ActionType: datamodel.SessionMount, Session: job}
+	failedAction := action
+	failedAction.Error = "failed on 1 hosts: client2: fake"
+	actions.EXPECT().CompleteSessionAction(failedAction)
+
+	handler.ProcessSessionAction(action)
+
+	assert.False(t, stored["job1"].Status.MountComplete)
+	assert.Equal(t, map[datamodel.SessionName]datamodel.AttachmentSession{}, stored["buffer1"].CurrentAttachments)
+}
+
+func TestSessionActionHandler_ProcessSessionAction_CreateMarksBufferUsed(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	job := datamodel.Session{Name: "job1", MultiJobAttachments: []datamodel.SessionName{"buffer1"}}
+	buffer := datamodel.Session{Name: "buffer1", VolumeRequest: datamodel.VolumeRequest{MultiJob: true},
+		PrimaryBrickHost: "dac1"}
+	handler, actions, fsProvider, stored := setupMountTest(mockCtrl, job, buffer)
+
+	fsProvider.EXPECT().Mount(gomock.Any(), gomock.Any(), gomock.Any(), false).Return(nil)
+	actions.EXPECT().CompleteSessionAction(gomock.Any())
+
+	handler.ProcessSessionAction(datamodel.SessionAction{
+		Uuid: "uuid1", ActionType: datamodel.SessionCreateFilesystem, Session: job})
+
+	assert.True(t, stored["job1"].Status.FileSystemCreated)
+	assert.NotZero(t, stored["buffer1"].LastUsedAt)
+}
+
+func TestSessionActionHandler_ProcessSessionAction_PreemptWipesBricks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	buffer := datamodel.Session{Name: "buffer1", ActualSizeBytes: 2048, AllocatedBricks: wipeBricks,
+		VolumeRequest: datamodel.VolumeRequest{MultiJob: true, PoolName: "secure"},
+		Status:        datamodel.SessionStatus{CopyDataOutComplete: true},
+		Preemption:    &datamodel.PreemptionRecord{PreemptedBy: "urgent"}}
+	handler, actions, fsProvider, stored := setupMountTest(mockCtrl, buffer)
+	wipeHandler, _, _, brickHosts := setupWipeTest(mockCtrl, "discard")
+	handler.clusterConfig = wipeHandler.clusterConfig
+	handler.brickHosts = brickHosts
+	action := datamodel.SessionAction{Uuid: "uuid1", ActionType: datamodel.SessionPreempt, Session: buffer}
+
+	// the bricks are not released while any of them may still hold data
+	fsProvider.EXPECT().Unmount(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+	fsProvider.EXPECT().Delete(gomock.Any(), gomock.Any()).Times(2)
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[0], datamodel.WipeDiscard)
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[1], datamodel.WipeDiscard)
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[2], datamodel.WipeDiscard).Return(errors.New("fake"))
+	brickHosts.EXPECT().MarkBrickUnhealthy(gomock.Any()).Return(errors.New("etcd down"))
+	actions.EXPECT().CompleteSessionAction(gomock.Any()).DoAndReturn(func(action datamodel.SessionAction) error {
+		assert.Contains(t, action.Error, "unable to wipe bricks: ")
+		return nil
+	})
+
+	handler.ProcessSessionAction(action)
+
+	assert.Equal(t, wipeBricks, stored["buffer1"].AllocatedBricks)
+	assert.Equal(t, []datamodel.BrickHostName{"dac1"}, stored["buffer1"].Status.WipedBrickHosts)
+
+	// the retry only wipes the hosts that were not wiped, then releases the bricks
+	fsProvider.EXPECT().Wipe(gomock.Any(), wipeBricks[2], datamodel.WipeDiscard)
+	actions.EXPECT().CompleteSessionAction(gomock.Any()).DoAndReturn(func(action datamodel.SessionAction) error {
+		assert.Equal(t, "", action.Error)
+		return nil
+	})
+
+	handler.ProcessSessionAction(action)
+
+	assert.Nil(t, stored["buffer1"].AllocatedBricks)
+	assert.Nil(t, stored["buffer1"].Status.WipedBrickHosts)
+	assert.Equal(t, uint(0), uint(stored["buffer1"].ActualSizeBytes))
+}
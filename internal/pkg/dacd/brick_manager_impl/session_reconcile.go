@@ -0,0 +1,120 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"strings"
+)
+
+// Errors set by reconcile and restore, which are cleared once the session is healthy again,
+// and by expand, which is cleared once a retried expand succeeds
+const (
+	driftErrorPrefix   = "reconcile found drift: "
+	restoreErrorPrefix = "unable to restore: "
+	expandErrorPrefix  = "unable to expand: "
+)
+
+func (s *sessionActionHandler) ReconcileSession(ctxt context.Context, sessionName datamodel.SessionName, reportOnly bool) {
+	ctxt = logging.WithFields(ctxt, logging.Fields{logging.SessionField: sessionName, logging.ActionField: "Reconcile"})
+	logger := logging.FromContext(ctxt)
+	sessionMutex, err := s.sessionRegistry.GetSessionMutex(sessionName)
+	if err != nil {
+		logger.Errorf("unable to get session mutex due to: %s", err)
+		return
+	}
+	if err := sessionMutex.Lock(ctxt); err != nil {
+		logger.Errorf("unable to lock session mutex due to: %s", err)
+		return
+	}
+	defer func() {
+		if err := sessionMutex.Unlock(context.Background()); err != nil {
+			logger.Errorf("failed to drop session mutex due to: %s", err)
+		}
+	}()
+
+	// Get latest session now we have the mutex, it may have been deleted
+	session, err := s.sessionRegistry.GetSession(sessionName)
+	if err != nil {
+		logger.Infof("skip reconcile due to: %s", err)
+		return
+	}
+
+	if session.Status.DeleteRequested {
+		observeReconcileProblem("interrupted_delete")
+		logger.Warn("reconcile found interrupted delete")
+		if reportOnly {
+			return
+		}
+		if err := s.deleteSession(ctxt, session); err != nil {
+			logger.Errorf("reconcile unable to complete delete due to: %s", err)
+		}
+		return
+	}
+
+	if !session.Status.FileSystemCreated {
+		if session.Preemption == nil {
+			observeReconcileProblem("incomplete_create")
+			logger.Warnf("reconcile found session in strange state: %+v", session)
+		}
+		return
+	}
+
+	s.reconcileMounts(ctxt, session, reportOnly)
+}
+
+func (s *sessionActionHandler) reconcileMounts(ctxt context.Context, session datamodel.Session, reportOnly bool) {
+	logger := logging.FromContext(ctxt)
+	checkErr := s.fsProvider.CheckMounts(ctxt, session)
+	if checkErr != nil {
+		observeReconcileProblem("missing_mounts")
+		logger.Warnf("reconcile found drift: %s", checkErr)
+		if reportOnly {
+			return
+		}
+		checkErr = s.restoreMounts(ctxt, session, checkErr)
+	}
+
+	if reportOnly {
+		return
+	}
+	hasReconcileError := strings.HasPrefix(session.Status.Error, driftErrorPrefix) ||
+		strings.HasPrefix(session.Status.Error, restoreErrorPrefix)
+	if checkErr != nil {
+		logger.Errorf("reconcile unable to fix session due to: %s", checkErr)
+		session.Status.Error = driftErrorPrefix + checkErr.Error()
+	} else if hasReconcileError {
+		logger.Infof("reconcile cleared error: %s", session.Status.Error)
+		session.Status.Error = ""
+	} else {
+		return
+	}
+	if _, err := s.sessionRegistry.UpdateSession(session); err != nil {
+		logger.Errorf("unable to update session after reconcile due to: %s", err)
+	}
+}
+
+// Restore the filesystem targets, redo the missing mounts, then check again.
+// Hosts that are still mounted are left alone, unless the check didn't say which hosts are missing
+func (s *sessionActionHandler) restoreMounts(ctxt context.Context, session datamodel.Session, checkErr error) error {
+	if err := s.fsProvider.Restore(ctxt, session); err != nil {
+		return fmt.Errorf("failed restore, due to: %s", err)
+	}
+	missing, knownHosts := checkErr.(filesystem.MissingMountsError)
+	for _, key := range filesystem_impl.GetExpectedAttachments(session) {
+		attachment := session.CurrentAttachments[key]
+		if knownHosts {
+			attachment.Hosts = missing.Hosts[key]
+			if len(attachment.Hosts) == 0 {
+				continue
+			}
+		}
+		if err := s.fsProvider.Mount(ctxt, session, attachment, false); err != nil {
+			return fmt.Errorf("failed mount for %s, due to: %s", key, err)
+		}
+	}
+	return s.fsProvider.CheckMounts(ctxt, session)
+}
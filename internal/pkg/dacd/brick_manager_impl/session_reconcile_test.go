@@ -0,0 +1,129 @@
+package brick_manager_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/golang/mock/gomock"
+	"testing"
+)
+
+func setupReconcileTest(mockCtrl *gomock.Controller, session datamodel.Session) (
+	*sessionActionHandler, *mock_registry.MockSessionRegistry, *mock_filesystem.MockProvider) {
+	sessionRegistry := mock_registry.NewMockSessionRegistry(mockCtrl)
+	fsProvider := mock_filesystem.NewMockProvider(mockCtrl)
+	sessionMutex := mock_store.NewMockMutex(mockCtrl)
+	sessionRegistry.EXPECT().GetSessionMutex(session.Name).Return(sessionMutex, nil)
+	sessionMutex.EXPECT().Lock(gomock.Any())
+	sessionMutex.EXPECT().Unlock(context.Background())
+	sessionRegistry.EXPECT().GetSession(session.Name).Return(session, nil)
+	handler := &sessionActionHandler{sessionRegistry: sessionRegistry, fsProvider: fsProvider}
+	return handler, sessionRegistry, fsProvider
+}
+
+func TestSessionActionHandler_ReconcileSession_Healthy(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := datamodel.Session{Name: "job1", ActualSizeBytes: 1024,
+		Status: datamodel.SessionStatus{FileSystemCreated: true, Error: restoreErrorPrefix + "asdf"}}
+	handler, sessionRegistry, fsProvider := setupReconcileTest(mockCtrl, session)
+	fsProvider.EXPECT().CheckMounts(gomock.Any(), session)
+	updated := session
+	updated.Status.Error = ""
+	sessionRegistry.EXPECT().UpdateSession(updated)
+
+	handler.ReconcileSession(context.Background(), session.Name, false)
+}
+
+func TestSessionActionHandler_ReconcileSession_FixDrift(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := datamodel.Session{Name: "job1", ActualSizeBytes: 1024,
+		Status: datamodel.SessionStatus{FileSystemCreated: true, MountComplete: true},
+		CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{
+			"Primary_job1": {SessionName: "job1", Hosts: []string{"dac1"}},
+			"job1":         {SessionName: "job1", Hosts: []string{"client1"}},
+		}}
+	handler, _, fsProvider := setupReconcileTest(mockCtrl, session)
+	gomock.InOrder(
+		fsProvider.EXPECT().CheckMounts(gomock.Any(), session).Return(errors.New("expected mounts missing: client1")),
+		fsProvider.EXPECT().Restore(gomock.Any(), session),
+		fsProvider.EXPECT().Mount(gomock.Any(), session, session.CurrentAttachments["Primary_job1"], false),
+		fsProvider.EXPECT().Mount(gomock.Any(), session, session.CurrentAttachments["job1"], false),
+		fsProvider.EXPECT().CheckMounts(gomock.Any(), session),
+	)
+
+	handler.ReconcileSession(context.Background(), session.Name, false)
+}
+
+func TestSessionActionHandler_ReconcileSession_FixMissingHosts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := datamodel.Session{Name: "job1", ActualSizeBytes: 1024,
+		Status: datamodel.SessionStatus{FileSystemCreated: true, MountComplete: true},
+		CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{
+			"Primary_job1": {SessionName: "job1", Hosts: []string{"dac1"}},
+			"job1":         {SessionName: "job1", Hosts: []string{"client1", "client2"}, SwapBytes: 1024},
+		}}
+	handler, _, fsProvider := setupReconcileTest(mockCtrl, session)
+	missing := filesystem.MissingMountsError{Missing: []string{"client2:/mnt/dac/job1_job"},
+		Hosts: map[datamodel.SessionName][]string{"job1": {"client2"}}}
+	// only the host that is missing the mount is mounted again
+	remount := session.CurrentAttachments["job1"]
+	remount.Hosts = []string{"client2"}
+	gomock.InOrder(
+		fsProvider.EXPECT().CheckMounts(gomock.Any(), session).Return(missing),
+		fsProvider.EXPECT().Restore(gomock.Any(), session),
+		fsProvider.EXPECT().Mount(gomock.Any(), session, remount, false),
+		fsProvider.EXPECT().CheckMounts(gomock.Any(), session),
+	)
+
+	handler.ReconcileSession(context.Background(), session.Name, false)
+}
+
+func TestSessionActionHandler_ReconcileSession_DriftRemains(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := datamodel.Session{Name: "job1", ActualSizeBytes: 1024,
+		Status: datamodel.SessionStatus{FileSystemCreated: true}}
+	handler, sessionRegistry, fsProvider := setupReconcileTest(mockCtrl, session)
+	fsProvider.EXPECT().CheckMounts(gomock.Any(), session).Return(errors.New("expected mounts missing: dac1"))
+	fsProvider.EXPECT().Restore(gomock.Any(), session).Return(errors.New("ansible failed"))
+	updated := session
+	updated.Status.Error = "reconcile found drift: failed restore, due to: ansible failed"
+	sessionRegistry.EXPECT().UpdateSession(updated)
+
+	handler.ReconcileSession(context.Background(), session.Name, false)
+}
+
+func TestSessionActionHandler_ReconcileSession_ReportOnly(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := datamodel.Session{Name: "job1", ActualSizeBytes: 1024,
+		Status: datamodel.SessionStatus{FileSystemCreated: true, Error: driftErrorPrefix + "asdf"}}
+	handler, _, fsProvider := setupReconcileTest(mockCtrl, session)
+	fsProvider.EXPECT().CheckMounts(gomock.Any(), session).Return(errors.New("expected mounts missing: dac1"))
+
+	handler.ReconcileSession(context.Background(), session.Name, true)
+
+	session.Status = datamodel.SessionStatus{DeleteRequested: true}
+	handler, _, _ = setupReconcileTest(mockCtrl, session)
+	handler.ReconcileSession(context.Background(), session.Name, true)
+}
+
+func TestSessionActionHandler_ReconcileSession_InterruptedDelete(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	session := datamodel.Session{Name: "job1", ActualSizeBytes: 1024,
+		Status: datamodel.SessionStatus{DeleteRequested: true, UnmountComplete: true, CopyDataOutComplete: true}}
+	handler, sessionRegistry, fsProvider := setupReconcileTest(mockCtrl, session)
+	fsProvider.EXPECT().Unmount(gomock.Any(), session, datamodel.AttachmentSession{})
+	fsProvider.EXPECT().Delete(gomock.Any(), session)
+	sessionRegistry.EXPECT().DeleteSession(session)
+
+	handler.ReconcileSession(context.Background(), session.Name, false)
+}
@@ -1,14 +1,49 @@
 package dacd
 
+import "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+
 type BrickManager interface {
 	// Get the current hostname key that is being kept alive
 	Hostname() string
 
+	// Number of session actions waiting for a free worker, by action type
+	ActionQueueDepth() map[datamodel.SessionActionType]int
+
+	// True once startup has finished and the host is accepting new actions
+	IsReady() bool
+
+	// Summary of the bricks, actions and sessions on this host
+	GetStatus() (BrickManagerStatus, error)
+
 	// Tidy up from previous shutdowns
 	// , then start waiting for session actions
 	// notify dacctl we are listening via keep alive key
 	Startup()
-	// Wait for any events to complete
-	// then do any tidy up required for a graceful shutdown
-	Shutdown()
+	// Stop accepting new session actions,
+	// then wait for any in-flight actions to complete.
+	// Error if the actions didn't complete before the configured timeout
+	Shutdown() error
+}
+
+type BrickManagerStatus struct {
+	Hostname string
+	Ready    bool
+
+	// Bricks this host has registered
+	Bricks []datamodel.Brick
+
+	// Actions currently being processed
+	InFlightActions []datamodel.SessionAction
+
+	// Number of actions waiting for a free worker, by action type
+	QueueDepth map[datamodel.SessionActionType]int
+
+	// Sessions that have at least one brick on this host
+	LocalSessions []datamodel.Session
+
+	// True if this host is running the cluster wide housekeeping tasks
+	IsLeader bool
+
+	// Host currently elected to run the housekeeping tasks, empty if none
+	Leader datamodel.BrickHostName
 }
@@ -0,0 +1,50 @@
+package status_impl
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"log"
+	"net/http"
+)
+
+// Serves /healthz, /readyz, /status and prometheus /metrics for the given brick manager
+func NewStatusHandler(manager dacd.BrickManager) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		// if we can answer, the process is alive
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if !manager.IsReady() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintln(w, "not ready")
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		status, err := manager.GetStatus()
+		if err != nil {
+			log.Println("unable to get status due to:", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(getStatusResponse(status)); err != nil {
+			log.Println("unable to write status due to:", err)
+		}
+	})
+	return mux
+}
+
+// Listens in the background, the process exits if the listener fails
+func StartStatusServer(address string, manager dacd.BrickManager) {
+	server := &http.Server{Addr: address, Handler: NewStatusHandler(manager)}
+	go func() {
+		log.Println("Starting status listener on", address)
+		log.Fatal(server.ListenAndServe())
+	}()
+}
@@ -0,0 +1,104 @@
+package status_impl
+
+import (
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type stubBrickManager struct {
+	ready     bool
+	status    dacd.BrickManagerStatus
+	statusErr error
+}
+
+func (*stubBrickManager) Hostname() string {
+	return "host1"
+}
+func (*stubBrickManager) ActionQueueDepth() map[datamodel.SessionActionType]int {
+	return nil
+}
+func (m *stubBrickManager) IsReady() bool {
+	return m.ready
+}
+func (m *stubBrickManager) GetStatus() (dacd.BrickManagerStatus, error) {
+	return m.status, m.statusErr
+}
+func (*stubBrickManager) Startup() {
+}
+func (*stubBrickManager) Shutdown() error {
+	return nil
+}
+
+func get(handler http.Handler, path string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+	return recorder
+}
+
+func TestStatusHandler_Health(t *testing.T) {
+	manager := &stubBrickManager{}
+	handler := NewStatusHandler(manager)
+
+	response := get(handler, "/healthz")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "ok\n", response.Body.String())
+
+	response = get(handler, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, response.Code)
+	assert.Equal(t, "not ready\n", response.Body.String())
+
+	manager.ready = true
+	response = get(handler, "/readyz")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "ready\n", response.Body.String())
+
+	response = get(handler, "/metrics")
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), "go_goroutines")
+}
+
+func TestStatusHandler_Status(t *testing.T) {
+	manager := &stubBrickManager{status: dacd.BrickManagerStatus{
+		Hostname: "host1",
+		Ready:    true,
+		Bricks:   []datamodel.Brick{{Device: "nvme0n1", BrickHostName: "host1", PoolName: "pool1", CapacityGiB: 1400}},
+		InFlightActions: []datamodel.SessionAction{
+			{Uuid: "uuid1", ActionType: datamodel.SessionMount, Session: datamodel.Session{Name: "foo"}},
+		},
+		QueueDepth: map[datamodel.SessionActionType]int{datamodel.SessionCopyDataIn: 2},
+		LocalSessions: []datamodel.Session{{
+			Name:             "foo",
+			PrimaryBrickHost: "host2",
+			ActualSizeBytes:  1024,
+			AllocatedBricks: []datamodel.Brick{
+				{Device: "nvme0n1", BrickHostName: "host2"},
+				{Device: "nvme0n1", BrickHostName: "host1"},
+			},
+		}},
+		IsLeader: true,
+		Leader:   "host1",
+	}}
+	handler := NewStatusHandler(manager)
+
+	response := get(handler, "/status")
+
+	assert.Equal(t, http.StatusOK, response.Code)
+	assert.Equal(t, "application/json", response.Header().Get("Content-Type"))
+	expected := `{"hostname":"host1","ready":true,` +
+		`"bricks":[{"device":"nvme0n1","pool_name":"pool1","capacity_gib":1400}],` +
+		`"in_flight_actions":[{"uuid":"uuid1","session":"foo","action_type":"Mount"}],` +
+		`"queue_depth":{"CopyDataIn":2},` +
+		`"local_sessions":[{"name":"foo","primary_host":"host2","local_bricks":1,"actual_size_bytes":1024}],` +
+		`"is_leader":true,"leader":"host1"}` + "\n"
+	assert.Equal(t, expected, response.Body.String())
+
+	manager.statusErr = errors.New("fake")
+	response = get(handler, "/status")
+	assert.Equal(t, http.StatusInternalServerError, response.Code)
+	assert.Equal(t, "fake\n", response.Body.String())
+}
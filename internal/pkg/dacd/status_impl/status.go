@@ -0,0 +1,82 @@
+package status_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacd"
+)
+
+type statusBrick struct {
+	Device      string `json:"device"`
+	PoolName    string `json:"pool_name"`
+	CapacityGiB uint   `json:"capacity_gib"`
+}
+
+type statusAction struct {
+	Uuid       string `json:"uuid"`
+	Session    string `json:"session"`
+	ActionType string `json:"action_type"`
+}
+
+type statusSession struct {
+	Name            string `json:"name"`
+	PrimaryHost     string `json:"primary_host"`
+	LocalBricks     int    `json:"local_bricks"`
+	ActualSizeBytes int    `json:"actual_size_bytes"`
+	Error           string `json:"error,omitempty"`
+}
+
+type statusResponse struct {
+	Hostname        string          `json:"hostname"`
+	Ready           bool            `json:"ready"`
+	Bricks          []statusBrick   `json:"bricks"`
+	InFlightActions []statusAction  `json:"in_flight_actions"`
+	QueueDepth      map[string]int  `json:"queue_depth"`
+	LocalSessions   []statusSession `json:"local_sessions"`
+	IsLeader        bool            `json:"is_leader"`
+	Leader          string          `json:"leader"`
+}
+
+func getStatusResponse(status dacd.BrickManagerStatus) statusResponse {
+	response := statusResponse{
+		Hostname:        status.Hostname,
+		Ready:           status.Ready,
+		Bricks:          []statusBrick{},
+		InFlightActions: []statusAction{},
+		QueueDepth:      make(map[string]int),
+		LocalSessions:   []statusSession{},
+		IsLeader:        status.IsLeader,
+		Leader:          string(status.Leader),
+	}
+	for _, brick := range status.Bricks {
+		response.Bricks = append(response.Bricks, statusBrick{
+			Device:      brick.Device,
+			PoolName:    string(brick.PoolName),
+			CapacityGiB: brick.CapacityGiB,
+		})
+	}
+	for _, action := range status.InFlightActions {
+		response.InFlightActions = append(response.InFlightActions, statusAction{
+			Uuid:       action.Uuid,
+			Session:    string(action.Session.Name),
+			ActionType: string(action.ActionType),
+		})
+	}
+	for actionType, depth := range status.QueueDepth {
+		response.QueueDepth[string(actionType)] = depth
+	}
+	for _, session := range status.LocalSessions {
+		localBricks := 0
+		for _, brick := range session.AllocatedBricks {
+			if string(brick.BrickHostName) == status.Hostname {
+				localBricks += 1
+			}
+		}
+		response.LocalSessions = append(response.LocalSessions, statusSession{
+			Name:            string(session.Name),
+			PrimaryHost:     string(session.PrimaryBrickHost),
+			LocalBricks:     localBricks,
+			ActualSizeBytes: session.ActualSizeBytes,
+			Error:           session.Status.Error,
+		})
+	}
+	return response
+}
@@ -12,6 +12,17 @@ type BrickHost struct {
 	Enabled bool
 }
 
+// A brick that must not be given to new sessions until an admin has checked it
+type UnhealthyBrick struct {
+	Brick Brick
+
+	// Why the brick was marked unhealthy
+	Reason string
+
+	// utc unix timestamp when the brick was marked unhealthy
+	MarkedAt uint
+}
+
 type BrickHostStatus struct {
 	BrickHost BrickHost
 
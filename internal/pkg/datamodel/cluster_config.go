@@ -0,0 +1,37 @@
+package datamodel
+
+// Settings that must be the same on every dacd, stored in the keystore.
+// Values use the config file key names, e.g. max_mdt_count
+type ClusterConfig struct {
+	// Currently stored revision, checked when an update is requested
+	Revision int64
+
+	// Used by every pool, unless overridden below
+	Settings map[string]string
+
+	// Per pool overrides of Settings
+	PoolSettings map[PoolName]map[string]string
+}
+
+// Filesystem settings used when a session's filesystem was created,
+// so later changes to the cluster config don't affect existing filesystems
+type FilesystemSettings struct {
+	MGSDevice  string
+	MaxMDTs    uint
+	MDTSizeMB  uint
+	LnetSuffix string
+
+	// Empty for filesystems created before the type was recorded, which are all Lustre
+	Type FilesystemType
+
+	// Name of the provider that created the filesystem,
+	// empty for filesystems created before providers were configurable, which all used ansible
+	Provider string
+}
+
+type FilesystemType string
+
+const (
+	LustreFilesystem = FilesystemType("lustre")
+	BeeGFSFilesystem = FilesystemType("beegfs")
+)
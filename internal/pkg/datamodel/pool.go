@@ -19,4 +19,89 @@ type PoolInfo struct {
 
 	// All currently active bricks
 	AllocatedBricks []BrickAllocation
+
+	// Free bricks that only the given session can allocate,
+	// as it preempted the buffers that were using them
+	ReservedBricks map[SessionName][]Brick
+
+	// Free bricks that can't be used until an admin looks at them,
+	// such as those that failed to wipe
+	UnhealthyBricks []Brick
+}
+
+// How a pool's bricks are cleaned when a buffer is deleted,
+// before they can be given to another session
+type WipePolicy string
+
+const (
+	// Bricks are reused with the previous data still on them, until the next format
+	WipeNone = WipePolicy("none")
+
+	// Discard (TRIM) every block on the device
+	WipeDiscard = WipePolicy("discard")
+
+	// Overwrite every block on the device with zeros
+	WipeOverwrite = WipePolicy("overwrite")
+)
+
+// Result of checking a capacity request against the current pool state,
+// without allocating any bricks
+type PoolCapacityCheck struct {
+	PoolName PoolName
+
+	// Bytes asked for by the user
+	RequestedBytes int
+
+	// Requested bytes rounded up to the pool granularity
+	ActualSizeBytes int
+
+	// Number of bricks that would be allocated
+	BricksRequired int
+
+	// True if there are enough available bricks right now
+	FitsNow bool
+
+	// True if the request would fit if no bricks were allocated,
+	// when false the request can never be satisfied
+	FitsEmptyPool bool
+}
+
+// How bricks are chosen when placing a buffer,
+// used to report the largest buffer each approach could place
+type AllocationPolicy string
+
+const (
+	// Any free brick in the pool, this is what is used to allocate buffers
+	AllocationPolicyAny = AllocationPolicy("any")
+
+	// At most one brick from each host
+	AllocationPolicySpread = AllocationPolicy("spread")
+
+	// All bricks from a single host
+	AllocationPolicySingleHost = AllocationPolicy("single_host")
+)
+
+// Fragmentation and utilisation summary of a pool,
+// used to size buffers and plan hardware purchases
+type PoolReport struct {
+	Pool Pool
+
+	// Free bricks on alive and enabled hosts, by host
+	FreeBricksByHost map[BrickHostName]int
+
+	// Largest buffer that could be placed right now, for each policy
+	LargestBufferBytes map[AllocationPolicy]int
+
+	// Bytes asked for by all the sessions using this pool
+	RequestedBytes int
+
+	// Bytes allocated after rounding up to the pool granularity
+	AllocatedBytes int
+
+	// Bricks that can't be used for new buffers
+	DeadHostBricks     int
+	DisabledHostBricks int
+
+	// Count of sessions with bricks in this pool, keyed by age bucket
+	AllocationAges map[string]int
 }
@@ -22,6 +22,9 @@ type Session struct {
 	// utc unix timestamp when buffer created
 	CreatedAt uint
 
+	// utc unix timestamp when a job last detached from this persistent buffer
+	LastUsedAt uint
+
 	// Details of what was requested
 	VolumeRequest VolumeRequest
 
@@ -49,6 +52,10 @@ type Session struct {
 	// One is the primary brick that should be watching for all actions
 	AllocatedBricks []Brick
 
+	// Bricks added to AllocatedBricks by an expand request,
+	// that have not yet been added to the filesystem
+	PendingExpandBricks []Brick
+
 	// Where session requests should be sent
 	PrimaryBrickHost BrickHostName
 
@@ -60,20 +67,93 @@ type Session struct {
 	// and track if the filesystem had a recent error
 	FilesystemStatus FilesystemStatus
 
+	// Cluster wide filesystem settings, recorded when the filesystem is created
+	FilesystemSettings *FilesystemSettings
+
 	// For multi-job volumes these are always other sessions
 	// for job volumes this is always for just this session
 	CurrentAttachments map[SessionName]AttachmentSession
+
+	// Set when bricks were reclaimed for a higher priority session,
+	// the buffer has no filesystem once the preemption completes
+	Preemption *PreemptionRecord
+}
+
+// Idle means no jobs have the buffer attached,
+// other than the mount on its own primary brick host
+func (session Session) IsIdle() bool {
+	for _, attachment := range session.CurrentAttachments {
+		if attachment.SessionName != session.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// Persistent buffers listed in any session's MultiJobAttachments,
+// including jobs that are set up but have not yet mounted them
+func GetReferencedBuffers(sessions []Session) map[SessionName]bool {
+	referenced := make(map[SessionName]bool)
+	for _, session := range sessions {
+		for _, name := range session.MultiJobAttachments {
+			referenced[name] = true
+		}
+	}
+	return referenced
 }
 
 const MountJobBasePattern = "/mnt/dac/%s_job"
 const MountMultiJobBasePattern = "/mnt/dac/%s_persistent_%s"
 const MountGlobalDir = "global"
 const MountPrivatePattern = "/mnt/dac/%s_job_private"
+const MountMultiJobPrivatePattern = "/mnt/dac/%s_persistent_%s_private"
+
+type PreemptionRecord struct {
+	// Session that needed the capacity
+	PreemptedBy SessionName
+
+	// Priority of the session that needed the capacity
+	PreemptedByPriority PriorityClass
+
+	// utc unix timestamp when the decision was made
+	PreemptedAt uint
+
+	// Why this session was picked
+	Reason string
+
+	// Bricks freed by the preemption, only PreemptedBy can allocate them
+	// until they are used or ReservedUntil passes
+	ReservedBricks []Brick
+
+	// utc unix timestamp when any unused reserved bricks return to the pool
+	ReservedUntil uint
+}
 
 type FilesystemStatus struct {
 	Error        string
 	InternalName string
 	InternalData string
+
+	// Targets formatted when the filesystem was created,
+	// nil for filesystems created before it was recorded
+	Layout *FilesystemLayout
+}
+
+// Bricks added by an expand only have an OST, so later restores
+// must use the layout from when the filesystem was created
+type FilesystemLayout struct {
+	// Number of bricks when the filesystem was created
+	CreatedBrickCount int
+
+	// Targets on each of the bricks the filesystem was created with
+	Targets []BrickTargets
+}
+
+type BrickTargets struct {
+	Brick    Brick
+	HasMDT   bool
+	MDTIndex int
+	OSTIndex int
 }
 
 type AttachmentSession struct {
@@ -83,6 +163,15 @@ type AttachmentSession struct {
 	GlobalMount  bool
 	PrivateMount bool
 	SwapBytes    int
+
+	// Result of the last mount or unmount on each host
+	HostResults map[string]AttachmentHostResult
+}
+
+type AttachmentHostResult struct {
+	// True if the host may still have the buffer mounted
+	Mounted bool
+	Error   string
 }
 
 type SessionStatus struct {
@@ -107,6 +196,13 @@ type SessionStatus struct {
 	// Mount status
 	UnmountComplete bool
 	MountComplete   bool
+
+	// Failed attempts from the most recent action that had to retry something
+	FailedAttempts []ActionAttempt
+
+	// Brick hosts that have finished wiping the session's bricks during delete,
+	// so a retried delete only wipes the remaining hosts
+	WipedBrickHosts []BrickHostName
 }
 
 type VolumeRequest struct {
@@ -117,8 +213,20 @@ type VolumeRequest struct {
 	Access             AccessMode
 	Type               BufferType
 	SwapBytes          int
+	Priority           PriorityClass
+	// Optional, when empty the pool's filesystem type is used
+	FilesystemType FilesystemType
 }
 
+// Higher priority sessions are able to preempt idle lower priority buffers
+type PriorityClass int
+
+const (
+	LowPriority    PriorityClass = -1
+	NormalPriority PriorityClass = 0
+	HighPriority   PriorityClass = 1
+)
+
 type AccessMode int
 
 const (
@@ -1,10 +1,21 @@
 package datamodel
 
+import (
+	"fmt"
+	"time"
+)
+
 type SessionAction struct {
 	Uuid       string
 	Session    Session
 	ActionType SessionActionType
 	Error      string
+
+	// Action is stopped if it has not finished by this time, zero means no deadline
+	Deadline time.Time
+
+	// Who asked for the action, e.g. the Slurm function and job, added to dacd's logs
+	Caller string
 }
 
 type SessionActionType string
@@ -18,4 +29,22 @@ const (
 	SessionMount                              = SessionActionType("Mount")
 	SessionUnmount                            = SessionActionType("Unmount")
 	SessionCopyDataOut                        = SessionActionType("CopyDataOut")
+	SessionExpand                             = SessionActionType("Expand")
+	SessionPreempt                            = SessionActionType("Preempt")
 )
+
+// One failed attempt at an operation, such as mounting on a compute node
+type ActionAttempt struct {
+	ActionType  SessionActionType
+	Operation   string
+	Hostname    string
+	Attempt     uint
+	MaxAttempts uint
+	Error       string
+	AttemptedAt time.Time
+}
+
+func (attempt ActionAttempt) String() string {
+	return fmt.Sprintf("%s attempt %d/%d on %s failed: %s",
+		attempt.Operation, attempt.Attempt, attempt.MaxAttempts, attempt.Hostname, attempt.Error)
+}
@@ -9,6 +9,10 @@ type Session interface {
 	// Allocates storage and
 	CreateSession(session datamodel.Session) error
 
+	// Allocates extra bricks from the given pool,
+	// then adds them to the existing persistent buffer's filesystem
+	ExpandSession(sessionName datamodel.SessionName, poolName datamodel.PoolName, extraCapacityBytes int) error
+
 	// Deletes the requested volume and session allocation
 	// If hurry, there is no stage-out attempted
 	// Unmount is always attempted before deleting the buffer
@@ -29,6 +33,13 @@ type Session interface {
 	// Get brick availability by pool
 	GetPools() ([]datamodel.PoolInfo, error)
 
+	// Get fragmentation and utilisation report for every pool
+	GetPoolReports() ([]datamodel.PoolReport, error)
+
+	// Check if the requested capacity could be allocated from the pool,
+	// using the same rounding as a real allocation, but nothing is allocated
+	CheckCapacity(poolName datamodel.PoolName, capacityBytes int) (datamodel.PoolCapacityCheck, error)
+
 	// Get requested session
 	//
 	// Error if session does not exist
@@ -37,6 +48,28 @@ type Session interface {
 	// Get all sessions
 	GetAllSessions() ([]datamodel.Session, error)
 
+	// Get settings shared by every brick host
+	GetClusterConfig() (datamodel.ClusterConfig, error)
+
+	// Replace the cluster config, which brick hosts use for any new sessions
+	//
+	// Error if the config has been updated since it was read
+	UpdateClusterConfig(clusterConfig datamodel.ClusterConfig) (datamodel.ClusterConfig, error)
+
+	// Get bricks that are not given to new sessions, such as those that failed to wipe
+	GetUnhealthyBricks() ([]datamodel.UnhealthyBrick, error)
+
+	// Allow an unhealthy brick to be given to new sessions again
+	//
+	// Error if the brick is not marked unhealthy
+	ClearUnhealthyBrick(brick datamodel.Brick) error
+
+	// Ask the primary brick host to stop any running or queued actions for the session,
+	// the caller waiting for each action sees it fail
+	//
+	// Error if there are no actions to cancel
+	CancelSession(sessionName datamodel.SessionName) error
+
 	// Generate ansible test dir
 	GenerateAnsible(sessionName datamodel.SessionName) (string, error)
 }
@@ -1,8 +1,14 @@
 package facade
 
-import "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+)
 
 type SessionActionHandler interface {
 	ProcessSessionAction(action datamodel.SessionAction)
-	RestoreSession(session datamodel.Session)
+	RestoreSession(ctxt context.Context, session datamodel.Session)
+
+	// Compare the session with what is really on the hosts, fixing any drift unless reportOnly
+	ReconcileSession(ctxt context.Context, sessionName datamodel.SessionName, reportOnly bool)
 }
@@ -0,0 +1,27 @@
+package filesystem
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+)
+
+// Describes the action an operation is part of,
+// used to pick the retry policy and report any failed attempts
+type ActionInfo struct {
+	ActionType datamodel.SessionActionType
+
+	// Called after each failed attempt, may be nil
+	RecordAttempt func(attempt datamodel.ActionAttempt)
+}
+
+type actionInfoKey struct{}
+
+func WithActionInfo(ctxt context.Context, info ActionInfo) context.Context {
+	return context.WithValue(ctxt, actionInfoKey{}, info)
+}
+
+// Returns an empty ActionInfo if none was added to the context
+func GetActionInfo(ctxt context.Context) ActionInfo {
+	info, _ := ctxt.Value(actionInfoKey{}).(ActionInfo)
+	return info
+}
@@ -0,0 +1,48 @@
+package filesystem
+
+import (
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"sort"
+	"strings"
+)
+
+// Returned by Mount and Unmount when only some of the hosts failed, keyed by hostname.
+// Any host not listed worked
+type HostErrors map[string]error
+
+func (e HostErrors) Error() string {
+	var hostnames []string
+	for hostname := range e {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+	var messages []string
+	for _, hostname := range hostnames {
+		messages = append(messages, fmt.Sprintf("%s: %s", hostname, e[hostname]))
+	}
+	return fmt.Sprintf("failed on %d hosts: %s", len(e), strings.Join(messages, "; "))
+}
+
+// Used in HostErrors for a host whose mount failed and could not be undone,
+// so the host may still have the filesystem mounted
+type StillMountedError struct {
+	MountErr   error
+	UnmountErr error
+}
+
+func (e StillMountedError) Error() string {
+	return fmt.Sprintf("%s, then unable to unmount due to: %s", e.MountErr, e.UnmountErr)
+}
+
+// Returned by CheckMounts, so only the hosts that are missing an attachment are mounted again
+type MissingMountsError struct {
+	// Everything found missing, in the order it was checked
+	Missing []string
+	// Hosts missing each attachment, keyed by the attachment
+	Hosts map[datamodel.SessionName][]string
+}
+
+func (e MissingMountsError) Error() string {
+	return fmt.Sprintf("expected mounts missing: %s", strings.Join(e.Missing, ", "))
+}
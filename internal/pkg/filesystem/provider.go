@@ -1,15 +1,31 @@
 package filesystem
 
-import "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+)
 
+// Any commands are stopped if the context is cancelled or reaches its deadline
 type Provider interface {
-	Create(session datamodel.Session) (datamodel.FilesystemStatus, error)
-	Restore(session datamodel.Session) error
-	Delete(session datamodel.Session) error
+	Create(ctxt context.Context, session datamodel.Session) (datamodel.FilesystemStatus, error)
+	// Leaves out the session's bricks from an incomplete expand, as they may not be formatted
+	Restore(ctxt context.Context, session datamodel.Session) error
+	Delete(ctxt context.Context, session datamodel.Session) error
+	Expand(ctxt context.Context, session datamodel.Session, newBricks []datamodel.Brick) error
 
-	DataCopyIn(session datamodel.Session) error
-	DataCopyOut(session datamodel.Session) error
+	DataCopyIn(ctxt context.Context, session datamodel.Session) error
+	DataCopyOut(ctxt context.Context, session datamodel.Session) error
 
-	Mount(session datamodel.Session, attachments datamodel.AttachmentSession, setInitialPermissions bool) error
-	Unmount(session datamodel.Session, attachments datamodel.AttachmentSession) error
+	// Return HostErrors if only some hosts failed, so only the other hosts are unmounted
+	Mount(ctxt context.Context, session datamodel.Session, attachments datamodel.AttachmentSession,
+		setInitialPermissions bool) error
+	// Return HostErrors if only some hosts failed, so only those are unmounted again
+	Unmount(ctxt context.Context, session datamodel.Session, attachments datamodel.AttachmentSession) error
+
+	// Check the filesystem targets, and all the session's current attachments, are mounted,
+	// leaving out bricks from an incomplete expand, like Restore
+	CheckMounts(ctxt context.Context, session datamodel.Session) error
+
+	// Remove any data left on the brick, once the filesystem has been deleted
+	Wipe(ctxt context.Context, brick datamodel.Brick, policy datamodel.WipePolicy) error
 }
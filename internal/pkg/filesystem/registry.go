@@ -0,0 +1,34 @@
+package filesystem
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Creates a provider, given the values from its config section, keyed by the config file key name
+type ProviderFactory func(settings map[string]string) (Provider, error)
+
+var providerFactories = make(map[string]ProviderFactory)
+
+// Makes a provider available to pools that name it in their config.
+// Must be called from an init function, use config.RegisterProviderKeys to add any settings
+func RegisterProvider(name string, factory ProviderFactory) {
+	if _, ok := providerFactories[name]; ok {
+		panic(fmt.Sprintf("filesystem provider %s registered twice", name))
+	}
+	providerFactories[name] = factory
+}
+
+func GetProviderNames() []string {
+	var names []string
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func GetProviderFactory(name string) (ProviderFactory, bool) {
+	factory, ok := providerFactories[name]
+	return factory, ok
+}
@@ -1,10 +1,13 @@
 package filesystem_impl
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"log"
@@ -12,6 +15,8 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 )
 
@@ -41,66 +46,183 @@ func NewAnsible() filesystem.Ansible {
 type ansibleImpl struct {
 }
 
-func (*ansibleImpl) CreateEnvironment(session datamodel.Session) (string, error) {
-	return setupAnsible(Lustre, session.FilesystemStatus.InternalName, session.AllocatedBricks)
+func (*ansibleImpl) CreateEnvironment(ctxt context.Context, session datamodel.Session) (string, error) {
+	settings := getFilesystemSettings(session)
+	fsType := getFSType(settings)
+	return setupAnsible(ctxt, fsType, session.FilesystemStatus.InternalName, getFormattedBricks(session),
+		getSessionLayout(fsType, session, settings), settings)
 }
 
 var conf = config.GetFilesystemConfig()
 
-func getInventory(fsType FSType, fsUuid string, allBricks []datamodel.Brick) string {
-	allocationByHost := make(map[datamodel.BrickHostName][]datamodel.BrickAllocation)
-	for i, brick := range allBricks {
-		allocationByHost[brick.BrickHostName] = append(allocationByHost[brick.BrickHostName], datamodel.BrickAllocation{
-			Brick:          brick,
-			AllocatedIndex: uint(i),
-		})
+// Sessions created before settings were recorded use the local config
+func getFilesystemSettings(session datamodel.Session) datamodel.FilesystemSettings {
+	if session.FilesystemSettings != nil {
+		return *session.FilesystemSettings
 	}
+	return conf.GetFilesystemSettings()
+}
+
+func getInventory(fsType FSType, fsUuid string, allBricks []datamodel.Brick,
+	settings datamodel.FilesystemSettings) string {
+	return getLayoutInventory(fsType, fsUuid, allBricks, getLayout(fsType, allBricks, settings), settings)
+}
+
+func getLayoutInventory(fsType FSType, fsUuid string, bricks []datamodel.Brick, layout datamodel.FilesystemLayout,
+	settings datamodel.FilesystemSettings) string {
+	hosts, mgsnode := getHostInfos(fsType, bricks, layout, settings)
+
+	// TODO: add attachments?
 
+	return inventoryToString(fsUuid, mgsnode, hosts, settings)
+}
+
+// Targets for a new filesystem, every brick has an OST and
+// the MDTs are spread across the bricks
+func getLayout(fsType FSType, allBricks []datamodel.Brick,
+	settings datamodel.FilesystemSettings) datamodel.FilesystemLayout {
 	// If we have more brick allocations than maxMDTs
 	// assign at most one mdt per host.
 	// While this may give us less MDTs than max MDTs,
-	// but it helps spread MDTs across network connections
-	oneMdtPerHost := len(allBricks) > int(conf.MaxMDTs)
+	// but it helps spread MDTs across network connections.
+	// BeeGFS runs a single metadata service per filesystem on each host
+	oneMdtPerHost := len(allBricks) > int(settings.MaxMDTs) || fsType == BeegFS
 
-	hosts := make(map[string]HostInfo)
-	mgsnode := ""
-	for host, allocations := range allocationByHost {
-		osts := make(map[string]int)
-		for _, allocation := range allocations {
-			osts[allocation.Brick.Device] = int(allocation.AllocatedIndex)
+	layout := datamodel.FilesystemLayout{CreatedBrickCount: len(allBricks)}
+	hostsWithMdt := make(map[datamodel.BrickHostName]bool)
+	for i, brick := range allBricks {
+		target := datamodel.BrickTargets{Brick: brick, OSTIndex: i}
+		if !oneMdtPerHost || !hostsWithMdt[brick.BrickHostName] {
+			target.HasMDT = true
+			target.MDTIndex = i
+			hostsWithMdt[brick.BrickHostName] = true
+		}
+		layout.Targets = append(layout.Targets, target)
+	}
+	return layout
+}
+
+// Filesystems created before the layout was recorded have not been expanded
+func getSessionLayout(fsType FSType, session datamodel.Session,
+	settings datamodel.FilesystemSettings) datamodel.FilesystemLayout {
+	if session.FilesystemStatus.Layout != nil {
+		return *session.FilesystemStatus.Layout
+	}
+	return getLayout(fsType, getFormattedBricks(session), settings)
+}
+
+// Bricks from an expand that has not completed may not have been formatted
+func getFormattedBricks(session datamodel.Session) []datamodel.Brick {
+	pending := make(map[datamodel.Brick]bool)
+	for _, brick := range session.PendingExpandBricks {
+		pending[brick] = true
+	}
+	var bricks []datamodel.Brick
+	for _, brick := range session.AllocatedBricks {
+		if !pending[brick] {
+			bricks = append(bricks, brick)
 		}
+	}
+	return bricks
+}
+
+// Get the targets on each host, and which host has the MGS.
+// Bricks not in the layout were added by an expand, so only have an OST
+func getHostInfos(fsType FSType, bricks []datamodel.Brick, layout datamodel.FilesystemLayout,
+	settings datamodel.FilesystemSettings) (map[string]HostInfo, string) {
+	targets := make(map[datamodel.Brick]datamodel.BrickTargets)
+	for _, target := range layout.Targets {
+		targets[target.Brick] = target
+	}
 
-		mdts := make(map[string]int)
-		if oneMdtPerHost {
-			allocation := allocations[0]
-			mdts[allocation.Brick.Device] = int(allocation.AllocatedIndex)
-		} else {
-			for _, allocation := range allocations {
-				mdts[allocation.Brick.Device] = int(allocation.AllocatedIndex)
+	hosts := make(map[string]HostInfo)
+	for i, brick := range bricks {
+		target, ok := targets[brick]
+		if !ok {
+			if i < layout.CreatedBrickCount {
+				log.Panicf("brick not in the filesystem's layout: %+v", brick)
 			}
+			target = datamodel.BrickTargets{Brick: brick, OSTIndex: i}
 		}
 
-		hostInfo := HostInfo{MDTS: mdts, OSTS: osts}
+		host := string(brick.BrickHostName)
+		hostInfo, ok := hosts[host]
+		if !ok {
+			hostInfo = HostInfo{MDTS: make(map[string]int), OSTS: make(map[string]int)}
+		}
+		hostInfo.OSTS[brick.Device] = target.OSTIndex
+		if target.HasMDT {
+			hostInfo.MDTS[brick.Device] = target.MDTIndex
+		}
+		hosts[host] = hostInfo
+	}
 
-		if allocations[0].AllocatedIndex == 0 {
+	// The MGS is on the host with the first brick
+	mgsnode := ""
+	if len(layout.Targets) > 0 {
+		firstBrick := layout.Targets[0].Brick
+		mgsnode = string(firstBrick.BrickHostName)
+		if hostInfo, ok := hosts[mgsnode]; ok {
 			if fsType == Lustre {
-				hostInfo.MGS = conf.MGSDevice
+				hostInfo.MGS = settings.MGSDevice
 			} else {
-				hostInfo.MGS = allocations[0].Brick.Device
+				hostInfo.MGS = firstBrick.Device
 			}
-			mgsnode = string(host)
+			hosts[mgsnode] = hostInfo
 		}
-		hosts[string(host)] = hostInfo
 	}
+	return hosts, mgsnode
+}
 
-	// TODO: add attachments?
+// Inventory only lists the OSTs for the new bricks,
+// so the existing MGS, MDTs and OSTs are left alone
+func getExpandInventory(fsUuid string, allBricks []datamodel.Brick, newBricks []datamodel.Brick,
+	settings datamodel.FilesystemSettings) string {
+	hosts := make(map[string]HostInfo)
+	for _, newBrick := range newBricks {
+		allocatedIndex := -1
+		for i, brick := range allBricks {
+			if brick == newBrick {
+				allocatedIndex = i
+				break
+			}
+		}
+		if allocatedIndex < 0 {
+			log.Panicf("new brick not in the filesystem's bricks: %+v", newBrick)
+		}
+
+		host := string(newBrick.BrickHostName)
+		hostInfo, ok := hosts[host]
+		if !ok {
+			hostInfo = HostInfo{OSTS: make(map[string]int)}
+		}
+		hostInfo.OSTS[newBrick.Device] = allocatedIndex
+		hosts[host] = hostInfo
+	}
+	return inventoryToString(fsUuid, string(allBricks[0].BrickHostName), hosts, settings)
+}
 
+// Compute hosts are added to the filesystem's group, so the client playbooks
+// can find the MGS, and the playbook run is limited to just the clients
+func getClientInventory(fsUuid string, allBricks []datamodel.Brick, layout datamodel.FilesystemLayout,
+	clientHosts []string, settings datamodel.FilesystemSettings) string {
+	hosts, mgsnode := getHostInfos(BeegFS, allBricks, layout, settings)
+	for _, host := range clientHosts {
+		if _, ok := hosts[host]; !ok {
+			hosts[host] = HostInfo{}
+		}
+	}
+	return inventoryToString(fsUuid, mgsnode, hosts, settings)
+}
+
+func inventoryToString(fsUuid string, mgsnode string, hosts map[string]HostInfo,
+	settings datamodel.FilesystemSettings) string {
 	fsinfo := FSInfo{
 		Vars: map[string]string{
 			"mgsnode": mgsnode,
 			//"client_port": fmt.Sprintf("%d", volume.ClientPort),
-			"lnet_suffix": conf.LnetSuffix,
-			"mdt_size_mb": fmt.Sprintf("%d", conf.MDTSizeMB),
+			"lnet_suffix": settings.LnetSuffix,
+			"mdt_size_mb": fmt.Sprintf("%d", settings.MDTSizeMB),
 			"fs_name":     fsUuid,
 		},
 		Hosts: hosts,
@@ -132,37 +254,55 @@ func getPlaybook(fsType FSType, fsUuid string) string {
         fs_name: %s`, fsUuid, role, fsUuid)
 }
 
+// BeeGFS has its own playbooks, e.g. beegfs-create.yml
+func getPlaybookFile(fsType FSType, playbook string) string {
+	if fsType == BeegFS {
+		return fmt.Sprintf("beegfs-%s", playbook)
+	}
+	return playbook
+}
+
+var allPlaybooks = []string{
+	"create.yml", "delete.yml", "restore.yml", "expand.yml",
+	"beegfs-create.yml", "beegfs-delete.yml", "beegfs-restore.yml",
+	"beegfs-client-mount.yml", "beegfs-client-unmount.yml",
+}
+
 func getAnsibleDir(suffix string) string {
 	return path.Join(conf.AnsibleDir, suffix)
 }
 
-func setupAnsible(fsType FSType, internalName string, bricks []datamodel.Brick) (string, error) {
+func setupAnsible(ctxt context.Context, fsType FSType, internalName string, bricks []datamodel.Brick,
+	layout datamodel.FilesystemLayout, settings datamodel.FilesystemSettings) (string, error) {
 	if len(bricks) == 0 {
 		log.Panicf("can't create filesystem with no bricks: %s", internalName)
 	}
+	return setupAnsibleWithInventory(ctxt, internalName,
+		getLayoutInventory(fsType, internalName, bricks, layout, settings))
+}
 
+func setupAnsibleWithInventory(ctxt context.Context, internalName string, inventory string) (string, error) {
 	dir, err := ioutil.TempDir("", fmt.Sprintf("fs%s_", internalName))
 	if err != nil {
 		return dir, err
 	}
 	log.Println("Using ansible tempdir:", dir)
 
-	inventory := getInventory(fsType, internalName, bricks)
 	tmpInventory := filepath.Join(dir, "inventory")
 	if err := ioutil.WriteFile(tmpInventory, []byte(inventory), 0666); err != nil {
 		return dir, err
 	}
 	log.Println(inventory)
 
-	cmd := exec.Command("cp", "-r", getAnsibleDir("roles"), dir)
+	cmd := exec.CommandContext(ctxt, "cp", "-r", getAnsibleDir("roles"), dir)
 	output, err := cmd.CombinedOutput()
 	log.Println("copy roles", string(output))
 	if err != nil {
 		return dir, err
 	}
 
-	for _, playbook := range []string{"create.yml", "delete.yml", "restore.yml"} {
-		cmd = exec.Command("cp", getAnsibleDir(playbook), dir)
+	for _, playbook := range allPlaybooks {
+		cmd = exec.CommandContext(ctxt, "cp", getAnsibleDir(playbook), dir)
 		output, err = cmd.CombinedOutput()
 		log.Println("copy playbooks", playbook, string(output))
 		if err != nil {
@@ -170,15 +310,28 @@ func setupAnsible(fsType FSType, internalName string, bricks []datamodel.Brick)
 		}
 	}
 
-	cmd = exec.Command("cp", "-r", getAnsibleDir(".venv"), dir)
+	// Sites can describe their hosts, e.g. beegfs_host_info, next to the playbooks
+	for _, varsDir := range []string{"group_vars", "host_vars"} {
+		if _, err := os.Stat(getAnsibleDir(varsDir)); err != nil {
+			continue
+		}
+		cmd = exec.CommandContext(ctxt, "cp", "-r", getAnsibleDir(varsDir), dir)
+		output, err = cmd.CombinedOutput()
+		log.Println("copy", varsDir, string(output))
+		if err != nil {
+			return dir, err
+		}
+	}
+
+	cmd = exec.CommandContext(ctxt, "cp", "-r", getAnsibleDir(".venv"), dir)
 	output, err = cmd.CombinedOutput()
 	log.Println("copy venv", string(output))
 	return dir, err
 }
 
-func executeAnsibleSetup(internalName string, bricks []datamodel.Brick, doFormat bool) error {
-	// TODO: restore beegfs support
-	dir, err := setupAnsible(Lustre, internalName, bricks)
+func executeAnsibleSetup(ctxt context.Context, fsType FSType, internalName string, bricks []datamodel.Brick,
+	layout datamodel.FilesystemLayout, settings datamodel.FilesystemSettings, doFormat bool) error {
+	dir, err := setupAnsible(ctxt, fsType, internalName, bricks, layout, settings)
 	if err != nil {
 		return err
 	}
@@ -190,14 +343,14 @@ func executeAnsibleSetup(internalName string, bricks []datamodel.Brick, doFormat
 
 	// allow skip format when trying to rebuild
 	if doFormat {
-		formatArgs := "create.yml -i inventory"
-		err = executeAnsiblePlaybook(dir, formatArgs)
+		formatArgs := fmt.Sprintf("%s -i inventory", getPlaybookFile(fsType, "create.yml"))
+		err = executeAnsiblePlaybook(ctxt, dir, formatArgs)
 		if err != nil {
 			return fmt.Errorf("error during ansible create: %s", err.Error())
 		}
 	} else {
-		formatArgs := "restore.yml -i inventory"
-		err = executeAnsiblePlaybook(dir, formatArgs)
+		formatArgs := fmt.Sprintf("%s -i inventory", getPlaybookFile(fsType, "restore.yml"))
+		err = executeAnsiblePlaybook(ctxt, dir, formatArgs)
 		if err != nil {
 			return fmt.Errorf("error during ansible create: %s", err.Error())
 		}
@@ -205,8 +358,9 @@ func executeAnsibleSetup(internalName string, bricks []datamodel.Brick, doFormat
 	return nil
 }
 
-func executeAnsibleTeardown(internalName string, bricks []datamodel.Brick) error {
-	dir, err := setupAnsible(Lustre, internalName, bricks)
+func executeAnsibleTeardown(ctxt context.Context, fsType FSType, internalName string, bricks []datamodel.Brick,
+	layout datamodel.FilesystemLayout, settings datamodel.FilesystemSettings) error {
+	dir, err := setupAnsible(ctxt, fsType, internalName, bricks, layout, settings)
 	if err != nil {
 		return err
 	}
@@ -216,48 +370,134 @@ func executeAnsibleTeardown(internalName string, bricks []datamodel.Brick) error
 		}
 	}()
 
-	formatArgs := "delete.yml -i inventory"
-	err = executeAnsiblePlaybook(dir, formatArgs)
+	formatArgs := fmt.Sprintf("%s -i inventory", getPlaybookFile(fsType, "delete.yml"))
+	err = executeAnsiblePlaybook(ctxt, dir, formatArgs)
 	if err != nil {
 		return fmt.Errorf("error during server clean: %s", err.Error())
 	}
 	return nil
 }
 
-func executeAnsiblePlaybook(dir string, args string) error {
+func executeAnsibleExpand(ctxt context.Context, internalName string, allBricks []datamodel.Brick, newBricks []datamodel.Brick,
+	settings datamodel.FilesystemSettings) error {
+	if len(allBricks) == 0 || len(newBricks) == 0 {
+		log.Panicf("can't expand filesystem with no bricks: %s", internalName)
+	}
+	dir, err := setupAnsibleWithInventory(ctxt, internalName, getExpandInventory(internalName, allBricks, newBricks, settings))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("error removing %s due to %s\n", dir, err)
+		}
+	}()
+
+	formatArgs := "expand.yml -i inventory"
+	err = executeAnsiblePlaybook(ctxt, dir, formatArgs)
+	if err != nil {
+		return fmt.Errorf("error during ansible expand: %s", err.Error())
+	}
+	return nil
+}
+
+// Configure, or remove, the BeeGFS client on the given compute hosts
+func executeAnsibleClients(ctxt context.Context, internalName string, bricks []datamodel.Brick,
+	layout datamodel.FilesystemLayout, clientHosts []string, settings datamodel.FilesystemSettings,
+	playbook string) error {
+	if len(bricks) == 0 {
+		log.Panicf("can't mount filesystem with no bricks: %s", internalName)
+	}
+	dir, err := setupAnsibleWithInventory(ctxt, internalName,
+		getClientInventory(internalName, bricks, layout, clientHosts, settings))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("error removing %s due to %s\n", dir, err)
+		}
+	}()
+
+	args := fmt.Sprintf("%s -i inventory --limit %s", playbook, strings.Join(clientHosts, ","))
+	err = executeAnsiblePlaybook(ctxt, dir, args)
+	if err != nil {
+		return fmt.Errorf("error during ansible client setup: %s", err.Error())
+	}
+	return nil
+}
+
+func executeAnsiblePlaybook(ctxt context.Context, dir string, args string) error {
 	// TODO: downgrade debug log!
 	cmdStr := fmt.Sprintf(`cd %s; . .venv/bin/activate; ansible-playbook %s;`, dir, args)
-	log.Println("Requested ansible:", cmdStr)
+	logger := logging.FromContext(ctxt).WithField("command", cmdStr)
+	logger.Info("requested ansible")
 
 	if conf.SkipAnsible {
-		log.Println("Skip as DAC_SKIP_ANSIBLE=True")
+		logger.Info("skip ansible as DAC_SKIP_ANSIBLE=True")
 		time.Sleep(time.Millisecond * 200)
 		return nil
 	}
 
-	var err error
-	for i := 1; i <= 3; i++ {
-		log.Println("Attempt", i, "of ansible:", cmdStr)
-		cmd := exec.Command("bash", "-c", cmdStr)
+	playbook := getPlaybookLabel(args)
+	attempts := 0
+	return retry(ctxt, ansibleOperation, "localhost", func() error {
+		attempts += 1
+		logger.Infof("attempt %d of ansible", attempts)
+		ansibleAttempts.WithLabelValues(playbook).Inc()
+		if attempts > 1 {
+			ansibleRetries.WithLabelValues(playbook).Inc()
+		}
+		startTime := time.Now()
+		output, err := runAnsiblePlaybook(ctxt, cmdStr)
+		ansibleDuration.WithLabelValues(playbook, getResultLabel(err)).Observe(time.Since(startTime).Seconds())
 
-		timer := time.AfterFunc(time.Minute*10, func() {
-			log.Println("Time up, waited more than 10 mins to complete.")
-			if err := cmd.Process.Kill(); err != nil {
-				log.Panicf("error trying to kill process: %s", err.Error())
-			}
-		})
-		output, currentErr := cmd.CombinedOutput()
-		timer.Stop()
-
-		if currentErr == nil {
-			log.Println("Completed ansible run:", cmdStr)
-			log.Println(string(output))
-			return nil
-		} else {
-			log.Println("Error in ansible run:", string(output))
-			err = currentErr
-			time.Sleep(time.Second * 2)
+		if err != nil {
+			logger.WithFields(logging.Fields{"output": string(output), "error": err.Error()}).Error(
+				"error in ansible run")
+			return err
 		}
+		logger.WithField("output", string(output)).Info("completed ansible run")
+		return nil
+	})
+}
+
+// Longest any single ansible-playbook run can take
+const ansibleTimeout = time.Minute * 10
+
+// The process is killed if the action is cancelled, or it takes too long
+func runAnsiblePlaybook(ctxt context.Context, cmdStr string) ([]byte, error) {
+	ctxt, cancelFunc := context.WithTimeout(ctxt, ansibleTimeout)
+	defer cancelFunc()
+	return runProcessGroup(ctxt, "bash", "-c", cmdStr)
+}
+
+// Killing only bash would leave ansible-playbook running, holding the output open,
+// so the command gets its own process group, and the whole group is killed
+func runProcessGroup(ctxt context.Context, name string, args ...string) ([]byte, error) {
+	if err := ctxt.Err(); err != nil {
+		return nil, err
+	}
+	var output bytes.Buffer
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return output.Bytes(), err
+	case <-ctxt.Done():
 	}
-	return err
+	// the group has the same id as the process that started it
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	err := <-done
+	return output.Bytes(), fmt.Errorf("%s, killed due to: %s", err, ctxt.Err())
 }
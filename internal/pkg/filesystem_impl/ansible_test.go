@@ -1,12 +1,17 @@
 package filesystem_impl
 
 import (
+	"context"
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/stretchr/testify/assert"
+	"path"
 	"testing"
+	"time"
 )
 
+var testSettings = datamodel.FilesystemSettings{MGSDevice: "sdb", MaxMDTs: 24, MDTSizeMB: 20480}
+
 func TestPlugin_GetInventory(t *testing.T) {
 	brickAllocations := []datamodel.Brick{
 		{BrickHostName: "dac1", Device: "nvme1n1"},
@@ -16,17 +21,17 @@ func TestPlugin_GetInventory(t *testing.T) {
 		{BrickHostName: "dac2", Device: "nvme3n1"},
 	}
 	fsUuid := "abcdefgh"
-	result := getInventory(BeegFS, fsUuid, brickAllocations)
+	result := getInventory(BeegFS, fsUuid, brickAllocations, testSettings)
 	expected := `dacs:
   children:
     abcdefgh:
       hosts:
         dac1:
           mgs: nvme1n1
-          mdts: {nvme1n1: 0, nvme2n1: 1, nvme3n1: 2}
+          mdts: {nvme1n1: 0}
           osts: {nvme1n1: 0, nvme2n1: 1, nvme3n1: 2}
         dac2:
-          mdts: {nvme2n1: 3, nvme3n1: 4}
+          mdts: {nvme2n1: 3}
           osts: {nvme2n1: 3, nvme3n1: 4}
       vars:
         fs_name: abcdefgh
@@ -37,6 +42,119 @@ func TestPlugin_GetInventory(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
+func TestPlugin_GetClientInventory(t *testing.T) {
+	brickAllocations := []datamodel.Brick{
+		{BrickHostName: "dac1", Device: "nvme1n1"},
+		{BrickHostName: "dac2", Device: "nvme2n1"},
+	}
+	result := getClientInventory("abcdefgh", brickAllocations, getLayout(BeegFS, brickAllocations, testSettings),
+		[]string{"client1", "dac2"}, testSettings)
+	expected := `dacs:
+  children:
+    abcdefgh:
+      hosts:
+        client1: {}
+        dac1:
+          mgs: nvme1n1
+          mdts: {nvme1n1: 0}
+          osts: {nvme1n1: 0}
+        dac2:
+          mdts: {nvme2n1: 1}
+          osts: {nvme2n1: 1}
+      vars:
+        fs_name: abcdefgh
+        lnet_suffix: ""
+        mdt_size_mb: "20480"
+        mgsnode: dac1
+`
+	assert.Equal(t, expected, result)
+}
+
+func TestPlugin_GetPlaybookFile(t *testing.T) {
+	assert.Equal(t, "create.yml", getPlaybookFile(Lustre, "create.yml"))
+	assert.Equal(t, "beegfs-delete.yml", getPlaybookFile(BeegFS, "delete.yml"))
+	for _, playbook := range allPlaybooks {
+		assert.FileExists(t, path.Join("../../../fs-ansible", playbook))
+	}
+}
+
+func TestPlugin_GetExpandInventory(t *testing.T) {
+	allBricks := []datamodel.Brick{
+		{BrickHostName: "dac1", Device: "nvme1n1"},
+		{BrickHostName: "dac2", Device: "nvme2n1"},
+		{BrickHostName: "dac2", Device: "nvme3n1"},
+		{BrickHostName: "dac3", Device: "nvme1n1"},
+	}
+	result := getExpandInventory("abcdefgh", allBricks, allBricks[2:], testSettings)
+	expected := `dacs:
+  children:
+    abcdefgh:
+      hosts:
+        dac2:
+          osts: {nvme3n1: 2}
+        dac3:
+          osts: {nvme1n1: 3}
+      vars:
+        fs_name: abcdefgh
+        lnet_suffix: ""
+        mdt_size_mb: "20480"
+        mgsnode: dac1
+`
+	assert.Equal(t, expected, result)
+
+	assert.PanicsWithValue(t, "new brick not in the filesystem's bricks: {Device:sda BrickHostName:dac1 PoolName: CapacityGiB:0}", func() {
+		getExpandInventory("abcdefgh", allBricks, []datamodel.Brick{{BrickHostName: "dac1", Device: "sda"}},
+			testSettings)
+	})
+}
+
+func TestPlugin_GetInventory_Expanded(t *testing.T) {
+	settings := datamodel.FilesystemSettings{MGSDevice: "sdb", MaxMDTs: 2, MDTSizeMB: 20480}
+	session := datamodel.Session{
+		AllocatedBricks: []datamodel.Brick{
+			{BrickHostName: "dac1", Device: "nvme1n1"},
+			{BrickHostName: "dac1", Device: "nvme2n1"},
+		},
+	}
+	layout := getLayout(Lustre, session.AllocatedBricks, settings)
+	session.FilesystemStatus.Layout = &layout
+
+	// the third brick goes over MaxMDTs, and the fourth is still being added
+	session.AllocatedBricks = append(session.AllocatedBricks,
+		datamodel.Brick{BrickHostName: "dac1", Device: "nvme3n1"},
+		datamodel.Brick{BrickHostName: "dac2", Device: "nvme1n1"})
+	session.PendingExpandBricks = session.AllocatedBricks[3:]
+
+	result := getLayoutInventory(Lustre, "abcdefgh", getFormattedBricks(session),
+		getSessionLayout(Lustre, session, settings), settings)
+	expected := `dacs:
+  children:
+    abcdefgh:
+      hosts:
+        dac1:
+          mgs: sdb
+          mdts: {nvme1n1: 0, nvme2n1: 1}
+          osts: {nvme1n1: 0, nvme2n1: 1, nvme3n1: 2}
+      vars:
+        fs_name: abcdefgh
+        lnet_suffix: ""
+        mdt_size_mb: "20480"
+        mgsnode: dac1
+`
+	assert.Equal(t, expected, result)
+
+	// once the expand completes, teardown includes the new OST
+	session.PendingExpandBricks = nil
+	hosts, mgsnode := getHostInfos(Lustre, session.AllocatedBricks, layout, settings)
+	assert.Equal(t, "dac1", mgsnode)
+	assert.Equal(t, map[string]int{"nvme1n1": 0, "nvme2n1": 1}, hosts["dac1"].MDTS)
+	assert.Equal(t, HostInfo{MDTS: map[string]int{}, OSTS: map[string]int{"nvme1n1": 3}}, hosts["dac2"])
+
+	assert.PanicsWithValue(t, "brick not in the filesystem's layout: {Device:sda BrickHostName:dac1 PoolName: CapacityGiB:0}", func() {
+		getHostInfos(Lustre, []datamodel.Brick{{BrickHostName: "dac1", Device: "sda"}}, layout, settings)
+	})
+}
+
 func TestPlugin_GetInventory_withNoOstOnOneHost(t *testing.T) {
 	brickAllocations := []datamodel.Brick{
 		{BrickHostName: "dac1", Device: "nvme1n1"},
@@ -44,7 +162,7 @@ func TestPlugin_GetInventory_withNoOstOnOneHost(t *testing.T) {
 		{BrickHostName: "dac2", Device: "nvme3n1"},
 	}
 	fsUuid := "abcdefgh"
-	result := getInventory(Lustre, fsUuid, brickAllocations)
+	result := getInventory(Lustre, fsUuid, brickAllocations, testSettings)
 	expected := `dacs:
   children:
     abcdefgh:
@@ -107,7 +225,7 @@ func TestPlugin_GetInventory_MaxMDT(t *testing.T) {
 	}
 
 	fsUuid := "abcdefgh"
-	result := getInventory(Lustre, fsUuid, brickAllocations)
+	result := getInventory(Lustre, fsUuid, brickAllocations, testSettings)
 	expected := `dacs:
   children:
     abcdefgh:
@@ -160,3 +278,26 @@ func TestPlugin_GetInventory_MaxMDT(t *testing.T) {
 `
 	assert.Equal(t, expected, result)
 }
+
+func TestPlugin_ExecuteAnsiblePlaybook_Cancelled(t *testing.T) {
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+
+	err := executeAnsiblePlaybook(ctxt, "/does/not/exist", "create.yml -i inventory")
+
+	assert.Equal(t, "ansible attempt 1/3 on localhost failed: context canceled", err.Error())
+}
+
+func TestRunAnsiblePlaybook_Cancelled(t *testing.T) {
+	output, err := runAnsiblePlaybook(context.Background(), "echo hello")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(output))
+
+	// the sleep keeps the output open after bash is killed, so it must be killed too
+	ctxt, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancelFunc()
+	startTime := time.Now()
+	_, err = runAnsiblePlaybook(ctxt, "sleep 30; echo done")
+	assert.Equal(t, "signal: killed, killed due to: context deadline exceeded", err.Error())
+	assert.True(t, time.Since(startTime) < time.Second*10)
+}
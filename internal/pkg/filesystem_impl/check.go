@@ -0,0 +1,118 @@
+package filesystem_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"sort"
+)
+
+func isMounted(ctxt context.Context, hostname string, directory string) bool {
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("grep %s /etc/mtab", directory)) == nil
+}
+
+func sortedKeys(devices map[string]int) []string {
+	var keys []string
+	for key := range devices {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Directories where the filesystem targets are mounted, keyed by host
+func getTargetMountDirs(fsType FSType, fsname string, hosts map[string]HostInfo) map[string][]string {
+	dirs := make(map[string][]string)
+	for host, hostInfo := range hosts {
+		if fsType == BeegFS {
+			// Each disk is mounted once, whatever services use it
+			devices := make(map[string]int)
+			for device, index := range hostInfo.MDTS {
+				devices[device] = index
+			}
+			for device, index := range hostInfo.OSTS {
+				devices[device] = index
+			}
+			for _, device := range sortedKeys(devices) {
+				dirs[host] = append(dirs[host], fmt.Sprintf("/data/%s/%s", fsname, device))
+			}
+			continue
+		}
+		if hostInfo.MGS != "" {
+			dirs[host] = append(dirs[host], "/lustre/MGS")
+		}
+		for _, device := range sortedKeys(hostInfo.MDTS) {
+			dirs[host] = append(dirs[host], fmt.Sprintf("/lustre/%s/MDT/%s", fsname, device))
+		}
+		for _, device := range sortedKeys(hostInfo.OSTS) {
+			dirs[host] = append(dirs[host], fmt.Sprintf("/lustre/%s/OST/%s", fsname, device))
+		}
+	}
+	return dirs
+}
+
+// Attachments that should currently be mounted. Job attachments stay
+// recorded after unmount, so also check the session status
+func GetExpectedAttachments(session datamodel.Session) []datamodel.SessionName {
+	isMounted := session.Status.MountComplete && !session.Status.UnmountComplete
+	var keys []datamodel.SessionName
+	for key := range session.CurrentAttachments {
+		if key == session.Name && !session.VolumeRequest.MultiJob && !isMounted {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func checkMounts(ctxt context.Context, fsType FSType, session datamodel.Session, settings datamodel.FilesystemSettings) error {
+	var missing []string
+	missingHosts := make(map[datamodel.SessionName][]string)
+	if len(session.AllocatedBricks) > 0 {
+		hosts, _ := getHostInfos(fsType, getFormattedBricks(session), getSessionLayout(fsType, session, settings),
+			settings)
+		targetDirs := getTargetMountDirs(fsType, session.FilesystemStatus.InternalName, hosts)
+		var hostnames []string
+		for host := range targetDirs {
+			hostnames = append(hostnames, host)
+		}
+		sort.Strings(hostnames)
+		for _, host := range hostnames {
+			for _, dir := range targetDirs[host] {
+				if !isMounted(ctxt, host, dir) {
+					missing = append(missing, fmt.Sprintf("%s:%s", host, dir))
+				}
+			}
+		}
+	}
+
+	for _, key := range GetExpectedAttachments(session) {
+		attachment := session.CurrentAttachments[key]
+		mountDir := getMountDir(session.Name, session.VolumeRequest.MultiJob, attachment.SessionName)
+		if fsType == BeegFS {
+			// the mount dir is a link to the client mount
+			mountDir = getBeegFSClientDir(session.FilesystemStatus.InternalName)
+		}
+		// Jobs can have many hosts, so check them in parallel
+		err := forEachHost(ctxt, attachment.Hosts, func(hostname string) error {
+			if !isMounted(ctxt, hostname, mountDir) {
+				return fmt.Errorf("not mounted")
+			}
+			return nil
+		})
+		failedHosts, _ := err.(filesystem.HostErrors)
+		for _, host := range attachment.Hosts {
+			if _, ok := failedHosts[host]; ok {
+				missing = append(missing, fmt.Sprintf("%s:%s", host, mountDir))
+				missingHosts[key] = append(missingHosts[key], host)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return filesystem.MissingMountsError{Missing: missing, Hosts: missingHosts}
+	}
+	return nil
+}
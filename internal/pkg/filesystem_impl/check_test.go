@@ -0,0 +1,127 @@
+package filesystem_impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type fakeMountRunner struct {
+	unmounted map[string]bool
+}
+
+func (f *fakeMountRunner) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
+	var dir string
+	if _, err := fmt.Sscanf(cmdStr, "grep %s /etc/mtab", &dir); err != nil {
+		return err
+	}
+	if f.unmounted[hostname+":"+dir] {
+		return errors.New("not mounted")
+	}
+	return nil
+}
+
+func (f *fakeMountRunner) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	return "", f.Execute(ctxt, hostname, asRoot, cmdStr)
+}
+
+func Test_checkMounts(t *testing.T) {
+	defer func() { runner = &run{} }()
+	session := datamodel.Session{
+		Name: "job1",
+		AllocatedBricks: []datamodel.Brick{
+			{BrickHostName: "dac1", Device: "nvme1n1"}, {BrickHostName: "dac2", Device: "nvme1n1"},
+		},
+		FilesystemStatus: datamodel.FilesystemStatus{InternalName: "fsuuid"},
+		CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{
+			"Primary_job1": {SessionName: "job1", Hosts: []string{"dac1"}},
+			"job1":         {SessionName: "job1", Hosts: []string{"client1"}},
+		},
+	}
+
+	runner = &fakeMountRunner{}
+	assert.Nil(t, checkMounts(context.TODO(), Lustre, session, testSettings))
+
+	runner = &fakeMountRunner{unmounted: map[string]bool{
+		"dac2:/lustre/fsuuid/OST/nvme1n1": true,
+		"client1:/mnt/dac/job1_job":       true,
+	}}
+	err := checkMounts(context.TODO(), Lustre, session, testSettings)
+	assert.Equal(t, "expected mounts missing: dac2:/lustre/fsuuid/OST/nvme1n1", err.Error())
+
+	session.Status.MountComplete = true
+	err = checkMounts(context.TODO(), Lustre, session, testSettings)
+	assert.Equal(t, "expected mounts missing: dac2:/lustre/fsuuid/OST/nvme1n1, client1:/mnt/dac/job1_job",
+		err.Error())
+	// the hosts missing each attachment are known, so only those are mounted again
+	assert.Equal(t, map[datamodel.SessionName][]string{"job1": {"client1"}},
+		err.(filesystem.MissingMountsError).Hosts)
+}
+
+func Test_checkMounts_expanded(t *testing.T) {
+	defer func() { runner = &run{} }()
+	settings := datamodel.FilesystemSettings{MGSDevice: "sdb", MaxMDTs: 2}
+	bricks := []datamodel.Brick{
+		{BrickHostName: "dac1", Device: "nvme1n1"}, {BrickHostName: "dac1", Device: "nvme2n1"},
+		{BrickHostName: "dac2", Device: "nvme1n1"}, {BrickHostName: "dac2", Device: "nvme2n1"},
+	}
+	layout := getLayout(Lustre, bricks[:2], settings)
+	session := datamodel.Session{
+		Name:                "job1",
+		AllocatedBricks:     bricks,
+		PendingExpandBricks: bricks[3:],
+		FilesystemStatus:    datamodel.FilesystemStatus{InternalName: "fsuuid", Layout: &layout},
+	}
+
+	// The expanded brick only has an OST, and the pending brick is not checked,
+	// even though there are now more bricks than MaxMDTs
+	runner = &fakeMountRunner{unmounted: map[string]bool{
+		"dac1:/lustre/fsuuid/MDT/nvme2n1": true,
+		"dac2:/lustre/fsuuid/MDT/nvme1n1": true,
+		"dac2:/lustre/fsuuid/OST/nvme1n1": true,
+		"dac2:/lustre/fsuuid/OST/nvme2n1": true,
+	}}
+	err := checkMounts(context.TODO(), Lustre, session, settings)
+	assert.Equal(t, "expected mounts missing: dac1:/lustre/fsuuid/MDT/nvme2n1, dac2:/lustre/fsuuid/OST/nvme1n1",
+		err.Error())
+}
+
+func Test_getTargetMountDirs(t *testing.T) {
+	hosts := map[string]HostInfo{
+		"dac1": {MGS: "sdb", MDTS: map[string]int{"nvme1n1": 0}, OSTS: map[string]int{"nvme2n1": 1, "nvme1n1": 0}},
+		"dac2": {OSTS: map[string]int{"nvme1n1": 2}},
+	}
+	assert.Equal(t, map[string][]string{
+		"dac1": {"/lustre/MGS", "/lustre/fs/MDT/nvme1n1", "/lustre/fs/OST/nvme1n1", "/lustre/fs/OST/nvme2n1"},
+		"dac2": {"/lustre/fs/OST/nvme1n1"},
+	}, getTargetMountDirs(Lustre, "fs", hosts))
+
+	assert.Equal(t, map[string][]string{
+		"dac1": {"/data/fs/nvme1n1", "/data/fs/nvme2n1"},
+		"dac2": {"/data/fs/nvme1n1"},
+	}, getTargetMountDirs(BeegFS, "fs", hosts))
+}
+
+func Test_checkMounts_beegfs(t *testing.T) {
+	defer func() { runner = &run{} }()
+	session := datamodel.Session{
+		Name:             "job1",
+		AllocatedBricks:  []datamodel.Brick{{BrickHostName: "dac1", Device: "nvme1n1"}},
+		FilesystemStatus: datamodel.FilesystemStatus{InternalName: "fsuuid"},
+		Status:           datamodel.SessionStatus{MountComplete: true},
+		CurrentAttachments: map[datamodel.SessionName]datamodel.AttachmentSession{
+			"job1": {SessionName: "job1", Hosts: []string{"client1"}},
+		},
+	}
+
+	runner = &fakeMountRunner{unmounted: map[string]bool{
+		"dac1:/data/fsuuid/nvme1n1":  true,
+		"client1:/mnt/beegfs/fsuuid": true,
+	}}
+	err := checkMounts(context.TODO(), BeegFS, session, testSettings)
+	assert.Equal(t, "expected mounts missing: dac1:/data/fsuuid/nvme1n1, client1:/mnt/beegfs/fsuuid", err.Error())
+}
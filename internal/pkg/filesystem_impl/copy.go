@@ -1,25 +1,29 @@
 package filesystem_impl
 
 import (
+	"context"
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"log"
 	"strings"
 )
 
-func processDataCopy(session datamodel.Session, request datamodel.DataCopyRequest) error {
+func processDataCopy(ctxt context.Context, session datamodel.Session, request datamodel.DataCopyRequest) error {
 	cmd, err := generateDataCopyCmd(session, request)
 	if err != nil {
 		return err
 	}
 	if cmd == "" {
-		log.Println("No files to copy for:", session.Name)
+		logging.FromContext(ctxt).Info("no files to copy")
 		return nil
 	}
 
-	log.Printf("Doing copy: %s", cmd)
-	return runner.Execute("localhost", false, cmd)
+	logging.FromContext(ctxt).WithField("command", cmd).Info("doing copy")
+	return retry(ctxt, copyOperation, "localhost", func() error {
+		return runner.Execute(ctxt, "localhost", false, cmd)
+	})
 }
 
 func generateDataCopyCmd(session datamodel.Session, request datamodel.DataCopyRequest) (string, error) {
@@ -3,6 +3,7 @@ package filesystem_impl
 import (
 	"bytes"
 	"encoding/json"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 )
 
 type FSType int
@@ -42,3 +43,11 @@ func (fsType *FSType) UnmarshalJSON(b []byte) error {
 	*fsType = stringToFSType[str]
 	return nil
 }
+
+// Filesystems created before the type was recorded are all Lustre
+func getFSType(settings datamodel.FilesystemSettings) FSType {
+	if settings.Type == datamodel.BeeGFSFilesystem {
+		return BeegFS
+	}
+	return Lustre
+}
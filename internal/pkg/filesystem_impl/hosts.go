@@ -0,0 +1,54 @@
+package filesystem_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"sync"
+)
+
+// Runs the action on every host, at most DAC_SSH_PARALLEL_HOSTS at once.
+// Every host is tried even if some fail, unless the context is done,
+// any failures are returned as filesystem.HostErrors
+func forEachHost(ctxt context.Context, hostnames []string, action func(hostname string) error) error {
+	limit := int(conf.SSHParallelHosts)
+	if limit < 1 {
+		limit = 1
+	}
+	slots := make(chan struct{}, limit)
+	errs := make(filesystem.HostErrors)
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, hostname := range hostnames {
+		started := false
+		if ctxt.Err() == nil {
+			select {
+			case slots <- struct{}{}:
+				started = true
+			case <-ctxt.Done():
+			}
+		}
+		if !started {
+			// Hosts not yet started are not tried
+			mutex.Lock()
+			errs[hostname] = fmt.Errorf("not started due to: %s", ctxt.Err())
+			mutex.Unlock()
+			continue
+		}
+		wg.Add(1)
+		go func(hostname string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			if err := action(hostname); err != nil {
+				mutex.Lock()
+				errs[hostname] = err
+				mutex.Unlock()
+			}
+		}(hostname)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package filesystem_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_forEachHost(t *testing.T) {
+	defer func(limit uint) { conf.SSHParallelHosts = limit }(conf.SSHParallelHosts)
+	conf.SSHParallelHosts = 2
+
+	var running, maxRunning int32
+	err := forEachHost(context.TODO(), []string{"host1", "host2", "host3", "host4"}, func(hostname string) error {
+		now := atomic.AddInt32(&running, 1)
+		for {
+			last := atomic.LoadInt32(&maxRunning)
+			if now <= last || atomic.CompareAndSwapInt32(&maxRunning, last, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond * 10)
+		atomic.AddInt32(&running, -1)
+		if hostname == "host3" || hostname == "host1" {
+			return errors.New("fake")
+		}
+		return nil
+	})
+
+	assert.Equal(t, int32(2), maxRunning)
+	assert.Equal(t, "failed on 2 hosts: host1: fake; host3: fake", err.Error())
+	assert.Equal(t, 2, len(err.(filesystem.HostErrors)))
+
+	assert.Nil(t, forEachHost(context.TODO(), []string{"host1"}, func(string) error { return nil }))
+	assert.Nil(t, forEachHost(context.TODO(), nil, func(string) error { return errors.New("never called") }))
+}
+
+func Test_forEachHost_Cancelled(t *testing.T) {
+	ctxt, cancel := context.WithCancel(context.TODO())
+	cancel()
+	err := forEachHost(ctxt, []string{"host1", "host2"}, func(string) error {
+		return errors.New("never called")
+	})
+	assert.Equal(t, "failed on 2 hosts: host1: not started due to: context canceled; "+
+		"host2: not started due to: context canceled", err.Error())
+}
@@ -0,0 +1,58 @@
+package filesystem_impl
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strings"
+)
+
+var (
+	ansibleDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "dac_ansible_playbook_duration_seconds",
+		Help: "Time taken by each ansible-playbook attempt, by playbook and result.",
+		// 1 second up to around an hour, as formatting can take tens of minutes
+		Buckets: prometheus.ExponentialBuckets(1, 2, 13),
+	}, []string{"playbook", "result"})
+
+	ansibleAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dac_ansible_playbook_attempts_total",
+		Help: "Number of ansible-playbook attempts, including retries, by playbook.",
+	}, []string{"playbook"})
+
+	ansibleRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "dac_ansible_playbook_retries_total",
+		Help: "Number of times an ansible-playbook run was retried after failing, by playbook.",
+	}, []string{"playbook"})
+
+	sshDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "dac_ssh_command_duration_seconds",
+		Help:    "Time taken by remote ssh commands, by command and result.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 13),
+	}, []string{"command", "result"})
+)
+
+func getResultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// Find the playbook in the ansible-playbook arguments, e.g. create.yml
+func getPlaybookLabel(args string) string {
+	for _, arg := range strings.Fields(args) {
+		if strings.HasSuffix(arg, ".yml") {
+			return arg
+		}
+	}
+	return "unknown"
+}
+
+// Use just the command name, e.g. mount or rsync, to keep the number of labels small
+func getCommandLabel(cmdStr string) string {
+	fields := strings.Fields(cmdStr)
+	if len(fields) == 0 {
+		return "unknown"
+	}
+	return fields[0]
+}
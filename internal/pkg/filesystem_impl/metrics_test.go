@@ -0,0 +1,22 @@
+package filesystem_impl
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetPlaybookLabel(t *testing.T) {
+	assert.Equal(t, "create.yml", getPlaybookLabel("-i inventory --tag format create.yml"))
+	assert.Equal(t, "unknown", getPlaybookLabel("-i inventory"))
+}
+
+func TestGetCommandLabel(t *testing.T) {
+	assert.Equal(t, "mount", getCommandLabel("mount -t lustre host:/fs /mnt/fs"))
+	assert.Equal(t, "unknown", getCommandLabel(" "))
+}
+
+func TestGetResultLabel(t *testing.T) {
+	assert.Equal(t, "success", getResultLabel(nil))
+	assert.Equal(t, "failure", getResultLabel(errors.New("fake")))
+}
@@ -1,11 +1,15 @@
 package filesystem_impl
 
 import (
+	"context"
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"log"
 	"os/exec"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -17,215 +21,327 @@ func getMountDir(sourceName datamodel.SessionName, isMultiJob bool, attachingFor
 	return fmt.Sprintf(datamodel.MountJobBasePattern, sourceName)
 }
 
-func mount(fsType FSType, sessionName datamodel.SessionName, isMultiJob bool, internalName string,
-	primaryBrickHost datamodel.BrickHostName, attachment datamodel.AttachmentSession,
+// Each job attaching a persistent buffer gets its own link, to the same per host directory
+func getPrivateSymLinkDir(sourceName datamodel.SessionName, isMultiJob bool,
+	attachingForSession datamodel.SessionName) string {
+	if isMultiJob {
+		return fmt.Sprintf(datamodel.MountMultiJobPrivatePattern, attachingForSession, sourceName)
+	}
+	return fmt.Sprintf(datamodel.MountPrivatePattern, sourceName)
+}
+
+// Hosts are mounted in parallel. Any host that fails is left unmounted,
+// and the hosts that failed are returned as filesystem.HostErrors
+func mount(ctxt context.Context, fsType FSType, sessionName datamodel.SessionName, isMultiJob bool, internalName string,
+	primaryBrickHost datamodel.BrickHostName, lnetSuffix string, attachment datamodel.AttachmentSession,
 	owner uint, group uint, setInitialPermissions bool) error {
-	log.Println("Mount for:", sessionName)
+	logging.FromContext(ctxt).Infof("mount for: %s", sessionName)
 
 	if primaryBrickHost == "" {
 		log.Panicf("failed to find primary brick for volume: %s", sessionName)
 	}
 	if len(attachment.Hosts) < 1 {
-		log.Println("Skip mount as no hosts given")
+		logging.FromContext(ctxt).Info("skip mount as no hosts given")
 		return nil
 	}
-	if fsType == BeegFS {
-		// Write out the config needed, and do the mount using ansible
-		// TODO: Move Lustre mount here that is done below
-		//executeAnsibleMount(fsType, volume, brickAllocations)
-	}
 	var mountDir = getMountDir(sessionName, isMultiJob, attachment.SessionName)
 
-	for _, attachHost := range attachment.Hosts {
-		log.Printf("Mounting %s on host: %s for session: %s", sessionName, attachHost,
+	return forEachHost(ctxt, attachment.Hosts, func(attachHost string) error {
+		logging.FromContext(ctxt).Infof("mounting %s on host: %s for session: %s", sessionName, attachHost,
 			attachment.SessionName)
 
-		if err := mkdir(attachHost, mountDir); err != nil {
+		err := retry(ctxt, mountOperation, attachHost, func() error {
+			if err := mkdir(ctxt, attachHost, mountDir); err != nil {
+				return err
+			}
+			return mountRemoteFilesystem(ctxt, fsType, attachHost, lnetSuffix,
+				string(primaryBrickHost), internalName, mountDir)
+		})
+		if err != nil {
 			return err
 		}
-		if err := mountRemoteFilesystem(fsType, attachHost, conf.LnetSuffix,
-			string(primaryBrickHost), internalName, mountDir); err != nil {
-			return err
+
+		// Every host sees the same global directory, so only the first host creates it
+		createGlobalDir := setInitialPermissions && attachHost == attachment.Hosts[0]
+		err = setupHost(ctxt, sessionName, isMultiJob, mountDir, attachment, attachHost, owner, group, createGlobalDir)
+		if err != nil {
+			return rollbackHost(ctxt, fsType, sessionName, isMultiJob, attachment, attachHost, err)
 		}
-	}
+		return nil
+	})
+}
 
-	// Create global and private directories, with correct permissions
-	if setInitialPermissions {
-		// use first mounted host
-		attachHost := attachment.Hosts[0]
+// Don't leave a host that failed half mounted. The unmount still runs if the action was
+// cancelled or timed out, and if it fails the host is reported as still mounted
+func rollbackHost(ctxt context.Context, fsType FSType, sessionName datamodel.SessionName, isMultiJob bool,
+	attachment datamodel.AttachmentSession, attachHost string, mountErr error) error {
+	rollbackCtxt := filesystem.WithActionInfo(context.Background(), filesystem.GetActionInfo(ctxt))
+	unmountErr := unmountHost(rollbackCtxt, fsType, sessionName, isMultiJob, attachment, attachHost)
+	if unmountErr != nil {
+		logging.FromContext(ctxt).Errorf("unable to unmount %s after failed mount due to: %s",
+			attachHost, unmountErr)
+		return filesystem.StillMountedError{MountErr: mountErr, UnmountErr: unmountErr}
+	}
+	return mountErr
+}
 
+// Create global and private directories, with correct permissions, and any swap
+func setupHost(ctxt context.Context, sessionName datamodel.SessionName, isMultiJob bool, mountDir string,
+	attachment datamodel.AttachmentSession, attachHost string, owner uint, group uint, createGlobalDir bool) error {
+	if createGlobalDir {
 		// make a directory users can write into
 		sharedDir := path.Join(mountDir, fmt.Sprintf("/%s", datamodel.MountGlobalDir))
 		// TODO: would install be better here?
-		if err := mkdir(attachHost, sharedDir); err != nil {
+		if err := mkdir(ctxt, attachHost, sharedDir); err != nil {
 			return err
 		}
-		if err := fixUpOwnership(attachHost, owner, group, sharedDir); err != nil {
+		if err := fixUpOwnership(ctxt, attachHost, owner, group, sharedDir); err != nil {
 			return err
 		}
 	}
 
 	// add sym link to a private directory as needed
 	if attachment.PrivateMount {
-		for _, attachHost := range attachment.Hosts {
-			privateDir := path.Join(mountDir, fmt.Sprintf("/private/%s", attachHost))
-			if err := mkdir(attachHost, privateDir); err != nil {
-				return err
-			}
-			if err := fixUpOwnership(attachHost, owner, group, privateDir); err != nil {
-				return err
-			}
-			// need a consistent symlink for shared environment variables across all hosts
-			privateSymLinkDir := fmt.Sprintf(datamodel.MountPrivatePattern, sessionName)
-			if err := createSymbolicLink(attachHost, privateDir, privateSymLinkDir); err != nil {
-				return err
-			}
+		privateDir := path.Join(mountDir, fmt.Sprintf("/private/%s", attachHost))
+		if err := mkdir(ctxt, attachHost, privateDir); err != nil {
+			return err
+		}
+		if err := fixUpOwnership(ctxt, attachHost, owner, group, privateDir); err != nil {
+			return err
+		}
+		// need a consistent symlink for shared environment variables across all hosts
+		privateSymLinkDir := getPrivateSymLinkDir(sessionName, isMultiJob, attachment.SessionName)
+		if err := createSymbolicLink(ctxt, attachHost, privateDir, privateSymLinkDir); err != nil {
+			return err
 		}
 	}
 
-	// TODO: swap!
-	//if !volume.MultiJob && volume.AttachAsSwapBytes > 0 {
-	//	swapDir := path.Join(mountDir, "/swap")
-	//	if err := mkdir(attachment.Hostname, swapDir); err != nil {
-	//		return err
-	//	}
-	//	if err := fixUpOwnership(attachment.Hostname, 0, 0, swapDir); err != nil {
-	//		return err
-	//	}
-	//	swapSizeMB := int(volume.AttachAsSwapBytes / (1024 * 1024))
-	//	swapFile := path.Join(swapDir, fmt.Sprintf("/%s", attachment.Hostname))
-	//	loopback := fmt.Sprintf("/dev/loop%d", volume.ClientPort)
-	//	if err := createSwap(attachment.Hostname, swapSizeMB, swapFile, loopback); err != nil {
-	//		return err
-	//	}
-	//	if err := swapOn(attachment.Hostname, loopback); err != nil {
-	//		return err
-	//	}
-	//}
-	// TODO on error should we always call umount? maybe?
-	// TODO move to ansible style automation or preamble?
+	if !isMultiJob && attachment.SwapBytes > 0 {
+		return setupSwap(ctxt, attachHost, mountDir, attachment.SwapBytes)
+	}
 	return nil
 }
 
-func unmount(fsType FSType, sessionName datamodel.SessionName, isMultiJob bool, internalName string,
+// Hosts are unmounted in parallel, any that fail are returned as filesystem.HostErrors
+func unmount(ctxt context.Context, fsType FSType, sessionName datamodel.SessionName, isMultiJob bool, internalName string,
 	primaryBrickHost datamodel.BrickHostName, attachment datamodel.AttachmentSession) error {
-	log.Println("Umount for:", sessionName)
+	logging.FromContext(ctxt).Infof("umount for: %s", sessionName)
 
-	for _, attachHost := range attachment.Hosts {
-		log.Printf("Unmounting %s on host: %s for session: %s", sessionName, attachHost,
-			attachment.SessionName)
+	return forEachHost(ctxt, attachment.Hosts, func(attachHost string) error {
+		return unmountHost(ctxt, fsType, sessionName, isMultiJob, attachment, attachHost)
+	})
+}
+
+func unmountHost(ctxt context.Context, fsType FSType, sessionName datamodel.SessionName, isMultiJob bool,
+	attachment datamodel.AttachmentSession, attachHost string) error {
+	logging.FromContext(ctxt).Infof("unmounting %s on host: %s for session: %s", sessionName, attachHost,
+		attachment.SessionName)
 
-		var mountDir = getMountDir(sessionName, isMultiJob, attachment.SessionName)
-		// TODO: swap!
-		//if !volume.MultiJob && volume.AttachAsSwapBytes > 0 {
-		//	swapFile := path.Join(mountDir, fmt.Sprintf("/swap/%s", attachment.Hostname)) // TODO share?
-		//	loopback := fmt.Sprintf("/dev/loop%d", volume.ClientPort)                     // TODO share?
-		//	if err := swapOff(attachment.Hostname, loopback); err != nil {
-		//		log.Printf("Warn: failed to swap off %+v", attachment)
-		//	}
-		//	if err := detachLoopback(attachment.Hostname, loopback); err != nil {
-		//		log.Printf("Warn: failed to detach loopback %+v", attachment)
-		//	}
-		//	if err := removeSubtree(attachment.Hostname, swapFile); err != nil {
-		//		return err
-		//	}
-		//}
+	var mountDir = getMountDir(sessionName, isMultiJob, attachment.SessionName)
+	return retry(ctxt, unmountOperation, attachHost, func() error {
+		if !isMultiJob && attachment.SwapBytes > 0 {
+			if err := removeSwap(ctxt, attachHost, getSwapFile(mountDir, attachHost)); err != nil {
+				return err
+			}
+		}
 		if attachment.PrivateMount {
-			privateSymLinkDir := fmt.Sprintf(datamodel.MountPrivatePattern, sessionName)
-			if err := removeSubtree(attachHost, privateSymLinkDir); err != nil {
+			privateSymLinkDir := getPrivateSymLinkDir(sessionName, isMultiJob, attachment.SessionName)
+			if err := removeSubtree(ctxt, attachHost, privateSymLinkDir); err != nil {
 				return err
 			}
 		}
 
 		if fsType == Lustre {
-			if err := umountLustre(attachHost, mountDir); err != nil {
-				return err
-			}
-			if err := removeSubtree(attachHost, mountDir); err != nil {
+			if err := umountLustre(ctxt, attachHost, mountDir); err != nil {
 				return err
 			}
 		}
-	}
+		// For BeeGFS this removes the link to the client mount, which is removed by ansible
+		return removeSubtree(ctxt, attachHost, mountDir)
+	})
+}
+
+// Creating a large swap file can take a while, the action's deadline still applies
+const swapTimeout = time.Minute * 30
 
-	if fsType == BeegFS {
-		// TODO: Move Lustre unmount here that is done below
-		// executeAnsibleUnmount(fsType, volume, brickAllocations)
-		// TODO: this makes copy out much harder in its current form :(
+func getSwapFile(mountDir string, hostname string) string {
+	return path.Join(mountDir, "swap", hostname)
+}
+
+// Rounded up, so a swap request never gets less than asked for
+func getSwapMB(swapBytes int) int {
+	return (swapBytes + 1024*1024 - 1) / (1024 * 1024)
+}
+
+// Each host gets its own swap file in the buffer
+func setupSwap(ctxt context.Context, hostname string, mountDir string, swapBytes int) error {
+	swapDir := path.Join(mountDir, "swap")
+	if err := mkdir(ctxt, hostname, swapDir); err != nil {
+		return err
+	}
+	if err := fixUpOwnership(ctxt, hostname, 0, 0, swapDir); err != nil {
+		return err
+	}
+	if err := createSwap(ctxt, hostname, getSwapMB(swapBytes), getSwapFile(mountDir, hostname)); err != nil {
+		return fmt.Errorf("unable to create swap on %s due to: %s", hostname, err)
 	}
 	return nil
 }
 
-func createSwap(hostname string, swapMB int, filename string, loopback string) error {
+// losetup picks a free loop device and attaches the file in one step, so two swap files
+// can never get the same device. The device is detached again if it can't be used for swap.
+// Mount can run again on a host that is already mounted, so swap that is in use is left alone
+func createSwap(ctxt context.Context, hostname string, swapMB int, filename string) error {
+	if conf.SkipAnsible {
+		return nil
+	}
+	loopbacks, activeSwap, err := getSwapLoopbacks(ctxt, hostname, filename)
+	if err != nil {
+		return err
+	}
+	for _, loopback := range loopbacks {
+		if activeSwap[loopback] {
+			logging.FromContext(ctxt).Infof("skip swap setup on %s, %s is already in use", hostname, filename)
+			return nil
+		}
+	}
+	// left behind by an earlier attempt, so never used for swap
+	for _, loopback := range loopbacks {
+		if err := detachLoopback(ctxt, hostname, loopback); err != nil {
+			return err
+		}
+	}
+
 	file := fmt.Sprintf("dd if=/dev/zero of=%s bs=1024 count=%d", filename, swapMB*1024)
-	if err := runner.Execute(hostname, true, file); err != nil {
+	if err := runner.Execute(withCommandTimeout(ctxt, swapTimeout), hostname, true, file); err != nil {
+		return err
+	}
+	if err := runner.Execute(ctxt, hostname, true, fmt.Sprintf("chmod 0600 %s", filename)); err != nil {
 		return err
 	}
-	if err := runner.Execute(hostname, true, fmt.Sprintf("chmod 0600 %s", filename)); err != nil {
+	output, err := runner.Output(ctxt, hostname, true, fmt.Sprintf("losetup --find --show %s", filename))
+	if err != nil {
 		return err
 	}
-	device := fmt.Sprintf("losetup %s %s", loopback, filename)
-	if err := runner.Execute(hostname, true, device); err != nil {
+	loopback := strings.TrimSpace(output)
+	if !strings.HasPrefix(loopback, "/dev/loop") {
+		return fmt.Errorf("unexpected loop device from losetup: %s", loopback)
+	}
+
+	err = runner.Execute(ctxt, hostname, true, fmt.Sprintf("mkswap %s", loopback))
+	if err == nil {
+		err = runner.Execute(ctxt, hostname, true, fmt.Sprintf("swapon %s", loopback))
+	}
+	if err != nil {
+		if detachErr := detachLoopback(context.Background(), hostname, loopback); detachErr != nil {
+			logging.FromContext(ctxt).Errorf("unable to detach %s on %s due to: %s", loopback, hostname, detachErr)
+		}
 		return err
 	}
-	swap := fmt.Sprintf("mkswap %s", loopback)
-	return runner.Execute(hostname, true, swap)
+	return nil
 }
 
-func swapOn(hostname string, loopback string) error {
-	return runner.Execute(hostname, true, fmt.Sprintf("swapon %s", loopback))
+func getLines(output string) []string {
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
 }
 
-func swapOff(hostname string, loopback string) error {
-	return runner.Execute(hostname, true, fmt.Sprintf("swapoff %s", loopback))
+// The loop devices attached to the swap file, and which devices are in use as swap
+func getSwapLoopbacks(ctxt context.Context, hostname string, filename string) ([]string, map[string]bool, error) {
+	output, err := runner.Output(ctxt, hostname, false,
+		fmt.Sprintf("losetup --list --noheadings --output NAME --associated %s", filename))
+	if err != nil {
+		return nil, nil, err
+	}
+	loopbacks := getLines(output)
+	activeSwap := make(map[string]bool)
+	if len(loopbacks) > 0 {
+		output, err := runner.Output(ctxt, hostname, false, "swapon --show=NAME --noheadings")
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, device := range getLines(output) {
+			activeSwap[device] = true
+		}
+	}
+	return loopbacks, activeSwap, nil
 }
 
-func detachLoopback(hostname string, loopback string) error {
-	return runner.Execute(hostname, true, fmt.Sprintf("losetup -d %s", loopback))
+// Looks up the loop devices using the file, so this works after a restart, and is safe to repeat
+func removeSwap(ctxt context.Context, hostname string, filename string) error {
+	loopbacks, activeSwap, err := getSwapLoopbacks(ctxt, hostname, filename)
+	if err != nil {
+		return err
+	}
+	for _, loopback := range loopbacks {
+		if activeSwap[loopback] {
+			if err := swapOff(ctxt, hostname, loopback); err != nil {
+				return err
+			}
+		}
+		if err := detachLoopback(ctxt, hostname, loopback); err != nil {
+			return err
+		}
+	}
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("rm -f %s", filename))
+}
+
+func swapOff(ctxt context.Context, hostname string, loopback string) error {
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("swapoff %s", loopback))
 }
 
-func fixUpOwnership(hostname string, owner uint, group uint, directory string) error {
-	if err := runner.Execute(hostname, true, fmt.Sprintf("chown %d:%d %s", owner, group, directory)); err != nil {
+func detachLoopback(ctxt context.Context, hostname string, loopback string) error {
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("losetup --detach %s", loopback))
+}
+
+func fixUpOwnership(ctxt context.Context, hostname string, owner uint, group uint, directory string) error {
+	if err := runner.Execute(ctxt, hostname, true, fmt.Sprintf("chown %d:%d %s", owner, group, directory)); err != nil {
 		return err
 	}
-	return runner.Execute(hostname, true, fmt.Sprintf("chmod 700 %s", directory))
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("chmod 700 %s", directory))
 }
 
-func umountLustre(hostname string, directory string) error {
+func umountLustre(ctxt context.Context, hostname string, directory string) error {
 	// only unmount if already mounted
-	if err := runner.Execute(hostname, true, fmt.Sprintf("grep %s /etc/mtab", directory)); err == nil {
+	if isMounted(ctxt, hostname, directory) {
 		// Don't add -l so we can spot when this fails
-		if err := runner.Execute(hostname, true, fmt.Sprintf("umount %s", directory)); err != nil {
+		if err := runner.Execute(ctxt, hostname, true, fmt.Sprintf("umount %s", directory)); err != nil {
 			return err
 		}
 	} else {
 		// TODO: we should really just avoid this being possible?
-		log.Println("skip umount, as not currently mounted.")
+		logging.FromContext(ctxt).Infof("skip umount of %s on %s, as not currently mounted", directory, hostname)
 	}
 	return nil
 }
 
-func removeSubtree(hostname string, directory string) error {
-	return runner.Execute(hostname, true, fmt.Sprintf("rm -df %s", directory))
+func removeSubtree(ctxt context.Context, hostname string, directory string) error {
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("rm -df %s", directory))
 }
 
-func createSymbolicLink(hostname string, src string, dest string) error {
-	return runner.Execute(hostname, true, fmt.Sprintf("ln -s %s %s", src, dest))
+func createSymbolicLink(ctxt context.Context, hostname string, src string, dest string) error {
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("ln -sfn %s %s", src, dest))
 }
 
-func mountRemoteFilesystem(fsType FSType, hostname string, lnetSuffix string, mgtHost string, fsname string, directory string) error {
+func mountRemoteFilesystem(ctxt context.Context, fsType FSType, hostname string, lnetSuffix string, mgtHost string, fsname string, directory string) error {
 	if fsType == Lustre {
-		return mountLustre(hostname, lnetSuffix, mgtHost, fsname, directory)
+		return mountLustre(ctxt, hostname, lnetSuffix, mgtHost, fsname, directory)
 	} else if fsType == BeegFS {
-		return mountBeegFS(hostname, mgtHost, fsname, directory)
+		return mountBeegFS(ctxt, hostname, mgtHost, fsname, directory)
 	}
 	return fmt.Errorf("mount unsuported by filesystem type %s", fsType)
 }
 
-func mountLustre(hostname string, lnetSuffix string, mgtHost string, fsname string, directory string) error {
+func mountLustre(ctxt context.Context, hostname string, lnetSuffix string, mgtHost string, fsname string, directory string) error {
 	// We assume modprobe -v lustre is already done
 	// First check if we are mounted already
-	if err := runner.Execute(hostname, true, fmt.Sprintf("grep %s /etc/mtab", directory)); err != nil || conf.SkipAnsible {
-		if err := runner.Execute(hostname, true, fmt.Sprintf(
+	if !isMounted(ctxt, hostname, directory) || conf.SkipAnsible {
+		if err := runner.Execute(ctxt, hostname, true, fmt.Sprintf(
 			"mount -t lustre -o flock,nodev,nosuid %s%s:/%s %s",
 			mgtHost, lnetSuffix, fsname, directory)); err != nil {
 			return err
@@ -234,62 +350,98 @@ func mountLustre(hostname string, lnetSuffix string, mgtHost string, fsname stri
 	return nil
 }
 
-func mountBeegFS(hostname string, mgtHost string, fsname string, directory string) error {
+func mountBeegFS(ctxt context.Context, hostname string, mgtHost string, fsname string, directory string) error {
 	// Ansible mounts beegfs at /mnt/beegfs/<fsname>, link into above location here
 	// First remove the directory, then replace with a symbolic link
-	if err := removeSubtree(hostname, directory); err != nil {
+	if err := removeSubtree(ctxt, hostname, directory); err != nil {
 		return err
 	}
-	return runner.Execute(hostname, true, fmt.Sprintf("ln -s /mnt/beegfs/%s %s", fsname, directory))
+	return createSymbolicLink(ctxt, hostname, getBeegFSClientDir(fsname), directory)
+}
+
+// Where ansible mounts the BeeGFS client
+func getBeegFSClientDir(fsname string) string {
+	return fmt.Sprintf("/mnt/beegfs/%s", fsname)
 }
 
-func mkdir(hostname string, directory string) error {
-	return runner.Execute(hostname, true, fmt.Sprintf("mkdir -p %s", directory))
+func mkdir(ctxt context.Context, hostname string, directory string) error {
+	return runner.Execute(ctxt, hostname, true, fmt.Sprintf("mkdir -p %s", directory))
 }
 
 type Run interface {
-	Execute(name string, asRoot bool, cmd string) error
+	Execute(ctxt context.Context, name string, asRoot bool, cmd string) error
+	// Same as Execute, also returning what the command printed
+	Output(ctxt context.Context, name string, asRoot bool, cmd string) (string, error)
 }
 
 type run struct {
 }
 
-// TODO: need some code sharing here!!!
-func (*run) Execute(hostname string, asRoot bool, cmdStr string) error {
-	log.Println("SSH to:", hostname, "with command:", cmdStr)
+// Longest any single remote command can run, unless the context says otherwise
+const sshTimeout = time.Minute * 5
 
-	if conf.SkipAnsible {
-		log.Println("Skip as DAC_SKIP_ANSIBLE=True")
-		time.Sleep(time.Millisecond * 200)
-		return nil
-	}
+type commandTimeoutKey struct{}
+
+// Used for commands, such as wiping a brick, that are expected to take longer than sshTimeout
+func withCommandTimeout(ctxt context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctxt, commandTimeoutKey{}, timeout)
+}
 
-	cmd := exec.Command("ssh", "-o", "StrictHostKeyChecking=no",
-		"-o", "UserKnownHostsFile=/dev/null", hostname, cmdStr)
-	if asRoot {
-		cmd = exec.Command("ssh", "-o", "StrictHostKeyChecking=no",
-			"-o", "UserKnownHostsFile=/dev/null", hostname, "sudo", cmdStr)
+func getCommandTimeout(ctxt context.Context) time.Duration {
+	if timeout, ok := ctxt.Value(commandTimeoutKey{}).(time.Duration); ok {
+		return timeout
 	}
+	return sshTimeout
+}
+
+func (r *run) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
+	_, err := r.Output(ctxt, hostname, asRoot, cmdStr)
+	return err
+}
 
-	timer := time.AfterFunc(time.Minute*5, func() {
-		log.Println("Time up, waited more than 5 mins to complete.")
-		if err := cmd.Process.Kill(); err != nil {
-			log.Panicf("error trying to kill process: %s", err.Error())
+func (*run) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	return observeRun(ctxt, hostname, cmdStr, func(ctxt context.Context) ([]byte, error) {
+		// The process is killed if the action is cancelled, or it takes too long
+		cmd := exec.CommandContext(ctxt, "ssh", "-o", "StrictHostKeyChecking=no",
+			"-o", "UserKnownHostsFile=/dev/null", hostname, cmdStr)
+		if asRoot {
+			cmd = exec.CommandContext(ctxt, "ssh", "-o", "StrictHostKeyChecking=no",
+				"-o", "UserKnownHostsFile=/dev/null", hostname, "sudo", cmdStr)
 		}
+		return cmd.CombinedOutput()
 	})
+}
 
-	output, err := cmd.CombinedOutput()
-	timer.Stop()
+// Shared by every Run, so remote commands are logged, timed out and measured the same way
+func observeRun(ctxt context.Context, hostname string, cmdStr string,
+	remoteRun func(ctxt context.Context) ([]byte, error)) (string, error) {
+	logger := logging.FromContext(ctxt).WithFields(logging.Fields{"ssh_host": hostname, "command": cmdStr})
+	logger.Debug("starting remote ssh run")
 
+	if conf.SkipAnsible {
+		logger.Info("skip ssh as DAC_SKIP_ANSIBLE=True")
+		time.Sleep(time.Millisecond * 200)
+		return "", nil
+	}
+
+	ctxt, cancelFunc := context.WithTimeout(ctxt, getCommandTimeout(ctxt))
+	defer cancelFunc()
+
+	startTime := time.Now()
+	output, err := remoteRun(ctxt)
+	if err != nil && ctxt.Err() != nil {
+		err = fmt.Errorf("%s, stopped due to: %s", err, ctxt.Err())
+	}
+	sshDuration.WithLabelValues(getCommandLabel(cmdStr), getResultLabel(err)).Observe(time.Since(startTime).Seconds())
+
+	logger = logger.WithField("output", string(output))
 	if err == nil {
-		log.Println("Completed remote ssh run:", cmdStr)
-		log.Println(string(output))
-		return nil
+		logger.Info("completed remote ssh run")
+		return string(output), nil
 	} else {
-		log.Printf("Error in remote ssh run: '%s' error: %s\n", cmdStr, err.Error())
-		log.Println(string(output))
-		return err
+		logger.WithField("error", err.Error()).Error("error in remote ssh run")
+		return string(output), err
 	}
 }
 
-var runner Run = &run{}
+var runner = getRunner(conf)
@@ -1,9 +1,12 @@
 package filesystem_impl
 
 import (
+	"context"
 	"errors"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
@@ -12,9 +15,10 @@ type fakeRunner struct {
 	calls     int
 	hostnames []string
 	cmdStrs   []string
+	outputs   map[string]string
 }
 
-func (f *fakeRunner) Execute(hostname string, asRoot bool, cmdStr string) error {
+func (f *fakeRunner) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
 	f.calls += 1
 	f.hostnames = append(f.hostnames, hostname)
 	f.cmdStrs = append(f.cmdStrs, cmdStr)
@@ -27,18 +31,23 @@ func (f *fakeRunner) Execute(hostname string, asRoot bool, cmdStr string) error
 	return f.err
 }
 
+func (f *fakeRunner) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	err := f.Execute(ctxt, hostname, asRoot, cmdStr)
+	return f.outputs[cmdStr], err
+}
+
 func Test_mkdir(t *testing.T) {
 	defer func() { runner = &run{} }()
 	fake := &fakeRunner{}
 	runner = fake
 
-	err := mkdir("host", "dir")
+	err := mkdir(context.TODO(), "host", "dir")
 	assert.Nil(t, err)
 	assert.Equal(t, "host", fake.hostnames[0])
 	assert.Equal(t, "mkdir -p dir", fake.cmdStrs[0])
 
 	runner = &fakeRunner{err: errors.New("expected")}
-	err = mkdir("", "")
+	err = mkdir(context.TODO(), "", "")
 	assert.Equal(t, "expected", err.Error())
 }
 
@@ -47,7 +56,7 @@ func Test_mountLustre(t *testing.T) {
 	fake := &fakeRunner{}
 	runner = fake
 
-	err := mountLustre("host", "-opa@o2ib1", "mgt", "fs", "/mnt/dac/job1_job")
+	err := mountLustre(context.TODO(), "host", "-opa@o2ib1", "mgt", "fs", "/mnt/dac/job1_job")
 	assert.Nil(t, err)
 	assert.Equal(t, 2, fake.calls)
 	assert.Equal(t, "host", fake.hostnames[0])
@@ -57,7 +66,7 @@ func Test_mountLustre(t *testing.T) {
 
 	fake = &fakeRunner{err: errors.New("expected")}
 	runner = fake
-	err = mountRemoteFilesystem(Lustre, "host", "", "mgt", "fs", "asdf")
+	err = mountRemoteFilesystem(context.TODO(), Lustre, "host", "", "mgt", "fs", "asdf")
 	assert.Equal(t, "expected", err.Error())
 	assert.Equal(t, 2, fake.calls)
 	assert.Equal(t, "grep asdf /etc/mtab", fake.cmdStrs[0])
@@ -66,19 +75,251 @@ func Test_mountLustre(t *testing.T) {
 
 func Test_createSwap(t *testing.T) {
 	defer func() { runner = &run{} }()
-	fake := &fakeRunner{}
+	fake := &fakeRunner{outputs: map[string]string{"losetup --find --show file": "/dev/loop3\n"}}
 	runner = fake
 
-	err := createSwap("host", 3, "file", "loopback")
+	err := createSwap(context.TODO(), "host", 3, "file")
 	assert.Nil(t, err)
-	assert.Equal(t, "host", fake.hostnames[0])
-	assert.Equal(t, "host", fake.hostnames[1])
-	assert.Equal(t, "host", fake.hostnames[2])
-	assert.Equal(t, 4, len(fake.cmdStrs))
-	assert.Equal(t, "dd if=/dev/zero of=file bs=1024 count=3072", fake.cmdStrs[0])
-	assert.Equal(t, "chmod 0600 file", fake.cmdStrs[1])
-	assert.Equal(t, "losetup loopback file", fake.cmdStrs[2])
-	assert.Equal(t, "mkswap loopback", fake.cmdStrs[3])
+	assert.Equal(t, []string{"host", "host", "host", "host", "host", "host"}, fake.hostnames)
+	assert.Equal(t, []string{
+		"losetup --list --noheadings --output NAME --associated file",
+		"dd if=/dev/zero of=file bs=1024 count=3072",
+		"chmod 0600 file",
+		"losetup --find --show file",
+		"mkswap /dev/loop3",
+		"swapon /dev/loop3",
+	}, fake.cmdStrs)
+}
+
+func Test_createSwap_AlreadySetup(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &fakeRunner{outputs: map[string]string{
+		"losetup --list --noheadings --output NAME --associated file": "/dev/loop3\n",
+		"swapon --show=NAME --noheadings":                             "/dev/sda2\n/dev/loop3\n",
+	}}
+	runner = fake
+
+	// swap that is in use is not overwritten, or given a second loop device
+	err := createSwap(context.TODO(), "host", 3, "file")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"losetup --list --noheadings --output NAME --associated file",
+		"swapon --show=NAME --noheadings",
+	}, fake.cmdStrs)
+
+	// a loop device left by an earlier attempt is detached before starting again
+	fake.outputs["swapon --show=NAME --noheadings"] = "/dev/sda2\n"
+	fake.outputs["losetup --find --show file"] = "/dev/loop4\n"
+	fake.cmdStrs = nil
+	err = createSwap(context.TODO(), "host", 3, "file")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"losetup --list --noheadings --output NAME --associated file",
+		"swapon --show=NAME --noheadings",
+		"losetup --detach /dev/loop3",
+		"dd if=/dev/zero of=file bs=1024 count=3072",
+		"chmod 0600 file",
+		"losetup --find --show file",
+		"mkswap /dev/loop4",
+		"swapon /dev/loop4",
+	}, fake.cmdStrs)
+}
+
+type failingSwapRunner struct {
+	fakeRunner
+	failHost string
+}
+
+func (f *failingSwapRunner) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
+	_ = f.fakeRunner.Execute(ctxt, hostname, asRoot, cmdStr)
+	if hostname == f.failHost && strings.HasPrefix(cmdStr, "swapon /dev/") {
+		return errors.New("swapon failed")
+	}
+	return nil
+}
+
+func (f *failingSwapRunner) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	err := f.Execute(ctxt, hostname, asRoot, cmdStr)
+	return f.outputs[hostname+":"+cmdStr], err
+}
+
+func Test_createSwap_Detach(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &failingSwapRunner{failHost: "host", fakeRunner: fakeRunner{
+		outputs: map[string]string{"host:losetup --find --show file": "/dev/loop3"}}}
+	runner = fake
+
+	err := createSwap(context.TODO(), "host", 3, "file")
+	assert.Equal(t, "swapon failed", err.Error())
+	assert.Equal(t, "losetup --detach /dev/loop3", fake.cmdStrs[6])
+
+	fake = &failingSwapRunner{}
+	runner = fake
+	err = createSwap(context.TODO(), "host", 3, "file")
+	assert.Equal(t, "unexpected loop device from losetup: ", err.Error())
+}
+
+func Test_removeSwap(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &fakeRunner{outputs: map[string]string{
+		"losetup --list --noheadings --output NAME --associated file": "/dev/loop3\n/dev/loop7\n",
+		"swapon --show=NAME --noheadings":                             "/dev/sda2\n/dev/loop7\n",
+	}}
+	runner = fake
+
+	err := removeSwap(context.TODO(), "host", "file")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"losetup --list --noheadings --output NAME --associated file",
+		"swapon --show=NAME --noheadings",
+		"losetup --detach /dev/loop3",
+		"swapoff /dev/loop7",
+		"losetup --detach /dev/loop7",
+		"rm -f file",
+	}, fake.cmdStrs)
+
+	fake = &fakeRunner{}
+	runner = fake
+	err = removeSwap(context.TODO(), "host", "file")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"losetup --list --noheadings --output NAME --associated file",
+		"rm -f file",
+	}, fake.cmdStrs)
+}
+
+func Test_getSwapMB(t *testing.T) {
+	assert.Equal(t, 1, getSwapMB(1))
+	assert.Equal(t, 1, getSwapMB(1024*1024))
+	assert.Equal(t, 2, getSwapMB(1024*1024+1))
+}
+
+// Hosts run one at a time, so the commands are in a predictable order
+func runHostsInOrder() func() {
+	limit := conf.SSHParallelHosts
+	conf.SSHParallelHosts = 1
+	return func() { conf.SSHParallelHosts = limit }
+}
+
+func Test_Mount_PartialFailure(t *testing.T) {
+	defer func() { runner = &run{} }()
+	defer runHostsInOrder()()
+	fake := &failingSwapRunner{failHost: "client2", fakeRunner: fakeRunner{outputs: map[string]string{
+		"client1:losetup --find --show /mnt/dac/job1_job/swap/client1":                                  "/dev/loop0",
+		"client2:losetup --find --show /mnt/dac/job1_job/swap/client2":                                  "/dev/loop0",
+		"client3:losetup --find --show /mnt/dac/job1_job/swap/client3":                                  "/dev/loop0",
+		"client2:losetup --list --noheadings --output NAME --associated /mnt/dac/job1_job/swap/client2": "",
+	}}}
+	runner = fake
+
+	attachment := datamodel.AttachmentSession{SessionName: "job1", Hosts: []string{"client1", "client2", "client3"},
+		SwapBytes: 1024 * 1024}
+	err := mount(context.TODO(), BeegFS, "job1", false, "fsuuid", "host1", "", attachment,
+		1001, 1002, false)
+
+	assert.Equal(t, filesystem.HostErrors{
+		"client2": errors.New("unable to create swap on client2 due to: swapon failed"),
+	}, err)
+	var client2Cmds []string
+	for i, hostname := range fake.hostnames {
+		if hostname == "client2" {
+			client2Cmds = append(client2Cmds, fake.cmdStrs[i])
+		}
+	}
+	assert.Equal(t, []string{
+		"mkdir -p /mnt/dac/job1_job",
+		"rm -df /mnt/dac/job1_job",
+		"ln -sfn /mnt/beegfs/fsuuid /mnt/dac/job1_job",
+		"mkdir -p /mnt/dac/job1_job/swap",
+		"chown 0:0 /mnt/dac/job1_job/swap",
+		"chmod 700 /mnt/dac/job1_job/swap",
+		"losetup --list --noheadings --output NAME --associated /mnt/dac/job1_job/swap/client2",
+		"dd if=/dev/zero of=/mnt/dac/job1_job/swap/client2 bs=1024 count=1024",
+		"chmod 0600 /mnt/dac/job1_job/swap/client2",
+		"losetup --find --show /mnt/dac/job1_job/swap/client2",
+		"mkswap /dev/loop0",
+		"swapon /dev/loop0",
+		"losetup --detach /dev/loop0",
+		// the failed host is unmounted again
+		"losetup --list --noheadings --output NAME --associated /mnt/dac/job1_job/swap/client2",
+		"rm -f /mnt/dac/job1_job/swap/client2",
+		"rm -df /mnt/dac/job1_job",
+	}, client2Cmds)
+	// the other hosts are still mounted
+	assert.Equal(t, "swapon /dev/loop0", fake.cmdStrs[11])
+	assert.Equal(t, "client3", fake.hostnames[len(fake.hostnames)-1])
+	assert.Equal(t, "swapon /dev/loop0", fake.cmdStrs[len(fake.cmdStrs)-1])
+}
+
+// Cancels the action when swap fails, like an action timing out part way through
+type cancellingRunner struct {
+	failingSwapRunner
+	cancel     context.CancelFunc
+	failRemove bool
+	failed     bool
+}
+
+func (f *cancellingRunner) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
+	if ctxt.Err() != nil {
+		return ctxt.Err()
+	}
+	err := f.failingSwapRunner.Execute(ctxt, hostname, asRoot, cmdStr)
+	if err != nil {
+		f.failed = true
+		f.cancel()
+	}
+	if f.failed && f.failRemove && strings.HasPrefix(cmdStr, "rm -df") {
+		return errors.New("busy")
+	}
+	return err
+}
+
+func (f *cancellingRunner) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	err := f.Execute(ctxt, hostname, asRoot, cmdStr)
+	return f.outputs[hostname+":"+cmdStr], err
+}
+
+func Test_Mount_RollbackAfterCancel(t *testing.T) {
+	defer func() { runner = &run{} }()
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	defer cancelFunc()
+	fake := &cancellingRunner{cancel: cancelFunc, failingSwapRunner: failingSwapRunner{failHost: "client1",
+		fakeRunner: fakeRunner{outputs: map[string]string{
+			"client1:losetup --find --show /mnt/dac/job1_job/swap/client1": "/dev/loop0",
+		}}}}
+	runner = fake
+
+	attachment := datamodel.AttachmentSession{SessionName: "job1", Hosts: []string{"client1"},
+		SwapBytes: 1024 * 1024}
+	err := mount(ctxt, BeegFS, "job1", false, "fsuuid", "host1", "", attachment, 1001, 1002, false)
+
+	assert.Equal(t, filesystem.HostErrors{
+		"client1": errors.New("unable to create swap on client1 due to: swapon failed"),
+	}, err)
+	// the rollback runs even though the action was cancelled
+	assert.Equal(t, "rm -df /mnt/dac/job1_job", fake.cmdStrs[len(fake.cmdStrs)-1])
+}
+
+func Test_Mount_RollbackFailed(t *testing.T) {
+	defer func() { runner = &run{} }()
+	policy := defaultRetryPolicies[unmountOperation]
+	defer func() { defaultRetryPolicies[unmountOperation] = policy }()
+	defaultRetryPolicies[unmountOperation] = retryPolicy{MaxAttempts: 1, IsRetryable: retryAny}
+	fake := &cancellingRunner{cancel: func() {}, failRemove: true, failingSwapRunner: failingSwapRunner{
+		failHost: "client1", fakeRunner: fakeRunner{outputs: map[string]string{
+			"client1:losetup --find --show /mnt/dac/job1_job/swap/client1": "/dev/loop0",
+		}}}}
+	runner = fake
+
+	attachment := datamodel.AttachmentSession{SessionName: "job1", Hosts: []string{"client1"},
+		SwapBytes: 1024 * 1024}
+	err := mount(context.TODO(), BeegFS, "job1", false, "fsuuid", "host1", "", attachment, 1001, 1002, false)
+
+	hostErrors, ok := err.(filesystem.HostErrors)
+	assert.True(t, ok)
+	stillMounted, ok := hostErrors["client1"].(filesystem.StillMountedError)
+	assert.True(t, ok)
+	assert.Equal(t, "unable to create swap on client1 due to: swapon failed", stillMounted.MountErr.Error())
 }
 
 func Test_fixUpOwnership(t *testing.T) {
@@ -86,7 +327,7 @@ func Test_fixUpOwnership(t *testing.T) {
 	fake := &fakeRunner{}
 	runner = fake
 
-	err := fixUpOwnership("host", 10, 11, "dir")
+	err := fixUpOwnership(context.TODO(), "host", 10, 11, "dir")
 	assert.Nil(t, err)
 
 	assert.Equal(t, 2, fake.calls)
@@ -98,7 +339,11 @@ func Test_fixUpOwnership(t *testing.T) {
 
 func Test_Mount(t *testing.T) {
 	defer func() { runner = &run{} }()
-	fake := &fakeRunner{}
+	defer runHostsInOrder()()
+	fake := &fakeRunner{outputs: map[string]string{
+		"losetup --find --show /mnt/dac/job1_job/swap/client1": "/dev/loop0",
+		"losetup --find --show /mnt/dac/job1_job/swap/client2": "/dev/loop0",
+	}}
 	runner = fake
 
 	sessionName := datamodel.SessionName("job1")
@@ -115,42 +360,50 @@ func Test_Mount(t *testing.T) {
 		PrivateMount: true,
 		SwapBytes:    1024 * 1024, // 1 MiB
 	}
-	err := mount(Lustre, sessionName, false,
-		internalName, primaryBrickHost, attachment,
+	err := mount(context.TODO(), Lustre, sessionName, false,
+		internalName, primaryBrickHost, "", attachment,
 		owner, group, true)
 	assert.Nil(t, err)
-	assert.Equal(t, 17, fake.calls)
-
-	assert.Equal(t, "client1", fake.hostnames[0])
-	assert.Equal(t, "mkdir -p /mnt/dac/job1_job", fake.cmdStrs[0])
-	assert.Equal(t, "grep /mnt/dac/job1_job /etc/mtab", fake.cmdStrs[1])
-	assert.Equal(t, "mount -t lustre -o flock,nodev,nosuid host1:/fsuuid /mnt/dac/job1_job", fake.cmdStrs[2])
-
-	assert.Equal(t, "client2", fake.hostnames[3])
-	assert.Equal(t, "mkdir -p /mnt/dac/job1_job", fake.cmdStrs[3])
-	assert.Equal(t, "grep /mnt/dac/job1_job /etc/mtab", fake.cmdStrs[4])
-	assert.Equal(t, "mount -t lustre -o flock,nodev,nosuid host1:/fsuuid /mnt/dac/job1_job", fake.cmdStrs[5])
-
-	assert.Equal(t, "client1", fake.hostnames[6])
-	assert.Equal(t, "mkdir -p /mnt/dac/job1_job/global", fake.cmdStrs[6])
-	assert.Equal(t, "chown 1001:1002 /mnt/dac/job1_job/global", fake.cmdStrs[7])
-	assert.Equal(t, "chmod 700 /mnt/dac/job1_job/global", fake.cmdStrs[8])
-
-	assert.Equal(t, "client1", fake.hostnames[9])
-	assert.Equal(t, "mkdir -p /mnt/dac/job1_job/private/client1", fake.cmdStrs[9])
-	assert.Equal(t, "chown 1001:1002 /mnt/dac/job1_job/private/client1", fake.cmdStrs[10])
-	assert.Equal(t, "chmod 700 /mnt/dac/job1_job/private/client1", fake.cmdStrs[11])
-	assert.Equal(t, "ln -s /mnt/dac/job1_job/private/client1 /mnt/dac/job1_job_private", fake.cmdStrs[12])
-
-	assert.Equal(t, "client2", fake.hostnames[13])
-	assert.Equal(t, "mkdir -p /mnt/dac/job1_job/private/client2", fake.cmdStrs[13])
+	assert.Equal(t, 35, fake.calls)
+
+	assert.Equal(t, []string{
+		"mkdir -p /mnt/dac/job1_job",
+		"grep /mnt/dac/job1_job /etc/mtab",
+		"mount -t lustre -o flock,nodev,nosuid host1:/fsuuid /mnt/dac/job1_job",
+		"mkdir -p /mnt/dac/job1_job/global",
+		"chown 1001:1002 /mnt/dac/job1_job/global",
+		"chmod 700 /mnt/dac/job1_job/global",
+		"mkdir -p /mnt/dac/job1_job/private/client1",
+		"chown 1001:1002 /mnt/dac/job1_job/private/client1",
+		"chmod 700 /mnt/dac/job1_job/private/client1",
+		"ln -sfn /mnt/dac/job1_job/private/client1 /mnt/dac/job1_job_private",
+		"mkdir -p /mnt/dac/job1_job/swap",
+		"chown 0:0 /mnt/dac/job1_job/swap",
+		"chmod 700 /mnt/dac/job1_job/swap",
+		"losetup --list --noheadings --output NAME --associated /mnt/dac/job1_job/swap/client1",
+		"dd if=/dev/zero of=/mnt/dac/job1_job/swap/client1 bs=1024 count=1024",
+		"chmod 0600 /mnt/dac/job1_job/swap/client1",
+		"losetup --find --show /mnt/dac/job1_job/swap/client1",
+		"mkswap /dev/loop0",
+		"swapon /dev/loop0",
+	}, fake.cmdStrs[:19])
+	for i := 0; i < 19; i++ {
+		assert.Equal(t, "client1", fake.hostnames[i])
+	}
 
-	assert.Equal(t, "client2", fake.hostnames[16])
-	assert.Equal(t, "ln -s /mnt/dac/job1_job/private/client2 /mnt/dac/job1_job_private", fake.cmdStrs[16])
+	assert.Equal(t, "client2", fake.hostnames[19])
+	assert.Equal(t, "mkdir -p /mnt/dac/job1_job", fake.cmdStrs[19])
+	assert.Equal(t, "mount -t lustre -o flock,nodev,nosuid host1:/fsuuid /mnt/dac/job1_job", fake.cmdStrs[21])
+	// only the first host creates the global directory
+	assert.Equal(t, "mkdir -p /mnt/dac/job1_job/private/client2", fake.cmdStrs[22])
+	assert.Equal(t, "ln -sfn /mnt/dac/job1_job/private/client2 /mnt/dac/job1_job_private", fake.cmdStrs[25])
+	assert.Equal(t, "dd if=/dev/zero of=/mnt/dac/job1_job/swap/client2 bs=1024 count=1024", fake.cmdStrs[30])
+	assert.Equal(t, "swapon /dev/loop0", fake.cmdStrs[34])
 }
 
 func Test_Umount(t *testing.T) {
 	defer func() { runner = &run{} }()
+	defer runHostsInOrder()()
 	fake := &fakeRunner{}
 	runner = fake
 
@@ -166,19 +419,22 @@ func Test_Umount(t *testing.T) {
 		PrivateMount: true,
 		SwapBytes:    1024 * 1024, // 1 MiB
 	}
-	err := unmount(Lustre, sessionName, false,
+	err := unmount(context.TODO(), Lustre, sessionName, false,
 		internalName, primaryBrickHost, attachment)
 	assert.Nil(t, err)
-	assert.Equal(t, 8, fake.calls)
+	assert.Equal(t, 12, fake.calls)
 
 	assert.Equal(t, "client1", fake.hostnames[0])
-	assert.Equal(t, "rm -df /mnt/dac/job4_job_private", fake.cmdStrs[0])
-	assert.Equal(t, "grep /mnt/dac/job4_job /etc/mtab", fake.cmdStrs[1])
-	assert.Equal(t, "umount /mnt/dac/job4_job", fake.cmdStrs[2])
-	assert.Equal(t, "rm -df /mnt/dac/job4_job", fake.cmdStrs[3])
-
-	assert.Equal(t, "client2", fake.hostnames[7])
-	assert.Equal(t, "rm -df /mnt/dac/job4_job", fake.cmdStrs[7])
+	assert.Equal(t, "losetup --list --noheadings --output NAME --associated /mnt/dac/job4_job/swap/client1",
+		fake.cmdStrs[0])
+	assert.Equal(t, "rm -f /mnt/dac/job4_job/swap/client1", fake.cmdStrs[1])
+	assert.Equal(t, "rm -df /mnt/dac/job4_job_private", fake.cmdStrs[2])
+	assert.Equal(t, "grep /mnt/dac/job4_job /etc/mtab", fake.cmdStrs[3])
+	assert.Equal(t, "umount /mnt/dac/job4_job", fake.cmdStrs[4])
+	assert.Equal(t, "rm -df /mnt/dac/job4_job", fake.cmdStrs[5])
+
+	assert.Equal(t, "client2", fake.hostnames[11])
+	assert.Equal(t, "rm -df /mnt/dac/job4_job", fake.cmdStrs[11])
 }
 
 func Test_Umount_multi(t *testing.T) {
@@ -196,7 +452,7 @@ func Test_Umount_multi(t *testing.T) {
 		PrivateMount: false,
 		SwapBytes:    0,
 	}
-	err := unmount(Lustre, sessionName, true,
+	err := unmount(context.TODO(), Lustre, sessionName, true,
 		internalName, primaryBrickHost, attachment)
 
 	assert.Nil(t, err)
@@ -225,8 +481,8 @@ func Test_Mount_multi(t *testing.T) {
 		PrivateMount: false,
 		SwapBytes:    0,
 	}
-	err := mount(Lustre, sessionName, true,
-		internalName, primaryBrickHost, attachment,
+	err := mount(context.TODO(), Lustre, sessionName, true,
+		internalName, primaryBrickHost, "", attachment,
 		owner, group, false)
 
 	assert.Nil(t, err)
@@ -239,3 +495,69 @@ func Test_Mount_multi(t *testing.T) {
 	assert.Equal(t, "client1", fake.hostnames[2])
 	assert.Equal(t, "mount -t lustre -o flock,nodev,nosuid host1:/uuidasdf /mnt/dac/job1_persistent_asdf", fake.cmdStrs[2])
 }
+
+func Test_Mount_multiPrivate(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &fakeRunner{}
+	runner = fake
+
+	attachment := datamodel.AttachmentSession{
+		SessionName:  "job1",
+		Hosts:        []string{"client1"},
+		PrivateMount: true,
+	}
+	err := mount(context.TODO(), Lustre, "asdf", true, "uuidasdf", "host1", "", attachment,
+		1001, 1002, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"mkdir -p /mnt/dac/job1_persistent_asdf",
+		"grep /mnt/dac/job1_persistent_asdf /etc/mtab",
+		"mount -t lustre -o flock,nodev,nosuid host1:/uuidasdf /mnt/dac/job1_persistent_asdf",
+		"mkdir -p /mnt/dac/job1_persistent_asdf/private/client1",
+		"chown 1001:1002 /mnt/dac/job1_persistent_asdf/private/client1",
+		"chmod 700 /mnt/dac/job1_persistent_asdf/private/client1",
+		"ln -sfn /mnt/dac/job1_persistent_asdf/private/client1 /mnt/dac/job1_persistent_asdf_private",
+	}, fake.cmdStrs)
+
+	fake = &fakeRunner{}
+	runner = fake
+	err = unmount(context.TODO(), Lustre, "asdf", true, "uuidasdf", "host1", attachment)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"rm -df /mnt/dac/job1_persistent_asdf_private",
+		"grep /mnt/dac/job1_persistent_asdf /etc/mtab",
+		"rm -df /mnt/dac/job1_persistent_asdf",
+	}, fake.cmdStrs)
+}
+
+func Test_Mount_beegfs(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &fakeRunner{}
+	runner = fake
+
+	attachment := datamodel.AttachmentSession{SessionName: "job1", Hosts: []string{"client1"}, GlobalMount: true}
+	err := mount(context.TODO(), BeegFS, "job1", false, "fsuuid", "host1", "", attachment,
+		1001, 1002, false)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{
+		"mkdir -p /mnt/dac/job1_job",
+		"rm -df /mnt/dac/job1_job",
+		"ln -sfn /mnt/beegfs/fsuuid /mnt/dac/job1_job",
+	}, fake.cmdStrs)
+
+	fake = &fakeRunner{}
+	runner = fake
+	err = unmount(context.TODO(), BeegFS, "job1", false, "fsuuid", "host1", attachment)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rm -df /mnt/dac/job1_job"}, fake.cmdStrs)
+}
+
+func Test_run_Cancelled(t *testing.T) {
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+
+	err := (&run{}).Execute(ctxt, "localhost", false, "true")
+
+	assert.Equal(t, "context canceled, stopped due to: context canceled", err.Error())
+}
@@ -1,12 +1,24 @@
 package filesystem_impl
 
 import (
+	"context"
+	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
 	"math/rand"
 	"time"
 )
 
+// Name pools use to pick this provider, its settings are in the ansible_provider config section
+const ProviderName = "ansible"
+
+func init() {
+	filesystem.RegisterProvider(ProviderName, func(settings map[string]string) (filesystem.Provider, error) {
+		// conf already holds the settings
+		return NewFileSystemProvider(nil), nil
+	})
+}
+
 func NewFileSystemProvider(ansible filesystem.Ansible) filesystem.Provider {
 	return &fileSystemProvider{ansible: ansible}
 }
@@ -29,26 +41,48 @@ func GetNewUUID() string {
 	return string(b)
 }
 
-func (f *fileSystemProvider) Create(session datamodel.Session) (datamodel.FilesystemStatus, error) {
+func (f *fileSystemProvider) Create(ctxt context.Context, session datamodel.Session) (datamodel.FilesystemStatus, error) {
 	session.FilesystemStatus = datamodel.FilesystemStatus{
 		InternalName: GetNewUUID(),
 		InternalData: "",
 	}
-	err := executeAnsibleSetup(session.FilesystemStatus.InternalName, session.AllocatedBricks, true)
+	settings := getFilesystemSettings(session)
+	fsType := getFSType(settings)
+	// Recorded so the same targets are used after an expand
+	layout := getLayout(fsType, session.AllocatedBricks, settings)
+	session.FilesystemStatus.Layout = &layout
+	err := executeAnsibleSetup(ctxt, fsType, session.FilesystemStatus.InternalName,
+		session.AllocatedBricks, layout, settings, true)
 	return session.FilesystemStatus, err
 }
 
-func (f *fileSystemProvider) Restore(session datamodel.Session) error {
-	return executeAnsibleSetup(session.FilesystemStatus.InternalName, session.AllocatedBricks, false)
+func (f *fileSystemProvider) Restore(ctxt context.Context, session datamodel.Session) error {
+	settings := getFilesystemSettings(session)
+	fsType := getFSType(settings)
+	return executeAnsibleSetup(ctxt, fsType, session.FilesystemStatus.InternalName,
+		getFormattedBricks(session), getSessionLayout(fsType, session, settings), settings, false)
+}
+
+// Includes bricks from an incomplete expand, as they may have been partly formatted
+func (f *fileSystemProvider) Delete(ctxt context.Context, session datamodel.Session) error {
+	settings := getFilesystemSettings(session)
+	fsType := getFSType(settings)
+	return executeAnsibleTeardown(ctxt, fsType, session.FilesystemStatus.InternalName,
+		session.AllocatedBricks, getSessionLayout(fsType, session, settings), settings)
 }
 
-func (f *fileSystemProvider) Delete(session datamodel.Session) error {
-	return executeAnsibleTeardown(session.FilesystemStatus.InternalName, session.AllocatedBricks)
+func (f *fileSystemProvider) Expand(ctxt context.Context, session datamodel.Session, newBricks []datamodel.Brick) error {
+	settings := getFilesystemSettings(session)
+	if getFSType(settings) != Lustre {
+		return fmt.Errorf("expand is not supported for %s filesystems", settings.Type)
+	}
+	return executeAnsibleExpand(ctxt, session.FilesystemStatus.InternalName, session.AllocatedBricks, newBricks,
+		settings)
 }
 
-func (f *fileSystemProvider) DataCopyIn(session datamodel.Session) error {
+func (f *fileSystemProvider) DataCopyIn(ctxt context.Context, session datamodel.Session) error {
 	for _, dataCopy := range session.StageInRequests {
-		err := processDataCopy(session, dataCopy)
+		err := processDataCopy(ctxt, session, dataCopy)
 		if err != nil {
 			return err
 		}
@@ -57,9 +91,9 @@ func (f *fileSystemProvider) DataCopyIn(session datamodel.Session) error {
 
 }
 
-func (f *fileSystemProvider) DataCopyOut(session datamodel.Session) error {
+func (f *fileSystemProvider) DataCopyOut(ctxt context.Context, session datamodel.Session) error {
 	for _, dataCopy := range session.StageOutRequests {
-		err := processDataCopy(session, dataCopy)
+		err := processDataCopy(ctxt, session, dataCopy)
 		if err != nil {
 			return err
 		}
@@ -67,15 +101,41 @@ func (f *fileSystemProvider) DataCopyOut(session datamodel.Session) error {
 	return nil
 }
 
-func (f *fileSystemProvider) Mount(session datamodel.Session, attachments datamodel.AttachmentSession,
+func (f *fileSystemProvider) Mount(ctxt context.Context, session datamodel.Session, attachments datamodel.AttachmentSession,
 	setInitialPermissions bool) error {
-	// TODO: pass setInitialPermissions
-	return mount(Lustre, session.Name, session.VolumeRequest.MultiJob, session.FilesystemStatus.InternalName,
-		session.PrimaryBrickHost, attachments, session.Owner, session.Group, setInitialPermissions)
+	settings := getFilesystemSettings(session)
+	fsType := getFSType(settings)
+	// Lustre clients are mounted directly, but the BeeGFS client needs configuring first
+	if fsType == BeegFS && len(attachments.Hosts) > 0 {
+		err := executeAnsibleClients(ctxt, session.FilesystemStatus.InternalName, session.AllocatedBricks,
+			getSessionLayout(fsType, session, settings), attachments.Hosts, settings, "beegfs-client-mount.yml")
+		if err != nil {
+			return err
+		}
+	}
+	return mount(ctxt, fsType, session.Name, session.VolumeRequest.MultiJob, session.FilesystemStatus.InternalName,
+		session.PrimaryBrickHost, settings.LnetSuffix, attachments,
+		session.Owner, session.Group, setInitialPermissions)
 
 }
 
-func (f *fileSystemProvider) Unmount(session datamodel.Session, attachments datamodel.AttachmentSession) error {
-	return unmount(Lustre, session.Name, session.VolumeRequest.MultiJob, session.FilesystemStatus.InternalName,
+func (f *fileSystemProvider) Unmount(ctxt context.Context, session datamodel.Session, attachments datamodel.AttachmentSession) error {
+	settings := getFilesystemSettings(session)
+	fsType := getFSType(settings)
+	err := unmount(ctxt, fsType, session.Name, session.VolumeRequest.MultiJob, session.FilesystemStatus.InternalName,
 		session.PrimaryBrickHost, attachments)
+	if err != nil || fsType != BeegFS || len(attachments.Hosts) == 0 {
+		return err
+	}
+	return executeAnsibleClients(ctxt, session.FilesystemStatus.InternalName, session.AllocatedBricks,
+		getSessionLayout(fsType, session, settings), attachments.Hosts, settings, "beegfs-client-unmount.yml")
+}
+
+func (f *fileSystemProvider) CheckMounts(ctxt context.Context, session datamodel.Session) error {
+	settings := getFilesystemSettings(session)
+	return checkMounts(ctxt, getFSType(settings), session, settings)
+}
+
+func (f *fileSystemProvider) Wipe(ctxt context.Context, brick datamodel.Brick, policy datamodel.WipePolicy) error {
+	return wipeBrick(ctxt, brick, policy)
 }
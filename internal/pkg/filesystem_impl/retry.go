@@ -0,0 +1,151 @@
+package filesystem_impl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"golang.org/x/crypto/ssh"
+	"log"
+	"os/exec"
+	"time"
+)
+
+type operation string
+
+const (
+	ansibleOperation operation = "ansible"
+	mountOperation   operation = "mount"
+	unmountOperation operation = "unmount"
+	copyOperation    operation = "copy"
+	wipeOperation    operation = "wipe"
+)
+
+type retryPolicy struct {
+	MaxAttempts uint
+
+	// Wait before the second attempt, doubling each time up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Errors that are worth trying again, errors after the action
+	// has been cancelled or reached its deadline are never retried
+	IsRetryable func(err error) bool
+}
+
+func retryAny(err error) bool {
+	return true
+}
+
+// ssh uses exit code 255 when it can't connect, the native runner reports
+// connections it can't make, and commands that lost their connection
+func isSSHError(err error) bool {
+	var exitErr *exec.ExitError
+	var connectionErr sshConnectionError
+	var exitMissingErr *ssh.ExitMissingError
+	return (errors.As(err, &exitErr) && exitErr.ExitCode() == 255) ||
+		errors.As(err, &connectionErr) || errors.As(err, &exitMissingErr)
+}
+
+// The remote command's exit code, from either runner
+func getExitCode(err error) (int, bool) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), true
+	}
+	var sshExitErr *ssh.ExitError
+	if errors.As(err, &sshExitErr) {
+		return sshExitErr.ExitStatus(), true
+	}
+	return 0, false
+}
+
+// rsync exit codes for network problems, timeouts and files changing during the copy
+var retryableRsyncExitCodes = map[int]bool{10: true, 12: true, 23: true, 24: true, 30: true, 35: true}
+
+func isRetryableCopyError(err error) bool {
+	exitCode, ok := getExitCode(err)
+	return isSSHError(err) || (ok && retryableRsyncExitCodes[exitCode])
+}
+
+var defaultRetryPolicies = map[operation]retryPolicy{
+	ansibleOperation: {MaxAttempts: 3, InitialBackoff: time.Second * 2, MaxBackoff: time.Second * 30,
+		IsRetryable: retryAny},
+	mountOperation: {MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Second * 30,
+		IsRetryable: retryAny},
+	unmountOperation: {MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: time.Second * 30,
+		IsRetryable: retryAny},
+	copyOperation: {MaxAttempts: 3, InitialBackoff: time.Second * 10, MaxBackoff: time.Minute,
+		IsRetryable: isRetryableCopyError},
+	// A device that fails to wipe is unlikely to work next time, but ssh might
+	wipeOperation: {MaxAttempts: 3, InitialBackoff: time.Second * 5, MaxBackoff: time.Minute,
+		IsRetryable: isSSHError},
+}
+
+// Replaces the default policy for operations that are part of the given action type
+var actionRetryPolicies = map[datamodel.SessionActionType]map[operation]retryPolicy{
+	// Bricks are only released once the delete works, so try harder
+	datamodel.SessionDelete: {
+		unmountOperation: {MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: time.Minute,
+			IsRetryable: retryAny},
+		ansibleOperation: {MaxAttempts: 5, InitialBackoff: time.Second * 2, MaxBackoff: time.Minute,
+			IsRetryable: retryAny},
+	},
+}
+
+func getRetryPolicy(actionType datamodel.SessionActionType, op operation) retryPolicy {
+	if policy, ok := actionRetryPolicies[actionType][op]; ok {
+		return policy
+	}
+	policy, ok := defaultRetryPolicies[op]
+	if !ok {
+		log.Panicf("no retry policy for operation %s", op)
+	}
+	return policy
+}
+
+// Keep calling attempt until it works, or the retry policy for the operation says to stop
+func retry(ctxt context.Context, op operation, hostname string, attempt func() error) error {
+	info := filesystem.GetActionInfo(ctxt)
+	return retryWithPolicy(ctxt, getRetryPolicy(info.ActionType, op), info, op, hostname, attempt)
+}
+
+func retryWithPolicy(ctxt context.Context, policy retryPolicy, info filesystem.ActionInfo,
+	op operation, hostname string, attempt func() error) error {
+	backoff := policy.InitialBackoff
+	for i := uint(1); ; i++ {
+		err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		failedAttempt := datamodel.ActionAttempt{
+			ActionType:  info.ActionType,
+			Operation:   string(op),
+			Hostname:    hostname,
+			Attempt:     i,
+			MaxAttempts: policy.MaxAttempts,
+			Error:       err.Error(),
+			AttemptedAt: time.Now(),
+		}
+		logging.FromContext(ctxt).Warn(failedAttempt.String())
+		if info.RecordAttempt != nil {
+			info.RecordAttempt(failedAttempt)
+		}
+		if i >= policy.MaxAttempts || ctxt.Err() != nil || !policy.IsRetryable(err) {
+			return errors.New(failedAttempt.String())
+		}
+
+		select {
+		case <-ctxt.Done():
+			return fmt.Errorf("%s, stopped due to: %s", failedAttempt, ctxt.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
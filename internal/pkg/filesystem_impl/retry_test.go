@@ -0,0 +1,92 @@
+package filesystem_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/stretchr/testify/assert"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+var testPolicy = retryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond * 2,
+	IsRetryable: retryAny}
+
+func TestRetryWithPolicy(t *testing.T) {
+	var recorded []datamodel.ActionAttempt
+	info := filesystem.ActionInfo{ActionType: datamodel.SessionMount,
+		RecordAttempt: func(attempt datamodel.ActionAttempt) { recorded = append(recorded, attempt) }}
+
+	calls := 0
+	err := retryWithPolicy(context.TODO(), testPolicy, info, mountOperation, "node123", func() error {
+		calls += 1
+		if calls < 2 {
+			return errors.New("fake")
+		}
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 1, len(recorded))
+	assert.Equal(t, "mount attempt 1/3 on node123 failed: fake", recorded[0].String())
+	assert.Equal(t, datamodel.SessionMount, recorded[0].ActionType)
+
+	calls = 0
+	err = retryWithPolicy(context.TODO(), testPolicy, info, mountOperation, "node123", func() error {
+		calls += 1
+		return errors.New("fake")
+	})
+
+	assert.Equal(t, "mount attempt 3/3 on node123 failed: fake", err.Error())
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 4, len(recorded))
+}
+
+func TestRetryWithPolicy_NotRetryable(t *testing.T) {
+	policy := testPolicy
+	policy.IsRetryable = func(err error) bool { return false }
+
+	calls := 0
+	err := retryWithPolicy(context.TODO(), policy, filesystem.ActionInfo{}, copyOperation, "localhost",
+		func() error {
+			calls += 1
+			return errors.New("fake")
+		})
+
+	assert.Equal(t, "copy attempt 1/3 on localhost failed: fake", err.Error())
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryWithPolicy_Cancelled(t *testing.T) {
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	policy := testPolicy
+	policy.InitialBackoff = time.Hour
+
+	err := retryWithPolicy(ctxt, policy, filesystem.ActionInfo{}, unmountOperation, "node1", func() error {
+		cancelFunc()
+		return errors.New("fake")
+	})
+
+	assert.Equal(t, "unmount attempt 1/3 on node1 failed: fake", err.Error())
+}
+
+func TestGetRetryPolicy(t *testing.T) {
+	assert.Equal(t, uint(5), getRetryPolicy(datamodel.SessionUnmount, unmountOperation).MaxAttempts)
+	assert.Equal(t, uint(10), getRetryPolicy(datamodel.SessionDelete, unmountOperation).MaxAttempts)
+	assert.Equal(t, uint(3), getRetryPolicy(datamodel.SessionDelete, copyOperation).MaxAttempts)
+	assert.Equal(t, uint(3), getRetryPolicy("", ansibleOperation).MaxAttempts)
+
+	assert.PanicsWithValue(t, "no retry policy for operation asdf", func() { getRetryPolicy("", "asdf") })
+}
+
+func TestIsRetryableCopyError(t *testing.T) {
+	assert.False(t, isRetryableCopyError(errors.New("fake")))
+	assert.True(t, isRetryableCopyError(exec.Command("sh", "-c", "exit 255").Run()))
+	assert.True(t, isRetryableCopyError(exec.Command("sh", "-c", "exit 23").Run()))
+	assert.False(t, isRetryableCopyError(exec.Command("sh", "-c", "exit 1").Run()))
+	assert.True(t, isSSHError(exec.Command("sh", "-c", "exit 255").Run()))
+	assert.False(t, isSSHError(exec.Command("sh", "-c", "exit 23").Run()))
+}
@@ -0,0 +1,368 @@
+package filesystem_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OpenSSH refuses more than 10 sessions on one connection by default
+const maxSessionsPerHost = 8
+
+const sshDialTimeout = time.Second * 30
+
+// How long a killed command has to stop, before its connection is closed
+const sshKillTimeout = time.Second * 5
+
+// Connections to hosts that are no longer used, such as compute nodes from old jobs, are closed
+const sshIdleTimeout = time.Minute * 5
+const sshIdleCheckInterval = time.Minute
+
+func getRunner(conf config.FilesystemConfig) Run {
+	if conf.SSHExecutor == "native" {
+		return newSSHRunner(conf)
+	}
+	return &run{}
+}
+
+type sshHost struct {
+	mutex    sync.Mutex
+	client   *ssh.Client
+	sessions chan struct{}
+
+	// Unix nanoseconds when the last command finished
+	lastUsed int64
+}
+
+// Keeps a connection open to each host, rather than starting ssh for every command,
+// and checks each host's key is in the known_hosts file
+type sshRunner struct {
+	conf config.FilesystemConfig
+	dial func(ctxt context.Context, network string, address string, config *ssh.ClientConfig) (*ssh.Client, error)
+
+	configOnce   sync.Once
+	clientConfig *ssh.ClientConfig
+	configErr    error
+
+	mutex sync.Mutex
+	hosts map[string]*sshHost
+	// Closed to stop closing idle connections, which starts with the first connection
+	stopIdleCheck chan struct{}
+}
+
+func newSSHRunner(conf config.FilesystemConfig) *sshRunner {
+	return &sshRunner{conf: conf, dial: dialContext, hosts: make(map[string]*sshHost)}
+}
+
+// Used like ssh exiting with 255, when the command may not have reached the host
+type sshConnectionError struct {
+	err error
+}
+
+func (e sshConnectionError) Error() string {
+	return e.err.Error()
+}
+
+func (r *sshRunner) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
+	_, err := r.Output(ctxt, hostname, asRoot, cmdStr)
+	return err
+}
+
+func (r *sshRunner) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	return observeRun(ctxt, hostname, cmdStr, func(ctxt context.Context) ([]byte, error) {
+		remoteCmd := cmdStr
+		if asRoot {
+			remoteCmd = "sudo " + cmdStr
+		}
+		return r.run(ctxt, hostname, remoteCmd)
+	})
+}
+
+func getDefaultKeyFiles(homeDir string) []string {
+	var keyFiles []string
+	for _, name := range []string{"id_rsa", "id_ecdsa", "id_ed25519"} {
+		keyFile := path.Join(homeDir, ".ssh", name)
+		if _, err := os.Stat(keyFile); err == nil {
+			keyFiles = append(keyFiles, keyFile)
+		}
+	}
+	return keyFiles
+}
+
+func getClientConfig(conf config.FilesystemConfig) (*ssh.ClientConfig, error) {
+	currentUser, err := user.Current()
+	if err != nil {
+		return nil, fmt.Errorf("unable to find current user due to: %s", err)
+	}
+	username := conf.SSHUser
+	if username == "" {
+		username = currentUser.Username
+	}
+	keyFiles := getDefaultKeyFiles(currentUser.HomeDir)
+	if conf.SSHKeyFile != "" {
+		keyFiles = []string{conf.SSHKeyFile}
+	}
+	if len(keyFiles) == 0 {
+		return nil, fmt.Errorf("no ssh key found in %s", path.Join(currentUser.HomeDir, ".ssh"))
+	}
+	knownHostsFile := conf.SSHKnownHostsFile
+	if knownHostsFile == "" {
+		knownHostsFile = path.Join(currentUser.HomeDir, ".ssh", "known_hosts")
+	}
+
+	var signers []ssh.Signer
+	for _, keyFile := range keyFiles {
+		key, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ssh key due to: %s", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ssh key %s due to: %s", keyFile, err)
+		}
+		signers = append(signers, signer)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read known hosts due to: %s", err)
+	}
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshDialTimeout,
+	}, nil
+}
+
+// Unlike ssh.Dial, stops connecting when the context is done
+func dialContext(ctxt context.Context, network string, address string,
+	config *ssh.ClientConfig) (*ssh.Client, error) {
+	dialer := net.Dialer{Timeout: config.Timeout}
+	conn, err := dialer.DialContext(ctxt, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	// The handshake ignores the context, so close the connection if it is done first
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func() {
+		select {
+		case <-ctxt.Done():
+			_ = conn.Close()
+		case <-handshakeDone:
+		}
+	}()
+	if config.Timeout > 0 {
+		_ = conn.SetDeadline(time.Now().Add(config.Timeout))
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		_ = conn.Close()
+		if ctxt.Err() != nil {
+			return nil, fmt.Errorf("%s, stopped due to: %s", err, ctxt.Err())
+		}
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+// Hosts can be given as host:port, otherwise the standard ssh port is used
+func getSSHAddress(hostname string) string {
+	if _, _, err := net.SplitHostPort(hostname); err == nil {
+		return hostname
+	}
+	return net.JoinHostPort(hostname, "22")
+}
+
+func (r *sshRunner) getHost(hostname string) *sshHost {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	host, ok := r.hosts[hostname]
+	if !ok {
+		host = &sshHost{sessions: make(chan struct{}, maxSessionsPerHost)}
+		r.hosts[hostname] = host
+	}
+	return host
+}
+
+func (r *sshRunner) startIdleCheck() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.stopIdleCheck != nil {
+		return
+	}
+	stop := make(chan struct{})
+	r.stopIdleCheck = stop
+	go func() {
+		ticker := time.NewTicker(sshIdleCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.closeIdleConnections(time.Now().Add(-sshIdleTimeout))
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stops checking for idle connections, and closes every connection no command is using
+func (r *sshRunner) Close() error {
+	r.mutex.Lock()
+	if r.stopIdleCheck != nil {
+		close(r.stopIdleCheck)
+		r.stopIdleCheck = nil
+	}
+	r.mutex.Unlock()
+	r.closeIdleConnections(time.Now())
+	return nil
+}
+
+// Closes any connections kept open by the runner, once no more commands will be run
+func CloseConnections() {
+	if closer, ok := runner.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
+
+// Close connections no command has used since the given time
+func (r *sshRunner) closeIdleConnections(idleSince time.Time) {
+	r.mutex.Lock()
+	hosts := make([]*sshHost, 0, len(r.hosts))
+	for _, host := range r.hosts {
+		hosts = append(hosts, host)
+	}
+	r.mutex.Unlock()
+
+	for _, host := range hosts {
+		host.mutex.Lock()
+		// A command holds a session slot from before it gets the client until it is done
+		if host.client != nil && len(host.sessions) == 0 &&
+			atomic.LoadInt64(&host.lastUsed) <= idleSince.UnixNano() {
+			_ = host.client.Close()
+			host.client = nil
+		}
+		host.mutex.Unlock()
+	}
+}
+
+// Keys and known hosts are read when first needed, so a bad file only fails the commands
+func (r *sshRunner) getClient(ctxt context.Context, hostname string, host *sshHost) (*ssh.Client, error) {
+	r.configOnce.Do(func() {
+		r.clientConfig, r.configErr = getClientConfig(r.conf)
+	})
+	if r.configErr != nil {
+		return nil, r.configErr
+	}
+
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	if host.client != nil {
+		return host.client, nil
+	}
+	client, err := r.dial(ctxt, "tcp", getSSHAddress(hostname), r.clientConfig)
+	if err != nil {
+		return nil, sshConnectionError{fmt.Errorf("unable to connect to %s due to: %s", hostname, err)}
+	}
+	host.client = client
+	r.startIdleCheck()
+	return client, nil
+}
+
+// Only closes the client if no one has replaced it already
+func (r *sshRunner) dropClient(host *sshHost, client *ssh.Client) {
+	host.mutex.Lock()
+	defer host.mutex.Unlock()
+	if host.client == client {
+		_ = client.Close()
+		host.client = nil
+	}
+}
+
+func (r *sshRunner) newSession(ctxt context.Context, hostname string,
+	host *sshHost) (*ssh.Client, *ssh.Session, error) {
+	client, err := r.getClient(ctxt, hostname, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err := client.NewSession()
+	if err == nil {
+		return client, session, nil
+	}
+
+	// The connection may have dropped since it was last used, so try a new one
+	r.dropClient(host, client)
+	client, err = r.getClient(ctxt, hostname, host)
+	if err != nil {
+		return nil, nil, err
+	}
+	session, err = client.NewSession()
+	if err != nil {
+		r.dropClient(host, client)
+		return nil, nil, sshConnectionError{
+			fmt.Errorf("unable to start ssh session on %s due to: %s", hostname, err)}
+	}
+	return client, session, nil
+}
+
+type sshResult struct {
+	output []byte
+	err    error
+}
+
+func (r *sshRunner) run(ctxt context.Context, hostname string, cmdStr string) ([]byte, error) {
+	host := r.getHost(hostname)
+	select {
+	case host.sessions <- struct{}{}:
+		defer func() {
+			atomic.StoreInt64(&host.lastUsed, time.Now().UnixNano())
+			<-host.sessions
+		}()
+	case <-ctxt.Done():
+		return nil, fmt.Errorf("stopped waiting for an ssh session on %s due to: %s", hostname, ctxt.Err())
+	}
+
+	client, session, err := r.newSession(ctxt, hostname, host)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	done := make(chan sshResult, 1)
+	go func() {
+		output, err := session.CombinedOutput(cmdStr)
+		done <- sshResult{output: output, err: err}
+	}()
+
+	select {
+	case result := <-done:
+		return result.output, result.err
+	case <-ctxt.Done():
+	}
+
+	// Not every server supports signals, closing the connection always stops the command,
+	// but also any other commands running on that host
+	_ = session.Signal(ssh.SIGKILL)
+	select {
+	case result := <-done:
+		return result.output, fmt.Errorf("killed ssh command: %v", result.err)
+	case <-time.After(sshKillTimeout):
+	}
+	r.dropClient(host, client)
+	result := <-done
+	return result.output, fmt.Errorf("closed ssh connection: %v", result.err)
+}
@@ -0,0 +1,255 @@
+package filesystem_impl
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T) (ssh.Signer, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	signer, err := ssh.NewSignerFromKey(key)
+	assert.Nil(t, err)
+	der, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	return signer, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// Runs each exec request by echoing the command back, failing any command starting with fail,
+// exiting with the given code for "exit <code>", and returning no exit status for "drop"
+type testSSHServer struct {
+	listener    net.Listener
+	config      *ssh.ServerConfig
+	connections int32
+	mutex       sync.Mutex
+	commands    []string
+}
+
+func startTestSSHServer(t *testing.T, hostSigner ssh.Signer, clientKey ssh.PublicKey) *testSSHServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	server := &testSSHServer{listener: listener, config: &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) || conn.User() != "dac" {
+				return nil, assert.AnError
+			}
+			return nil, nil
+		},
+	}}
+	server.config.AddHostKey(hostSigner)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+	return server
+}
+
+func (s *testSSHServer) serve(conn net.Conn) {
+	_, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		return
+	}
+	atomic.AddInt32(&s.connections, 1)
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer channel.Close()
+			for request := range channelRequests {
+				if request.Type != "exec" {
+					_ = request.Reply(false, nil)
+					continue
+				}
+				var payload struct{ Command string }
+				_ = ssh.Unmarshal(request.Payload, &payload)
+				_ = request.Reply(true, nil)
+				s.mutex.Lock()
+				s.commands = append(s.commands, payload.Command)
+				s.mutex.Unlock()
+
+				status := uint32(0)
+				if len(payload.Command) >= 4 && payload.Command[:4] == "fail" {
+					status = 1
+				}
+				_, _ = fmt.Sscanf(payload.Command, "exit %d", &status)
+				_, _ = channel.Write([]byte(payload.Command + "\n"))
+				if payload.Command == "drop" {
+					return
+				}
+				_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+				return
+			}
+		}()
+	}
+}
+
+func setupTestSSH(t *testing.T, knownHostKey ssh.PublicKey) (*sshRunner, *testSSHServer, string, func()) {
+	tmpDir, err := ioutil.TempDir("", "dac-ssh")
+	assert.Nil(t, err)
+	hostSigner, _ := newTestSigner(t)
+	clientSigner, clientKey := newTestSigner(t)
+	server := startTestSSHServer(t, hostSigner, clientSigner.PublicKey())
+	address := server.listener.Addr().String()
+
+	if knownHostKey == nil {
+		knownHostKey = hostSigner.PublicKey()
+	}
+	keyFile := path.Join(tmpDir, "id_ecdsa")
+	knownHostsFile := path.Join(tmpDir, "known_hosts")
+	assert.Nil(t, ioutil.WriteFile(keyFile, clientKey, 0600))
+	assert.Nil(t, ioutil.WriteFile(knownHostsFile,
+		[]byte(knownhosts.Line([]string{address}, knownHostKey)+"\n"), 0600))
+
+	sshRunner := newSSHRunner(config.FilesystemConfig{
+		SSHUser: "dac", SSHKeyFile: keyFile, SSHKnownHostsFile: knownHostsFile})
+	return sshRunner, server, address, func() {
+		server.listener.Close()
+		os.RemoveAll(tmpDir)
+	}
+}
+
+func TestSSHRunner_Output(t *testing.T) {
+	sshRunner, server, address, cleanup := setupTestSSH(t, nil)
+	defer cleanup()
+
+	output, err := sshRunner.Output(context.TODO(), address, false, "echo hello")
+	assert.Nil(t, err)
+	assert.Equal(t, "echo hello\n", output)
+
+	err = sshRunner.Execute(context.TODO(), address, true, "mkdir -p /mnt/dac")
+	assert.Nil(t, err)
+
+	output, err = sshRunner.Output(context.TODO(), address, false, "fail now")
+	assert.Equal(t, "Process exited with status 1", err.Error())
+	assert.Equal(t, "fail now\n", output)
+
+	assert.Equal(t, []string{"echo hello", "sudo mkdir -p /mnt/dac", "fail now"}, server.commands)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.connections))
+
+	// a dropped connection is replaced
+	_ = sshRunner.getHost(address).client.Close()
+	assert.Nil(t, sshRunner.Execute(context.TODO(), address, false, "echo again"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.connections))
+}
+
+func TestSSHRunner_CloseIdleConnections(t *testing.T) {
+	sshRunner, server, address, cleanup := setupTestSSH(t, nil)
+	defer cleanup()
+	assert.Nil(t, sshRunner.Execute(context.TODO(), address, false, "echo hello"))
+
+	// recently used connections are kept
+	sshRunner.closeIdleConnections(time.Now().Add(-time.Hour))
+	assert.NotNil(t, sshRunner.getHost(address).client)
+
+	sshRunner.closeIdleConnections(time.Now().Add(time.Hour))
+	assert.Nil(t, sshRunner.getHost(address).client)
+	assert.Nil(t, sshRunner.Execute(context.TODO(), address, false, "echo again"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.connections))
+
+	// idle connections are only checked for once there is a connection, until closed
+	assert.NotNil(t, sshRunner.stopIdleCheck)
+	assert.Nil(t, sshRunner.Close())
+	assert.Nil(t, sshRunner.stopIdleCheck)
+	assert.Nil(t, sshRunner.getHost(address).client)
+	assert.Nil(t, newSSHRunner(config.FilesystemConfig{}).stopIdleCheck)
+}
+
+func TestSSHRunner_Cancelled(t *testing.T) {
+	sshRunner, server, address, cleanup := setupTestSSH(t, nil)
+	defer cleanup()
+	ctxt, cancelFunc := context.WithCancel(context.Background())
+	cancelFunc()
+
+	_, err := sshRunner.getClient(ctxt, address, sshRunner.getHost(address))
+	assert.Contains(t, err.Error(), "unable to connect to "+address+" due to: ")
+	assert.Contains(t, err.Error(), "operation was canceled")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&server.connections))
+
+	// every session is in use
+	host := sshRunner.getHost(address)
+	for i := 0; i < maxSessionsPerHost; i++ {
+		host.sessions <- struct{}{}
+	}
+	err = sshRunner.Execute(ctxt, address, false, "echo hello")
+	assert.Contains(t, err.Error(), "stopped waiting for an ssh session on "+address+" due to: context canceled")
+}
+
+// Errors from the native runner are retried like those from running ssh
+func TestSSHRunner_RetryableErrors(t *testing.T) {
+	sshRunner, _, address, cleanup := setupTestSSH(t, nil)
+	defer cleanup()
+
+	err := sshRunner.Execute(context.TODO(), address, false, "exit 23")
+	assert.True(t, isRetryableCopyError(err))
+	assert.False(t, isSSHError(err))
+	err = sshRunner.Execute(context.TODO(), address, false, "exit 1")
+	assert.False(t, isRetryableCopyError(err))
+	// only ssh itself exits with 255 when it can't connect
+	err = sshRunner.Execute(context.TODO(), address, false, "exit 255")
+	assert.False(t, isSSHError(err))
+
+	err = sshRunner.Execute(context.TODO(), address, false, "drop")
+	assert.True(t, isSSHError(err))
+	assert.True(t, isRetryableCopyError(err))
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	closedAddress := listener.Addr().String()
+	listener.Close()
+	err = sshRunner.Execute(context.TODO(), closedAddress, false, "echo hello")
+	assert.Contains(t, err.Error(), "unable to connect to "+closedAddress)
+	assert.True(t, isSSHError(err))
+	assert.True(t, isRetryableCopyError(err))
+}
+
+func TestSSHRunner_UnknownHostKey(t *testing.T) {
+	otherSigner, _ := newTestSigner(t)
+	sshRunner, server, address, cleanup := setupTestSSH(t, otherSigner.PublicKey())
+	defer cleanup()
+
+	err := sshRunner.Execute(context.TODO(), address, false, "echo hello")
+	assert.Contains(t, err.Error(), "unable to connect to "+address+" due to: ")
+	assert.Contains(t, err.Error(), "knownhosts: key mismatch")
+	assert.Nil(t, server.commands)
+}
+
+func TestSSHRunner_BadConfig(t *testing.T) {
+	sshRunner := newSSHRunner(config.FilesystemConfig{SSHKeyFile: "/does/not/exist"})
+	err := sshRunner.Execute(context.TODO(), "host", false, "echo hello")
+	assert.Equal(t, "unable to read ssh key due to: open /does/not/exist: no such file or directory", err.Error())
+}
+
+func Test_getSSHAddress(t *testing.T) {
+	assert.Equal(t, "host:22", getSSHAddress("host"))
+	assert.Equal(t, "host:2222", getSSHAddress("host:2222"))
+}
+
+func Test_getRunner(t *testing.T) {
+	assert.IsType(t, &run{}, getRunner(config.FilesystemConfig{SSHExecutor: "exec"}))
+	assert.IsType(t, &sshRunner{}, getRunner(config.FilesystemConfig{SSHExecutor: "native"}))
+}
@@ -0,0 +1,39 @@
+package filesystem_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"log"
+	"time"
+)
+
+// Overwriting a large device can take a long time, the action's deadline still applies
+const wipeTimeout = time.Hour * 2
+
+func getWipeCommand(brick datamodel.Brick, policy datamodel.WipePolicy) string {
+	device := fmt.Sprintf("/dev/%s", brick.Device)
+	switch policy {
+	case datamodel.WipeDiscard:
+		return fmt.Sprintf("blkdiscard %s", device)
+	case datamodel.WipeOverwrite:
+		return fmt.Sprintf("shred --iterations=0 --zero %s", device)
+	case datamodel.WipeNone:
+		return ""
+	default:
+		log.Panicf("unknown wipe policy %s", policy)
+		return ""
+	}
+}
+
+func wipeBrick(ctxt context.Context, brick datamodel.Brick, policy datamodel.WipePolicy) error {
+	command := getWipeCommand(brick, policy)
+	if command == "" {
+		return nil
+	}
+	hostname := string(brick.BrickHostName)
+	ctxt = withCommandTimeout(ctxt, wipeTimeout)
+	return retry(ctxt, wipeOperation, hostname, func() error {
+		return runner.Execute(ctxt, hostname, true, command)
+	})
+}
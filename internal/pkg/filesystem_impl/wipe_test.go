@@ -0,0 +1,61 @@
+package filesystem_impl
+
+import (
+	"context"
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type timeoutRunner struct {
+	timeouts []time.Duration
+}
+
+func (r *timeoutRunner) Execute(ctxt context.Context, hostname string, asRoot bool, cmdStr string) error {
+	r.timeouts = append(r.timeouts, getCommandTimeout(ctxt))
+	return nil
+}
+
+func (r *timeoutRunner) Output(ctxt context.Context, hostname string, asRoot bool, cmdStr string) (string, error) {
+	return "", r.Execute(ctxt, hostname, asRoot, cmdStr)
+}
+
+func Test_wipeBrick(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &fakeRunner{}
+	runner = fake
+	brick := datamodel.Brick{BrickHostName: "dac1", Device: "nvme3n1"}
+
+	err := wipeBrick(context.TODO(), brick, datamodel.WipeNone)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, fake.calls)
+
+	err = wipeBrick(context.TODO(), brick, datamodel.WipeDiscard)
+	assert.Nil(t, err)
+	err = wipeBrick(context.TODO(), brick, datamodel.WipeOverwrite)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dac1", "dac1"}, fake.hostnames)
+	assert.Equal(t, []string{"blkdiscard /dev/nvme3n1", "shred --iterations=0 --zero /dev/nvme3n1"}, fake.cmdStrs)
+
+	runner = &fakeRunner{err: errors.New("expected")}
+	err = wipeBrick(context.TODO(), brick, datamodel.WipeDiscard)
+	assert.Equal(t, "wipe attempt 1/3 on dac1 failed: expected", err.Error())
+
+	assert.Panics(t, func() { _ = wipeBrick(context.TODO(), brick, "shred") })
+}
+
+func Test_wipeBrick_Timeout(t *testing.T) {
+	defer func() { runner = &run{} }()
+	fake := &timeoutRunner{}
+	runner = fake
+
+	err := wipeBrick(context.TODO(), datamodel.Brick{BrickHostName: "dac1", Device: "nvme3n1"},
+		datamodel.WipeOverwrite)
+	assert.Nil(t, err)
+	err = mkdir(context.TODO(), "host", "dir")
+	assert.Nil(t, err)
+
+	assert.Equal(t, []time.Duration{wipeTimeout, sshTimeout}, fake.timeouts)
+}
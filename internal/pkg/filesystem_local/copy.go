@@ -0,0 +1,62 @@
+package filesystem_local
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"os"
+	"strconv"
+)
+
+// Replaces $DW_ variables with the session's paths, leaving anything else alone
+func expandPath(session datamodel.Session, path string) string {
+	return os.Expand(path, func(name string) string {
+		if value, ok := session.Paths[name]; ok {
+			return value
+		}
+		return "$" + name
+	})
+}
+
+// Command that copies the data as the session's owner, sudo is only needed
+// if that is not the user running dacd
+func getCopyCommand(session datamodel.Session, request datamodel.DataCopyRequest,
+	uid int, gid int) ([]string, error) {
+	if request.Source == "" && request.Destination == "" {
+		return nil, nil
+	}
+	if !parsers.IsValidPath(request.Source) || !parsers.IsValidPath(request.Destination) {
+		return nil, fmt.Errorf("invalid path: %+v", request)
+	}
+
+	var command []string
+	if uid != int(session.Owner) || gid != int(session.Group) {
+		command = []string{"sudo", "-g", "#" + strconv.Itoa(int(session.Group)),
+			"-u", "#" + strconv.Itoa(int(session.Owner))}
+	}
+	command = append(command, "rsync")
+	switch request.SourceType {
+	case datamodel.Directory:
+		command = append(command, "-r", "-ospgu", "--stats")
+	case datamodel.File:
+		command = append(command, "-ospgu", "--stats")
+	default:
+		return nil, fmt.Errorf("unsupported source type %s for volume: %s", request.SourceType, session.Name)
+	}
+	return append(command, expandPath(session, request.Source), expandPath(session, request.Destination)), nil
+}
+
+func copyData(ctxt context.Context, session datamodel.Session, request datamodel.DataCopyRequest) error {
+	command, err := getCopyCommand(session, request, os.Getuid(), os.Getgid())
+	if err != nil {
+		return err
+	}
+	if command == nil {
+		logging.FromContext(ctxt).Info("no files to copy")
+		return nil
+	}
+	logging.FromContext(ctxt).WithField("command", command).Info("doing copy")
+	return runCommand(ctxt, command[0], command[1:]...)
+}
@@ -0,0 +1,42 @@
+package filesystem_local
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGetCopyCommand(t *testing.T) {
+	session := datamodel.Session{
+		Name:  "job1",
+		Owner: 1001,
+		Group: 1002,
+		Paths: map[string]string{"DW_JOB_STRIPED": "/mnt/dac/job1_job/global"},
+	}
+
+	command, err := getCopyCommand(session, datamodel.DataCopyRequest{}, 1001, 1002)
+	assert.Nil(t, err)
+	assert.Nil(t, command)
+
+	request := datamodel.DataCopyRequest{SourceType: datamodel.Directory,
+		Source: "/home/user/input", Destination: "$DW_JOB_STRIPED/input"}
+	command, err = getCopyCommand(session, request, 1001, 1002)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"rsync", "-r", "-ospgu", "--stats", "/home/user/input", "/mnt/dac/job1_job/global/input"},
+		command)
+
+	request = datamodel.DataCopyRequest{SourceType: datamodel.File,
+		Source: "$DW_JOB_STRIPED/output", Destination: "$HOME/output"}
+	command, err = getCopyCommand(session, request, 0, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sudo", "-g", "#1002", "-u", "#1001",
+		"rsync", "-ospgu", "--stats", "/mnt/dac/job1_job/global/output", "$HOME/output"}, command)
+
+	request.SourceType = datamodel.List
+	_, err = getCopyCommand(session, request, 1001, 1002)
+	assert.Equal(t, "unsupported source type list for volume: job1", err.Error())
+
+	request.Source = "/a;rm -rf /"
+	_, err = getCopyCommand(session, request, 1001, 1002)
+	assert.Contains(t, err.Error(), "invalid path: ")
+}
@@ -0,0 +1,173 @@
+package filesystem_local
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Runs a command on this host, stopped if the context is cancelled
+var runCommand = func(ctxt context.Context, name string, args ...string) error {
+	output, err := exec.CommandContext(ctxt, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %s, output: %s", name, strings.Join(args, " "), err,
+			strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func isBindMounted(ctxt context.Context, directory string) bool {
+	return runCommand(ctxt, "mountpoint", "-q", directory) == nil
+}
+
+func (p *localProvider) getMountDir(session datamodel.Session, attachment datamodel.AttachmentSession) string {
+	if session.VolumeRequest.MultiJob {
+		return path.Join(p.mountRoot,
+			fmt.Sprintf(datamodel.MountMultiJobBasePattern, attachment.SessionName, session.Name))
+	}
+	return path.Join(p.mountRoot, fmt.Sprintf(datamodel.MountJobBasePattern, session.Name))
+}
+
+func (p *localProvider) getPrivateLink(session datamodel.Session, attachment datamodel.AttachmentSession) string {
+	if session.VolumeRequest.MultiJob {
+		return path.Join(p.mountRoot,
+			fmt.Sprintf(datamodel.MountMultiJobPrivatePattern, attachment.SessionName, session.Name))
+	}
+	return path.Join(p.mountRoot, fmt.Sprintf(datamodel.MountPrivatePattern, session.Name))
+}
+
+// Replace any existing link, so a retried mount still works
+func replaceSymlink(target string, link string) error {
+	if err := os.Remove(link); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Symlink(target, link)
+}
+
+func makeOwnedDir(directory string, owner uint, group uint) error {
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return err
+	}
+	if err := os.Chown(directory, int(owner), int(group)); err != nil {
+		return err
+	}
+	return os.Chmod(directory, 0700)
+}
+
+// Every host shares this host's view of /mnt/dac, so the buffer only appears there once
+func (p *localProvider) mount(ctxt context.Context, session datamodel.Session, attachment datamodel.AttachmentSession,
+	setInitialPermissions bool) error {
+	if len(attachment.Hosts) < 1 {
+		logging.FromContext(ctxt).Info("skip mount as no hosts given")
+		return nil
+	}
+	// Every host would follow the same private link, so they can't each have their own directory
+	if attachment.PrivateMount && len(attachment.Hosts) > 1 {
+		return fmt.Errorf("private access is only supported on one host by the local provider, not %d",
+			len(attachment.Hosts))
+	}
+	bufferDir := p.getBufferDir(session)
+	mountDir := p.getMountDir(session, attachment)
+	logging.FromContext(ctxt).Infof("mounting %s at %s for session: %s", bufferDir, mountDir,
+		attachment.SessionName)
+
+	if err := os.MkdirAll(path.Dir(mountDir), 0755); err != nil {
+		return fmt.Errorf("unable to create mount directory due to: %s", err)
+	}
+	if p.bindMount {
+		if err := os.MkdirAll(mountDir, 0755); err != nil {
+			return fmt.Errorf("unable to create mount directory due to: %s", err)
+		}
+		if !isBindMounted(ctxt, mountDir) {
+			if err := runCommand(ctxt, "mount", "--bind", bufferDir, mountDir); err != nil {
+				return err
+			}
+		}
+	} else if err := replaceSymlink(bufferDir, mountDir); err != nil {
+		return fmt.Errorf("unable to link mount directory due to: %s", err)
+	}
+
+	if setInitialPermissions {
+		globalDir := path.Join(mountDir, datamodel.MountGlobalDir)
+		if err := makeOwnedDir(globalDir, session.Owner, session.Group); err != nil {
+			return fmt.Errorf("unable to create global directory due to: %s", err)
+		}
+	}
+
+	if attachment.PrivateMount {
+		privateDir := path.Join(mountDir, "private", attachment.Hosts[0])
+		if err := makeOwnedDir(privateDir, session.Owner, session.Group); err != nil {
+			return fmt.Errorf("unable to create private directory due to: %s", err)
+		}
+		if err := replaceSymlink(privateDir, p.getPrivateLink(session, attachment)); err != nil {
+			return fmt.Errorf("unable to link private directory due to: %s", err)
+		}
+	}
+	return nil
+}
+
+func (p *localProvider) unmount(ctxt context.Context, session datamodel.Session,
+	attachment datamodel.AttachmentSession) error {
+	if len(attachment.Hosts) < 1 {
+		return nil
+	}
+	mountDir := p.getMountDir(session, attachment)
+	logging.FromContext(ctxt).Infof("unmounting %s for session: %s", mountDir, attachment.SessionName)
+
+	if attachment.PrivateMount {
+		if err := os.Remove(p.getPrivateLink(session, attachment)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("unable to remove private link due to: %s", err)
+		}
+	}
+	if p.bindMount && isBindMounted(ctxt, mountDir) {
+		if err := runCommand(ctxt, "umount", mountDir); err != nil {
+			return err
+		}
+	}
+	// Only removes the link, or the now empty mount point
+	if err := os.Remove(mountDir); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove mount directory due to: %s", err)
+	}
+	return nil
+}
+
+func (p *localProvider) isMounted(ctxt context.Context, bufferDir string, mountDir string) bool {
+	if p.bindMount {
+		return isBindMounted(ctxt, mountDir)
+	}
+	target, err := os.Readlink(mountDir)
+	return err == nil && target == bufferDir
+}
+
+func (p *localProvider) checkMounts(ctxt context.Context, session datamodel.Session) error {
+	bufferDir := p.getBufferDir(session)
+	var missing []string
+	missingHosts := make(map[datamodel.SessionName][]string)
+	if _, err := os.Stat(bufferDir); err != nil {
+		missing = append(missing, bufferDir)
+	}
+	for _, key := range filesystem_impl.GetExpectedAttachments(session) {
+		attachment := session.CurrentAttachments[key]
+		if len(attachment.Hosts) == 0 {
+			continue
+		}
+		mountDir := p.getMountDir(session, attachment)
+		if !p.isMounted(ctxt, bufferDir, mountDir) {
+			missing = append(missing, mountDir)
+			missingHosts[key] = attachment.Hosts
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return filesystem.MissingMountsError{Missing: missing, Hosts: missingHosts}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package filesystem_local
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/filesystem_impl"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
+	"log"
+	"os"
+	"path"
+)
+
+// Name pools use to pick this provider, its settings are in the local_provider config section
+const ProviderName = "local"
+
+func init() {
+	filesystem.RegisterProvider(ProviderName, func(settings map[string]string) (filesystem.Provider, error) {
+		return newLocalProvider(settings["root_dir"], settings["mount_method"])
+	})
+}
+
+// Each buffer is a directory on the brick host, so everything happens on the host running dacd.
+// Only useful when the compute hosts share this host's view of root_dir and /mnt/dac,
+// such as a single node or containers sharing volumes. Nothing needs Lustre, ansible or ssh
+type localProvider struct {
+	rootDir   string
+	bindMount bool
+
+	// Added before the /mnt/dac paths, only set by tests
+	mountRoot string
+}
+
+// Every dacd creates every provider, so root_dir is only created when the first buffer is
+func newLocalProvider(rootDir string, mountMethod string) (*localProvider, error) {
+	return &localProvider{rootDir: rootDir, bindMount: mountMethod == "bind"}, nil
+}
+
+func (p *localProvider) getBufferDir(session datamodel.Session) string {
+	if session.FilesystemStatus.InternalName == "" {
+		// Would otherwise be the root_dir itself
+		log.Panicf("no filesystem recorded for session %s", session.Name)
+	}
+	return path.Join(p.rootDir, session.FilesystemStatus.InternalName)
+}
+
+func (p *localProvider) Create(ctxt context.Context, session datamodel.Session) (datamodel.FilesystemStatus, error) {
+	session.FilesystemStatus = datamodel.FilesystemStatus{InternalName: filesystem_impl.GetNewUUID()}
+	bufferDir := p.getBufferDir(session)
+	logging.FromContext(ctxt).Infof("creating buffer directory %s", bufferDir)
+	if err := os.MkdirAll(p.rootDir, 0755); err != nil {
+		return session.FilesystemStatus, fmt.Errorf("unable to create root_dir due to: %s", err)
+	}
+	if err := os.Mkdir(bufferDir, 0755); err != nil {
+		return session.FilesystemStatus, fmt.Errorf("unable to create buffer directory due to: %s", err)
+	}
+	return session.FilesystemStatus, nil
+}
+
+// The directory survives a restart, so there is only something to do if it has been lost
+func (p *localProvider) Restore(ctxt context.Context, session datamodel.Session) error {
+	bufferDir := p.getBufferDir(session)
+	info, err := os.Stat(bufferDir)
+	if err != nil {
+		return fmt.Errorf("unable to restore buffer directory due to: %s", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("unable to restore buffer, %s is not a directory", bufferDir)
+	}
+	return nil
+}
+
+func (p *localProvider) Delete(ctxt context.Context, session datamodel.Session) error {
+	if session.FilesystemStatus.InternalName == "" {
+		logging.FromContext(ctxt).Info("skip delete, as no buffer directory was created")
+		return nil
+	}
+	bufferDir := p.getBufferDir(session)
+	logging.FromContext(ctxt).Infof("removing buffer directory %s", bufferDir)
+	if err := os.RemoveAll(bufferDir); err != nil {
+		return fmt.Errorf("unable to remove buffer directory due to: %s", err)
+	}
+	return nil
+}
+
+// A directory has no fixed size, so the new bricks only add to the buffer's share of the pool
+func (p *localProvider) Expand(ctxt context.Context, session datamodel.Session, newBricks []datamodel.Brick) error {
+	return nil
+}
+
+func (p *localProvider) DataCopyIn(ctxt context.Context, session datamodel.Session) error {
+	for _, request := range session.StageInRequests {
+		if err := copyData(ctxt, session, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *localProvider) DataCopyOut(ctxt context.Context, session datamodel.Session) error {
+	for _, request := range session.StageOutRequests {
+		if err := copyData(ctxt, session, request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *localProvider) Mount(ctxt context.Context, session datamodel.Session, attachment datamodel.AttachmentSession,
+	setInitialPermissions bool) error {
+	return p.mount(ctxt, session, attachment, setInitialPermissions)
+}
+
+func (p *localProvider) Unmount(ctxt context.Context, session datamodel.Session,
+	attachment datamodel.AttachmentSession) error {
+	return p.unmount(ctxt, session, attachment)
+}
+
+func (p *localProvider) CheckMounts(ctxt context.Context, session datamodel.Session) error {
+	return p.checkMounts(ctxt, session)
+}
+
+// Buffers don't write to the bricks, their data is removed with the buffer directory
+func (p *localProvider) Wipe(ctxt context.Context, brick datamodel.Brick, policy datamodel.WipePolicy) error {
+	return nil
+}
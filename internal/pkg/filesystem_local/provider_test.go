@@ -0,0 +1,125 @@
+package filesystem_local
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func setupProvider(t *testing.T) (*localProvider, string) {
+	tmpDir, err := ioutil.TempDir("", "dac-local")
+	assert.Nil(t, err)
+	provider, err := newLocalProvider(path.Join(tmpDir, "buffers"), "symlink")
+	assert.Nil(t, err)
+	provider.mountRoot = tmpDir
+	return provider, tmpDir
+}
+
+func TestLocalProvider_Lifecycle(t *testing.T) {
+	provider, tmpDir := setupProvider(t)
+	defer os.RemoveAll(tmpDir)
+	ctxt := context.TODO()
+	session := datamodel.Session{Name: "job1", Owner: uint(os.Getuid()), Group: uint(os.Getgid())}
+
+	status, err := provider.Create(ctxt, session)
+	assert.Nil(t, err)
+	assert.Equal(t, 8, len(status.InternalName))
+	session.FilesystemStatus = status
+	bufferDir := path.Join(tmpDir, "buffers", status.InternalName)
+	assert.DirExists(t, bufferDir)
+	assert.Nil(t, provider.Restore(ctxt, session))
+
+	attachment := datamodel.AttachmentSession{SessionName: "job1", Hosts: []string{"node1"}, PrivateMount: true}
+	session.CurrentAttachments = map[datamodel.SessionName]datamodel.AttachmentSession{"job1": attachment}
+	session.Status.MountComplete = true
+	assert.Equal(t, "expected mounts missing: "+tmpDir+"/mnt/dac/job1_job",
+		provider.CheckMounts(ctxt, session).Error())
+
+	err = provider.Mount(ctxt, session, attachment, true)
+	assert.Nil(t, err)
+	mountDir := path.Join(tmpDir, "mnt/dac/job1_job")
+	target, err := os.Readlink(mountDir)
+	assert.Nil(t, err)
+	assert.Equal(t, bufferDir, target)
+	assert.DirExists(t, path.Join(bufferDir, "global"))
+	target, err = os.Readlink(path.Join(tmpDir, "mnt/dac/job1_job_private"))
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(mountDir, "private/node1"), target)
+	assert.DirExists(t, path.Join(bufferDir, "private/node1"))
+	assert.Nil(t, provider.CheckMounts(ctxt, session))
+
+	// a retried mount still works
+	assert.Nil(t, provider.Mount(ctxt, session, attachment, true))
+
+	assert.Nil(t, provider.Unmount(ctxt, session, attachment))
+	_, err = os.Lstat(mountDir)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Lstat(path.Join(tmpDir, "mnt/dac/job1_job_private"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, provider.Unmount(ctxt, session, attachment))
+
+	assert.Nil(t, provider.Delete(ctxt, session))
+	_, err = os.Stat(bufferDir)
+	assert.True(t, os.IsNotExist(err))
+	assert.Contains(t, provider.Restore(ctxt, session).Error(), "unable to restore buffer directory due to: ")
+}
+
+func TestLocalProvider_MultiJob(t *testing.T) {
+	provider, tmpDir := setupProvider(t)
+	defer os.RemoveAll(tmpDir)
+	ctxt := context.TODO()
+	session := datamodel.Session{Name: "persistent", VolumeRequest: datamodel.VolumeRequest{MultiJob: true},
+		FilesystemStatus: datamodel.FilesystemStatus{InternalName: "fsuuid"}}
+	attachment := datamodel.AttachmentSession{SessionName: "job2", Hosts: []string{"node1"}, GlobalMount: true}
+
+	_, err := provider.Create(ctxt, datamodel.Session{Name: "other"})
+	assert.Nil(t, err)
+	assert.Nil(t, os.Mkdir(provider.getBufferDir(session), 0755))
+
+	assert.Nil(t, provider.Mount(ctxt, session, attachment, false))
+	target, err := os.Readlink(path.Join(tmpDir, "mnt/dac/job2_persistent_persistent"))
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(tmpDir, "buffers/fsuuid"), target)
+
+	assert.Nil(t, provider.Unmount(ctxt, session, attachment))
+
+	// private directories are kept in the buffer, so the next job sees the same one
+	session.Owner = uint(os.Getuid())
+	session.Group = uint(os.Getgid())
+	attachment.PrivateMount = true
+	assert.Nil(t, provider.Mount(ctxt, session, attachment, false))
+	privateLink := path.Join(tmpDir, "mnt/dac/job2_persistent_persistent_private")
+	target, err = os.Readlink(privateLink)
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(tmpDir, "mnt/dac/job2_persistent_persistent/private/node1"), target)
+	assert.DirExists(t, path.Join(tmpDir, "buffers/fsuuid/private/node1"))
+	assert.Nil(t, provider.Unmount(ctxt, session, attachment))
+	_, err = os.Lstat(privateLink)
+	assert.True(t, os.IsNotExist(err))
+	assert.DirExists(t, path.Join(tmpDir, "buffers/fsuuid/private/node1"))
+
+	assert.Nil(t, provider.Delete(ctxt, datamodel.Session{Name: "not-created"}))
+}
+
+func TestLocalProvider_PrivateManyHosts(t *testing.T) {
+	provider, tmpDir := setupProvider(t)
+	defer os.RemoveAll(tmpDir)
+	ctxt := context.TODO()
+	session := datamodel.Session{Name: "job1", FilesystemStatus: datamodel.FilesystemStatus{InternalName: "fsuuid"}}
+	attachment := datamodel.AttachmentSession{SessionName: "job1", Hosts: []string{"node1", "node2"},
+		PrivateMount: true}
+
+	// the hosts would all share one private directory
+	err := provider.Mount(ctxt, session, attachment, true)
+	assert.Equal(t, "private access is only supported on one host by the local provider, not 2", err.Error())
+	_, err = os.Lstat(path.Join(tmpDir, "mnt/dac/job1_job"))
+	assert.True(t, os.IsNotExist(err))
+
+	attachment.PrivateMount = false
+	assert.Nil(t, os.MkdirAll(provider.getBufferDir(session), 0755))
+	assert.Nil(t, provider.Mount(ctxt, session, attachment, false))
+}
@@ -0,0 +1,98 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io"
+	"log"
+	"strings"
+)
+
+type Fields = logrus.Fields
+
+// Fields added to every line, as needed
+const (
+	ComponentField  = "component"
+	HostField       = "host"
+	CallerField     = "caller"
+	SessionField    = "session"
+	ActionField     = "action"
+	ActionUuidField = "action_uuid"
+	TaskField       = "task"
+)
+
+var logger = logrus.New()
+
+// Fields for the whole process, e.g. the component and host
+var baseEntry = logrus.NewEntry(logger)
+
+// Send all logging to out, at the given level and format (json or text),
+// adding the given fields to every line.
+// Anything still using the standard log package is sent to the same place.
+func Setup(out io.Writer, level string, format string, fields Fields) error {
+	logLevel, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+		logger.SetFormatter(&logrus.TextFormatter{DisableColors: true, FullTimestamp: true})
+	default:
+		return fmt.Errorf("unknown log format: %s", format)
+	}
+	logger.SetOutput(out)
+	logger.SetLevel(logLevel)
+	baseEntry = logrus.NewEntry(logger).WithFields(fields)
+
+	log.SetFlags(0)
+	log.SetOutput(stdLogWriter{})
+	return nil
+}
+
+// Adds the process wide fields, e.g. once the hostname is known
+func AddFields(fields Fields) {
+	baseEntry = baseEntry.WithFields(fields)
+}
+
+type stdLogWriter struct{}
+
+// Older log lines mark their level with a prefix, e.g. "WARNING ..."
+func (stdLogWriter) Write(p []byte) (int, error) {
+	message := strings.TrimSuffix(string(p), "\n")
+	level := logrus.InfoLevel
+	upper := strings.ToUpper(message)
+	if strings.HasPrefix(upper, "ERROR") {
+		level = logrus.ErrorLevel
+	} else if strings.HasPrefix(upper, "WARN") {
+		level = logrus.WarnLevel
+	}
+	baseEntry.Log(level, message)
+	return len(p), nil
+}
+
+type fieldsKey struct{}
+
+// Returns a context whose logger adds the given fields,
+// on top of any fields already in the context
+func WithFields(ctxt context.Context, fields Fields) context.Context {
+	return context.WithValue(ctxt, fieldsKey{}, FromContext(ctxt).WithFields(fields))
+}
+
+// Get the logger for the context, which includes the process wide fields
+func FromContext(ctxt context.Context) *logrus.Entry {
+	if ctxt != nil {
+		if entry, ok := ctxt.Value(fieldsKey{}).(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return baseEntry
+}
+
+// Get the Slurm function (or other caller) that started the work, if known
+func GetCaller(ctxt context.Context) string {
+	caller, _ := FromContext(ctxt).Data[CallerField].(string)
+	return caller
+}
@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"log"
+	"os"
+	"strings"
+	"testing"
+)
+
+func resetLogging() {
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.LstdFlags)
+	logger.SetOutput(os.Stderr)
+	baseEntry = logger.WithFields(Fields{})
+}
+
+func readLines(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		fields := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, fields)
+	}
+	return lines
+}
+
+func TestSetup(t *testing.T) {
+	defer resetLogging()
+	buffer := &bytes.Buffer{}
+	err := Setup(buffer, "info", "json", Fields{ComponentField: "dacd"})
+	assert.Nil(t, err)
+	AddFields(Fields{HostField: "dac1"})
+
+	log.Println("WARNING something odd")
+	ctxt := WithFields(context.Background(), Fields{SessionField: "job1", ActionUuidField: "uuid1"})
+	ctxt = WithFields(ctxt, Fields{CallerField: "pre_run:1234"})
+	FromContext(ctxt).Info("starting action")
+	FromContext(ctxt).Debug("not logged")
+
+	lines := readLines(t, buffer)
+	assert.Equal(t, 2, len(lines))
+	assert.Equal(t, "WARNING something odd", lines[0]["msg"])
+	assert.Equal(t, "warning", lines[0]["level"])
+	assert.Equal(t, "dacd", lines[0][ComponentField])
+	assert.Equal(t, "dac1", lines[0][HostField])
+	assert.Equal(t, "starting action", lines[1]["msg"])
+	assert.Equal(t, "info", lines[1]["level"])
+	assert.Equal(t, "job1", lines[1][SessionField])
+	assert.Equal(t, "uuid1", lines[1][ActionUuidField])
+	assert.Equal(t, "pre_run:1234", lines[1][CallerField])
+	assert.Equal(t, "dac1", lines[1][HostField])
+}
+
+func TestSetup_Errors(t *testing.T) {
+	defer resetLogging()
+	assert.Equal(t, "not a valid logrus Level: \"loud\"", Setup(os.Stderr, "loud", "json", nil).Error())
+	assert.Equal(t, "unknown log format: xml", Setup(os.Stderr, "info", "xml", nil).Error())
+}
+
+func TestGetCaller(t *testing.T) {
+	defer resetLogging()
+	assert.Equal(t, "", GetCaller(context.Background()))
+
+	AddFields(Fields{CallerField: "setup:42"})
+	assert.Equal(t, "setup:42", GetCaller(context.Background()))
+
+	ctxt := WithFields(context.Background(), Fields{CallerField: "data_in:42"})
+	assert.Equal(t, "data_in:42", GetCaller(ctxt))
+}
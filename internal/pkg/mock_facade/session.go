@@ -47,6 +47,20 @@ func (mr *MockSessionMockRecorder) CreateSession(session interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockSession)(nil).CreateSession), session)
 }
 
+// ExpandSession mocks base method
+func (m *MockSession) ExpandSession(sessionName datamodel.SessionName, poolName datamodel.PoolName, extraCapacityBytes int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExpandSession", sessionName, poolName, extraCapacityBytes)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ExpandSession indicates an expected call of ExpandSession
+func (mr *MockSessionMockRecorder) ExpandSession(sessionName, poolName, extraCapacityBytes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpandSession", reflect.TypeOf((*MockSession)(nil).ExpandSession), sessionName, poolName, extraCapacityBytes)
+}
+
 // DeleteSession mocks base method
 func (m *MockSession) DeleteSession(sessionName datamodel.SessionName, hurry bool) error {
 	m.ctrl.T.Helper()
@@ -132,6 +146,36 @@ func (mr *MockSessionMockRecorder) GetPools() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPools", reflect.TypeOf((*MockSession)(nil).GetPools))
 }
 
+// GetPoolReports mocks base method
+func (m *MockSession) GetPoolReports() ([]datamodel.PoolReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPoolReports")
+	ret0, _ := ret[0].([]datamodel.PoolReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPoolReports indicates an expected call of GetPoolReports
+func (mr *MockSessionMockRecorder) GetPoolReports() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPoolReports", reflect.TypeOf((*MockSession)(nil).GetPoolReports))
+}
+
+// CheckCapacity mocks base method
+func (m *MockSession) CheckCapacity(poolName datamodel.PoolName, capacityBytes int) (datamodel.PoolCapacityCheck, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckCapacity", poolName, capacityBytes)
+	ret0, _ := ret[0].(datamodel.PoolCapacityCheck)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckCapacity indicates an expected call of CheckCapacity
+func (mr *MockSessionMockRecorder) CheckCapacity(poolName, capacityBytes interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckCapacity", reflect.TypeOf((*MockSession)(nil).CheckCapacity), poolName, capacityBytes)
+}
+
 // GetSession mocks base method
 func (m *MockSession) GetSession(sessionName datamodel.SessionName) (datamodel.Session, error) {
 	m.ctrl.T.Helper()
@@ -162,6 +206,79 @@ func (mr *MockSessionMockRecorder) GetAllSessions() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAllSessions", reflect.TypeOf((*MockSession)(nil).GetAllSessions))
 }
 
+// GetClusterConfig mocks base method
+func (m *MockSession) GetClusterConfig() (datamodel.ClusterConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClusterConfig")
+	ret0, _ := ret[0].(datamodel.ClusterConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClusterConfig indicates an expected call of GetClusterConfig
+func (mr *MockSessionMockRecorder) GetClusterConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClusterConfig", reflect.TypeOf((*MockSession)(nil).GetClusterConfig))
+}
+
+// UpdateClusterConfig mocks base method
+func (m *MockSession) UpdateClusterConfig(clusterConfig datamodel.ClusterConfig) (datamodel.ClusterConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateClusterConfig", clusterConfig)
+	ret0, _ := ret[0].(datamodel.ClusterConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateClusterConfig indicates an expected call of UpdateClusterConfig
+func (mr *MockSessionMockRecorder) UpdateClusterConfig(clusterConfig interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateClusterConfig", reflect.TypeOf((*MockSession)(nil).UpdateClusterConfig), clusterConfig)
+}
+
+// GetUnhealthyBricks mocks base method
+func (m *MockSession) GetUnhealthyBricks() ([]datamodel.UnhealthyBrick, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnhealthyBricks")
+	ret0, _ := ret[0].([]datamodel.UnhealthyBrick)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnhealthyBricks indicates an expected call of GetUnhealthyBricks
+func (mr *MockSessionMockRecorder) GetUnhealthyBricks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnhealthyBricks", reflect.TypeOf((*MockSession)(nil).GetUnhealthyBricks))
+}
+
+// ClearUnhealthyBrick mocks base method
+func (m *MockSession) ClearUnhealthyBrick(brick datamodel.Brick) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearUnhealthyBrick", brick)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearUnhealthyBrick indicates an expected call of ClearUnhealthyBrick
+func (mr *MockSessionMockRecorder) ClearUnhealthyBrick(brick interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearUnhealthyBrick", reflect.TypeOf((*MockSession)(nil).ClearUnhealthyBrick), brick)
+}
+
+// CancelSession mocks base method
+func (m *MockSession) CancelSession(sessionName datamodel.SessionName) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSession", sessionName)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CancelSession indicates an expected call of CancelSession
+func (mr *MockSessionMockRecorder) CancelSession(sessionName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSession", reflect.TypeOf((*MockSession)(nil).CancelSession), sessionName)
+}
+
 // GenerateAnsible mocks base method
 func (m *MockSession) GenerateAnsible(sessionName datamodel.SessionName) (string, error) {
 	m.ctrl.T.Helper()
@@ -5,6 +5,7 @@
 package mock_facade
 
 import (
+	context "context"
 	datamodel "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -46,13 +47,25 @@ func (mr *MockSessionActionHandlerMockRecorder) ProcessSessionAction(action inte
 }
 
 // RestoreSession mocks base method
-func (m *MockSessionActionHandler) RestoreSession(session datamodel.Session) {
+func (m *MockSessionActionHandler) RestoreSession(ctxt context.Context, session datamodel.Session) {
 	m.ctrl.T.Helper()
-	m.ctrl.Call(m, "RestoreSession", session)
+	m.ctrl.Call(m, "RestoreSession", ctxt, session)
 }
 
 // RestoreSession indicates an expected call of RestoreSession
-func (mr *MockSessionActionHandlerMockRecorder) RestoreSession(session interface{}) *gomock.Call {
+func (mr *MockSessionActionHandlerMockRecorder) RestoreSession(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSession", reflect.TypeOf((*MockSessionActionHandler)(nil).RestoreSession), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreSession", reflect.TypeOf((*MockSessionActionHandler)(nil).RestoreSession), ctxt, session)
+}
+
+// ReconcileSession mocks base method
+func (m *MockSessionActionHandler) ReconcileSession(ctxt context.Context, sessionName datamodel.SessionName, reportOnly bool) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "ReconcileSession", ctxt, sessionName, reportOnly)
+}
+
+// ReconcileSession indicates an expected call of ReconcileSession
+func (mr *MockSessionActionHandlerMockRecorder) ReconcileSession(ctxt, sessionName, reportOnly interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReconcileSession", reflect.TypeOf((*MockSessionActionHandler)(nil).ReconcileSession), ctxt, sessionName, reportOnly)
 }
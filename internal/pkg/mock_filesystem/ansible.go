@@ -5,6 +5,7 @@
 package mock_filesystem
 
 import (
+	context "context"
 	datamodel "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -34,16 +35,16 @@ func (m *MockAnsible) EXPECT() *MockAnsibleMockRecorder {
 }
 
 // CreateEnvironment mocks base method
-func (m *MockAnsible) CreateEnvironment(session datamodel.Session) (string, error) {
+func (m *MockAnsible) CreateEnvironment(ctxt context.Context, session datamodel.Session) (string, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CreateEnvironment", session)
+	ret := m.ctrl.Call(m, "CreateEnvironment", ctxt, session)
 	ret0, _ := ret[0].(string)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // CreateEnvironment indicates an expected call of CreateEnvironment
-func (mr *MockAnsibleMockRecorder) CreateEnvironment(session interface{}) *gomock.Call {
+func (mr *MockAnsibleMockRecorder) CreateEnvironment(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEnvironment", reflect.TypeOf((*MockAnsible)(nil).CreateEnvironment), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateEnvironment", reflect.TypeOf((*MockAnsible)(nil).CreateEnvironment), ctxt, session)
 }
@@ -5,6 +5,7 @@
 package mock_filesystem
 
 import (
+	context "context"
 	datamodel "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
@@ -34,100 +35,142 @@ func (m *MockProvider) EXPECT() *MockProviderMockRecorder {
 }
 
 // Create mocks base method
-func (m *MockProvider) Create(session datamodel.Session) (datamodel.FilesystemStatus, error) {
+func (m *MockProvider) Create(ctxt context.Context, session datamodel.Session) (datamodel.FilesystemStatus, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", session)
+	ret := m.ctrl.Call(m, "Create", ctxt, session)
 	ret0, _ := ret[0].(datamodel.FilesystemStatus)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create
-func (mr *MockProviderMockRecorder) Create(session interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) Create(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockProvider)(nil).Create), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockProvider)(nil).Create), ctxt, session)
 }
 
 // Restore mocks base method
-func (m *MockProvider) Restore(session datamodel.Session) error {
+func (m *MockProvider) Restore(ctxt context.Context, session datamodel.Session) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Restore", session)
+	ret := m.ctrl.Call(m, "Restore", ctxt, session)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Restore indicates an expected call of Restore
-func (mr *MockProviderMockRecorder) Restore(session interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) Restore(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockProvider)(nil).Restore), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockProvider)(nil).Restore), ctxt, session)
 }
 
 // Delete mocks base method
-func (m *MockProvider) Delete(session datamodel.Session) error {
+func (m *MockProvider) Delete(ctxt context.Context, session datamodel.Session) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", session)
+	ret := m.ctrl.Call(m, "Delete", ctxt, session)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete
-func (mr *MockProviderMockRecorder) Delete(session interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) Delete(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockProvider)(nil).Delete), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockProvider)(nil).Delete), ctxt, session)
+}
+
+// Expand mocks base method
+func (m *MockProvider) Expand(ctxt context.Context, session datamodel.Session, newBricks []datamodel.Brick) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Expand", ctxt, session, newBricks)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Expand indicates an expected call of Expand
+func (mr *MockProviderMockRecorder) Expand(ctxt, session, newBricks interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Expand", reflect.TypeOf((*MockProvider)(nil).Expand), ctxt, session, newBricks)
 }
 
 // DataCopyIn mocks base method
-func (m *MockProvider) DataCopyIn(session datamodel.Session) error {
+func (m *MockProvider) DataCopyIn(ctxt context.Context, session datamodel.Session) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DataCopyIn", session)
+	ret := m.ctrl.Call(m, "DataCopyIn", ctxt, session)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DataCopyIn indicates an expected call of DataCopyIn
-func (mr *MockProviderMockRecorder) DataCopyIn(session interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) DataCopyIn(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DataCopyIn", reflect.TypeOf((*MockProvider)(nil).DataCopyIn), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DataCopyIn", reflect.TypeOf((*MockProvider)(nil).DataCopyIn), ctxt, session)
 }
 
 // DataCopyOut mocks base method
-func (m *MockProvider) DataCopyOut(session datamodel.Session) error {
+func (m *MockProvider) DataCopyOut(ctxt context.Context, session datamodel.Session) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DataCopyOut", session)
+	ret := m.ctrl.Call(m, "DataCopyOut", ctxt, session)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // DataCopyOut indicates an expected call of DataCopyOut
-func (mr *MockProviderMockRecorder) DataCopyOut(session interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) DataCopyOut(ctxt, session interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DataCopyOut", reflect.TypeOf((*MockProvider)(nil).DataCopyOut), session)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DataCopyOut", reflect.TypeOf((*MockProvider)(nil).DataCopyOut), ctxt, session)
 }
 
 // Mount mocks base method
-func (m *MockProvider) Mount(session datamodel.Session, attachments datamodel.AttachmentSession, setInitialPermissions bool) error {
+func (m *MockProvider) Mount(ctxt context.Context, session datamodel.Session, attachments datamodel.AttachmentSession, setInitialPermissions bool) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Mount", session, attachments, setInitialPermissions)
+	ret := m.ctrl.Call(m, "Mount", ctxt, session, attachments, setInitialPermissions)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Mount indicates an expected call of Mount
-func (mr *MockProviderMockRecorder) Mount(session, attachments, setInitialPermissions interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) Mount(ctxt, session, attachments, setInitialPermissions interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mount", reflect.TypeOf((*MockProvider)(nil).Mount), session, attachments, setInitialPermissions)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Mount", reflect.TypeOf((*MockProvider)(nil).Mount), ctxt, session, attachments, setInitialPermissions)
 }
 
 // Unmount mocks base method
-func (m *MockProvider) Unmount(session datamodel.Session, attachments datamodel.AttachmentSession) error {
+func (m *MockProvider) Unmount(ctxt context.Context, session datamodel.Session, attachments datamodel.AttachmentSession) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Unmount", session, attachments)
+	ret := m.ctrl.Call(m, "Unmount", ctxt, session, attachments)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Unmount indicates an expected call of Unmount
-func (mr *MockProviderMockRecorder) Unmount(session, attachments interface{}) *gomock.Call {
+func (mr *MockProviderMockRecorder) Unmount(ctxt, session, attachments interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unmount", reflect.TypeOf((*MockProvider)(nil).Unmount), ctxt, session, attachments)
+}
+
+// CheckMounts mocks base method
+func (m *MockProvider) CheckMounts(ctxt context.Context, session datamodel.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckMounts", ctxt, session)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckMounts indicates an expected call of CheckMounts
+func (mr *MockProviderMockRecorder) CheckMounts(ctxt, session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckMounts", reflect.TypeOf((*MockProvider)(nil).CheckMounts), ctxt, session)
+}
+
+// Wipe mocks base method
+func (m *MockProvider) Wipe(ctxt context.Context, brick datamodel.Brick, policy datamodel.WipePolicy) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Wipe", ctxt, brick, policy)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Wipe indicates an expected call of Wipe
+func (mr *MockProviderMockRecorder) Wipe(ctxt, brick, policy interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unmount", reflect.TypeOf((*MockProvider)(nil).Unmount), session, attachments)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wipe", reflect.TypeOf((*MockProvider)(nil).Wipe), ctxt, brick, policy)
 }
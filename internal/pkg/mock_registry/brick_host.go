@@ -91,3 +91,46 @@ func (mr *MockBrickHostRegistryMockRecorder) IsBrickHostAlive(brickHostName inte
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsBrickHostAlive", reflect.TypeOf((*MockBrickHostRegistry)(nil).IsBrickHostAlive), brickHostName)
 }
+
+// MarkBrickUnhealthy mocks base method
+func (m *MockBrickHostRegistry) MarkBrickUnhealthy(unhealthyBrick datamodel.UnhealthyBrick) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkBrickUnhealthy", unhealthyBrick)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkBrickUnhealthy indicates an expected call of MarkBrickUnhealthy
+func (mr *MockBrickHostRegistryMockRecorder) MarkBrickUnhealthy(unhealthyBrick interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkBrickUnhealthy", reflect.TypeOf((*MockBrickHostRegistry)(nil).MarkBrickUnhealthy), unhealthyBrick)
+}
+
+// GetUnhealthyBricks mocks base method
+func (m *MockBrickHostRegistry) GetUnhealthyBricks() ([]datamodel.UnhealthyBrick, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUnhealthyBricks")
+	ret0, _ := ret[0].([]datamodel.UnhealthyBrick)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUnhealthyBricks indicates an expected call of GetUnhealthyBricks
+func (mr *MockBrickHostRegistryMockRecorder) GetUnhealthyBricks() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUnhealthyBricks", reflect.TypeOf((*MockBrickHostRegistry)(nil).GetUnhealthyBricks))
+}
+
+// ClearUnhealthyBrick mocks base method
+func (m *MockBrickHostRegistry) ClearUnhealthyBrick(brick datamodel.Brick) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearUnhealthyBrick", brick)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearUnhealthyBrick indicates an expected call of ClearUnhealthyBrick
+func (mr *MockBrickHostRegistryMockRecorder) ClearUnhealthyBrick(brick interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearUnhealthyBrick", reflect.TypeOf((*MockBrickHostRegistry)(nil).ClearUnhealthyBrick), brick)
+}
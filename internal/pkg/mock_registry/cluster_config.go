@@ -0,0 +1,79 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/pkg/registry/cluster_config.go
+
+// Package mock_registry is a generated GoMock package.
+package mock_registry
+
+import (
+	context "context"
+	datamodel "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockClusterConfigRegistry is a mock of ClusterConfigRegistry interface
+type MockClusterConfigRegistry struct {
+	ctrl     *gomock.Controller
+	recorder *MockClusterConfigRegistryMockRecorder
+}
+
+// MockClusterConfigRegistryMockRecorder is the mock recorder for MockClusterConfigRegistry
+type MockClusterConfigRegistryMockRecorder struct {
+	mock *MockClusterConfigRegistry
+}
+
+// NewMockClusterConfigRegistry creates a new mock instance
+func NewMockClusterConfigRegistry(ctrl *gomock.Controller) *MockClusterConfigRegistry {
+	mock := &MockClusterConfigRegistry{ctrl: ctrl}
+	mock.recorder = &MockClusterConfigRegistryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockClusterConfigRegistry) EXPECT() *MockClusterConfigRegistryMockRecorder {
+	return m.recorder
+}
+
+// GetClusterConfig mocks base method
+func (m *MockClusterConfigRegistry) GetClusterConfig() (datamodel.ClusterConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClusterConfig")
+	ret0, _ := ret[0].(datamodel.ClusterConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClusterConfig indicates an expected call of GetClusterConfig
+func (mr *MockClusterConfigRegistryMockRecorder) GetClusterConfig() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClusterConfig", reflect.TypeOf((*MockClusterConfigRegistry)(nil).GetClusterConfig))
+}
+
+// UpdateClusterConfig mocks base method
+func (m *MockClusterConfigRegistry) UpdateClusterConfig(clusterConfig datamodel.ClusterConfig) (datamodel.ClusterConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateClusterConfig", clusterConfig)
+	ret0, _ := ret[0].(datamodel.ClusterConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateClusterConfig indicates an expected call of UpdateClusterConfig
+func (mr *MockClusterConfigRegistryMockRecorder) UpdateClusterConfig(clusterConfig interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateClusterConfig", reflect.TypeOf((*MockClusterConfigRegistry)(nil).UpdateClusterConfig), clusterConfig)
+}
+
+// WatchClusterConfig mocks base method
+func (m *MockClusterConfigRegistry) WatchClusterConfig(ctxt context.Context) <-chan datamodel.ClusterConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchClusterConfig", ctxt)
+	ret0, _ := ret[0].(<-chan datamodel.ClusterConfig)
+	return ret0
+}
+
+// WatchClusterConfig indicates an expected call of WatchClusterConfig
+func (mr *MockClusterConfigRegistryMockRecorder) WatchClusterConfig(ctxt interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchClusterConfig", reflect.TypeOf((*MockClusterConfigRegistry)(nil).WatchClusterConfig), ctxt)
+}
@@ -0,0 +1,65 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: internal/pkg/registry/leader.go
+
+// Package mock_registry is a generated GoMock package.
+package mock_registry
+
+import (
+	context "context"
+	datamodel "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	gomock "github.com/golang/mock/gomock"
+	reflect "reflect"
+)
+
+// MockLeaderRegistry is a mock of LeaderRegistry interface
+type MockLeaderRegistry struct {
+	ctrl     *gomock.Controller
+	recorder *MockLeaderRegistryMockRecorder
+}
+
+// MockLeaderRegistryMockRecorder is the mock recorder for MockLeaderRegistry
+type MockLeaderRegistryMockRecorder struct {
+	mock *MockLeaderRegistry
+}
+
+// NewMockLeaderRegistry creates a new mock instance
+func NewMockLeaderRegistry(ctrl *gomock.Controller) *MockLeaderRegistry {
+	mock := &MockLeaderRegistry{ctrl: ctrl}
+	mock.recorder = &MockLeaderRegistryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use
+func (m *MockLeaderRegistry) EXPECT() *MockLeaderRegistryMockRecorder {
+	return m.recorder
+}
+
+// Campaign mocks base method
+func (m *MockLeaderRegistry) Campaign(ctxt context.Context, brickHostName datamodel.BrickHostName) (<-chan struct{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Campaign", ctxt, brickHostName)
+	ret0, _ := ret[0].(<-chan struct{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Campaign indicates an expected call of Campaign
+func (mr *MockLeaderRegistryMockRecorder) Campaign(ctxt, brickHostName interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Campaign", reflect.TypeOf((*MockLeaderRegistry)(nil).Campaign), ctxt, brickHostName)
+}
+
+// GetLeader mocks base method
+func (m *MockLeaderRegistry) GetLeader() (datamodel.BrickHostName, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLeader")
+	ret0, _ := ret[0].(datamodel.BrickHostName)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLeader indicates an expected call of GetLeader
+func (mr *MockLeaderRegistryMockRecorder) GetLeader() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLeader", reflect.TypeOf((*MockLeaderRegistry)(nil).GetLeader))
+}
@@ -109,6 +109,20 @@ func (mr *MockSessionRegistryMockRecorder) UpdateSession(session interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSession", reflect.TypeOf((*MockSessionRegistry)(nil).UpdateSession), session)
 }
 
+// UpdateFailedAttempts mocks base method
+func (m *MockSessionRegistry) UpdateFailedAttempts(sessionName datamodel.SessionName, attempts []datamodel.ActionAttempt) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateFailedAttempts", sessionName, attempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateFailedAttempts indicates an expected call of UpdateFailedAttempts
+func (mr *MockSessionRegistryMockRecorder) UpdateFailedAttempts(sessionName, attempts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateFailedAttempts", reflect.TypeOf((*MockSessionRegistry)(nil).UpdateFailedAttempts), sessionName, attempts)
+}
+
 // DeleteSession mocks base method
 func (m *MockSessionRegistry) DeleteSession(session datamodel.Session) error {
 	m.ctrl.T.Helper()
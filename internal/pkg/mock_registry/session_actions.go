@@ -9,6 +9,7 @@ import (
 	datamodel "github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	gomock "github.com/golang/mock/gomock"
 	reflect "reflect"
+	time "time"
 )
 
 // MockSessionActions is a mock of SessionActions interface
@@ -92,3 +93,48 @@ func (mr *MockSessionActionsMockRecorder) CompleteSessionAction(action interface
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteSessionAction", reflect.TypeOf((*MockSessionActions)(nil).CompleteSessionAction), action)
 }
+
+// CancelSessionActions mocks base method
+func (m *MockSessionActions) CancelSessionActions(session datamodel.Session) ([]datamodel.SessionAction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelSessionActions", session)
+	ret0, _ := ret[0].([]datamodel.SessionAction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelSessionActions indicates an expected call of CancelSessionActions
+func (mr *MockSessionActionsMockRecorder) CancelSessionActions(session interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelSessionActions", reflect.TypeOf((*MockSessionActions)(nil).CancelSessionActions), session)
+}
+
+// WatchForCancel mocks base method
+func (m *MockSessionActions) WatchForCancel(ctxt context.Context, action datamodel.SessionAction) (<-chan struct{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchForCancel", ctxt, action)
+	ret0, _ := ret[0].(<-chan struct{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WatchForCancel indicates an expected call of WatchForCancel
+func (mr *MockSessionActionsMockRecorder) WatchForCancel(ctxt, action interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchForCancel", reflect.TypeOf((*MockSessionActions)(nil).WatchForCancel), ctxt, action)
+}
+
+// DeleteStaleSessionActions mocks base method
+func (m *MockSessionActions) DeleteStaleSessionActions(now time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteStaleSessionActions", now)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteStaleSessionActions indicates an expected call of DeleteStaleSessionActions
+func (mr *MockSessionActionsMockRecorder) DeleteStaleSessionActions(now interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteStaleSessionActions", reflect.TypeOf((*MockSessionActions)(nil).DeleteStaleSessionActions), now)
+}
@@ -195,6 +195,21 @@ func (mr *MockKeystoreMockRecorder) NewMutex(lockKey interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NewMutex", reflect.TypeOf((*MockKeystore)(nil).NewMutex), lockKey)
 }
 
+// Campaign mocks base method
+func (m *MockKeystore) Campaign(ctxt context.Context, electionKey, value string) (<-chan struct{}, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Campaign", ctxt, electionKey, value)
+	ret0, _ := ret[0].(<-chan struct{})
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Campaign indicates an expected call of Campaign
+func (mr *MockKeystoreMockRecorder) Campaign(ctxt, electionKey, value interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Campaign", reflect.TypeOf((*MockKeystore)(nil).Campaign), ctxt, electionKey, value)
+}
+
 // MockMutex is a mock of Mutex interface
 type MockMutex struct {
 	ctrl     *gomock.Controller
@@ -26,4 +26,17 @@ type BrickHostRegistry interface {
 	//
 	// Error if brick host doesn't exist
 	IsBrickHostAlive(brickHostName datamodel.BrickHostName) (bool, error)
+
+	// Stop the brick being given to new sessions, until the mark is cleared
+	//
+	// Replaces any existing mark for the brick
+	MarkBrickUnhealthy(unhealthyBrick datamodel.UnhealthyBrick) error
+
+	// Get all bricks currently marked unhealthy
+	GetUnhealthyBricks() ([]datamodel.UnhealthyBrick, error)
+
+	// Allow the brick to be given to new sessions again
+	//
+	// Error if the brick is not marked unhealthy
+	ClearUnhealthyBrick(brick datamodel.Brick) error
 }
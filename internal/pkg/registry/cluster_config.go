@@ -0,0 +1,22 @@
+package registry
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+)
+
+type ClusterConfigRegistry interface {
+	// Get the current cluster config
+	//
+	// Returns an empty config if it has never been set
+	GetClusterConfig() (datamodel.ClusterConfig, error)
+
+	// Replace the cluster config
+	//
+	// Error if the config has been updated since the given revision was read
+	UpdateClusterConfig(clusterConfig datamodel.ClusterConfig) (datamodel.ClusterConfig, error)
+
+	// Get notified of every change to the cluster config,
+	// until the context is cancelled
+	WatchClusterConfig(ctxt context.Context) <-chan datamodel.ClusterConfig
+}
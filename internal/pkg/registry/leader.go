@@ -0,0 +1,19 @@
+package registry
+
+import (
+	"context"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+)
+
+// Elects one brick host to run cluster wide housekeeping
+type LeaderRegistry interface {
+	// Blocks until the given host is the leader, or the context is done.
+	// The returned channel is closed when leadership is lost,
+	// cancel the context to give up leadership.
+	Campaign(ctxt context.Context, brickHostName datamodel.BrickHostName) (<-chan struct{}, error)
+
+	// Get the current leader
+	//
+	// Empty if there is no leader
+	GetLeader() (datamodel.BrickHostName, error)
+}
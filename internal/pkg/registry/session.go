@@ -31,6 +31,13 @@ type SessionRegistry interface {
 	// Error if session does not exist
 	UpdateSession(session datamodel.Session) (datamodel.Session, error)
 
+	// Replace the failed attempts of the session's current action
+	//
+	// Stored apart from the session, so they can be saved while an action runs
+	// without changing the session's revision.
+	// GetSession and GetAllSessions return them in Status.FailedAttempts
+	UpdateFailedAttempts(sessionName datamodel.SessionName, attempts []datamodel.ActionAttempt) error
+
 	// This is called before confirming the Session delete request,
 	// after all bricks have been de-allocated
 	//
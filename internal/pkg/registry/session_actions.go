@@ -3,6 +3,7 @@ package registry
 import (
 	"context"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"time"
 )
 
 type SessionActions interface {
@@ -25,4 +26,17 @@ type SessionActions interface {
 	//
 	// Error if action has already completed or doesn't exist
 	CompleteSessionAction(action datamodel.SessionAction) error
+
+	// Ask the primary brick host to stop all outstanding actions for the session,
+	// returns the actions that were asked to stop
+	CancelSessionActions(session datamodel.Session) ([]datamodel.SessionAction, error)
+
+	// Returned channel is closed when the action is cancelled,
+	// stops watching when the context is done
+	WatchForCancel(ctxt context.Context, action datamodel.SessionAction) (<-chan struct{}, error)
+
+	// Remove responses no one is waiting for, because the action's deadline has passed,
+	// and cancel requests for actions that have already completed.
+	// Returns the number of keys removed.
+	DeleteStaleSessionActions(now time.Time) (int, error)
 }
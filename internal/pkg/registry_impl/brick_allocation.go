@@ -8,6 +8,7 @@ import (
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
 	"log"
+	"time"
 )
 
 func NewAllocationRegistry(keystore store.Keystore) registry.AllocationRegistry {
@@ -113,6 +114,33 @@ func (a *allocationRegistry) GetAllPoolInfos() ([]datamodel.PoolInfo, error) {
 	if err != nil {
 		return nil, fmt.Errorf("unable to get all briks due to: %s", err)
 	}
+	unhealthyBricks, err := a.brickHostRegistry.GetUnhealthyBricks()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get unhealthy bricks due to: %s", err)
+	}
+	unhealthy := make(map[datamodel.BrickHostName]map[string]bool)
+	for _, unhealthyBrick := range unhealthyBricks {
+		hostName := unhealthyBrick.Brick.BrickHostName
+		if unhealthy[hostName] == nil {
+			unhealthy[hostName] = make(map[string]bool)
+		}
+		unhealthy[hostName][unhealthyBrick.Brick.Device] = true
+	}
+
+	// Bricks reserved by a preemption are only free for the session that preempted them
+	now := uint(time.Now().Unix())
+	reservedFor := make(map[datamodel.BrickHostName]map[string]datamodel.SessionName)
+	for _, session := range sessions {
+		if session.Preemption == nil || session.Preemption.ReservedUntil <= now {
+			continue
+		}
+		for _, brick := range session.Preemption.ReservedBricks {
+			if reservedFor[brick.BrickHostName] == nil {
+				reservedFor[brick.BrickHostName] = make(map[string]datamodel.SessionName)
+			}
+			reservedFor[brick.BrickHostName][brick.Device] = session.Preemption.PreemptedBy
+		}
+	}
 
 	var allPoolInfos []datamodel.PoolInfo
 
@@ -155,9 +183,22 @@ func (a *allocationRegistry) GetAllPoolInfos() ([]datamodel.PoolInfo, error) {
 						allocated = true
 					}
 				}
-				if !allocated {
-					poolInfo.AvailableBricks = append(poolInfo.AvailableBricks, brick)
+				if allocated {
+					continue
+				}
+				// unhealthy bricks, such as those that failed to wipe, wait for an admin
+				if unhealthy[brick.BrickHostName][brick.Device] {
+					poolInfo.UnhealthyBricks = append(poolInfo.UnhealthyBricks, brick)
+					continue
+				}
+				if sessionName, ok := reservedFor[brick.BrickHostName][brick.Device]; ok {
+					if poolInfo.ReservedBricks == nil {
+						poolInfo.ReservedBricks = make(map[datamodel.SessionName][]datamodel.Brick)
+					}
+					poolInfo.ReservedBricks[sessionName] = append(poolInfo.ReservedBricks[sessionName], brick)
+					continue
 				}
+				poolInfo.AvailableBricks = append(poolInfo.AvailableBricks, brick)
 			}
 		}
 
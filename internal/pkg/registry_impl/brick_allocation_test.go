@@ -0,0 +1,61 @@
+package registry_impl
+
+import (
+	"encoding/json"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func toKeyValues(t *testing.T, values ...interface{}) []store.KeyValueVersion {
+	var keyValues []store.KeyValueVersion
+	for _, value := range values {
+		raw, err := json.Marshal(value)
+		assert.Nil(t, err)
+		keyValues = append(keyValues, store.KeyValueVersion{Value: raw})
+	}
+	return keyValues
+}
+
+func TestAllocationRegistry_GetPoolInfo_Reserved(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	registry := NewAllocationRegistry(keystore)
+
+	bricks := []datamodel.Brick{
+		{BrickHostName: "dac1", Device: "nvme0n1", PoolName: "pool1"},
+		{BrickHostName: "dac1", Device: "nvme1n1", PoolName: "pool1"},
+		{BrickHostName: "dac1", Device: "nvme2n1", PoolName: "pool1"},
+		{BrickHostName: "dac1", Device: "nvme3n1", PoolName: "pool1"},
+	}
+	now := uint(time.Now().Unix())
+	preempted := datamodel.Session{Name: "old", Preemption: &datamodel.PreemptionRecord{
+		PreemptedBy: "urgent", ReservedBricks: bricks[:2], ReservedUntil: now + 60}}
+	expired := datamodel.Session{Name: "older", Preemption: &datamodel.PreemptionRecord{
+		PreemptedBy: "urgent", ReservedBricks: bricks[2:3], ReservedUntil: now - 1}}
+	// reserved bricks are not free until the preempted buffer releases them
+	inUse := datamodel.Session{Name: "inuse", AllocatedBricks: bricks[1:2]}
+
+	keystore.EXPECT().GetAll(poolPrefix).Return(
+		toKeyValues(t, datamodel.Pool{Name: "pool1", GranularityBytes: 1024}), nil)
+	keystore.EXPECT().GetAll(sessionPrefix).Return(toKeyValues(t, preempted, expired, inUse), nil)
+	keystore.EXPECT().GetAll(failedAttemptsPrefix).Return(nil, nil)
+	keystore.EXPECT().GetAll(brickHostPrefix).Return(
+		toKeyValues(t, datamodel.BrickHost{Name: "dac1", Bricks: bricks, Enabled: true}), nil)
+	keystore.EXPECT().GetAll(unhealthyBrickPrefix).Return(
+		toKeyValues(t, datamodel.UnhealthyBrick{Brick: bricks[3]}), nil)
+	keystore.EXPECT().IsExist(getKeepAliveKey("dac1")).Return(true, nil)
+
+	poolInfo, err := registry.GetPoolInfo("pool1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, bricks[2:3], poolInfo.AvailableBricks)
+	assert.Equal(t, bricks[3:], poolInfo.UnhealthyBricks)
+	assert.Equal(t, map[datamodel.SessionName][]datamodel.Brick{"urgent": bricks[:1]}, poolInfo.ReservedBricks)
+	assert.Equal(t, 1, len(poolInfo.AllocatedBricks))
+}
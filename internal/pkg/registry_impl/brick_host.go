@@ -21,6 +21,7 @@ type brickHostRegistry struct {
 
 const brickHostPrefix = "/BrickHostStore/"
 const keepAlivePrefix = "/BrickHostAlive/"
+const unhealthyBrickPrefix = "/BrickUnhealthy/"
 
 func (b *brickHostRegistry) UpdateBrickHost(brickHostInfo datamodel.BrickHost) error {
 	// find out granularity for each reported pool
@@ -102,3 +103,39 @@ func (b *brickHostRegistry) KeepAliveHost(ctxt context.Context, brickHostName da
 func (b *brickHostRegistry) IsBrickHostAlive(brickHostName datamodel.BrickHostName) (bool, error) {
 	return b.store.IsExist(getKeepAliveKey(brickHostName))
 }
+
+func getUnhealthyBrickKey(brick datamodel.Brick) string {
+	if !parsers.IsValidName(string(brick.BrickHostName)) || !parsers.IsValidName(brick.Device) {
+		log.Panicf("invalid brick: %+v", brick)
+	}
+	return fmt.Sprintf("%s%s/%s", unhealthyBrickPrefix, brick.BrickHostName, brick.Device)
+}
+
+func (b *brickHostRegistry) MarkBrickUnhealthy(unhealthyBrick datamodel.UnhealthyBrick) error {
+	value, err := json.Marshal(unhealthyBrick)
+	if err != nil {
+		log.Panicf("unable to convert unhealthy brick to json: %+v", unhealthyBrick)
+	}
+	_, err = b.store.Update(getUnhealthyBrickKey(unhealthyBrick.Brick), value, 0)
+	return err
+}
+
+func (b *brickHostRegistry) GetUnhealthyBricks() ([]datamodel.UnhealthyBrick, error) {
+	allKeyValues, err := b.store.GetAll(unhealthyBrickPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get unhealthy bricks due to: %s", err)
+	}
+	var unhealthyBricks []datamodel.UnhealthyBrick
+	for _, keyValueVersion := range allKeyValues {
+		unhealthyBrick := datamodel.UnhealthyBrick{}
+		if err := json.Unmarshal(keyValueVersion.Value, &unhealthyBrick); err != nil {
+			log.Panicf("unable to parse unhealthy brick due to: %s", err)
+		}
+		unhealthyBricks = append(unhealthyBricks, unhealthyBrick)
+	}
+	return unhealthyBricks, nil
+}
+
+func (b *brickHostRegistry) ClearUnhealthyBrick(brick datamodel.Brick) error {
+	return b.store.Delete(getUnhealthyBrickKey(brick), 0)
+}
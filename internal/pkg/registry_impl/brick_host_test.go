@@ -0,0 +1,41 @@
+package registry_impl
+
+import (
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBrickHostRegistry_UnhealthyBricks(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	registry := NewBrickHostRegistry(keystore)
+	unhealthyBrick := datamodel.UnhealthyBrick{
+		Brick:    datamodel.Brick{BrickHostName: "dac1", Device: "nvme0n1"},
+		Reason:   "wipe failed",
+		MarkedAt: 42,
+	}
+	value := []byte(`{"Brick":{"Device":"nvme0n1","BrickHostName":"dac1","PoolName":"","CapacityGiB":0},` +
+		`"Reason":"wipe failed","MarkedAt":42}`)
+
+	keystore.EXPECT().Update("/BrickUnhealthy/dac1/nvme0n1", value, int64(0))
+	err := registry.MarkBrickUnhealthy(unhealthyBrick)
+	assert.Nil(t, err)
+
+	keystore.EXPECT().GetAll("/BrickUnhealthy/").Return([]store.KeyValueVersion{{Value: value}}, nil)
+	unhealthyBricks, err := registry.GetUnhealthyBricks()
+	assert.Nil(t, err)
+	assert.Equal(t, []datamodel.UnhealthyBrick{unhealthyBrick}, unhealthyBricks)
+
+	keystore.EXPECT().Delete("/BrickUnhealthy/dac1/nvme0n1", int64(0))
+	err = registry.ClearUnhealthyBrick(unhealthyBrick.Brick)
+	assert.Nil(t, err)
+
+	assert.Panics(t, func() {
+		_ = registry.ClearUnhealthyBrick(datamodel.Brick{BrickHostName: "dac1", Device: "/dev/nvme0n1"})
+	})
+}
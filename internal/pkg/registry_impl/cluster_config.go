@@ -0,0 +1,94 @@
+package registry_impl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
+	"log"
+)
+
+func NewClusterConfigRegistry(keystore store.Keystore) registry.ClusterConfigRegistry {
+	return &clusterConfigRegistry{keystore}
+}
+
+type clusterConfigRegistry struct {
+	store store.Keystore
+}
+
+const clusterConfigKey = "/ClusterConfig"
+
+func (c *clusterConfigRegistry) GetClusterConfig() (datamodel.ClusterConfig, error) {
+	exists, err := c.store.IsExist(clusterConfigKey)
+	if err != nil {
+		return datamodel.ClusterConfig{}, fmt.Errorf("unable to get cluster config due to: %s", err)
+	}
+	if !exists {
+		return datamodel.ClusterConfig{}, nil
+	}
+	keyValueVersion, err := c.store.Get(clusterConfigKey)
+	if err != nil {
+		return datamodel.ClusterConfig{}, fmt.Errorf("unable to get cluster config due to: %s", err)
+	}
+	clusterConfig := clusterConfigFromRaw(keyValueVersion.Value)
+	clusterConfig.Revision = keyValueVersion.ModRevision
+	return clusterConfig, nil
+}
+
+func (c *clusterConfigRegistry) UpdateClusterConfig(
+	clusterConfig datamodel.ClusterConfig) (datamodel.ClusterConfig, error) {
+	var revision int64
+	var err error
+	if clusterConfig.Revision == 0 {
+		// Fails if someone else created the config since we read it
+		revision, err = c.store.Create(clusterConfigKey, clusterConfigToRaw(clusterConfig))
+	} else {
+		revision, err = c.store.Update(clusterConfigKey, clusterConfigToRaw(clusterConfig), clusterConfig.Revision)
+	}
+	if err != nil {
+		return clusterConfig, fmt.Errorf("unable to update cluster config due to: %s", err)
+	}
+	clusterConfig.Revision = revision
+	return clusterConfig, nil
+}
+
+func (c *clusterConfigRegistry) WatchClusterConfig(ctxt context.Context) <-chan datamodel.ClusterConfig {
+	updates := c.store.Watch(ctxt, clusterConfigKey, false)
+	configChan := make(chan datamodel.ClusterConfig)
+	go func() {
+		log.Println("Starting watching for cluster config changes")
+		for update := range updates {
+			if update.Err != nil {
+				log.Printf("error watching cluster config: %s\n", update.Err)
+				continue
+			}
+			clusterConfig := datamodel.ClusterConfig{}
+			if !update.IsDelete && update.New != nil {
+				clusterConfig = clusterConfigFromRaw(update.New.Value)
+				clusterConfig.Revision = update.New.ModRevision
+			}
+			configChan <- clusterConfig
+		}
+		log.Println("Stopped watching for cluster config changes")
+		close(configChan)
+	}()
+	return configChan
+}
+
+func clusterConfigToRaw(clusterConfig datamodel.ClusterConfig) []byte {
+	raw, err := json.Marshal(clusterConfig)
+	if err != nil {
+		log.Panicf("unable to convert cluster config to json due to: %s", err)
+	}
+	return raw
+}
+
+func clusterConfigFromRaw(raw []byte) datamodel.ClusterConfig {
+	clusterConfig := datamodel.ClusterConfig{}
+	if err := json.Unmarshal(raw, &clusterConfig); err != nil {
+		log.Panicf("unable parse cluster config from store due to: %s", err)
+	}
+	return clusterConfig
+}
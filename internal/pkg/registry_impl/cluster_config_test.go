@@ -0,0 +1,60 @@
+package registry_impl
+
+import (
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var exampleClusterConfigString = []byte(
+	`{"Revision":0,"Settings":{"max_mdt_count":"8"},"PoolSettings":{"fast":{"mdt_size_mb":"1024"}}}`)
+var exampleClusterConfig = datamodel.ClusterConfig{
+	Settings:     map[string]string{"max_mdt_count": "8"},
+	PoolSettings: map[datamodel.PoolName]map[string]string{"fast": {"mdt_size_mb": "1024"}},
+}
+
+func TestClusterConfigRegistry_GetClusterConfig(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	registry := NewClusterConfigRegistry(keystore)
+
+	keystore.EXPECT().IsExist("/ClusterConfig").Return(false, nil)
+	clusterConfig, err := registry.GetClusterConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.ClusterConfig{}, clusterConfig)
+
+	keystore.EXPECT().IsExist("/ClusterConfig").Return(true, nil)
+	keystore.EXPECT().Get("/ClusterConfig").Return(store.KeyValueVersion{
+		Value: exampleClusterConfigString, ModRevision: 42,
+	}, nil)
+	clusterConfig, err = registry.GetClusterConfig()
+	assert.Nil(t, err)
+	expected := exampleClusterConfig
+	expected.Revision = 42
+	assert.Equal(t, expected, clusterConfig)
+
+	keystore.EXPECT().IsExist("/ClusterConfig").Return(false, errors.New("fake"))
+	_, err = registry.GetClusterConfig()
+	assert.Equal(t, "unable to get cluster config due to: fake", err.Error())
+}
+
+func TestClusterConfigRegistry_UpdateClusterConfig(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	registry := NewClusterConfigRegistry(keystore)
+
+	keystore.EXPECT().Create("/ClusterConfig", exampleClusterConfigString).Return(int64(42), nil)
+	clusterConfig, err := registry.UpdateClusterConfig(exampleClusterConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), clusterConfig.Revision)
+
+	keystore.EXPECT().Update("/ClusterConfig", gomock.Any(), int64(42)).Return(int64(0), errors.New("fake"))
+	_, err = registry.UpdateClusterConfig(clusterConfig)
+	assert.Equal(t, "unable to update cluster config due to: fake", err.Error())
+}
@@ -0,0 +1,42 @@
+package registry_impl
+
+import (
+	"context"
+	"fmt"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
+)
+
+func NewLeaderRegistry(keystore store.Keystore) registry.LeaderRegistry {
+	return &leaderRegistry{keystore}
+}
+
+type leaderRegistry struct {
+	store store.Keystore
+}
+
+const leaderElectionKey = "/leader/housekeeping"
+
+func (l *leaderRegistry) Campaign(ctxt context.Context,
+	brickHostName datamodel.BrickHostName) (<-chan struct{}, error) {
+	return l.store.Campaign(ctxt, leaderElectionKey, string(brickHostName))
+}
+
+func (l *leaderRegistry) GetLeader() (datamodel.BrickHostName, error) {
+	// Every candidate has a key under the election key, the oldest is the leader
+	candidates, err := l.store.GetAll(leaderElectionKey + "/")
+	if err != nil {
+		return "", fmt.Errorf("unable to get leader due to: %s", err)
+	}
+	var leader *store.KeyValueVersion
+	for i, candidate := range candidates {
+		if leader == nil || candidate.CreateRevision < leader.CreateRevision {
+			leader = &candidates[i]
+		}
+	}
+	if leader == nil {
+		return "", nil
+	}
+	return datamodel.BrickHostName(leader.Value), nil
+}
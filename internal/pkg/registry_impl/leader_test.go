@@ -0,0 +1,36 @@
+package registry_impl
+
+import (
+	"errors"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLeaderRegistry_GetLeader(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	leaders := leaderRegistry{keystore}
+
+	keystore.EXPECT().GetAll("/leader/housekeeping/").Return([]store.KeyValueVersion{
+		{Key: "/leader/housekeeping/2", Value: []byte("dac2"), CreateRevision: 12},
+		{Key: "/leader/housekeeping/1", Value: []byte("dac1"), CreateRevision: 10},
+		{Key: "/leader/housekeeping/3", Value: []byte("dac3"), CreateRevision: 11},
+	}, nil)
+	leader, err := leaders.GetLeader()
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.BrickHostName("dac1"), leader)
+
+	keystore.EXPECT().GetAll("/leader/housekeeping/").Return(nil, nil)
+	leader, err = leaders.GetLeader()
+	assert.Nil(t, err)
+	assert.Equal(t, datamodel.BrickHostName(""), leader)
+
+	keystore.EXPECT().GetAll("/leader/housekeeping/").Return(nil, errors.New("fake"))
+	_, err = leaders.GetLeader()
+	assert.Equal(t, "unable to get leader due to: fake", err.Error())
+}
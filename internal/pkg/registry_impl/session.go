@@ -68,6 +68,15 @@ func (s *sessionRegistry) GetSession(sessionName datamodel.SessionName) (datamod
 
 	session := sessionFromRaw(keyValueVersion.Value)
 	session.Revision = keyValueVersion.ModRevision
+
+	attemptsKey := getFailedAttemptsKey(sessionName)
+	attempts, err := s.getFailedAttempts(attemptsKey)
+	if err != nil {
+		return session, err
+	}
+	if sessionAttempts, ok := attempts[attemptsKey]; ok {
+		session.Status.FailedAttempts = sessionAttempts
+	}
 	return session, nil
 }
 
@@ -77,10 +86,18 @@ func (s *sessionRegistry) GetAllSessions() ([]datamodel.Session, error) {
 		return nil, fmt.Errorf("unable to get all sessions due to: %s", err.Error())
 	}
 
+	attempts, err := s.getFailedAttempts(failedAttemptsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
 	var sessions []datamodel.Session
 	for _, keyValueVersion := range results {
 		session := sessionFromRaw(keyValueVersion.Value)
 		session.Revision = keyValueVersion.ModRevision
+		if sessionAttempts, ok := attempts[getFailedAttemptsKey(session.Name)]; ok {
+			session.Status.FailedAttempts = sessionAttempts
+		}
 		sessions = append(sessions, session)
 	}
 
@@ -98,10 +115,58 @@ func (s *sessionRegistry) UpdateSession(session datamodel.Session) (datamodel.Se
 }
 
 func (s *sessionRegistry) DeleteSession(session datamodel.Session) error {
-	return s.store.Delete(getSessionKey(session.Name), session.Revision)
+	if err := s.store.Delete(getSessionKey(session.Name), session.Revision); err != nil {
+		return err
+	}
+	attemptsKey := getFailedAttemptsKey(session.Name)
+	exists, err := s.store.IsExist(attemptsKey)
+	if err != nil || !exists {
+		return err
+	}
+	return s.store.Delete(attemptsKey, 0)
+}
+
+const failedAttemptsPrefix = "/session_attempts/"
+
+func getFailedAttemptsKey(sessionName datamodel.SessionName) string {
+	if !parsers.IsValidName(string(sessionName)) {
+		log.Panicf("invalid session name: '%s'", sessionName)
+	}
+	return fmt.Sprintf("%s%s", failedAttemptsPrefix, sessionName)
+}
+
+func (s *sessionRegistry) UpdateFailedAttempts(sessionName datamodel.SessionName,
+	attempts []datamodel.ActionAttempt) error {
+	rawAttempts, err := json.Marshal(attempts)
+	if err != nil {
+		log.Panicf("unable to convert failed attempts to json due to: %s", err)
+	}
+	if _, err := s.store.Update(getFailedAttemptsKey(sessionName), rawAttempts, 0); err != nil {
+		return fmt.Errorf("unable to update failed attempts due to: %s", err)
+	}
+	return nil
+}
+
+// Failed attempts keyed by their key, for all keys with the given prefix
+func (s *sessionRegistry) getFailedAttempts(prefix string) (map[string][]datamodel.ActionAttempt, error) {
+	results, err := s.store.GetAll(prefix)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get failed attempts due to: %s", err)
+	}
+	attempts := make(map[string][]datamodel.ActionAttempt)
+	for _, keyValueVersion := range results {
+		var sessionAttempts []datamodel.ActionAttempt
+		if err := json.Unmarshal(keyValueVersion.Value, &sessionAttempts); err != nil {
+			log.Panicf("unable parse failed attempts from store due to: %s", err)
+		}
+		attempts[keyValueVersion.Key] = sessionAttempts
+	}
+	return attempts, nil
 }
 
+// Failed attempts are stored apart from the session, see UpdateFailedAttempts
 func sessionToRaw(session datamodel.Session) []byte {
+	session.Status.FailedAttempts = nil
 	rawSession, err := json.Marshal(session)
 	if err != nil {
 		log.Panicf("unable to convert session to json due to: %s", err.Error())
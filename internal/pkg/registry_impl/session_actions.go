@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/dacctl/actions_impl/parsers"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/registry"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
 	"github.com/google/uuid"
 	"log"
 	"sort"
+	"strings"
+	"time"
 )
 
 func NewSessionActionsRegistry(store store.Keystore) registry.SessionActions {
@@ -51,6 +54,15 @@ func getSessionActionResponseKey(action datamodel.SessionAction) string {
 	return fmt.Sprintf("%s%s/%s", sessionActionResponsePrefix, action.Session.Name, action.Uuid)
 }
 
+const sessionActionCancelPrefix = "/session_action/cancel/"
+
+func getSessionActionCancelKey(action datamodel.SessionAction) string {
+	if !parsers.IsValidName(action.Uuid) {
+		log.Panicf("invalid session action uuid %s", action.Uuid)
+	}
+	return fmt.Sprintf("%s%s", sessionActionCancelPrefix, action.Uuid)
+}
+
 func sessionActionToRaw(session datamodel.SessionAction) []byte {
 	rawSession, err := json.Marshal(session)
 	if err != nil {
@@ -79,6 +91,16 @@ func (s *sessionActions) SendSessionAction(
 		Session:    session,
 		ActionType: actionType,
 		Uuid:       uuid.New().String(),
+		Caller:     logging.GetCaller(ctxt),
+	}
+	logger := logging.FromContext(ctxt).WithFields(logging.Fields{
+		logging.SessionField:    session.Name,
+		logging.ActionField:     actionType,
+		logging.ActionUuidField: sessionAction.Uuid,
+	})
+	// dacd stops the action if it is still running when the caller gives up
+	if deadline, ok := ctxt.Deadline(); ok {
+		sessionAction.Deadline = deadline
 	}
 
 	isAlive, err := s.brickHostRegistry.IsBrickHostAlive(session.PrimaryBrickHost)
@@ -100,14 +122,14 @@ func (s *sessionActions) SendSessionAction(
 	responseChan := make(chan datamodel.SessionAction)
 
 	go func() {
-		log.Printf("started waiting for action response %+v\n", sessionAction)
+		logger.Info("started waiting for action response")
 		for update := range callbackKeyUpdates {
 			if !update.IsCreate || update.New.Value == nil {
 				log.Panicf("only expected to see the action response key being created")
 			}
 
 			responseSessionAction := sessionActionFromRaw(update.New.Value)
-			log.Printf("found action response %+v\n", responseSessionAction)
+			logger.WithField("error", responseSessionAction.Error).Info("found action response")
 
 			responseChan <- responseSessionAction
 
@@ -118,11 +140,11 @@ func (s *sessionActions) SendSessionAction(
 				}
 			}
 
-			log.Printf("completed waiting for action response %+v\n", sessionAction)
+			logger.Info("completed waiting for action response")
 			close(responseChan)
 			return
 		}
-		log.Println("stopped waiting for action response, likely the context timed out")
+		logger.Warn("stopped waiting for action response, likely the context timed out")
 		// TODO: double check watch gets stopped somehow? assume context has been cancelled externally?
 	}()
 	return responseChan, nil
@@ -194,6 +216,114 @@ func (s *sessionActions) CompleteSessionAction(sessionAction datamodel.SessionAc
 		return fmt.Errorf("unable to delete stale request message due to: %s", err)
 	}
 
+	// Clean up any cancel request, it may have arrived too late
+	if _, err := s.store.DeleteAllKeysWithPrefix(getSessionActionCancelKey(sessionAction)); err != nil {
+		return fmt.Errorf("unable to delete cancel request due to: %s", err)
+	}
+
 	log.Printf("Completed session action %s for session %s\n", sessionAction.Uuid, sessionAction.Session.Name)
 	return nil
 }
+
+func (s *sessionActions) CancelSessionActions(session datamodel.Session) ([]datamodel.SessionAction, error) {
+	if session.PrimaryBrickHost == "" {
+		return nil, fmt.Errorf("session %s has no primary brick host, so has no actions", session.Name)
+	}
+	outstanding, err := s.GetOutstandingSessionActionRequests(session.PrimaryBrickHost)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get outstanding actions due to: %s", err)
+	}
+	var cancelled []datamodel.SessionAction
+	for _, action := range outstanding {
+		if action.Session.Name != session.Name {
+			continue
+		}
+		cancelKey := getSessionActionCancelKey(action)
+		exists, err := s.store.IsExist(cancelKey)
+		if err != nil {
+			return cancelled, fmt.Errorf("unable to check for cancel request due to: %s", err)
+		}
+		if !exists {
+			if _, err := s.store.Create(cancelKey, []byte(action.Session.Name)); err != nil {
+				return cancelled, fmt.Errorf("unable to request cancel due to: %s", err)
+			}
+		}
+		log.Printf("Requested cancel of session action %s for session %s\n", action.Uuid, session.Name)
+		cancelled = append(cancelled, action)
+	}
+	return cancelled, nil
+}
+
+func (s *sessionActions) WatchForCancel(ctxt context.Context, action datamodel.SessionAction) (<-chan struct{}, error) {
+	cancelKey := getSessionActionCancelKey(action)
+	// Start watching before the check, so we can't miss the cancel request
+	updates := s.store.Watch(ctxt, cancelKey, false)
+	exists, err := s.store.IsExist(cancelKey)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check for cancel request due to: %s", err)
+	}
+
+	cancelled := make(chan struct{})
+	go func() {
+		isClosed := false
+		if exists {
+			close(cancelled)
+			isClosed = true
+		}
+		// Keep reading until the watch stops, when the context is done
+		for update := range updates {
+			if update.IsCreate && !isClosed {
+				log.Printf("Seen cancel request for session action %s\n", action.Uuid)
+				close(cancelled)
+				isClosed = true
+			}
+		}
+	}()
+	return cancelled, nil
+}
+
+func (s *sessionActions) DeleteStaleSessionActions(now time.Time) (int, error) {
+	// Get cancel requests first, so any action that is still outstanding is found below
+	cancelRequests, err := s.store.GetAll(sessionActionCancelPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get cancel requests due to: %s", err)
+	}
+	requests, err := s.store.GetAll(sessionActionRequestPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get action requests due to: %s", err)
+	}
+	outstanding := make(map[string]bool)
+	for _, request := range requests {
+		outstanding[sessionActionFromRaw(request.Value).Uuid] = true
+	}
+
+	var staleKeys []store.KeyValueVersion
+	for _, cancelRequest := range cancelRequests {
+		if !outstanding[strings.TrimPrefix(cancelRequest.Key, sessionActionCancelPrefix)] {
+			staleKeys = append(staleKeys, cancelRequest)
+		}
+	}
+
+	responses, err := s.store.GetAll(sessionActionResponsePrefix)
+	if err != nil {
+		return 0, fmt.Errorf("unable to get action responses due to: %s", err)
+	}
+	for _, response := range responses {
+		// After the deadline the caller has stopped waiting for the response
+		deadline := sessionActionFromRaw(response.Value).Deadline
+		if !deadline.IsZero() && deadline.Before(now) {
+			staleKeys = append(staleKeys, response)
+		}
+	}
+
+	deleted := 0
+	for _, staleKey := range staleKeys {
+		// Someone else may have just removed the key
+		if err := s.store.Delete(staleKey.Key, staleKey.ModRevision); err != nil {
+			log.Printf("unable to delete stale session action key %s due to: %s\n", staleKey.Key, err)
+			continue
+		}
+		deleted += 1
+	}
+	return deleted, nil
+}
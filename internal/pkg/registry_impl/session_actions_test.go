@@ -6,9 +6,11 @@ import (
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/datamodel"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_registry"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/mock_store"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestSessionActions_SendSessionAction(t *testing.T) {
@@ -29,3 +31,84 @@ func TestSessionActions_SendSessionAction(t *testing.T) {
 	assert.Nil(t, channel)
 	assert.Equal(t, "unable to send session action due to: fake", err.Error())
 }
+
+func TestSessionActions_CancelSessionActions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	actions := sessionActions{store: keystore}
+	session := datamodel.Session{Name: "foo", PrimaryBrickHost: "host1"}
+	keystore.EXPECT().GetAll("/session_action/request/host1/").Return([]store.KeyValueVersion{
+		{Value: sessionActionToRaw(datamodel.SessionAction{Uuid: "uuid1", Session: session}), CreateRevision: 2},
+		{Value: sessionActionToRaw(datamodel.SessionAction{Uuid: "uuid2",
+			Session: datamodel.Session{Name: "bar"}}), CreateRevision: 1},
+		{Value: sessionActionToRaw(datamodel.SessionAction{Uuid: "uuid3", Session: session}), CreateRevision: 3},
+	}, nil)
+	keystore.EXPECT().IsExist("/session_action/cancel/uuid1").Return(false, nil)
+	keystore.EXPECT().Create("/session_action/cancel/uuid1", []byte("foo"))
+	keystore.EXPECT().IsExist("/session_action/cancel/uuid3").Return(true, nil)
+
+	cancelled, err := actions.CancelSessionActions(session)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(cancelled))
+	assert.Equal(t, "uuid1", cancelled[0].Uuid)
+	assert.Equal(t, "uuid3", cancelled[1].Uuid)
+
+	_, err = actions.CancelSessionActions(datamodel.Session{Name: "foo"})
+	assert.Equal(t, "session foo has no primary brick host, so has no actions", err.Error())
+}
+
+func TestSessionActions_WatchForCancel(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	actions := sessionActions{store: keystore}
+	action := datamodel.SessionAction{Uuid: "uuid1"}
+	updates := make(chan store.KeyValueUpdate)
+	keystore.EXPECT().Watch(context.TODO(), "/session_action/cancel/uuid1", false).Return(updates)
+	keystore.EXPECT().IsExist("/session_action/cancel/uuid1").Return(false, nil)
+
+	cancelled, err := actions.WatchForCancel(context.TODO(), action)
+
+	assert.Nil(t, err)
+	updates <- store.KeyValueUpdate{IsCreate: true}
+	_, isOpen := <-cancelled
+	assert.False(t, isOpen)
+	close(updates)
+}
+
+func TestSessionActions_DeleteStaleSessionActions(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	actions := sessionActions{store: keystore}
+	now := time.Now()
+	session := datamodel.Session{Name: "foo", PrimaryBrickHost: "host1"}
+
+	keystore.EXPECT().GetAll("/session_action/cancel/").Return([]store.KeyValueVersion{
+		{Key: "/session_action/cancel/uuid1", ModRevision: 3},
+		{Key: "/session_action/cancel/uuid2", ModRevision: 4},
+	}, nil)
+	keystore.EXPECT().GetAll("/session_action/request/").Return([]store.KeyValueVersion{
+		{Value: sessionActionToRaw(datamodel.SessionAction{Uuid: "uuid1", Session: session})},
+	}, nil)
+	keystore.EXPECT().GetAll("/session_action/response/").Return([]store.KeyValueVersion{
+		{Key: "/session_action/response/foo/uuid3", ModRevision: 5, Value: sessionActionToRaw(
+			datamodel.SessionAction{Uuid: "uuid3", Session: session, Deadline: now.Add(-time.Minute)})},
+		{Key: "/session_action/response/foo/uuid4", ModRevision: 6, Value: sessionActionToRaw(
+			datamodel.SessionAction{Uuid: "uuid4", Session: session, Deadline: now.Add(time.Minute)})},
+		{Key: "/session_action/response/foo/uuid5", ModRevision: 7, Value: sessionActionToRaw(
+			datamodel.SessionAction{Uuid: "uuid5", Session: session})},
+		{Key: "/session_action/response/foo/uuid6", ModRevision: 8, Value: sessionActionToRaw(
+			datamodel.SessionAction{Uuid: "uuid6", Session: session, Deadline: now.Add(-time.Hour)})},
+	}, nil)
+	keystore.EXPECT().Delete("/session_action/cancel/uuid2", int64(4))
+	keystore.EXPECT().Delete("/session_action/response/foo/uuid3", int64(5))
+	keystore.EXPECT().Delete("/session_action/response/foo/uuid6", int64(8)).Return(errors.New("fake"))
+
+	deleted, err := actions.DeleteStaleSessionActions(now)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, deleted)
+}
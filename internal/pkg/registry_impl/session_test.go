@@ -11,7 +11,7 @@ import (
 	"testing"
 )
 
-var exampleSessionString = []byte(`{"Name":"foo","Revision":0,"Owner":0,"Group":0,"CreatedAt":0,"VolumeRequest":{"MultiJob":false,"Caller":"","TotalCapacityBytes":0,"PoolName":"","Access":0,"Type":0,"SwapBytes":0},"Status":{"Error":"","FileSystemCreated":false,"CopyDataInComplete":false,"CopyDataOutComplete":false,"DeleteRequested":false,"DeleteSkipCopyDataOut":false,"UnmountComplete":false,"MountComplete":false},"StageInRequests":null,"StageOutRequests":null,"MultiJobAttachments":null,"Paths":null,"ActualSizeBytes":0,"AllocatedBricks":null,"PrimaryBrickHost":"host1","RequestedAttachHosts":null,"FilesystemStatus":{"Error":"","InternalName":"","InternalData":""},"CurrentAttachments":null}`)
+var exampleSessionString = []byte(`{"Name":"foo","Revision":0,"Owner":0,"Group":0,"CreatedAt":0,"LastUsedAt":0,"VolumeRequest":{"MultiJob":false,"Caller":"","TotalCapacityBytes":0,"PoolName":"","Access":0,"Type":0,"SwapBytes":0,"Priority":0,"FilesystemType":""},"Status":{"Error":"","FileSystemCreated":false,"CopyDataInComplete":false,"CopyDataOutComplete":false,"DeleteRequested":false,"DeleteSkipCopyDataOut":false,"UnmountComplete":false,"MountComplete":false,"FailedAttempts":null,"WipedBrickHosts":null},"StageInRequests":null,"StageOutRequests":null,"MultiJobAttachments":null,"Paths":null,"ActualSizeBytes":0,"AllocatedBricks":null,"PendingExpandBricks":null,"PrimaryBrickHost":"host1","RequestedAttachHosts":null,"FilesystemStatus":{"Error":"","InternalName":"","InternalData":"","Layout":null},"FilesystemSettings":null,"CurrentAttachments":null,"Preemption":null}`)
 var exampleSession = datamodel.Session{Name: "foo", PrimaryBrickHost: "host1"}
 
 func TestExampleString(t *testing.T) {
@@ -88,12 +88,25 @@ func TestSessionRegistry_GetSession(t *testing.T) {
 		ModRevision: 42,
 		Value:       exampleSessionString,
 	}, nil)
+	keystore.EXPECT().GetAll("/session_attempts/foo").Return(nil, nil)
 
 	session, err := registry.GetSession("foo")
 
 	assert.Nil(t, err)
 	assert.Equal(t, datamodel.Session{Name: "foo", Revision: 42, PrimaryBrickHost: "host1"}, session)
 
+	keystore.EXPECT().Get("/session/foo").Return(store.KeyValueVersion{
+		ModRevision: 42,
+		Value:       exampleSessionString,
+	}, nil)
+	keystore.EXPECT().GetAll("/session_attempts/foo").Return([]store.KeyValueVersion{
+		{Key: "/session_attempts/foobar", Value: []byte(`[{"Attempt":2}]`)},
+		{Key: "/session_attempts/foo", Value: []byte(`[{"Attempt":1}]`)},
+	}, nil)
+	session, err = registry.GetSession("foo")
+	assert.Nil(t, err)
+	assert.Equal(t, []datamodel.ActionAttempt{{Attempt: 1}}, session.Status.FailedAttempts)
+
 	assert.PanicsWithValue(t, "invalid session name: 'foo/bar'", func() {
 		registry.GetSession("foo/bar")
 	})
@@ -121,10 +134,14 @@ func TestSessionRegistry_GetAllSessions(t *testing.T) {
 		ModRevision: 42,
 		Value:       exampleSessionString,
 	}}, nil)
+	keystore.EXPECT().GetAll("/session_attempts/").Return([]store.KeyValueVersion{
+		{Key: "/session_attempts/foo", Value: []byte(`[{"Attempt":1}]`)},
+	}, nil)
 
 	sessions, err := registry.GetAllSessions()
 	assert.Nil(t, err)
-	assert.Equal(t, []datamodel.Session{{Name: "foo", Revision: 42, PrimaryBrickHost: "host1"}}, sessions)
+	assert.Equal(t, []datamodel.Session{{Name: "foo", Revision: 42, PrimaryBrickHost: "host1",
+		Status: datamodel.SessionStatus{FailedAttempts: []datamodel.ActionAttempt{{Attempt: 1}}}}}, sessions)
 
 	fakeErr := errors.New("fake")
 	keystore.EXPECT().GetAll("/session/").Return(nil, fakeErr)
@@ -134,11 +151,13 @@ func TestSessionRegistry_GetAllSessions(t *testing.T) {
 	assert.Equal(t, "unable to get all sessions due to: fake", err.Error())
 
 	keystore.EXPECT().GetAll("/session/").Return(nil, nil)
+	keystore.EXPECT().GetAll("/session_attempts/").Return(nil, nil)
 	sessions, err = registry.GetAllSessions()
 	assert.Nil(t, err)
 	assert.Nil(t, sessions)
 
 	keystore.EXPECT().GetAll("/session/").Return([]store.KeyValueVersion{{}}, nil)
+	keystore.EXPECT().GetAll("/session_attempts/").Return(nil, nil)
 	assert.PanicsWithValue(t,
 		"unable parse session from store due to: unexpected end of JSON input",
 		func() { registry.GetAllSessions() })
@@ -155,6 +174,27 @@ func TestSessionRegistry_UpdateSession(t *testing.T) {
 
 	assert.Nil(t, err)
 	assert.Equal(t, datamodel.Session{Name: "foo", PrimaryBrickHost: "host1", Revision: 44}, session)
+
+	// failed attempts are not written with the session
+	keystore.EXPECT().Update("/session/foo", exampleSessionString, int64(0)).Return(int64(45), nil)
+	attempts := []datamodel.ActionAttempt{{Attempt: 1}}
+	session.Revision = 0
+	session.Status.FailedAttempts = attempts
+	session, err = registry.UpdateSession(session)
+	assert.Nil(t, err)
+	assert.Equal(t, attempts, session.Status.FailedAttempts)
+}
+
+func TestSessionRegistry_UpdateFailedAttempts(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+	keystore := mock_store.NewMockKeystore(mockCtrl)
+	registry := NewSessionRegistry(keystore)
+	keystore.EXPECT().Update("/session_attempts/foo", []byte(`[{"ActionType":"","Operation":"","Hostname":"","Attempt":1,"MaxAttempts":0,"Error":"","AttemptedAt":"0001-01-01T00:00:00Z"}]`), int64(0)).Return(int64(2), nil)
+
+	err := registry.UpdateFailedAttempts("foo", []datamodel.ActionAttempt{{Attempt: 1}})
+
+	assert.Nil(t, err)
 }
 
 func TestSessionRegistry_DeleteSession(t *testing.T) {
@@ -168,4 +208,12 @@ func TestSessionRegistry_DeleteSession(t *testing.T) {
 	err := registry.DeleteSession(datamodel.Session{Name: "foo", Revision: 40})
 
 	assert.Equal(t, fakeErr, err)
+
+	keystore.EXPECT().Delete("/session/foo", int64(40)).Return(nil)
+	keystore.EXPECT().IsExist("/session_attempts/foo").Return(true, nil)
+	keystore.EXPECT().Delete("/session_attempts/foo", int64(0)).Return(nil)
+
+	err = registry.DeleteSession(datamodel.Session{Name: "foo", Revision: 40})
+
+	assert.Nil(t, err)
 }
@@ -51,11 +51,17 @@ type Keystore interface {
 
 	// Add a key, and remove it when calling process dies
 	// Error is returned if the key already exists
-	// can be cancelled via the context
+	// can be cancelled via the context, which also removes the key
 	KeepAliveKey(ctxt context.Context, key string) error
 
 	// Get a new mutex associated with the specified key
 	NewMutex(lockKey string) (Mutex, error)
+
+	// Blocks until elected as the only leader for the given key, or the context is done.
+	// While leader, a key below the given key holds the value, it is removed if the calling process dies.
+	// The returned channel is closed when leadership is lost,
+	// cancel the context to give up leadership.
+	Campaign(ctxt context.Context, electionKey string, value string) (<-chan struct{}, error)
 }
 
 type KeyValueUpdateChan <-chan KeyValueUpdate
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/config"
+	"github.com/RSE-Cambridge/data-acc/internal/pkg/logging"
 	"github.com/RSE-Cambridge/data-acc/internal/pkg/store"
 	"github.com/coreos/etcd/clientv3"
 	"github.com/coreos/etcd/clientv3/clientv3util"
@@ -78,6 +79,40 @@ func (client *etcKeystore) NewMutex(lockKey string) (store.Mutex, error) {
 	return concurrency.NewMutex(session, key), nil
 }
 
+func (client *etcKeystore) Campaign(ctxt context.Context, electionKey string, value string) (<-chan struct{}, error) {
+	// The lease is kept alive until the session is closed
+	session, err := concurrency.NewSession(client.Client, concurrency.WithTTL(10))
+	if err != nil {
+		return nil, err
+	}
+	election := concurrency.NewElection(session, electionKey)
+	if err := election.Campaign(ctxt, value); err != nil {
+		if closeErr := session.Close(); closeErr != nil {
+			logging.FromContext(ctxt).Errorf("failed to close election session for %s due to: %s",
+				electionKey, closeErr)
+		}
+		return nil, err
+	}
+
+	lost := make(chan struct{})
+	go func() {
+		select {
+		case <-ctxt.Done():
+			// Let someone else take over now, rather than waiting for the lease to expire
+			if err := election.Resign(context.Background()); err != nil {
+				logging.FromContext(ctxt).Errorf("failed to resign from election %s due to: %s", electionKey, err)
+			}
+		case <-session.Done():
+			logging.FromContext(ctxt).Warnf("lost lease for election %s", electionKey)
+		}
+		if err := session.Close(); err != nil {
+			logging.FromContext(ctxt).Errorf("failed to close election session for %s due to: %s", electionKey, err)
+		}
+		close(lost)
+	}()
+	return lost, nil
+}
+
 func handleError(err error) {
 	if err != nil {
 		switch err {
@@ -239,7 +274,14 @@ func (client *etcKeystore) KeepAliveKey(ctxt context.Context, key string) error
 				counter++
 			}
 		}
-		// TODO: should allow context to be cancelled
+		if ctxt.Err() != nil {
+			// Remove the key now, rather than waiting for the lease to expire
+			if _, err := client.Client.Revoke(context.Background(), leaseID); err != nil {
+				log.Printf("failed to revoke keep-alive key %s due to: %s\n", key, err)
+			}
+			log.Println("Stopped refreshing key:", key)
+			return
+		}
 		log.Panicf("Unable to refresh key: %s", key)
 	}()
 	return nil